@@ -0,0 +1,254 @@
+// Package canonical rewrites URLs into a canonical form, so that the many spellings of one page are
+// crawled and stored once. It lower-cases the scheme and host, drops default ports, fragments and
+// tracking parameters, resolves dot segments, sorts the query and strips trailing slashes. Rules add
+// parameters to drop, and other adjustments, for the hosts that need them.
+package canonical
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultStripParams are the query parameters dropped from every URL: analytics and ad-click
+// trackers. A trailing * matches any parameter starting with what precedes it.
+var DefaultStripParams = []string{"utm_*", "gclid", "dclid", "fbclid", "msclkid", "mc_cid", "mc_eid", "_ga", "_hsenc", "_hsmi"}
+
+// Rule adjusts canonicalization for one host and its subdomains.
+type Rule struct {
+	Host              string   `json:"host" yaml:"host"`
+	StripParams       []string `json:"strip_params,omitempty" yaml:"strip_params,omitempty"` // dropped on top of DefaultStripParams
+	KeepParams        []string `json:"keep_params,omitempty" yaml:"keep_params,omitempty"`   // when set, every other parameter is dropped
+	KeepTrailingSlash bool     `json:"keep_trailing_slash,omitempty" yaml:"keep_trailing_slash,omitempty"`
+	LowercasePath     bool     `json:"lowercase_path,omitempty" yaml:"lowercase_path,omitempty"` // for servers that ignore case
+}
+
+// matches reports whether the rule applies to host.
+func (r Rule) matches(host string) bool {
+	ruleHost := strings.ToLower(r.Host)
+	return host == ruleHost || strings.HasSuffix(host, "."+ruleHost)
+}
+
+// builtinRules are the rules of the sites crab is pointed at.
+var builtinRules = []Rule{
+	// Indeed tags job links with the search that led to them
+	{Host: "indeed.com", StripParams: []string{"xkcb", "bb", "from", "tk", "vjs"}},
+}
+
+// Canonicalizer canonicalizes URLs with DefaultStripParams and a set of rules. It is safe for
+// concurrent use.
+type Canonicalizer struct {
+	mu    sync.RWMutex
+	rules []Rule
+}
+
+// New creates a canonicalizer with the given rules. When several rules match a host, the most
+// specific one wins.
+func New(rules ...Rule) *Canonicalizer {
+	c := &Canonicalizer{}
+	c.SetRules(rules)
+	return c
+}
+
+// SetRules replaces the rules of the canonicalizer.
+func (c *Canonicalizer) SetRules(rules []Rule) {
+	sorted := append([]Rule(nil), rules...)
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i].Host) > len(sorted[j].Host) })
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rules = sorted
+}
+
+// rule returns the rule for host, the zero Rule when none matches.
+func (c *Canonicalizer) rule(host string) Rule {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, r := range c.rules {
+		if r.matches(host) {
+			return r
+		}
+	}
+	return Rule{}
+}
+
+// Canonicalize returns the canonical form of an absolute URL. Only http and https URLs are rewritten;
+// others are returned as they are.
+func (c *Canonicalizer) Canonicalize(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+	if !u.IsAbs() {
+		return "", fmt.Errorf("%q is not an absolute URL", raw)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return u.String(), nil
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("%q has no host", raw)
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	u.Host = host
+	if port != "" {
+		u.Host = host + ":" + port
+	}
+	u.User = nil
+	u.Fragment, u.RawFragment = "", ""
+	rule := c.rule(host)
+
+	// The path is worked on escaped, as an escaped "/" is not a segment separator
+	p := u.EscapedPath()
+	if rule.LowercasePath {
+		p = strings.ToLower(p)
+	}
+	p = normalizeEscapes(p)
+	if p == "" {
+		p = "/"
+	}
+	trailing := strings.HasSuffix(p, "/")
+	p = path.Clean(p)
+	if trailing && rule.KeepTrailingSlash && p != "/" {
+		p += "/"
+	}
+	unescaped, err := url.PathUnescape(p)
+	if err != nil {
+		return "", err
+	}
+	u.Path, u.RawPath = unescaped, p
+
+	query := u.Query()
+	for name := range query {
+		if dropParam(name, rule) {
+			delete(query, name)
+		}
+	}
+	// Encode sorts by name and keeps the order of repeated values
+	u.RawQuery = query.Encode()
+	u.ForceQuery = false
+	return u.String(), nil
+}
+
+// normalizeEscapes decodes the percent-escapes of unreserved characters and writes the others in upper
+// case, as RFC 3986 normalizes them.
+func normalizeEscapes(p string) string {
+	if !strings.Contains(p, "%") {
+		return p
+	}
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		if p[i] != '%' || i+2 >= len(p) {
+			b.WriteByte(p[i])
+			continue
+		}
+		decoded, err := url.PathUnescape(p[i : i+3])
+		if err != nil {
+			b.WriteByte(p[i])
+			continue
+		}
+		if c := decoded[0]; c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			b.WriteString(strings.ToUpper(p[i : i+3]))
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+// dropParam reports whether the query parameter name is removed under rule.
+func dropParam(name string, rule Rule) bool {
+	if len(rule.KeepParams) > 0 {
+		return !matchParam(name, rule.KeepParams)
+	}
+	return matchParam(name, DefaultStripParams) || matchParam(name, rule.StripParams)
+}
+
+// matchParam reports whether name is one of patterns, where a trailing * matches any suffix.
+func matchParam(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRules decodes a list of rules from YAML, or JSON for files ending in .json. Unknown keys are
+// rejected.
+func ParseRules(filename string, data []byte) ([]Rule, error) {
+	var rules []Rule
+	var err error
+	if strings.EqualFold(path.Ext(filename), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&rules)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&rules)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	for i, r := range rules {
+		if strings.TrimSpace(r.Host) == "" {
+			return nil, fmt.Errorf("%s: rule %d has no host", filename, i+1)
+		}
+		if len(r.KeepParams) > 0 && len(r.StripParams) > 0 {
+			return nil, fmt.Errorf("%s: rule %d (%s): keep_params and strip_params are exclusive", filename, i+1, r.Host)
+		}
+	}
+	return rules, nil
+}
+
+// Default is the canonicalizer used by URL, with the built-in rules and those in the YAML or JSON file
+// named by CANONICAL_RULES, which take precedence.
+var Default = newDefault(os.Getenv("CANONICAL_RULES"))
+
+// newDefault creates the default canonicalizer, logging and skipping a rules file that cannot be read.
+func newDefault(file string) *Canonicalizer {
+	rules := builtinRules
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err == nil {
+			var extra []Rule
+			if extra, err = ParseRules(file, data); err == nil {
+				rules = append(extra, rules...)
+			}
+		}
+		if err != nil {
+			log.Printf("Error loading URL canonicalization rules from %s: %v", file, err)
+		}
+	}
+	return New(rules...)
+}
+
+// URL returns the canonical form of raw with the Default canonicalizer, or raw itself when it is not
+// an absolute URL.
+func URL(raw string) string {
+	canonical, err := Default.Canonicalize(raw)
+	if err != nil {
+		return raw
+	}
+	return canonical
+}
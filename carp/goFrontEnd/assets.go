@@ -0,0 +1,134 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// embeddedAssets holds the templates, static files and message catalogs compiled into the binary, so carp runs from
+// any directory.
+//
+//go:embed templates/*.gohtml static locales
+var embeddedAssets embed.FS
+
+// assetConfig selects where templates and static files are read from. In dev mode (CARP_DEV=true)
+// they are read from CARP_ASSET_DIR, by default the goFrontEnd source directory, and templates are
+// re-parsed on every request so edits show up without rebuilding.
+type assetConfig struct {
+	Dev bool
+	Dir string
+}
+
+// loadAssetConfig reads the asset settings from the environment.
+func loadAssetConfig() assetConfig {
+	cfg := assetConfig{Dev: strings.EqualFold(os.Getenv("CARP_DEV"), "true") || os.Getenv("CARP_DEV") == "1"}
+	cfg.Dir = os.Getenv("CARP_ASSET_DIR")
+	if cfg.Dir == "" {
+		if _, file, _, ok := runtime.Caller(0); ok {
+			cfg.Dir = filepath.Dir(file)
+		}
+	}
+	return cfg
+}
+
+// FS returns the file system holding the templates and static directories.
+func (c assetConfig) FS() fs.FS {
+	if c.Dev {
+		return os.DirFS(c.Dir)
+	}
+	return embeddedAssets
+}
+
+// staticFS returns the static directory of the assets.
+func staticFS(assets fs.FS) fs.FS {
+	static, err := fs.Sub(assets, "static")
+	if err != nil {
+		log.Fatal("Static assets: ", err)
+	}
+	return static
+}
+
+// templateSet is the parsed page templates. With reload set, the templates are parsed again before
+// every execution, which is what dev mode uses. The parsed set is never executed itself: Render runs
+// a clone bound to the request, so that functions such as csrfField see that request.
+type templateSet struct {
+	fsys   fs.FS
+	reload bool
+
+	mu   sync.Mutex
+	tmpl *template.Template
+}
+
+// newTemplateSet parses templates/*.gohtml from fsys.
+func newTemplateSet(fsys fs.FS, reload bool) (*templateSet, error) {
+	t := &templateSet{fsys: fsys, reload: reload}
+	tmpl, err := t.parse()
+	if err != nil {
+		return nil, err
+	}
+	t.tmpl = tmpl
+	return t, nil
+}
+
+func (t *templateSet) parse() (*template.Template, error) {
+	return template.New("").Funcs(pageFuncs(nil, "")).ParseFS(t.fsys, "templates/*.gohtml")
+}
+
+// current returns the templates to execute, re-parsing them first in reload mode. A template that no
+// longer parses is logged and the last good set is kept.
+func (t *templateSet) current() *template.Template {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.reload {
+		tmpl, err := t.parse()
+		if err != nil {
+			log.Printf("Error reloading templates: %v", err)
+		} else {
+			t.tmpl = tmpl
+		}
+	}
+	return t.tmpl
+}
+
+// Render applies the named template to data for request r and writes the output to w.
+func (t *templateSet) Render(w io.Writer, r *http.Request, name string, data interface{}) error {
+	tmpl, err := t.current().Clone()
+	if err != nil {
+		return err
+	}
+	setLanguageHeaders(w, r)
+	return tmpl.Funcs(pageFuncs(r, "")).ExecuteTemplate(w, name, data)
+}
+
+// RenderPage renders the layout with page, the name of a template such as "home" or "history", as
+// its content.
+func (t *templateSet) RenderPage(w io.Writer, r *http.Request, page string, data interface{}) error {
+	tmpl, err := t.current().Clone()
+	if err != nil {
+		return err
+	}
+	content := tmpl.Lookup(page)
+	if content == nil {
+		return fmt.Errorf("no page template %q", page)
+	}
+	if _, err := tmpl.AddParseTree("content", content.Tree.Copy()); err != nil {
+		return err
+	}
+	setLanguageHeaders(w, r)
+	return tmpl.Funcs(pageFuncs(r, page)).ExecuteTemplate(w, "layout.gohtml", data)
+}
+
+// DefinedTemplates lists the defined templates, for logging.
+func (t *templateSet) DefinedTemplates() string {
+	return t.current().DefinedTemplates()
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"cmpscfa23team2/charts"
+	"cmpscfa23team2/cuda/ML"
+	"cmpscfa23team2/datasets"
+	"cmpscfa23team2/predictions"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// chartsPath is the prefix of the chart service.
+const chartsPath = "/api/v1/charts/"
+
+// maxChartBody is the largest chart description accepted by POST /api/v1/charts/{kind}.{format}.
+const maxChartBody = 4 << 20
+
+// newChartRenderer creates the chart renderer, caching CHART_CACHE_SIZE images (default
+// charts.DefaultCacheSize, negative to disable).
+func newChartRenderer() (*charts.Renderer, error) {
+	size := 0
+	if value := os.Getenv("CHART_CACHE_SIZE"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("CHART_CACHE_SIZE: invalid size %q", value)
+		}
+		size = n
+	}
+	return charts.NewRenderer(size), nil
+}
+
+// chartsHandler renders charts on demand as PNG or SVG:
+//
+//	GET  /api/v1/charts/predictions/{id}.{format}             chart of a stored prediction
+//	GET  /api/v1/charts/predictions.{format}?algorithm=&query= chart of the latest matching prediction
+//	GET  /api/v1/charts/{kind}.{format}?dataset=&x=&y=...       chart of a registered dataset
+//	POST /api/v1/charts/{kind}.{format}                         chart described by the JSON body
+//
+// Kinds are scatter, neighbors and bars. Every response accepts ?width= and ?height= in pixels and
+// carries the content hash of the chart as its ETag. Dataset charts need a user token or an API key,
+// like /api/datasets.
+func chartsHandler(renderer *charts.Renderer, history predictions.Store, reg *datasets.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, chartsPath)
+		ext := path.Ext(rest)
+		name := strings.TrimSuffix(rest, ext)
+		format, err := charts.ParseFormat(ext)
+		if err != nil || name == "" {
+			http.NotFound(w, r)
+			return
+		}
+		opts, err := chartOptions(r, format)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var chart charts.Chart
+		switch {
+		case r.Method == http.MethodGet && (name == "predictions" || strings.HasPrefix(name, "predictions/")):
+			chart, err = predictionChart(history, r, strings.TrimPrefix(strings.TrimPrefix(name, "predictions"), "/"))
+		case r.Method == http.MethodGet:
+			// Datasets are only listed and read by authenticated clients, so the same goes for their charts.
+			if !authorize(w, r) {
+				return
+			}
+			chart, err = datasetChart(reg, r, charts.Kind(name))
+		case r.Method == http.MethodPost:
+			var body []byte
+			body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxChartBody))
+			if err == nil {
+				chart, err = charts.Decode(charts.Kind(name), body)
+			}
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err != nil {
+			chartError(w, r, err)
+			return
+		}
+
+		img, err := renderer.Render(chart, opts)
+		if err != nil {
+			chartError(w, r, err)
+			return
+		}
+		writeChart(w, r, img)
+	}
+}
+
+// chartOptions reads the image format and the ?width= and ?height= sizes.
+func chartOptions(r *http.Request, format charts.Format) (charts.Options, error) {
+	opts := charts.Options{Format: format}
+	for _, param := range []struct {
+		name  string
+		field *int
+	}{{"width", &opts.Width}, {"height", &opts.Height}} {
+		value := r.URL.Query().Get(param.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return opts, fmt.Errorf("%s must be a number of pixels", param.name)
+		}
+		*param.field = n
+	}
+	return opts.Normalize()
+}
+
+// predictionChart builds the chart of the prediction with the given ID, or of the latest prediction
+// matching ?algorithm= and ?query= when id is empty.
+func predictionChart(history predictions.Store, r *http.Request, id string) (charts.Chart, error) {
+	var record predictions.Record
+	if id != "" {
+		var err error
+		if record, err = history.Get(r.Context(), id); err != nil {
+			return nil, err
+		}
+	} else {
+		q := r.URL.Query()
+		if q.Get("query") == "" {
+			return nil, fmt.Errorf("%w: query is required", charts.ErrInvalid)
+		}
+		records, err := history.History(r.Context(), predictions.Filter{Algorithm: q.Get("algorithm"), QueryIdentifier: q.Get("query"), Limit: 1})
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, predictions.ErrNotFound
+		}
+		record = records[0]
+	}
+	if record.Algorithm == "NaiveBayes" {
+		return skillDemandChart(record)
+	}
+	return charts.ForPrediction(record)
+}
+
+// skillDemandChart charts how many of the jobs in the result file of a Naive Bayes prediction ask for
+// each skill of the domain.
+func skillDemandChart(record predictions.Record) (charts.Chart, error) {
+	container, err := ML.LoadDataFromJSON(record.PredictionInfo)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, charts.ErrNoData
+	}
+	if err != nil {
+		return nil, err
+	}
+	domain := container.Domain
+	if domain == "" {
+		domain = record.Domain
+	}
+	bars := charts.Bars{Title: "Skill demand: " + record.QueryIdentifier, XLabel: "Skill", YLabel: "Jobs"}
+	for _, skill := range ML.NewNaiveBayesClassifier().SkillDemand(domain, container.Data) {
+		bars.Bars = append(bars.Bars, charts.Bar{Label: skill.Skill, Value: float64(skill.Demand)})
+	}
+	if len(bars.Bars) == 0 {
+		return nil, charts.ErrNoData
+	}
+	return bars, nil
+}
+
+// datasetChart builds a chart from a registered dataset named by ?dataset= (an ID, name or
+// name@version), using the columns ?x=, ?y= and ?label=. Neighbors charts take the target as ?tx= and
+// ?ty= and the number of neighbors as ?k=; bar charts keep the ?top= largest bars; scatter charts draw
+// the regression line unless ?regression=false.
+func datasetChart(reg *datasets.Registry, r *http.Request, kind charts.Kind) (charts.Chart, error) {
+	q := r.URL.Query()
+	if q.Get("dataset") == "" {
+		return nil, fmt.Errorf("%w: dataset is required", charts.ErrInvalid)
+	}
+	cols := charts.Columns{X: q.Get("x"), Y: q.Get("y"), Label: q.Get("label"), Regression: q.Get("regression") != "false"}
+	var errs []error
+	for _, param := range []struct {
+		name  string
+		field *int
+	}{{"k", &cols.K}, {"top", &cols.Top}} {
+		if value := q.Get(param.name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				errs = append(errs, fmt.Errorf("%s must be a positive number", param.name))
+			}
+			*param.field = n
+		}
+	}
+	if q.Has("tx") || q.Has("ty") {
+		tx, errX := strconv.ParseFloat(q.Get("tx"), 64)
+		ty, errY := strconv.ParseFloat(q.Get("ty"), 64)
+		if errX != nil || errY != nil {
+			errs = append(errs, errors.New("tx and ty must be numbers"))
+		}
+		cols.Target = &charts.Point{X: tx, Y: ty}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("%w: %v", charts.ErrInvalid, err)
+	}
+
+	d, err := reg.Resolve(r.Context(), q.Get("dataset"))
+	if err != nil {
+		return nil, err
+	}
+	_, records, err := reg.Records(r.Context(), d.ID)
+	if err != nil {
+		return nil, err
+	}
+	return charts.FromDataset(kind, d, records, cols)
+}
+
+// chartError reports a chart that could not be built: 404 for unknown predictions and datasets, 400
+// for invalid requests, 422 when there is nothing to chart and 500 otherwise.
+func chartError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, predictions.ErrNotFound), errors.Is(err, datasets.ErrNotFound):
+		http.NotFound(w, r)
+	case errors.As(err, &tooLarge):
+		http.Error(w, fmt.Sprintf("Chart description larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, charts.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, charts.ErrNoData):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		log.Printf("Error rendering chart %s: %v", r.URL.Path, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// writeChart sends a rendered chart. Its content hash is the ETag, so clients revalidate cheaply and
+// get 304 Not Modified while the underlying data is unchanged.
+func writeChart(w http.ResponseWriter, r *http.Request, img charts.Image) {
+	etag := `"` + img.Key + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if img.Cached {
+		w.Header().Set("X-Chart-Cache", "hit")
+	} else {
+		w.Header().Set("X-Chart-Cache", "miss")
+	}
+	if match := r.Header.Get("If-None-Match"); match != "" && (match == etag || match == "*") {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", img.Format.ContentType())
+	w.Header().Set("Content-Length", strconv.Itoa(len(img.Data)))
+	w.Write(img.Data)
+}
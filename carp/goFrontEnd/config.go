@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// serverConfig holds the settings of the carp HTTP server. Every field can be overridden with the
+// environment variable named in its comment.
+type serverConfig struct {
+	Addr              string        // CARP_ADDR, e.g. ":8080" or "127.0.0.1:8443"
+	TLSCertFile       string        // CARP_TLS_CERT; serve HTTPS when set together with TLSKeyFile
+	TLSKeyFile        string        // CARP_TLS_KEY
+	ReadTimeout       time.Duration // CARP_READ_TIMEOUT, whole request including the body
+	ReadHeaderTimeout time.Duration // CARP_READ_HEADER_TIMEOUT
+	WriteTimeout      time.Duration // CARP_WRITE_TIMEOUT; event streams are exempt
+	IdleTimeout       time.Duration // CARP_IDLE_TIMEOUT, keep-alive connections
+	MaxHeaderBytes    int           // CARP_MAX_HEADER_BYTES
+	ShutdownTimeout   time.Duration // CARP_SHUTDOWN_TIMEOUT, how long in-flight requests may take to drain
+	Cookies           cookieConfig
+	CSP               string // CARP_CSP, the Content-Security-Policy header; "off" sends none
+	Language          string // CARP_LANGUAGE, the language of visitors whose preferences match no catalog
+	Theme             themeConfig
+}
+
+// themeConfig holds the look of a deployment, so that other departments can brand their own.
+type themeConfig struct {
+	Mode       string // CARP_THEME: light or dark
+	Brand      string // CARP_BRAND_NAME, shown in the navigation bar, titles and footer
+	Logo       string // CARP_BRAND_LOGO, the URL of the navigation bar logo
+	Stylesheet string // CARP_BRAND_CSS, the URL of a stylesheet loaded after the default one
+}
+
+// cookieConfig holds the attributes of the cookies carp sets.
+type cookieConfig struct {
+	Secure   bool          // CARP_COOKIE_SECURE; defaults to true when serving HTTPS
+	SameSite http.SameSite // CARP_COOKIE_SAMESITE: lax, strict or none
+	Domain   string        // CARP_COOKIE_DOMAIN; empty means the host that set the cookie
+}
+
+// cookie returns a cookie with the configured attributes, expiring after maxAge. HTTP-only cookies
+// cannot be read by scripts.
+func (c cookieConfig) cookie(name, value string, maxAge time.Duration, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   c.Domain,
+		MaxAge:   int(maxAge.Seconds()),
+		Secure:   c.Secure,
+		HttpOnly: httpOnly,
+		SameSite: c.SameSite,
+	}
+}
+
+// defaultServerConfig returns the settings used when no environment variable overrides them.
+func defaultServerConfig() serverConfig {
+	return serverConfig{
+		Addr:              ":8080",
+		ReadTimeout:       15 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		MaxHeaderBytes:    1 << 20,
+		ShutdownTimeout:   30 * time.Second,
+		Cookies:           cookieConfig{SameSite: http.SameSiteLaxMode},
+		CSP:               defaultCSP,
+		Language:          "en",
+		Theme:             themeConfig{Mode: "light", Brand: "PredictAI", Logo: "/static/Assets/logo.jpg"},
+	}
+}
+
+// loadServerConfig applies the CARP_* environment variables to the defaults.
+func loadServerConfig() (serverConfig, error) {
+	cfg := defaultServerConfig()
+	if addr := os.Getenv("CARP_ADDR"); addr != "" {
+		cfg.Addr = addr
+	}
+	cfg.TLSCertFile = os.Getenv("CARP_TLS_CERT")
+	cfg.TLSKeyFile = os.Getenv("CARP_TLS_KEY")
+
+	var errs []error
+	durations := []struct {
+		env   string
+		field *time.Duration
+	}{
+		{"CARP_READ_TIMEOUT", &cfg.ReadTimeout},
+		{"CARP_READ_HEADER_TIMEOUT", &cfg.ReadHeaderTimeout},
+		{"CARP_WRITE_TIMEOUT", &cfg.WriteTimeout},
+		{"CARP_IDLE_TIMEOUT", &cfg.IdleTimeout},
+		{"CARP_SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout},
+	}
+	for _, d := range durations {
+		value := os.Getenv(d.env)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			errs = append(errs, fmt.Errorf("%s: invalid duration %q", d.env, value))
+			continue
+		}
+		*d.field = parsed
+	}
+	if value := os.Getenv("CARP_MAX_HEADER_BYTES"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			errs = append(errs, fmt.Errorf("CARP_MAX_HEADER_BYTES: invalid size %q", value))
+		} else {
+			cfg.MaxHeaderBytes = n
+		}
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		errs = append(errs, errors.New("CARP_TLS_CERT and CARP_TLS_KEY must be set together"))
+	}
+
+	cfg.Cookies.Secure = cfg.TLS()
+	if value := os.Getenv("CARP_COOKIE_SECURE"); value != "" {
+		secure, err := strconv.ParseBool(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("CARP_COOKIE_SECURE: invalid boolean %q", value))
+		} else {
+			cfg.Cookies.Secure = secure
+		}
+	}
+	switch value := strings.ToLower(os.Getenv("CARP_COOKIE_SAMESITE")); value {
+	case "", "lax":
+		cfg.Cookies.SameSite = http.SameSiteLaxMode
+	case "strict":
+		cfg.Cookies.SameSite = http.SameSiteStrictMode
+	case "none":
+		cfg.Cookies.SameSite = http.SameSiteNoneMode
+		if !cfg.Cookies.Secure {
+			errs = append(errs, errors.New("CARP_COOKIE_SAMESITE=none requires secure cookies"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("CARP_COOKIE_SAMESITE: must be lax, strict or none, not %q", value))
+	}
+	cfg.Cookies.Domain = os.Getenv("CARP_COOKIE_DOMAIN")
+	if csp := os.Getenv("CARP_CSP"); csp != "" {
+		cfg.CSP = csp
+	}
+
+	if lang := os.Getenv("CARP_LANGUAGE"); lang != "" {
+		cfg.Language = lang
+	}
+	switch value := strings.ToLower(os.Getenv("CARP_THEME")); value {
+	case "":
+	case "light", "dark":
+		cfg.Theme.Mode = value
+	default:
+		errs = append(errs, fmt.Errorf("CARP_THEME: must be light or dark, not %q", value))
+	}
+	for _, s := range []struct {
+		env   string
+		field *string
+	}{
+		{"CARP_BRAND_NAME", &cfg.Theme.Brand},
+		{"CARP_BRAND_LOGO", &cfg.Theme.Logo},
+		{"CARP_BRAND_CSS", &cfg.Theme.Stylesheet},
+	} {
+		if value := os.Getenv(s.env); value != "" {
+			*s.field = value
+		}
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// TLS reports whether the server should serve HTTPS.
+func (c serverConfig) TLS() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"cmpscfa23team2/crab"
+	"cmpscfa23team2/dal"
+	"cmpscfa23team2/events"
+	"context"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// frontierStore adapts the dal crawl_frontier functions to the crab.FrontierStore interface.
+type frontierStore struct{}
+
+// Load reads the frontier of a crawl from the crawl_frontier table.
+func (frontierStore) Load(crawl string) ([]crab.FrontierEntry, error) {
+	urls, err := dal.GetFrontierURLs(crawl)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]crab.FrontierEntry, 0, len(urls))
+	for _, u := range urls {
+		entries = append(entries, crab.FrontierEntry{URL: u.URL, Seed: u.Seed, Depth: u.Depth, Priority: u.Priority, Crawled: u.Crawled})
+	}
+	return entries, nil
+}
+
+// Add inserts a frontier row for the entry, or queues its row again.
+func (frontierStore) Add(crawl string, e crab.FrontierEntry) error {
+	return dal.InsertFrontierURL(dal.FrontierURL{Crawl: crawl, URL: e.URL, Seed: e.Seed, Depth: e.Depth, Priority: e.Priority})
+}
+
+// Done sets the crawl time of the entry's frontier row.
+func (frontierStore) Done(crawl string, e crab.FrontierEntry) error {
+	return dal.MarkFrontierURLCrawled(crawl, e.URL, e.Crawled)
+}
+
+// crawlName names the crawl of a job or pipeline run in the frontier store. Retries of the job and
+// resumed runs use the same name, so they continue the crawl instead of starting over.
+func crawlName(kind, id string) string {
+	return kind + "-" + strings.ToLower(id)
+}
+
+// runCrawl crawls from the seeds with a frontier kept in the database, and from the pages listed by the
+// sitemaps of their sites when the crawl asks for it. Sitemaps that cannot be read are logged.
+func runCrawl(ctx context.Context, config crab.CrawlConfig, seeds []string, pub events.Publisher) ([]crab.URLData, error) {
+	frontier, err := crab.NewFrontier(config, frontierStore{})
+	if err != nil {
+		return nil, err
+	}
+	sites := make(map[string]bool)
+	for _, seed := range seeds {
+		if _, err := frontier.AddSeed(seed); err != nil {
+			return nil, err
+		}
+		u, _ := url.Parse(seed)
+		if !config.Sitemaps || sites[u.Host] {
+			continue
+		}
+		sites[u.Host] = true
+		queued, err := frontier.AddSitemaps(seed)
+		if err != nil {
+			log.Printf("Error reading the sitemaps of %s: %v", seed, err)
+		}
+		log.Printf("Queued %d pages from the sitemaps of %s", queued, u.Host)
+	}
+	return frontier.Run(ctx, pub)
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"cmpscfa23team2/dal"
+	"cmpscfa23team2/datasets"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// datasetStore adapts the datasets table of the dal to datasets.Store.
+type datasetStore struct{}
+
+func (datasetStore) Create(ctx context.Context, d datasets.Dataset) error {
+	schema, err := json.Marshal(d.Schema)
+	if err != nil {
+		return err
+	}
+	return dal.CreateDataset(ctx, dal.Dataset{
+		DatasetID:   d.ID,
+		Name:        d.Name,
+		Version:     d.Version,
+		Domain:      d.Domain,
+		Source:      d.Source,
+		Format:      string(d.Format),
+		FilePath:    d.Path,
+		SizeBytes:   d.Size,
+		RowCount:    d.Rows,
+		Checksum:    d.Checksum,
+		Schema:      string(schema),
+		CreatedTime: d.CreatedTime,
+	})
+}
+
+func (datasetStore) Get(ctx context.Context, id string) (datasets.Dataset, error) {
+	row, err := dal.GetDataset(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return datasets.Dataset{}, datasets.ErrNotFound
+	}
+	if err != nil {
+		return datasets.Dataset{}, err
+	}
+	return datasetFromRow(row), nil
+}
+
+func (datasetStore) List(ctx context.Context, name string) ([]datasets.Dataset, error) {
+	rows, err := dal.GetDatasets(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]datasets.Dataset, 0, len(rows))
+	for _, row := range rows {
+		list = append(list, datasetFromRow(row))
+	}
+	return list, nil
+}
+
+func (datasetStore) Delete(ctx context.Context, id string) error {
+	err := dal.DeleteDataset(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return datasets.ErrNotFound
+	}
+	return err
+}
+
+// datasetFromRow converts a datasets row into a datasets.Dataset.
+func datasetFromRow(row *dal.Dataset) datasets.Dataset {
+	d := datasets.Dataset{
+		ID:          row.DatasetID,
+		Name:        row.Name,
+		Version:     row.Version,
+		Domain:      row.Domain,
+		Source:      row.Source,
+		Format:      datasets.Format(row.Format),
+		Path:        row.FilePath,
+		Size:        row.SizeBytes,
+		Rows:        row.RowCount,
+		Checksum:    row.Checksum,
+		CreatedTime: row.CreatedTime,
+	}
+	if row.Schema != "" {
+		if err := json.Unmarshal([]byte(row.Schema), &d.Schema); err != nil {
+			log.Printf("Invalid schema of dataset %s: %v", row.DatasetID, err)
+		}
+	}
+	return d
+}
+
+// datasetConfig holds the dataset registry settings.
+type datasetConfig struct {
+	Dir      string // DATASET_DIR, where uploaded files are kept
+	MaxBytes int64  // DATASET_MAX_BYTES, the largest accepted upload
+}
+
+// loadDatasetConfig reads the registry settings from the environment.
+func loadDatasetConfig() (datasetConfig, error) {
+	cfg := datasetConfig{Dir: filepath.Join("uploads", "datasets"), MaxBytes: 64 << 20}
+	if dir := os.Getenv("DATASET_DIR"); dir != "" {
+		cfg.Dir = dir
+	}
+	if value := os.Getenv("DATASET_MAX_BYTES"); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("DATASET_MAX_BYTES: invalid size %q", value)
+		}
+		cfg.MaxBytes = n
+	}
+	return cfg, nil
+}
+
+// datasetRegistry is the dataset registry of the running server, used by jobs and pipeline stages
+// that train on a dataset.
+var datasetRegistry *datasets.Registry
+
+// Preview sizes of GET /api/datasets/{id}/preview.
+const (
+	defaultPreviewRows = 20
+	maxPreviewRows     = 500
+)
+
+// datasetsHandler lists datasets (GET, optional ?name= for the versions of one dataset) and uploads a
+// new dataset or version (POST).
+//
+// Uploads are either a multipart form with a "file" part and optional name, domain and source fields,
+// or the raw file as the request body with the fields as query parameters and the format taken from
+// ?format= or the Content-Type. The response is 201 for a new version and 200 when the file is
+// identical to the latest version.
+func datasetsHandler(reg *datasets.Registry, cfg datasetConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			list, err := reg.List(r.Context(), r.URL.Query().Get("name"))
+			if err != nil {
+				log.Printf("Error listing datasets: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if list == nil {
+				list = []datasets.Dataset{}
+			}
+			writeJSON(w, http.StatusOK, list)
+
+		case http.MethodPost:
+			if r.ContentLength > cfg.MaxBytes {
+				http.Error(w, fmt.Sprintf("Dataset larger than %d bytes", cfg.MaxBytes), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxBytes)
+			upload, body, err := readDatasetUpload(r)
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					uploadError(w, err)
+				} else {
+					http.Error(w, "Invalid dataset upload: "+err.Error(), http.StatusBadRequest)
+				}
+				return
+			}
+			defer body.Close()
+
+			d, created, err := reg.Add(r.Context(), upload, body)
+			if err != nil {
+				uploadError(w, err)
+				return
+			}
+			status := http.StatusOK
+			if created {
+				status = http.StatusCreated
+				log.Printf("Dataset %s version %d uploaded (%d rows, request %s)", d.Name, d.Version, d.Rows, dal.RequestID(r.Context()))
+			}
+			w.Header().Set("Location", "/api/datasets/"+d.ID)
+			writeJSON(w, status, d)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// readDatasetUpload returns the description and content of an uploaded dataset.
+func readDatasetUpload(r *http.Request) (datasets.Upload, io.ReadCloser, error) {
+	q := r.URL.Query()
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(10 << 20); err != nil {
+			return datasets.Upload{}, nil, err
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return datasets.Upload{}, nil, errors.New("multipart upload needs a file part")
+		}
+		upload := datasets.Upload{
+			Name:     r.FormValue("name"),
+			Domain:   r.FormValue("domain"),
+			Source:   r.FormValue("source"),
+			Filename: header.Filename,
+		}
+		if format := r.FormValue("format"); format != "" {
+			if upload.Format, err = datasets.ParseFormat(format); err != nil {
+				file.Close()
+				return datasets.Upload{}, nil, err
+			}
+		} else if upload.Format, err = datasets.DetectFormat(header.Filename, header.Header.Get("Content-Type")); err != nil {
+			file.Close()
+			return datasets.Upload{}, nil, err
+		}
+		if upload.Source == "" {
+			upload.Source = "upload:" + filepath.Base(header.Filename)
+		}
+		return upload, file, nil
+	}
+
+	upload := datasets.Upload{
+		Name:     q.Get("name"),
+		Domain:   q.Get("domain"),
+		Source:   q.Get("source"),
+		Filename: q.Get("filename"),
+	}
+	var err error
+	if format := q.Get("format"); format != "" {
+		upload.Format, err = datasets.ParseFormat(format)
+	} else {
+		upload.Format, err = datasets.DetectFormat(upload.Filename, r.Header.Get("Content-Type"))
+	}
+	if err != nil {
+		return datasets.Upload{}, nil, err
+	}
+	if upload.Name == "" && upload.Filename == "" {
+		return datasets.Upload{}, nil, errors.New("name is required")
+	}
+	if upload.Source == "" {
+		upload.Source = "upload"
+	}
+	return upload, r.Body, nil
+}
+
+// uploadError reports a failed upload: 413 for oversized bodies, 400 for files the registry rejects
+// and 500 when storing the dataset failed.
+func uploadError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		http.Error(w, fmt.Sprintf("Dataset larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, datasets.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Error storing dataset: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// datasetHandler serves one dataset version: its metadata (GET /api/datasets/{id}), its first rows
+// (GET /api/datasets/{id}/preview?rows=), its file (GET /api/datasets/{id}/download), and removes it
+// (DELETE /api/datasets/{id}).
+func datasetHandler(reg *datasets.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/datasets/"), "/")
+		id, action, _ := strings.Cut(rest, "/")
+		if id == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet && action == "":
+			d, err := reg.Get(r.Context(), id)
+			if !datasetFound(w, r, id, err) {
+				return
+			}
+			writeJSON(w, http.StatusOK, d)
+
+		case r.Method == http.MethodGet && action == "preview":
+			rows := defaultPreviewRows
+			if value := r.URL.Query().Get("rows"); value != "" {
+				n, err := strconv.Atoi(value)
+				if err != nil || n <= 0 {
+					http.Error(w, "rows must be a positive number", http.StatusBadRequest)
+					return
+				}
+				rows = min(n, maxPreviewRows)
+			}
+			d, records, err := reg.Preview(r.Context(), id, rows)
+			if !datasetFound(w, r, id, err) {
+				return
+			}
+			if records == nil {
+				records = []datasets.Record{}
+			}
+			writeJSON(w, http.StatusOK, struct {
+				Dataset datasets.Dataset  `json:"dataset"`
+				Rows    []datasets.Record `json:"rows"`
+			}{d, records})
+
+		case r.Method == http.MethodGet && action == "download":
+			d, f, err := reg.Open(r.Context(), id)
+			if !datasetFound(w, r, id, err) {
+				return
+			}
+			defer f.Close()
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-v%d.%s"`, safeFilename(d.Name), d.Version, d.Format))
+			w.Header().Set("X-Checksum-SHA256", d.Checksum)
+			http.ServeContent(w, r, "", d.CreatedTime, f)
+
+		case r.Method == http.MethodDelete && action == "":
+			if !datasetFound(w, r, id, reg.Delete(r.Context(), id)) {
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// datasetFound writes the response for a failed dataset lookup and reports whether err was nil.
+func datasetFound(w http.ResponseWriter, r *http.Request, id string, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, datasets.ErrNotFound):
+		http.NotFound(w, r)
+	default:
+		log.Printf("Error reading dataset %s: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+	return false
+}
+
+// safeFilename replaces the characters of name that do not belong in a Content-Disposition file name.
+func safeFilename(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '"' || r == '\\' || r == '/' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// resolveDataset finds the dataset a job or pipeline refers to by ID, name or name@version.
+func resolveDataset(ctx context.Context, ref string) (datasets.Dataset, error) {
+	if datasetRegistry == nil {
+		return datasets.Dataset{}, errors.New("dataset registry is not available")
+	}
+	d, err := datasetRegistry.Resolve(ctx, ref)
+	if errors.Is(err, datasets.ErrNotFound) {
+		return d, fmt.Errorf("dataset %q not found", ref)
+	}
+	return d, err
+}
+
+// datasetItems loads a dataset as scraped items for the model stage. Records need a title or a price;
+// salary is accepted in place of price, as in the job files CRAB writes.
+func datasetItems(ctx context.Context, ref string) ([]dal.ScrapedItem, error) {
+	d, err := resolveDataset(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	_, records, err := datasetRegistry.Records(ctx, d.ID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]dal.ScrapedItem, 0, len(records))
+	for _, record := range records {
+		item := dal.ScrapedItem{
+			Domain:      d.Domain,
+			Title:       recordString(record, "title"),
+			URL:         recordString(record, "url"),
+			Description: recordString(record, "description"),
+			Price:       recordString(record, "price"),
+			Source:      d.Source,
+		}
+		if item.Price == "" {
+			item.Price = recordString(record, "salary")
+		}
+		if item.Title == "" && item.Price == "" {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// recordString returns a field of a dataset record as text.
+func recordString(record datasets.Record, field string) string {
+	switch v := record[field].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
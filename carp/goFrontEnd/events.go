@@ -0,0 +1,103 @@
+package main
+
+import (
+	"cmpscfa23team2/events"
+	"cmpscfa23team2/jobs"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// sseHeartbeat is how often a comment line is sent on an idle stream so proxies keep it open.
+const sseHeartbeat = 15 * time.Second
+
+// publishJobStates reports every job state change on the event bus.
+func publishJobStates(manager *jobs.Manager, pub events.Publisher) {
+	manager.Observe(func(job jobs.Job) {
+		pub.Publish(events.Event{
+			Type:    events.JobState,
+			JobID:   job.ID,
+			Message: job.Message,
+			Data: map[string]interface{}{
+				"type":     job.Type,
+				"status":   job.Status,
+				"progress": job.Progress,
+				"attempts": job.Attempts,
+				"error":    job.Error,
+			},
+		})
+	})
+}
+
+// eventsHandler streams bus events as server-sent events (GET /api/events, optional ?job=<id>).
+// A reconnecting EventSource sends Last-Event-ID and receives the remembered events it missed.
+func eventsHandler(bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		var filter events.Filter
+		if jobID := r.URL.Query().Get("job"); jobID != "" {
+			filter = events.ForJobID(jobID)
+		}
+		lastID := r.Header.Get("Last-Event-ID")
+		if lastID == "" {
+			lastID = r.URL.Query().Get("since")
+		}
+		since, _ := strconv.ParseUint(lastID, 10, 64)
+
+		// The stream outlives the server's WriteTimeout, so lift the deadline for this response.
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			log.Printf("Error clearing write deadline for event stream: %v", err)
+		}
+
+		sub := bus.Subscribe(filter, since)
+		defer sub.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "retry: 3000\n\n")
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case e, open := <-sub.C:
+				if !open {
+					return
+				}
+				data, err := json.Marshal(e)
+				if err != nil {
+					log.Printf("Error encoding event %d: %v", e.ID, err)
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"cmpscfa23team2/dal"
+	"cmpscfa23team2/jobs"
+	"cmpscfa23team2/metrics"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// requiredTables are the tables the current code relies on. /readyz reports the database as not
+// migrated until scripts.sql has created all of them.
+var requiredTables = []string{
+	"users", "log", "web_service", "urls", "crawl_frontier", "scrapedData", "tasks", "schedules", "pipeline_runs", "datasets",
+	"knn_predictions", "linear_regression_predictions", "naive_bayes_predictions",
+}
+
+// requiredColumns are the columns the current code added to tables that older schemas already have.
+// /readyz reports the database as not migrated until scripts.sql has been applied again.
+var requiredColumns = map[string][]string{
+	"log":                           {"request_id"},
+	"web_service":                   {"daily_quota"},
+	"urls":                          {"url_hash"},
+	"scrapedData":                   {"run_id", "stage"},
+	"tasks":                         {"payload", "progress", "message", "error_message", "attempts", "max_attempts", "run_at", "updated_time"},
+	"knn_predictions":               {"domain"},
+	"linear_regression_predictions": {"domain"},
+	"naive_bayes_predictions":       {"domain"},
+}
+
+// readinessTimeout bounds the database checks of /readyz.
+const readinessTimeout = 3 * time.Second
+
+var (
+	httpRequests = metrics.Default.NewCounter("carp_http_requests_total",
+		"HTTP requests handled, by route, method and status code.", "route", "method", "status")
+	httpDuration = metrics.Default.NewHistogram("carp_http_request_duration_seconds",
+		"Time taken to handle HTTP requests, by route.", nil, "route")
+	modelInference = metrics.Default.NewHistogram("cuda_model_inference_seconds",
+		"Time taken to run a prediction model, by algorithm.", nil, "algorithm")
+)
+
+// observeInference records how long a model run that began at start took.
+func observeInference(algorithm string, start time.Time) {
+	modelInference.Observe(time.Since(start).Seconds(), algorithm)
+}
+
+// registerEngineMetrics adds the gauges sampled on every scrape: job queue depth and DB pool stats.
+func registerEngineMetrics(manager *jobs.Manager) {
+	metrics.Default.NewGaugeFunc("jobs_queue_depth", "Jobs waiting to run, including those waiting for a retry.",
+		func() float64 { return float64(manager.QueueDepth()) })
+	metrics.Default.NewGaugeFunc("db_open_connections", "Open database connections, in use and idle.",
+		func() float64 { return float64(dal.PoolStats().OpenConnections) })
+	metrics.Default.NewGaugeFunc("db_in_use_connections", "Database connections currently in use.",
+		func() float64 { return float64(dal.PoolStats().InUse) })
+	metrics.Default.NewGaugeFunc("db_idle_connections", "Idle database connections.",
+		func() float64 { return float64(dal.PoolStats().Idle) })
+	metrics.Default.NewGaugeFunc("db_wait_count", "Total number of times a query waited for a free connection.",
+		func() float64 { return float64(dal.PoolStats().WaitCount) })
+	metrics.Default.NewGaugeFunc("db_wait_duration_seconds", "Total time spent waiting for a free connection.",
+		func() float64 { return dal.PoolStats().WaitDuration.Seconds() })
+}
+
+// statusRecorder remembers the status code and body size written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush event streams.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Flush passes flushes through for handlers that check for http.Flusher.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// instrument counts the requests served by next and measures their latency per route. The route is
+// the pattern of mux that matches, so /api/jobs/{id} requests share one series instead of one per ID.
+func instrument(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		httpRequests.Inc(route, r.Method, strconv.Itoa(rec.status))
+		httpDuration.Observe(time.Since(start).Seconds(), route)
+	})
+}
+
+// healthzHandler reports that the process is up (GET /healthz).
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readiness is the body of /readyz.
+type readiness struct {
+	Status         string   `json:"status"`
+	Database       string   `json:"database"`
+	MissingTables  []string `json:"missing_tables,omitempty"`
+	MissingColumns []string `json:"missing_columns,omitempty"`
+}
+
+// readyzHandler reports whether carp can serve traffic: the database answers and its schema has
+// every table and column the code needs (GET /readyz). It responds 503 otherwise.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	status := readiness{Status: "ready", Database: "ok"}
+	if err := dal.Ping(ctx); err != nil {
+		status.Status, status.Database = "unavailable", err.Error()
+		writeJSON(w, http.StatusServiceUnavailable, status)
+		return
+	}
+	missing, err := dal.MissingTables(ctx, requiredTables...)
+	if err != nil {
+		status.Status, status.Database = "unavailable", err.Error()
+		writeJSON(w, http.StatusServiceUnavailable, status)
+		return
+	}
+	missingColumns, err := dal.MissingColumns(ctx, requiredColumns)
+	if err != nil {
+		status.Status, status.Database = "unavailable", err.Error()
+		writeJSON(w, http.StatusServiceUnavailable, status)
+		return
+	}
+	if len(missing) > 0 || len(missingColumns) > 0 {
+		status.Status, status.Database = "unavailable", "migrations pending"
+		status.MissingTables, status.MissingColumns = missing, missingColumns
+		writeJSON(w, http.StatusServiceUnavailable, status)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"cmpscfa23team2/dal"
+	"cmpscfa23team2/predictions"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// predictionStore adapts the prediction tables of the dal to predictions.Store.
+type predictionStore struct{}
+
+func (predictionStore) History(ctx context.Context, f predictions.Filter) ([]predictions.Record, error) {
+	f = f.Normalize()
+	rows, err := dal.GetPredictionHistory(ctx, dal.PredictionFilter{
+		Domain:          f.Domain,
+		Algorithm:       f.Algorithm,
+		QueryIdentifier: f.QueryIdentifier,
+		Since:           f.Since,
+		Until:           f.Until,
+		Limit:           f.Limit,
+		Offset:          f.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	records := make([]predictions.Record, 0, len(rows))
+	for _, row := range rows {
+		records = append(records, predictionRecord(row))
+	}
+	return records, nil
+}
+
+func (predictionStore) Get(ctx context.Context, id string) (predictions.Record, error) {
+	row, err := dal.GetPredictionByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return predictions.Record{}, predictions.ErrNotFound
+	}
+	if err != nil {
+		return predictions.Record{}, err
+	}
+	return predictionRecord(row), nil
+}
+
+func (predictionStore) Domains(ctx context.Context) ([]string, error) {
+	return dal.GetPredictionDomains(ctx)
+}
+
+// predictionRecord converts a dal prediction into a predictions.Record.
+func predictionRecord(p *dal.PredictionRecord) predictions.Record {
+	return predictions.Record{
+		ID:              p.PredictionID,
+		Algorithm:       p.Algorithm,
+		Domain:          p.Domain,
+		QueryIdentifier: p.QueryIdentifier,
+		InputData:       p.InputData,
+		PredictionInfo:  p.PredictionInfo,
+		Time:            p.PredictionTime,
+	}
+}
+
+// predictionFilter reads a history filter from the query string: domain, algorithm, query, since,
+// until, limit and offset. Times are RFC 3339 timestamps or dates; a date for until includes that day.
+func predictionFilter(r *http.Request) (predictions.Filter, error) {
+	q := r.URL.Query()
+	f := predictions.Filter{
+		Domain:          q.Get("domain"),
+		Algorithm:       q.Get("algorithm"),
+		QueryIdentifier: q.Get("query"),
+	}
+	var errs []error
+	if f.Algorithm != "" && !validAlgorithm(f.Algorithm) {
+		errs = append(errs, fmt.Errorf("algorithm must be one of %s", strings.Join(dal.PredictionAlgorithms, ", ")))
+	}
+	var err error
+	if f.Since, err = parseHistoryTime(q.Get("since"), false); err != nil {
+		errs = append(errs, fmt.Errorf("since: %v", err))
+	}
+	if f.Until, err = parseHistoryTime(q.Get("until"), true); err != nil {
+		errs = append(errs, fmt.Errorf("until: %v", err))
+	}
+	for _, param := range []struct {
+		name  string
+		field *int
+	}{{"limit", &f.Limit}, {"offset", &f.Offset}} {
+		value := q.Get(param.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("%s must be a non-negative number", param.name))
+			continue
+		}
+		*param.field = n
+	}
+	return f.Normalize(), errors.Join(errs...)
+}
+
+// validAlgorithm reports whether name is one of the algorithms that store predictions.
+func validAlgorithm(name string) bool {
+	for _, algorithm := range dal.PredictionAlgorithms {
+		if algorithm == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHistoryTime parses an RFC 3339 timestamp or a YYYY-MM-DD date. With endOfDay set, a date means
+// the start of the following day, so that an exclusive bound includes the whole date.
+func parseHistoryTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use YYYY-MM-DD or RFC 3339", value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// predictionHistoryHandler lists past predictions, newest first (GET /api/predictions/history), and
+// fetches a single one (GET /api/predictions/history/{id}).
+func predictionHistoryHandler(store predictions.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/predictions/history"), "/"); id != "" {
+			record, err := store.Get(r.Context(), id)
+			if errors.Is(err, predictions.ErrNotFound) {
+				http.NotFound(w, r)
+				return
+			}
+			if err != nil {
+				log.Printf("Error fetching prediction %s: %v", id, err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, record)
+			return
+		}
+
+		filter, err := predictionFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		records, err := store.History(r.Context(), filter)
+		if err != nil {
+			log.Printf("Error listing prediction history: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if records == nil {
+			records = []predictions.Record{}
+		}
+		writeJSON(w, http.StatusOK, records)
+	}
+}
+
+// predictionDomainsHandler lists the domains that have predictions (GET /api/predictions/domains).
+func predictionDomainsHandler(store predictions.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		domains, err := store.Domains(r.Context())
+		if err != nil {
+			log.Printf("Error listing prediction domains: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if domains == nil {
+			domains = []string{}
+		}
+		writeJSON(w, http.StatusOK, domains)
+	}
+}
+
+// predictionCompareHandler compares two runs side by side (GET /api/predictions/compare?a={id}&b={id}).
+func predictionCompareHandler(store predictions.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		idA, idB := r.URL.Query().Get("a"), r.URL.Query().Get("b")
+		if idA == "" || idB == "" {
+			http.Error(w, "Missing a or b parameter", http.StatusBadRequest)
+			return
+		}
+		var runs [2]predictions.Record
+		for i, id := range []string{idA, idB} {
+			record, err := store.Get(r.Context(), id)
+			if errors.Is(err, predictions.ErrNotFound) {
+				http.Error(w, "Prediction not found: "+id, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Printf("Error fetching prediction %s: %v", id, err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			runs[i] = record
+		}
+		writeJSON(w, http.StatusOK, predictions.Compare(runs[0], runs[1]))
+	}
+}
+
+// predictionExportHandler downloads predictions with their input data as CSV or JSON
+// (GET /api/predictions/export?format=csv|json). Repeated id parameters select the predictions to
+// export; without them the history filter parameters apply.
+func predictionExportHandler(store predictions.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		format, err := predictions.ParseFormat(r.URL.Query().Get("format"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var records []predictions.Record
+		if ids := r.URL.Query()["id"]; len(ids) > 0 {
+			if len(ids) > predictions.MaxLimit {
+				http.Error(w, fmt.Sprintf("At most %d predictions can be exported at once", predictions.MaxLimit), http.StatusBadRequest)
+				return
+			}
+			for _, id := range ids {
+				record, err := store.Get(r.Context(), id)
+				if errors.Is(err, predictions.ErrNotFound) {
+					http.Error(w, "Prediction not found: "+id, http.StatusNotFound)
+					return
+				}
+				if err != nil {
+					log.Printf("Error fetching prediction %s: %v", id, err)
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+					return
+				}
+				records = append(records, record)
+			}
+		} else {
+			filter, err := predictionFilter(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if r.URL.Query().Get("limit") == "" {
+				filter.Limit = predictions.MaxLimit
+			}
+			if records, err = store.History(r.Context(), filter); err != nil {
+				log.Printf("Error exporting prediction history: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", `attachment; filename="`+format.Filename(time.Now())+`"`)
+		if err := predictions.Export(w, format, records); err != nil {
+			log.Printf("Error writing prediction export: %v", err)
+		}
+	}
+}
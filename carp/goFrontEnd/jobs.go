@@ -0,0 +1,501 @@
+package main
+
+import (
+	"cmpscfa23team2/crab"
+	"cmpscfa23team2/cuda/ML"
+	"cmpscfa23team2/dal"
+	"cmpscfa23team2/events"
+	"cmpscfa23team2/jobs"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// taskStore adapts the dal tasks functions to the jobs.Store interface.
+type taskStore struct{}
+
+// Create saves a new job as a task row.
+func (taskStore) Create(ctx context.Context, job jobs.Job) error {
+	_, err := dal.CreateTask(ctx, jobToTask(job))
+	return err
+}
+
+// Update writes the job's current state to its task row.
+func (taskStore) Update(ctx context.Context, job jobs.Job) error {
+	return dal.UpdateTask(ctx, jobToTask(job))
+}
+
+// Get loads a job from the tasks table.
+func (taskStore) Get(ctx context.Context, id string) (jobs.Job, error) {
+	task, err := dal.GetTask(ctx, id)
+	if err == sql.ErrNoRows {
+		return jobs.Job{}, jobs.ErrNotFound
+	}
+	if err != nil {
+		return jobs.Job{}, err
+	}
+	return taskToJob(task), nil
+}
+
+// List loads the jobs with the given status from the tasks table.
+func (taskStore) List(ctx context.Context, status jobs.Status) ([]jobs.Job, error) {
+	tasks, err := dal.GetTasksByStatus(ctx, string(status))
+	if err != nil {
+		return nil, err
+	}
+	list := make([]jobs.Job, 0, len(tasks))
+	for _, task := range tasks {
+		list = append(list, taskToJob(task))
+	}
+	return list, nil
+}
+
+// maxTaskMessage is the size of the message column of the tasks table, in characters.
+const maxTaskMessage = 255
+
+// truncateMessage shortens a progress message to what the tasks table holds, marking the cut with "…".
+func truncateMessage(message string) string {
+	runes := []rune(message)
+	if len(runes) <= maxTaskMessage {
+		return message
+	}
+	return string(runes[:maxTaskMessage-1]) + "…"
+}
+
+// jobToTask converts a job into the dal representation of a task row.
+func jobToTask(job jobs.Job) dal.Task {
+	return dal.Task{
+		TaskID:       job.ID,
+		TaskName:     job.Type,
+		Priority:     job.Priority,
+		Status:       string(job.Status),
+		Payload:      string(job.Payload),
+		Progress:     job.Progress,
+		Message:      truncateMessage(job.Message),
+		ErrorMessage: job.Error,
+		Attempts:     job.Attempts,
+		MaxAttempts:  job.MaxAttempts,
+		RunAt:        job.RunAt,
+		CreatedTime:  job.CreatedAt,
+		UpdatedTime:  job.UpdatedAt,
+	}
+}
+
+// taskToJob converts a task row back into a job.
+func taskToJob(task *dal.Task) jobs.Job {
+	job := jobs.Job{
+		ID:          task.TaskID,
+		Type:        task.TaskName,
+		Priority:    task.Priority,
+		Status:      jobs.Status(task.Status),
+		Progress:    task.Progress,
+		Message:     task.Message,
+		Error:       task.ErrorMessage,
+		Attempts:    task.Attempts,
+		MaxAttempts: task.MaxAttempts,
+		RunAt:       task.RunAt,
+		CreatedAt:   task.CreatedTime,
+		UpdatedAt:   task.UpdatedTime,
+	}
+	if task.Payload != "" {
+		job.Payload = json.RawMessage(task.Payload)
+	}
+	return job
+}
+
+// newJobManager creates the job manager backed by the tasks table and registers the engine's job types.
+func newJobManager(cfg jobs.Config) *jobs.Manager {
+	manager := jobs.NewManager(taskStore{}, cfg)
+	manager.Register("crawl", crawlJob)
+	manager.RegisterValidator("crawl", func(payload json.RawMessage) error {
+		_, err := parseCrawlPayload(payload)
+		return err
+	})
+	manager.Register("scrape", scrapeJob)
+	manager.RegisterValidator("scrape", func(payload json.RawMessage) error {
+		_, err := parseScrapePayload(payload)
+		return err
+	})
+	manager.Register("train-nbc", trainNaiveBayesJob)
+	manager.RegisterValidator("train-nbc", func(payload json.RawMessage) error {
+		_, err := parseTrainPayload(payload)
+		return err
+	})
+	manager.Register("scrape-gas", func(ctx context.Context, job jobs.Job, progress jobs.ProgressFunc) error {
+		return crab.ScrapeGasInflationDataContext(ctx)
+	})
+	manager.Register("scrape-inflation", func(ctx context.Context, job jobs.Job, progress jobs.ProgressFunc) error {
+		return crab.ScrapeInflationDataContext(ctx)
+	})
+	return manager
+}
+
+// crawlPayload holds the parameters of a "crawl" job. Without URLs the default crawl list is used.
+// Without a depth only the seed URLs are crawled; scope, include, exclude, sitemaps and since are those
+// of crab.CrawlConfig.
+type crawlPayload struct {
+	URLs        []string `json:"urls"`
+	Concurrency int      `json:"concurrency"`
+	MaxDepth    int      `json:"max_depth"`
+	MaxPages    int      `json:"max_pages"`
+	Scope       string   `json:"scope"`
+	Pattern     string   `json:"pattern"`
+	Include     []string `json:"include"`
+	Exclude     []string `json:"exclude"`
+	Sitemaps    bool     `json:"sitemaps"`
+	Since       string   `json:"since"`
+}
+
+// config returns the crawl configuration of the payload under the given crawl name.
+func (p crawlPayload) config(name string) crab.CrawlConfig {
+	return crab.CrawlConfig{
+		Name:        name,
+		Concurrency: p.Concurrency,
+		MaxDepth:    p.MaxDepth,
+		MaxPages:    p.MaxPages,
+		Scope:       p.Scope,
+		Pattern:     p.Pattern,
+		Include:     p.Include,
+		Exclude:     p.Exclude,
+		Sitemaps:    p.Sitemaps,
+		Since:       p.Since,
+	}
+}
+
+// parseCrawlPayload decodes the payload of a "crawl" job and checks its crawl configuration.
+func parseCrawlPayload(payload json.RawMessage) (crawlPayload, error) {
+	var p crawlPayload
+	if err := decodePayload(payload, &p); err != nil {
+		return p, err
+	}
+	// The crawl is named after the job, which does not exist yet; any name checks the rest
+	return p, p.config("payload").Validate()
+}
+
+// crawlJob runs the CRAB crawler over the requested seed URLs. The frontier is kept in the database,
+// so a retried job continues where the failed attempt stopped.
+func crawlJob(ctx context.Context, job jobs.Job, progress jobs.ProgressFunc) error {
+	p, err := parseCrawlPayload(job.Payload)
+	if err != nil {
+		return err
+	}
+	seeds := p.URLs
+	if len(seeds) == 0 {
+		for _, u := range crab.GetURLsToCrawl() {
+			seeds = append(seeds, u.URL)
+		}
+	}
+	config := p.config(crawlName("job", job.ID))
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	progress(0, fmt.Sprintf("crawling from %d seed URLs", len(seeds)))
+	crawled, err := runCrawl(ctx, config, seeds, events.ForJob(events.Default, job.ID))
+	if err != nil {
+		return err
+	}
+	if err := crab.CreateSiteMap(crawled); err != nil {
+		log.Println("Error creating sitemap:", err)
+	}
+	return ctx.Err()
+}
+
+// scrapePayload holds the parameters of a "scrape" job.
+type scrapePayload struct {
+	Domain string   `json:"domain"`
+	URLs   []string `json:"urls"`
+}
+
+// parseScrapePayload decodes the payload of a "scrape" job, filling in the start URLs of the domain
+// when it names no URLs.
+func parseScrapePayload(payload json.RawMessage) (scrapePayload, error) {
+	var p scrapePayload
+	if err := decodePayload(payload, &p); err != nil {
+		return p, err
+	}
+	domainConfig, exists := crab.GetDomainConfig(p.Domain)
+	if !exists {
+		return p, fmt.Errorf("invalid domain name provided: %s", p.Domain)
+	}
+	if len(p.URLs) == 0 {
+		p.URLs = domainConfig.StartURLs
+	}
+	if len(p.URLs) == 0 {
+		return p, errors.New("scrape job needs at least one URL")
+	}
+	return p, nil
+}
+
+// scrapeJob scrapes the given URLs, or the start URLs of the domain, with the scraper configuration of
+// the requested domain, and writes what every URL yielded to one file. Domains with a table mapping are
+// scraped into a time series file instead. A URL that cannot be scraped fails the job, so it is retried.
+func scrapeJob(ctx context.Context, job jobs.Job, progress jobs.ProgressFunc) error {
+	p, err := parseScrapePayload(job.Payload)
+	if err != nil {
+		return err
+	}
+	domainConfig, _ := crab.GetDomainConfig(p.Domain)
+	urls := p.URLs
+	pub := events.ForJob(events.Default, job.ID)
+	var series []crab.Observation
+	var items []crab.GenericData
+	for i, u := range urls {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		progress(float64(i)/float64(len(urls)), "scraping "+u)
+		if domainConfig.Table != nil {
+			observations, err := crab.ScrapeSeriesWithEvents(u, domainConfig, pub)
+			if err != nil {
+				return fmt.Errorf("scraping %s: %w", u, err)
+			}
+			series = append(series, observations...)
+			continue
+		}
+		scraped, err := crab.ScrapeItemsWithEvents(u, domainConfig, pub)
+		if err != nil {
+			return fmt.Errorf("scraping %s: %w", u, err)
+		}
+		items = append(items, scraped...)
+	}
+
+	if domainConfig.Table != nil {
+		filename := fmt.Sprintf("%s_series.json", domainConfig.Name)
+		if err := crab.InsertSeries(crab.SeriesData{Domain: domainConfig.Name, Data: series}, filename); err != nil {
+			return fmt.Errorf("saving %s: %v", filename, err)
+		}
+		return nil
+	}
+	filename := fmt.Sprintf("%s_data.json", domainConfig.Name)
+	if err := crab.InsertData(crab.ItemData{Domain: domainConfig.Name, Data: items}, filename); err != nil {
+		return fmt.Errorf("saving %s: %v", filename, err)
+	}
+	return nil
+}
+
+// nbcOutputDir is where train-nbc jobs write the top jobs of each domain.
+const nbcOutputDir = "Nbc_output"
+
+// trainPayload holds the parameters of a "train-nbc" job. The jobs file is a registered dataset, by ID,
+// name or name@version; the domain defaults to that of the dataset.
+type trainPayload struct {
+	Dataset string `json:"dataset"`
+	Domain  string `json:"domain"`
+}
+
+// parseTrainPayload decodes the payload of a "train-nbc" job.
+func parseTrainPayload(payload json.RawMessage) (trainPayload, error) {
+	var p trainPayload
+	if err := decodePayload(payload, &p); err != nil {
+		return p, err
+	}
+	if p.Dataset == "" {
+		return p, errors.New("train-nbc job needs a dataset")
+	}
+	if !safeDomain(p.Domain) {
+		return p, fmt.Errorf("invalid domain %q", p.Domain)
+	}
+	return p, nil
+}
+
+// safeDomain reports whether a domain can name a file in the output directory without leading out of it.
+// The empty domain, which defaults to that of the dataset, is safe.
+func safeDomain(domain string) bool {
+	return domain == "" || (domain == filepath.Base(domain) && domain != "..")
+}
+
+// trainNaiveBayesJob trains the Naive Bayes classifier on a registered jobs dataset, writes the top jobs
+// for the domain to Nbc_output and records the prediction.
+func trainNaiveBayesJob(ctx context.Context, job jobs.Job, progress jobs.ProgressFunc) error {
+	p, err := parseTrainPayload(job.Payload)
+	if err != nil {
+		return err
+	}
+	d, err := resolveDataset(ctx, p.Dataset)
+	if err != nil {
+		return err
+	}
+	if p.Domain == "" {
+		p.Domain = d.Domain
+	}
+	if p.Domain == "" {
+		p.Domain = strings.Split(filepath.Base(d.Path), "_")[0]
+	}
+	// The domain names the output file, so it must not lead out of the output directory
+	if !safeDomain(p.Domain) {
+		return fmt.Errorf("invalid domain %q", p.Domain)
+	}
+
+	progress(0.1, "loading dataset "+p.Dataset)
+	container, err := ML.LoadDataFromJSON(d.Path)
+	if err != nil {
+		return fmt.Errorf("error loading dataset %s: %v", p.Dataset, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	progress(0.4, "training classifier")
+	topJobTitles, topJobsData := rankJobs(container.Data, p.Domain)
+	if len(topJobTitles) == 0 {
+		return fmt.Errorf("no jobs matched for the domain: %s", p.Domain)
+	}
+	result := ML.JobDataContainer{
+		Domain:   p.Domain,
+		URL:      container.URL,
+		Data:     topJobsData,
+		Metadata: container.Metadata,
+	}
+
+	progress(0.8, "writing results")
+	if err := os.MkdirAll(nbcOutputDir, os.ModePerm); err != nil {
+		return err
+	}
+	resultJSON, err := json.MarshalIndent(result, "", "    ")
+	if err != nil {
+		return err
+	}
+	outputFilename := filepath.Join(nbcOutputDir, p.Domain+"_top_jobs.json")
+	if err := os.WriteFile(outputFilename, resultJSON, 0644); err != nil {
+		return err
+	}
+
+	queryIdentifier := fmt.Sprintf("Top %d %s Jobs", len(topJobTitles), p.Domain)
+	return dal.InsertPrediction("NaiveBayes", queryIdentifier, d.Path, outputFilename, topJobTitles[0])
+}
+
+// rankJobs trains the Naive Bayes classifier on data and returns the titles and entries of the jobs
+// that best match the skill set of the given category.
+func rankJobs(data []ML.JobData, category string) ([]string, []ML.JobData) {
+	defer observeInference("NaiveBayes", time.Now())
+	classifier := ML.NewNaiveBayesClassifier()
+	classifier.Train(data, category)
+	topJobTitles := classifier.PredictBestMatchingJob(category, data)
+
+	var topJobsData []ML.JobData
+	for _, title := range topJobTitles {
+		for _, j := range data {
+			if j.Title == title {
+				topJobsData = append(topJobsData, j)
+				break
+			}
+		}
+	}
+	return topJobTitles, topJobsData
+}
+
+// decodePayload unmarshals a job payload into v. An empty payload leaves v unchanged.
+func decodePayload(payload json.RawMessage, v interface{}) error {
+	if len(payload) == 0 {
+		return nil
+	}
+	return json.Unmarshal(payload, v)
+}
+
+// maxJobBody is the largest job or schedule request accepted, payload included.
+const maxJobBody = 1 << 20
+
+// jobRequest is the body accepted by POST /api/jobs.
+type jobRequest struct {
+	Type     string          `json:"type"`
+	Payload  json.RawMessage `json:"payload"`
+	Priority int             `json:"priority"`
+}
+
+// jobsHandler lists jobs (GET, optional ?status=) and submits new ones (POST).
+func jobsHandler(manager *jobs.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			list, err := manager.List(r.Context(), jobs.Status(r.URL.Query().Get("status")))
+			if err != nil {
+				log.Printf("Error listing jobs: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, list)
+
+		case http.MethodPost:
+			var req jobRequest
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJobBody)).Decode(&req); err != nil {
+				http.Error(w, "Invalid job request: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			job, err := manager.Enqueue(r.Context(), req.Type, req.Payload, req.Priority)
+			if errors.Is(err, jobs.ErrUnknownType) || errors.Is(err, jobs.ErrInvalidPayload) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				log.Printf("Error enqueueing job: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Location", "/api/jobs/"+job.ID)
+			writeJSON(w, http.StatusAccepted, job)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// jobHandler polls a single job (GET /api/jobs/{id}) and cancels it (POST /api/jobs/{id}/cancel or DELETE /api/jobs/{id}).
+func jobHandler(manager *jobs.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/jobs/"), "/")
+		id, action, _ := strings.Cut(rest, "/")
+		if id == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet && action == "":
+			job, err := manager.Get(r.Context(), id)
+			if errors.Is(err, jobs.ErrNotFound) {
+				http.NotFound(w, r)
+				return
+			}
+			if err != nil {
+				log.Printf("Error fetching job %s: %v", id, err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, job)
+
+		case (r.Method == http.MethodPost && action == "cancel") || (r.Method == http.MethodDelete && action == ""):
+			err := manager.Cancel(r.Context(), id)
+			if errors.Is(err, jobs.ErrNotFound) {
+				http.NotFound(w, r)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			job, _ := manager.Get(r.Context(), id)
+			writeJSON(w, http.StatusOK, job)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+	}
+}
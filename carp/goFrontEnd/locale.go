@@ -0,0 +1,109 @@
+package main
+
+import (
+	"cmpscfa23team2/i18n"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// languageCookieName holds the language a visitor picked, which wins over Accept-Language.
+	languageCookieName = "lang"
+
+	languageCookieLifetime = 365 * 24 * time.Hour
+)
+
+// navItems are the pages linked from the navigation bar, in order. Their labels are the "nav.<page>"
+// messages.
+var navItems = []string{"about", "register", "login", "contributors", "documentation", "history", "dashboard"}
+
+// translations are the message catalogs of the running server, loaded from locales/ on startup.
+var translations = i18n.NewBundle("en")
+
+// siteTheme is the theme of the running server, set from its config on startup.
+var siteTheme = defaultServerConfig().Theme
+
+// loadTranslations reads the message catalogs from the locales directory of the assets and logs the
+// messages each language lacks, which are shown in the default language instead.
+func loadTranslations(assets fs.FS, fallback string) (*i18n.Bundle, error) {
+	bundle, err := i18n.Load(assets, "locales", fallback)
+	if err != nil {
+		return nil, err
+	}
+	for _, lang := range bundle.Languages() {
+		if missing := bundle.Missing(lang); len(missing) > 0 {
+			log.Printf("Locale %s lacks %d messages: %s", lang, len(missing), strings.Join(missing, ", "))
+		}
+	}
+	return bundle, nil
+}
+
+// requestLanguage returns the language to answer r in: the one the visitor picked, else the best
+// match for Accept-Language, else the default.
+func requestLanguage(r *http.Request) string {
+	if r == nil {
+		return translations.Default()
+	}
+	if c, err := r.Cookie(languageCookieName); err == nil && translations.Has(c.Value) {
+		return i18n.Normalize(c.Value)
+	}
+	return translations.Match(i18n.ParseAcceptLanguage(r.Header.Get("Accept-Language"))...)
+}
+
+// pageFuncs returns the template functions for rendering page for request r: the CSRF helpers,
+// translation ("t", "lang", "languages"), the deployment theme and the navigation.
+func pageFuncs(r *http.Request, page string) template.FuncMap {
+	lang := requestLanguage(r)
+	funcs := csrfFuncs(r)
+	funcs["t"] = func(key string, args ...interface{}) string { return translations.T(lang, key, args...) }
+	funcs["lang"] = func() string { return lang }
+	funcs["languages"] = translations.Languages
+	funcs["theme"] = func() themeConfig { return siteTheme }
+	funcs["page"] = func() string { return page }
+	funcs["navItems"] = func() []string { return navItems }
+	return funcs
+}
+
+// setLanguageHeaders tells caches that a page depends on the visitor's language.
+func setLanguageHeaders(w interface{}, r *http.Request) {
+	rw, ok := w.(http.ResponseWriter)
+	if !ok {
+		return
+	}
+	rw.Header().Set("Content-Language", requestLanguage(r))
+	rw.Header().Add("Vary", "Accept-Language")
+	rw.Header().Add("Vary", "Cookie")
+}
+
+// languageHandler stores the language picked in the footer (POST /language, field lang) and sends the
+// visitor back to the page they came from.
+func languageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	lang := r.FormValue("lang")
+	if !translations.Has(lang) {
+		http.Error(w, "Unsupported language", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, cookies.cookie(languageCookieName, i18n.Normalize(lang), languageCookieLifetime, false))
+	http.Redirect(w, r, localReferer(r), http.StatusSeeOther)
+}
+
+// localReferer returns the path of the referring page when it is on this site, or /home.
+func localReferer(r *http.Request) string {
+	ref, err := url.Parse(r.Referer())
+	if err != nil || !strings.HasPrefix(ref.Path, "/") || strings.HasPrefix(ref.Path, "//") || strings.HasPrefix(ref.Path, "/\\") || (ref.Host != "" && ref.Host != r.Host) {
+		return "/home"
+	}
+	if ref.RawQuery != "" {
+		return ref.Path + "?" + ref.RawQuery
+	}
+	return ref.Path
+}
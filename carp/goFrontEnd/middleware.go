@@ -4,7 +4,6 @@ import (
 	"cmpscfa23team2/dal"          // Import the data access layer package
 	"errors"                      // Import the errors package for error handling
 	"github.com/dgrijalva/jwt-go" // Import the jwt-go package for JWT authentication
-	"html/template"               // Import the template package for HTML templating
 	"log"                         // Import the log package for logging
 	"net/http"                    // Import the net/http package for HTTP server and client
 	"strings"                     // Import the strings package for string manipulation
@@ -35,25 +34,28 @@ func serveTemplate(content string, loggedIn bool) http.HandlerFunc {
 		LoggedIn: loggedIn,
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
-		renderTemplate(w, "layout.gohtml", emptyData)
+		renderTemplate(w, r, "layout.gohtml", emptyData)
 	}
 }
 
+// pages holds the page templates once the server has loaded them.
+var pages *templateSet
+
 // renderTemplate renders the specified HTML template.
 // w: the response writer
-// tmpl: the path to the template file
+// r: the request being answered
+// name: the name of the template, e.g. "layout.gohtml" or "login"
 // data: data to be passed to the template
-func renderTemplate(w http.ResponseWriter, tmpl string, data *AuthData) {
-	t, err := template.ParseFiles("path/to/" + tmpl)
-	if err != nil {
-		log.Printf("Error parsing template: %v", err)
+func renderTemplate(w http.ResponseWriter, r *http.Request, name string, data *AuthData) {
+	if pages == nil {
+		log.Printf("Error rendering %s: templates not loaded", name)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 	if data == nil {
 		data = &AuthData{}
 	}
-	err = t.Execute(w, data)
+	err := pages.Render(w, r, name, data)
 	if err != nil {
 		log.Printf("Error executing template: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -67,7 +69,7 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
 		// Render the login template for GET requests
 		data := AuthData{Action: "login"}
-		renderTemplate(w, "path/to/login.gohtml", &data)
+		renderTemplate(w, r, "login", &data)
 	} else if r.Method == "POST" {
 		// Process POST request for login
 		r.ParseForm()
@@ -75,7 +77,7 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 		password := r.FormValue("password")
 
 		// Call the DAL authentication function
-		token, err := dal.AuthenticateUser(username, password)
+		token, err := dal.AuthenticateUser(r.Context(), username, password)
 		if err != nil {
 			// Log the authentication error
 			log.Printf("Authentication error: %v", err)
@@ -87,7 +89,7 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 				Error:    "Invalid credentials",
 				LoggedIn: false,
 			}
-			renderTemplate(w, "path/to/login.gohtml", &data)
+			renderTemplate(w, r, "login", &data)
 			return
 		}
 
@@ -129,7 +131,7 @@ func logoutHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Call the DAL function to log out the user
-	err = dal.LogoutUser(userID)
+	err = dal.LogoutUser(r.Context(), userID)
 	if err != nil {
 		http.Error(w, "Logout failed", http.StatusInternalServerError)
 		return
@@ -0,0 +1,607 @@
+package main
+
+import (
+	"cmpscfa23team2/canonical"
+	"cmpscfa23team2/crab"
+	"cmpscfa23team2/cuda/ML"
+	"cmpscfa23team2/dal"
+	"cmpscfa23team2/events"
+	"cmpscfa23team2/jobs"
+	"cmpscfa23team2/pipeline"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// pipelineDir returns the directory holding the pipeline definition files: PIPELINE_DIR, by default the
+// pipelines directory in the root of the source tree, found from this file rather than the working directory.
+func pipelineDir() string {
+	if dir := os.Getenv("PIPELINE_DIR"); dir != "" {
+		return dir
+	}
+	if _, file, _, ok := runtime.Caller(0); ok {
+		return filepath.Join(filepath.Dir(file), "..", "..", "pipelines")
+	}
+	return "pipelines"
+}
+
+// findPipeline loads the definition with the given name. Definitions are re-read on every call so
+// edits to the files take effect without restarting the server.
+func findPipeline(name string) (pipeline.Definition, error) {
+	defs, err := pipeline.LoadDefinitions(pipelineDir())
+	if err != nil {
+		return pipeline.Definition{}, err
+	}
+	for _, def := range defs {
+		if def.Name == name {
+			return def, nil
+		}
+	}
+	return pipeline.Definition{}, fmt.Errorf("unknown pipeline: %s", name)
+}
+
+// pipelineStore adapts the dal pipeline run functions to the pipeline.Store interface.
+type pipelineStore struct{}
+
+// Save writes the run and its stage checkpoints to the pipeline_runs table.
+func (pipelineStore) Save(ctx context.Context, run pipeline.Run) error {
+	definition, err := json.Marshal(run.Definition)
+	if err != nil {
+		return err
+	}
+	stages, err := json.Marshal(run.Stages)
+	if err != nil {
+		return err
+	}
+	return dal.SavePipelineRun(ctx, dal.PipelineRun{
+		RunID:        run.ID,
+		PipelineName: run.Pipeline,
+		Status:       string(run.Status),
+		Definition:   string(definition),
+		Stages:       string(stages),
+		CreatedTime:  run.CreatedAt,
+	})
+}
+
+// Get loads a run from the pipeline_runs table.
+func (pipelineStore) Get(ctx context.Context, id string) (pipeline.Run, error) {
+	row, err := dal.GetPipelineRun(ctx, id)
+	if err == sql.ErrNoRows {
+		return pipeline.Run{}, pipeline.ErrNotFound
+	}
+	if err != nil {
+		return pipeline.Run{}, err
+	}
+	return rowToRun(row)
+}
+
+// List loads the runs of a pipeline from the pipeline_runs table.
+func (pipelineStore) List(ctx context.Context, name string) ([]pipeline.Run, error) {
+	rows, err := dal.GetPipelineRuns(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]pipeline.Run, 0, len(rows))
+	for _, row := range rows {
+		run, err := rowToRun(row)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// rowToRun decodes a pipeline_runs row.
+func rowToRun(row *dal.PipelineRun) (pipeline.Run, error) {
+	run := pipeline.Run{
+		ID:        row.RunID,
+		Pipeline:  row.PipelineName,
+		Status:    pipeline.Status(row.Status),
+		CreatedAt: row.CreatedTime,
+		UpdatedAt: row.UpdatedTime,
+	}
+	if row.Definition != "" {
+		if err := json.Unmarshal([]byte(row.Definition), &run.Definition); err != nil {
+			return run, fmt.Errorf("run %s: invalid definition: %v", row.RunID, err)
+		}
+	}
+	if row.Stages != "" {
+		if err := json.Unmarshal([]byte(row.Stages), &run.Stages); err != nil {
+			return run, fmt.Errorf("run %s: invalid stages: %v", row.RunID, err)
+		}
+	}
+	return run, nil
+}
+
+// newOrchestrator creates the pipeline orchestrator and registers the CRAB → DAL → CUDA stages.
+func newOrchestrator() *pipeline.Orchestrator {
+	o := pipeline.NewOrchestrator(pipelineStore{})
+	o.Handle(pipeline.StageCrawl, crawlStage)
+	o.Handle(pipeline.StageScrape, scrapeStage)
+	o.Handle(pipeline.StageStore, storeStage)
+	o.Handle(pipeline.StageModel, modelStage)
+	o.Handle(pipeline.StageOutput, outputStage)
+	return o
+}
+
+// outputDir returns the directory a pipeline writes its files to.
+func outputDir(def pipeline.Definition) string {
+	if def.Output.Dir != "" {
+		return def.Output.Dir
+	}
+	return "output"
+}
+
+// crawlStage crawls from the seed URLs and stores every crawled page and every link on the hosts of the
+// seeds through dal, along with the items the pages describe in structured data. The frontier is kept
+// in the database, so a resumed run continues the crawl.
+func crawlStage(ctx context.Context, def pipeline.Definition, run pipeline.Run) (string, error) {
+	if len(def.Crawl.Seeds) == 0 {
+		return "no seeds configured", nil
+	}
+	hosts := make(map[string]bool)
+	for _, seed := range def.Crawl.Seeds {
+		u, err := url.Parse(canonical.URL(seed))
+		if err != nil || u.Host == "" {
+			return "", fmt.Errorf("invalid seed URL %q", seed)
+		}
+		hosts[u.Host] = true
+	}
+	config := crab.CrawlConfig{
+		Name:        crawlName("run", run.ID),
+		Concurrency: def.Crawl.Concurrency,
+		MaxDepth:    def.Crawl.MaxDepth,
+		MaxPages:    def.Crawl.MaxPages,
+		Scope:       def.Crawl.Scope,
+		Pattern:     def.Crawl.Pattern,
+		Include:     def.Crawl.Include,
+		Exclude:     def.Crawl.Exclude,
+		Sitemaps:    def.Crawl.Sitemaps,
+		Since:       def.Crawl.Since,
+	}
+	crawled, err := runCrawl(ctx, config, def.Crawl.Seeds, events.ForJob(events.Default, run.ID))
+	if err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	seen := make(map[string]bool)
+	for _, page := range crawled {
+		// A page is stored under the canonical URL it declares; links are already canonical
+		pageURL := page.URL
+		if page.Canonical != "" {
+			pageURL = page.Canonical
+		}
+		candidates := append([]string{pageURL}, page.Links...)
+		for _, link := range candidates {
+			u, err := url.Parse(link)
+			if err != nil || !hosts[u.Host] || seen[link] {
+				continue
+			}
+			seen[link] = true
+			tags := map[string]interface{}{"pipeline": def.Name, "run": run.ID, "found_on": pageURL}
+			if _, err := dal.InsertURL(ctx, link, def.Domain, tags); err != nil {
+				return "", err
+			}
+		}
+	}
+	// A resumed or rerun stage replaces the items it stored before
+	if err := dal.DeleteScrapedItemsOfRun(ctx, run.ID, pipeline.StageCrawl); err != nil {
+		return "", err
+	}
+	items := 0
+	for _, page := range crawled {
+		for _, item := range page.Items {
+			timestamp, _ := time.Parse(time.RFC3339, item.Metadata.Timestamp)
+			err := dal.InsertScrapedItem(ctx, dal.ScrapedItem{
+				Domain:      def.Domain,
+				Title:       item.Title,
+				URL:         item.URL,
+				Description: item.Description,
+				Price:       item.Price,
+				Source:      item.Metadata.Source,
+				Timestamp:   timestamp,
+				RunID:       run.ID,
+				Stage:       pipeline.StageCrawl,
+			})
+			if err != nil {
+				return "", err
+			}
+			items++
+		}
+	}
+	return fmt.Sprintf("stored %d URLs and %d structured-data items for %s", len(seen), items, def.Domain), nil
+}
+
+// maxScrapeURLs bounds how many stored URLs one scrape stage visits.
+const maxScrapeURLs = 50
+
+// scrapeStage scrapes the configured URLs, else the URLs stored for the domain by the crawl stage,
+// else the start URLs of the scraper configuration, and writes the items to a run-specific file in the
+// output directory for the store stage.
+func scrapeStage(ctx context.Context, def pipeline.Definition, run pipeline.Run) (string, error) {
+	configName := def.Scrape.Config
+	if configName == "" {
+		configName = def.Domain
+	}
+	domainConfig, exists := crab.GetDomainConfig(configName)
+	if !exists {
+		return "", fmt.Errorf("invalid domain name provided: %s", configName)
+	}
+
+	urls := def.Scrape.URLs
+	if len(urls) == 0 {
+		stored, err := dal.GetURLsFromDomain(ctx, def.Domain)
+		if err != nil {
+			return "", err
+		}
+		urls = stored
+	}
+	if len(urls) == 0 {
+		urls = domainConfig.StartURLs
+	}
+	if len(urls) == 0 {
+		return "", fmt.Errorf("no URLs to scrape for %s", def.Domain)
+	}
+	if len(urls) > maxScrapeURLs {
+		urls = urls[:maxScrapeURLs]
+	}
+
+	var items []crab.GenericData
+	for _, u := range urls {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		found, err := crab.ScrapeItemsWithEvents(u, domainConfig, events.ForJob(events.Default, run.ID))
+		if err != nil {
+			log.Printf("Pipeline %s: error scraping %s: %v", def.Name, u, err)
+			continue
+		}
+		items = append(items, found...)
+	}
+
+	dir := outputDir(def)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", err
+	}
+	filename := filepath.Join(dir, fmt.Sprintf("%s_%s_scraped.json", def.Name, run.ID))
+	if err := crab.InsertData(crab.ItemData{Domain: def.Domain, Data: items}, filename); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// storeStage loads the items written by the scrape stage and stores them in the scrapedData table,
+// replacing those an earlier attempt of the stage stored for the run.
+func storeStage(ctx context.Context, def pipeline.Definition, run pipeline.Run) (string, error) {
+	scrape := run.Stage(pipeline.StageScrape)
+	if scrape == nil || scrape.Output == "" {
+		return "", errors.New("store stage needs the output of the scrape stage")
+	}
+	data, err := os.ReadFile(scrape.Output)
+	if err != nil {
+		return "", err
+	}
+	var scraped crab.ItemData
+	if err := json.Unmarshal(data, &scraped); err != nil {
+		return "", fmt.Errorf("invalid scrape output %s: %v", scrape.Output, err)
+	}
+
+	if err := dal.DeleteScrapedItemsOfRun(ctx, run.ID, pipeline.StageStore); err != nil {
+		return "", err
+	}
+	for _, item := range scraped.Data {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		timestamp, _ := time.Parse(time.RFC3339, item.Metadata.Timestamp)
+		err := dal.InsertScrapedItem(ctx, dal.ScrapedItem{
+			Domain:      def.Domain,
+			Title:       item.Title,
+			URL:         item.URL,
+			Description: item.Description,
+			Price:       item.Price,
+			Source:      item.Metadata.Source,
+			Timestamp:   timestamp,
+			RunID:       run.ID,
+			Stage:       pipeline.StageStore,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("stored %d items for %s", len(scraped.Data), def.Domain), nil
+}
+
+// pipelineResult is the file the model stage writes and the output stage records.
+type pipelineResult struct {
+	Pipeline        string      `json:"pipeline"`
+	Domain          string      `json:"domain"`
+	Algorithm       string      `json:"algorithm"`
+	QueryIdentifier string      `json:"query_identifier"`
+	Summary         string      `json:"summary"`
+	Result          interface{} `json:"result"`
+}
+
+// modelParams holds the parameters of the supported model algorithms.
+type modelParams struct {
+	Category string    `json:"category"` // NaiveBayes: skill category to rank jobs for
+	K        int       `json:"k"`        // KNN: number of neighbors
+	Target   []float64 `json:"target"`   // KNN: features of the point to classify, e.g. a price
+}
+
+// modelStage trains the configured CUDA model on the items stored for the domain, or on the dataset
+// the definition names, and writes the result file for the output stage.
+func modelStage(ctx context.Context, def pipeline.Definition, run pipeline.Run) (string, error) {
+	var items []dal.ScrapedItem
+	var err error
+	if def.Model.Dataset != "" {
+		items, err = datasetItems(ctx, def.Model.Dataset)
+	} else {
+		items, err = dal.GetScrapedItems(ctx, def.Domain)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(items) == 0 {
+		if def.Model.Dataset != "" {
+			return "", fmt.Errorf("dataset %s has no items with a title or price", def.Model.Dataset)
+		}
+		return "", fmt.Errorf("no stored items for %s", def.Domain)
+	}
+	var params modelParams
+	if len(def.Model.Params) > 0 {
+		if err := json.Unmarshal(def.Model.Params, &params); err != nil {
+			return "", fmt.Errorf("invalid model params: %v", err)
+		}
+	}
+
+	result := pipelineResult{Pipeline: def.Name, Domain: def.Domain}
+	switch def.Model.Algorithm {
+	case "NaiveBayes":
+		if params.Category == "" {
+			return "", errors.New("NaiveBayes model needs a category")
+		}
+		data := make([]ML.JobData, 0, len(items))
+		for _, item := range items {
+			data = append(data, ML.JobData{Title: item.Title, URL: item.URL, Description: item.Description, Salary: item.Price})
+		}
+		topJobTitles, topJobsData := rankJobs(data, params.Category)
+		if len(topJobTitles) == 0 {
+			return "", fmt.Errorf("no jobs matched for the category: %s", params.Category)
+		}
+		result.Algorithm = "NaiveBayes"
+		result.QueryIdentifier = fmt.Sprintf("Top %d %s Jobs", len(topJobTitles), params.Category)
+		result.Summary = topJobTitles[0]
+		result.Result = topJobsData
+
+	case "KNN":
+		if params.K <= 0 {
+			params.K = 3
+		}
+		if len(params.Target) != 1 {
+			return "", errors.New("KNN model needs a single target price")
+		}
+		points := make([]ML.Point, 0, len(items))
+		for _, item := range items {
+			points = append(points, ML.Point{Features: []float64{ML.ParseFloat(item.Price)}, Label: item.Title})
+		}
+		if len(points) < params.K {
+			return "", fmt.Errorf("KNN needs at least %d items, have %d", params.K, len(points))
+		}
+		start := time.Now()
+		label, neighbors := ML.KNN(params.K, points, ML.Point{Features: params.Target})
+		observeInference("KNN", start)
+		result.Algorithm = "KNN"
+		result.QueryIdentifier = fmt.Sprintf("%d nearest %s to %v", params.K, def.Domain, params.Target[0])
+		result.Summary = label
+		result.Result = neighbors
+
+	default:
+		return "", fmt.Errorf("unsupported model algorithm: %q", def.Model.Algorithm)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	dir := outputDir(def)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", err
+	}
+	resultJSON, err := json.MarshalIndent(result, "", "    ")
+	if err != nil {
+		return "", err
+	}
+	filename := filepath.Join(dir, fmt.Sprintf("%s_%s_result.json", def.Name, run.ID))
+	if err := os.WriteFile(filename, resultJSON, 0644); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// outputStage records the model result as a prediction when the definition asks for it.
+func outputStage(ctx context.Context, def pipeline.Definition, run pipeline.Run) (string, error) {
+	model := run.Stage(pipeline.StageModel)
+	if model == nil || model.Output == "" {
+		return "", errors.New("output stage needs the output of the model stage")
+	}
+	if !def.Output.Record {
+		return "result written to " + model.Output, nil
+	}
+	data, err := os.ReadFile(model.Output)
+	if err != nil {
+		return "", err
+	}
+	var result pipelineResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("invalid model output %s: %v", model.Output, err)
+	}
+	if err := dal.InsertDomainPrediction(result.Algorithm, def.Domain, result.QueryIdentifier, model.Output, result.Summary); err != nil {
+		return "", err
+	}
+	return "recorded prediction " + result.QueryIdentifier, nil
+}
+
+// pipelinePayload holds the parameters of a "pipeline" job: either the name of a pipeline to start
+// or the ID of a failed run to resume.
+type pipelinePayload struct {
+	Pipeline string `json:"pipeline"`
+	Resume   string `json:"resume"`
+}
+
+// parsePipelinePayload decodes the payload of a "pipeline" job and checks that the pipeline to start exists.
+func parsePipelinePayload(payload json.RawMessage) (pipelinePayload, error) {
+	var p pipelinePayload
+	if err := decodePayload(payload, &p); err != nil {
+		return p, err
+	}
+	if p.Resume == "" {
+		if _, err := findPipeline(p.Pipeline); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+// pipelineJob returns the job handler that starts or resumes pipeline runs. A new run takes the job's ID,
+// so when the job is retried after a failure the run resumes from the failed stage instead of starting over.
+func pipelineJob(o *pipeline.Orchestrator) jobs.Handler {
+	return func(ctx context.Context, job jobs.Job, progress jobs.ProgressFunc) error {
+		p, err := parsePipelinePayload(job.Payload)
+		if err != nil {
+			return err
+		}
+		report := func(stage string, done, total int) {
+			if stage == "" {
+				progress(1, "pipeline finished")
+				return
+			}
+			progress(float64(done)/float64(total), "running stage "+stage)
+		}
+
+		var run pipeline.Run
+		if p.Resume != "" {
+			run, err = o.Resume(ctx, p.Resume, report)
+		} else {
+			def, findErr := findPipeline(p.Pipeline)
+			if findErr != nil {
+				return findErr
+			}
+			run, err = o.StartRun(ctx, job.ID, def, report)
+		}
+		if err != nil && run.ID != "" {
+			return fmt.Errorf("pipeline run %s: %w", run.ID, err)
+		}
+		return err
+	}
+}
+
+// pipelinesHandler lists the pipeline definitions (GET) and starts a run (POST {"pipeline": name}).
+// The run executes as a "pipeline" job; poll /api/jobs/{id} for progress and /api/pipelines/runs for checkpoints.
+func pipelinesHandler(manager *jobs.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			defs, err := pipeline.LoadDefinitions(pipelineDir())
+			if err != nil {
+				log.Printf("Error loading pipelines: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, defs)
+
+		case http.MethodPost:
+			var p pipelinePayload
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJobBody)).Decode(&p); err != nil {
+				http.Error(w, "Invalid pipeline request: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if _, err := findPipeline(p.Pipeline); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			enqueuePipelineJob(w, r, manager, pipelinePayload{Pipeline: p.Pipeline})
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// pipelineRunsHandler lists runs (GET /api/pipelines/runs, optional ?pipeline=), shows one run with
+// its stage checkpoints (GET /api/pipelines/runs/{id}) and resumes a failed run (POST /api/pipelines/runs/{id}/resume).
+func pipelineRunsHandler(o *pipeline.Orchestrator, manager *jobs.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/pipelines/runs"), "/")
+		id, action, _ := strings.Cut(rest, "/")
+
+		switch {
+		case r.Method == http.MethodGet && id == "":
+			runs, err := o.List(r.Context(), r.URL.Query().Get("pipeline"))
+			if err != nil {
+				log.Printf("Error listing pipeline runs: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, runs)
+
+		case r.Method == http.MethodGet && action == "":
+			run, err := o.Get(r.Context(), id)
+			if errors.Is(err, pipeline.ErrNotFound) {
+				http.NotFound(w, r)
+				return
+			}
+			if err != nil {
+				log.Printf("Error fetching pipeline run %s: %v", id, err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, run)
+
+		case r.Method == http.MethodPost && id != "" && action == "resume":
+			run, err := o.Get(r.Context(), id)
+			if errors.Is(err, pipeline.ErrNotFound) {
+				http.NotFound(w, r)
+				return
+			}
+			if err != nil {
+				log.Printf("Error fetching pipeline run %s: %v", id, err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if run.Status == pipeline.StatusSucceeded {
+				http.Error(w, "Pipeline run already succeeded", http.StatusConflict)
+				return
+			}
+			enqueuePipelineJob(w, r, manager, pipelinePayload{Resume: id})
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// enqueuePipelineJob submits a "pipeline" job and answers 202 with the job.
+func enqueuePipelineJob(w http.ResponseWriter, r *http.Request, manager *jobs.Manager, p pipelinePayload) {
+	job, err := manager.Enqueue(r.Context(), "pipeline", p, 0)
+	if err != nil {
+		log.Printf("Error enqueueing pipeline job: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
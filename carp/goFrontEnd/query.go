@@ -0,0 +1,98 @@
+package main
+
+import (
+	"cmpscfa23team2/dal"
+	"cmpscfa23team2/nlq"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// newQueryProvider returns the provider that interprets free-text questions. The offline rule provider
+// is used unless NLQ_PROVIDER=openai, in which case NLQ_BASE_URL, NLQ_API_KEY and NLQ_MODEL configure
+// an OpenAI-compatible endpoint and the rules are kept as fallback.
+func newQueryProvider() nlq.Provider {
+	catalog := nlq.DefaultCatalog()
+	rules := nlq.NewRuleProvider(catalog)
+	if !strings.EqualFold(os.Getenv("NLQ_PROVIDER"), "openai") {
+		return rules
+	}
+
+	baseURL := os.Getenv("NLQ_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	model := os.Getenv("NLQ_MODEL")
+	if model == "" {
+		model = "gpt-3.5-turbo"
+	}
+	log.Printf("Interpreting questions with %s at %s", model, baseURL)
+	return nlq.Fallback(nlq.NewOpenAIProvider(baseURL, os.Getenv("NLQ_API_KEY"), model, catalog), rules)
+}
+
+// queryRequest is the body accepted by POST /api/query.
+type queryRequest struct {
+	Question string `json:"question"`
+}
+
+// queryResponse is the answer to a free-text question: how it was understood, the stored prediction
+// and an explanation in prose.
+type queryResponse struct {
+	Intent      nlq.Intent         `json:"intent"`
+	Prediction  dal.PredictionData `json:"prediction"`
+	Explanation string             `json:"explanation"`
+}
+
+// queryHandler answers free-text questions (POST /api/query {"question": "..."}).
+func queryHandler(provider nlq.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req queryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Question) == "" {
+			http.Error(w, "Missing question", http.StatusBadRequest)
+			return
+		}
+
+		intent, err := provider.Interpret(r.Context(), req.Question)
+		if errors.Is(err, nlq.ErrNotUnderstood) {
+			http.Error(w, "Sorry, that question could not be matched to a prediction. Try asking about jobs, gas prices or airfare.", http.StatusUnprocessableEntity)
+			return
+		}
+		if err != nil {
+			log.Printf("Error interpreting question %q: %v", req.Question, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		predictionData, err := dal.FetchPredictionData(intent.QueryIdentifier, intent.Domain)
+		if err != nil {
+			log.Printf("Error fetching prediction data: %v", err)
+		}
+		explanation, err := provider.Explain(r.Context(), intent, predictionResult(predictionData))
+		if err != nil {
+			log.Printf("Error explaining prediction %q: %v", intent.QueryIdentifier, err)
+		}
+
+		writeJSON(w, http.StatusOK, queryResponse{Intent: intent, Prediction: predictionData, Explanation: explanation})
+	}
+}
+
+// predictionResult converts stored prediction data into the form the query providers explain.
+func predictionResult(data dal.PredictionData) nlq.Result {
+	result := nlq.Result{PredictionInfo: data.PredictionInfo, ImagePath: data.ImagePath}
+	if data.SpecificJob != nil {
+		result.Items = append(result.Items, data.SpecificJob.Title)
+	}
+	for _, job := range data.JobListings {
+		if data.SpecificJob == nil || job.Title != data.SpecificJob.Title {
+			result.Items = append(result.Items, job.Title)
+		}
+	}
+	return result
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"cmpscfa23team2/dal"
+	"cmpscfa23team2/ratelimit"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiKeyHeader carries the access token of a web_service customer.
+const apiKeyHeader = "X-API-Key"
+
+// apiKeyCacheTTL is how long a looked-up API key is trusted before the database is asked again.
+// Unknown keys are remembered for a shorter time, and the cache holds at most apiKeyCacheSize keys.
+const (
+	apiKeyCacheTTL  = time.Minute
+	apiKeyMissTTL   = 10 * time.Second
+	apiKeyCacheSize = 10000
+)
+
+// defaultRateLimits apply when RATE_LIMIT_CONFIG does not name a config file. The first matching
+// rule wins, so the catch-all /api/ rule comes last. The login forms post to /login, which the "/"
+// route serves, and older clients post to / itself; that rule is exact so that it does not catch the
+// posts to every other path.
+func defaultRateLimits() ratelimit.Config {
+	minute := ratelimit.Duration(time.Minute)
+	return ratelimit.Config{Rules: []ratelimit.Rule{
+		{Name: "login", Path: "/login", Methods: []string{http.MethodPost}, By: ratelimit.ByIP, Limit: ratelimit.Limit{Requests: 5, Per: minute}},
+		{Name: "login-root", Path: "/", Exact: true, Methods: []string{http.MethodPost}, By: ratelimit.ByIP, Limit: ratelimit.Limit{Requests: 5, Per: minute}},
+		{Name: "register", Path: "/register", Methods: []string{http.MethodPost}, By: ratelimit.ByIP, Limit: ratelimit.Limit{Requests: 3, Per: ratelimit.Duration(time.Hour)}},
+		{Name: "predictions", Path: "/api/predictions", By: ratelimit.ByAPIKey, Limit: ratelimit.Limit{Requests: 30, Per: minute, Burst: 10}},
+		{Name: "query", Path: "/api/query", By: ratelimit.ByAPIKey, Limit: ratelimit.Limit{Requests: 20, Per: minute, Burst: 5}},
+		{Name: "api", Path: "/api/", By: ratelimit.ByAPIKey, Limit: ratelimit.Limit{Requests: 120, Per: minute}},
+	}}
+}
+
+// newRateLimiter loads the rules from the JSON file named by RATE_LIMIT_CONFIG, or uses the defaults,
+// and keeps the buckets in memory.
+func newRateLimiter() (*ratelimit.Limiter, error) {
+	cfg := defaultRateLimits()
+	if path := os.Getenv("RATE_LIMIT_CONFIG"); path != "" {
+		loaded, err := ratelimit.LoadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		cfg = loaded
+		log.Printf("Loaded %d rate limit rules from %s", len(cfg.Rules), path)
+	}
+	return ratelimit.NewLimiter(cfg, ratelimit.NewMemoryStore()), nil
+}
+
+// clientIP returns the address of the client. X-Forwarded-For is only believed when
+// CARP_TRUST_PROXY=true, i.e. when carp runs behind a proxy that sets it.
+func clientIP(r *http.Request) string {
+	if strings.EqualFold(os.Getenv("CARP_TRUST_PROXY"), "true") {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// apiKeyCache remembers web service lookups so that API requests do not each query the database.
+type apiKeyCache struct {
+	mu      sync.Mutex
+	entries map[string]apiKeyEntry
+}
+
+type apiKeyEntry struct {
+	service *dal.WebService // nil for unknown keys
+	expires time.Time
+}
+
+// lookup returns the web service of key, or nil if the key is unknown.
+func (c *apiKeyCache) lookup(ctx context.Context, key string) (*dal.WebService, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.service, nil
+	}
+
+	service, err := dal.GetWebServiceByToken(ctx, key)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	ttl := apiKeyCacheTTL
+	if service == nil {
+		ttl = apiKeyMissTTL
+	}
+	c.store(key, apiKeyEntry{service: service, expires: time.Now().Add(ttl)})
+	return service, nil
+}
+
+// store caches an entry, first dropping the expired ones when the cache is full, then unknown keys,
+// then any others, so that a client sending made-up keys cannot grow it without bound.
+func (c *apiKeyCache) store(key string, entry apiKeyEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= apiKeyCacheSize {
+		now := time.Now()
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		for k, e := range c.entries {
+			if len(c.entries) < apiKeyCacheSize {
+				break
+			}
+			if e.service == nil {
+				delete(c.entries, k)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < apiKeyCacheSize {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = entry
+}
+
+type webServiceKey struct{}
+
+// requestWebService returns the web service whose valid API key the request carries, or nil.
+func requestWebService(r *http.Request) *dal.WebService {
+	service, _ := r.Context().Value(webServiceKey{}).(*dal.WebService)
+	return service
+}
+
+// rateLimit rejects requests over their rule's limit, or over the daily quota of their API key,
+// with 429 Too Many Requests and a Retry-After header. Requests with a valid API key carry its web
+// service in their context.
+func rateLimit(limiter *ratelimit.Limiter, next http.Handler) http.Handler {
+	keys := &apiKeyCache{entries: make(map[string]apiKeyEntry)}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey := r.Header.Get(apiKeyHeader)
+		var service *dal.WebService
+		if apiKey != "" {
+			var err error
+			service, err = keys.lookup(r.Context(), apiKey)
+			if err != nil {
+				log.Printf("Error checking API key: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if service == nil {
+				http.Error(w, "Invalid API key", http.StatusUnauthorized)
+				return
+			}
+			if !service.Active {
+				http.Error(w, "API key is not active", http.StatusForbidden)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), webServiceKey{}, service))
+		}
+
+		// The rate limit comes first so that rejected requests do not use up the daily quota
+		if rule := limiter.Rule(r); rule != nil {
+			decision, err := limiter.Allow(r, clientKey(r, rule.By, apiKey))
+			if err != nil {
+				// A broken store should not take the site down; let the request through.
+				log.Printf("Error checking rate limit %s: %v", rule.Name, err)
+			} else {
+				w.Header().Set("X-RateLimit-Limit", strconv.Itoa(rule.Capacity()))
+				w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+				if !decision.Allowed {
+					tooManyRequests(w, decision, "Too many requests")
+					return
+				}
+			}
+		}
+
+		if service != nil && service.DailyQuota > 0 {
+			decision, err := limiter.Quota("customer:"+service.CustomerID, service.DailyQuota)
+			if err != nil {
+				log.Printf("Error checking quota of customer %s: %v", service.CustomerID, err)
+			} else if !decision.Allowed {
+				tooManyRequests(w, decision, "Daily quota exceeded")
+				return
+			} else {
+				w.Header().Set("X-Quota-Remaining", strconv.Itoa(decision.Remaining))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientKey identifies the client for a rule, falling back from API key to user ID to IP address.
+func clientKey(r *http.Request, by ratelimit.KeyBy, apiKey string) string {
+	if by == ratelimit.ByAPIKey && apiKey != "" {
+		return "key:" + apiKey
+	}
+	if by == ratelimit.ByAPIKey || by == ratelimit.ByUser {
+		if userID := requestUserID(r); userID != "" {
+			return "user:" + userID
+		}
+	}
+	return "ip:" + clientIP(r)
+}
+
+// tooManyRequests writes a 429 response telling the client when to retry.
+func tooManyRequests(w http.ResponseWriter, decision ratelimit.Decision, message string) {
+	w.Header().Set("Retry-After", strconv.Itoa(decision.RetryAfterSeconds()))
+	http.Error(w, message, http.StatusTooManyRequests)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"cmpscfa23team2/dal"
+	"cmpscfa23team2/ratelimit"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// requestIDHeader carries the request ID in both directions.
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID limits the request IDs accepted from clients and proxies to a safe format.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// accessLogger writes one JSON line per request to standard output.
+var accessLogger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+// middleware wraps the router with the handlers every request passes through, outermost first:
+// request ID, access log, metrics, panic recovery, security headers, rate limiting and CSRF checks.
+// Panic recovery sits inside the access log and metrics, so a panicking request is still logged and
+// counted as the 500 it is answered with, and outside everything else.
+func middleware(mux *http.ServeMux, cfg serverConfig, limiter *ratelimit.Limiter) http.Handler {
+	return withRequestID(accessLog(instrument(mux, recoverPanics(securityHeaders(cfg, rateLimit(limiter, csrfProtect(mux)))))))
+}
+
+// withRequestID propagates the X-Request-ID of the incoming request, or assigns a new one, echoes it
+// in the response and stores it in the request context for dal logging.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.New().String()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(dal.WithRequestID(r.Context(), id)))
+	})
+}
+
+// accessLog writes a structured log line for every request once it has been served. Server errors
+// are also written to the log table, tagged with the request ID.
+func accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+			dal.InsertLogContext(r.Context(), "400", fmt.Sprintf("%s %s returned %d", r.Method, r.URL.Path, rec.status), "carp "+r.URL.Path)
+		}
+		accessLogger.LogAttrs(r.Context(), level, "request",
+			slog.String("request_id", dal.RequestID(r.Context())),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Int("bytes", rec.bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("user_id", requestUserID(r)),
+			slog.String("remote_addr", r.RemoteAddr),
+		)
+	})
+}
+
+// recoverPanics turns a panicking handler into a 500 response. The stack trace goes to the server
+// log and a row tagged with the request ID goes to the log table.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				// The handler deliberately aborted the response; let net/http close the connection.
+				panic(p)
+			}
+			requestID := dal.RequestID(r.Context())
+			log.Printf("Panic serving %s %s (request %s): %v\n%s", r.Method, r.URL.Path, requestID, p, debug.Stack())
+			dal.InsertLogContext(r.Context(), "400", fmt.Sprintf("panic serving %s %s: %v", r.Method, r.URL.Path, p), "carp "+r.URL.Path)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestUserID returns the user ID from the request's token, taken from the Authorization header or
+// the auth_token cookie, or "" for anonymous requests.
+func requestUserID(r *http.Request) string {
+	tokenString := ""
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		tokenString = strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	} else if cookie, err := r.Cookie("auth_token"); err == nil {
+		tokenString = cookie.Value
+	}
+	if tokenString == "" {
+		return ""
+	}
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(dal.SECRET_KEY), nil
+	})
+	if err != nil || !token.Valid {
+		return ""
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	for _, claim := range []string{"uid", "sub"} {
+		if id, ok := claims[claim].(string); ok {
+			return id
+		}
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"cmpscfa23team2/dal"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPanicIsLoggedAndCounted(t *testing.T) {
+	var logged bytes.Buffer
+	defer func(l *slog.Logger) { accessLogger = l }(accessLogger)
+	accessLogger = slog.New(slog.NewJSONHandler(&logged, nil))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/test/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	handler := withRequestID(accessLog(instrument(mux, recoverPanics(mux))))
+
+	before := httpRequests.Value("/test/panic", http.MethodGet, "500")
+	r := httptest.NewRequest(http.MethodGet, "/test/panic", nil)
+	r.Header.Set(requestIDHeader, "panic-request")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	if got := rec.Header().Get(requestIDHeader); got != "panic-request" {
+		t.Errorf("%s = %q, want the request's", requestIDHeader, got)
+	}
+	if got := httpRequests.Value("/test/panic", http.MethodGet, "500"); got != before+1 {
+		t.Errorf("500 responses counted = %v, want %v", got, before+1)
+	}
+	var line struct {
+		Level     string `json:"level"`
+		RequestID string `json:"request_id"`
+		Path      string `json:"path"`
+		Status    int    `json:"status"`
+	}
+	if err := json.Unmarshal(logged.Bytes(), &line); err != nil {
+		t.Fatalf("access log %q: %v", logged.String(), err)
+	}
+	if line.Level != "ERROR" || line.RequestID != "panic-request" || line.Path != "/test/panic" || line.Status != 500 {
+		t.Errorf("access log line = %+v", line)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	handler := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = dal.RequestID(r.Context())
+	}))
+
+	for sent, keep := range map[string]bool{"abc-123": true, "": false, "bad id\n": false} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(requestIDHeader, sent)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		got := rec.Header().Get(requestIDHeader)
+		if got != seen || !validRequestID.MatchString(got) || (got == sent) != keep {
+			t.Errorf("request ID %q: echoed %q, context %q", sent, got, seen)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"cmpscfa23team2/dal"
+	"cmpscfa23team2/scheduler"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// scheduleStore adapts the dal schedules functions to the scheduler.Store interface.
+type scheduleStore struct{}
+
+// List loads every schedule from the schedules table.
+func (scheduleStore) List(ctx context.Context) ([]scheduler.Schedule, error) {
+	rows, err := dal.GetSchedules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]scheduler.Schedule, 0, len(rows))
+	for _, row := range rows {
+		s := scheduler.Schedule{
+			ID:        row.ScheduleID,
+			Name:      row.ScheduleName,
+			CronExpr:  row.CronExpr,
+			JobType:   row.JobType,
+			Priority:  row.Priority,
+			Enabled:   row.Enabled,
+			LastRun:   row.LastRun,
+			NextRun:   row.NextRun,
+			LastJobID: row.LastTaskID,
+		}
+		if row.Params != "" {
+			s.Params = json.RawMessage(row.Params)
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// Save creates or updates a schedule row.
+func (scheduleStore) Save(ctx context.Context, s scheduler.Schedule) error {
+	return dal.SaveSchedule(ctx, dal.ScheduleRow{
+		ScheduleID:   s.ID,
+		ScheduleName: s.Name,
+		CronExpr:     s.CronExpr,
+		JobType:      s.JobType,
+		Params:       string(s.Params),
+		Priority:     s.Priority,
+		Enabled:      s.Enabled,
+		LastRun:      s.LastRun,
+		NextRun:      s.NextRun,
+		LastTaskID:   s.LastJobID,
+	})
+}
+
+// Delete removes a schedule row.
+func (scheduleStore) Delete(ctx context.Context, id string) error {
+	err := dal.DeleteSchedule(ctx, id)
+	if err == sql.ErrNoRows {
+		return scheduler.ErrNotFound
+	}
+	return err
+}
+
+// schedulesHandler lists schedules (GET) and creates new ones (POST).
+func schedulesHandler(sched *scheduler.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			list, err := sched.List(r.Context())
+			if err != nil {
+				log.Printf("Error listing schedules: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, list)
+
+		case http.MethodPost:
+			s := scheduler.Schedule{Enabled: true}
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJobBody)).Decode(&s); err != nil {
+				http.Error(w, "Invalid schedule: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			created, err := sched.Add(r.Context(), s)
+			if errors.Is(err, scheduler.ErrExists) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeJSON(w, http.StatusCreated, created)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// scheduleHandler deletes a single schedule (DELETE /api/schedules/{id}).
+func scheduleHandler(sched *scheduler.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/schedules/"), "/")
+		if id == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		err := sched.Remove(r.Context(), id)
+		if errors.Is(err, scheduler.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			log.Printf("Error deleting schedule %s: %v", id, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
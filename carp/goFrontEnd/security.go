@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	csrfCookieName = "csrf_token"
+	csrfFormField  = "csrf_token"
+	csrfHeader     = "X-CSRF-Token"
+
+	// csrfTokenBytes is the amount of randomness in a CSRF token.
+	csrfTokenBytes = 32
+
+	// maxCSRFFormBytes caps the url-encoded forms read for their CSRF field.
+	maxCSRFFormBytes = 1 << 20
+
+	// csrfTokenLifetime is how long the CSRF cookie lives. The token is renewed whenever the cookie
+	// is missing, so an expired one only costs a reload of the form.
+	csrfTokenLifetime = 12 * time.Hour
+)
+
+// defaultCSP allows the scripts, styles and images of the templates: local files, inline blocks and
+// the CDNs they load libraries from.
+const defaultCSP = "default-src 'self'; " +
+	"script-src 'self' 'unsafe-inline' https://ajax.googleapis.com https://cdn.jsdelivr.net https://cdnjs.cloudflare.com https://code.jquery.com https://maxcdn.bootstrapcdn.com; " +
+	"style-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net https://maxcdn.bootstrapcdn.com https://cdnjs.cloudflare.com; " +
+	"font-src 'self' data: https://maxcdn.bootstrapcdn.com https://cdn.jsdelivr.net; " +
+	"img-src 'self' data: https:; " +
+	"connect-src 'self'; " +
+	"frame-ancestors 'none'; base-uri 'self'; form-action 'self'"
+
+// hstsHeader tells browsers to use HTTPS for a year; it is only sent when carp serves HTTPS.
+const hstsHeader = "max-age=31536000; includeSubDomains"
+
+// cookies are the cookie attributes of the running server, set from its config on startup.
+var cookies = defaultServerConfig().Cookies
+
+type csrfTokenKey struct{}
+
+// csrfToken returns the CSRF token of the request, which templates put in their forms.
+func csrfToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfTokenKey{}).(string)
+	return token
+}
+
+// newCSRFToken returns a random token.
+func newCSRFToken() (string, error) {
+	b := make([]byte, csrfTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// validCSRFToken reports whether a token has the format newCSRFToken produces.
+func validCSRFToken(token string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	return err == nil && len(b) == csrfTokenBytes
+}
+
+// csrfProtect guards state-changing requests with double-submit tokens. Every visitor gets a random
+// token in the csrf_token cookie, which templates copy into their forms and pages into the
+// X-CSRF-Token header of their requests. Another site can make a browser send the cookie, but cannot
+// read it to send it back, so a POST whose form field or header does not match the cookie is refused.
+// The form field is only read from url-encoded forms; multipart uploads and API requests send the header.
+//
+// Only requests a browser could be tricked into sending are checked: those carrying the auth_token
+// cookie and the plain form posts, which include logins. Clients authenticating with an API key or a
+// bearer token have no ambient credentials to abuse, and cross-site JSON requests need CORS approval.
+func csrfProtect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := ""
+		if cookie, err := r.Cookie(csrfCookieName); err == nil && validCSRFToken(cookie.Value) {
+			token = cookie.Value
+		}
+		if token == "" {
+			var err error
+			if token, err = newCSRFToken(); err != nil {
+				log.Printf("Error generating CSRF token: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			// Scripts read the token from the page, not the cookie, so it can be HTTP-only.
+			http.SetCookie(w, cookies.cookie(csrfCookieName, token, csrfTokenLifetime, true))
+		}
+
+		if needsCSRFCheck(r) {
+			sent := r.Header.Get(csrfHeader)
+			if sent == "" && isURLEncodedForm(r) {
+				// Only small url-encoded forms carry the token in a field; uploads and API requests must
+				// send the header, so that their bodies are not read before the handler limits them.
+				r.Body = http.MaxBytesReader(w, r.Body, maxCSRFFormBytes)
+				sent = r.PostFormValue(csrfFormField)
+			}
+			if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				log.Printf("Rejected %s %s: missing or invalid CSRF token", r.Method, r.URL.Path)
+				http.Error(w, "Invalid or missing CSRF token; reload the page and try again", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), csrfTokenKey{}, token)))
+	})
+}
+
+// needsCSRFCheck reports whether the request changes state and could have been forged by another
+// site, as described on csrfProtect.
+func needsCSRFCheck(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	if r.Header.Get(apiKeyHeader) != "" || strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		return false
+	}
+	if _, err := r.Cookie("auth_token"); err == nil {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-www-form-urlencoded", "multipart/form-data", "text/plain":
+		return true
+	}
+	return false
+}
+
+// requireAuth refuses requests that carry neither a valid user token nor a valid API key with 401
+// Unauthorized. It guards the API routes that start work on the server or expose its data.
+func requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if authorize(w, r) {
+			next(w, r)
+		}
+	}
+}
+
+// authorize reports whether the request carries a valid user token or API key, answering 401
+// Unauthorized when it does not. Handlers whose routes are only partly private call it directly.
+func authorize(w http.ResponseWriter, r *http.Request) bool {
+	if requestUserID(r) == "" && requestWebService(r) == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="carp"`)
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// isURLEncodedForm reports whether the request body is an application/x-www-form-urlencoded form.
+func isURLEncodedForm(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/x-www-form-urlencoded"
+}
+
+// securityHeaders sets the headers that keep browsers from framing the pages, sniffing content types
+// or loading scripts from unexpected hosts, and, over HTTPS, from falling back to HTTP.
+func securityHeaders(cfg serverConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		if cfg.CSP != "" && cfg.CSP != "off" {
+			h.Set("Content-Security-Policy", cfg.CSP)
+		}
+		if cfg.TLS() {
+			h.Set("Strict-Transport-Security", hstsHeader)
+		}
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// csrfFuncs returns the template functions that put the request's CSRF token in a page.
+func csrfFuncs(r *http.Request) template.FuncMap {
+	token := ""
+	if r != nil {
+		token = csrfToken(r)
+	}
+	return template.FuncMap{
+		"csrfToken": func() string { return token },
+		"csrfField": func() template.HTML {
+			return template.HTML(`<input type="hidden" name="` + csrfFormField + `" value="` + template.HTMLEscapeString(token) + `">`)
+		},
+	}
+}
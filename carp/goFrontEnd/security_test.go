@@ -0,0 +1,219 @@
+package main
+
+import (
+	"cmpscfa23team2/dal"
+	"github.com/dgrijalva/jwt-go"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// okHandler answers every request it is reached by with 200 OK.
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+// csrfCookie returns a csrf_token cookie holding a fresh token.
+func csrfCookie(t *testing.T) *http.Cookie {
+	t.Helper()
+	token, err := newCSRFToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Cookie{Name: csrfCookieName, Value: token}
+}
+
+// formPost returns a url-encoded POST of the given form.
+func formPost(form url.Values) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func TestCSRFProtect(t *testing.T) {
+	cookie := csrfCookie(t)
+	other := csrfCookie(t)
+	handler := csrfProtect(okHandler)
+
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		want    int
+	}{
+		{"form without token", func() *http.Request {
+			r := formPost(url.Values{"username": {"u"}})
+			r.AddCookie(cookie)
+			return r
+		}, http.StatusForbidden},
+		{"form without cookie", func() *http.Request {
+			return formPost(url.Values{csrfFormField: {cookie.Value}})
+		}, http.StatusForbidden},
+		{"form with mismatched token", func() *http.Request {
+			r := formPost(url.Values{csrfFormField: {other.Value}})
+			r.AddCookie(cookie)
+			return r
+		}, http.StatusForbidden},
+		{"form with matching token", func() *http.Request {
+			r := formPost(url.Values{csrfFormField: {cookie.Value}})
+			r.AddCookie(cookie)
+			return r
+		}, http.StatusOK},
+		{"header with matching token", func() *http.Request {
+			r := httptest.NewRequest(http.MethodPost, "/api/jobs", strings.NewReader(`{}`))
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set(csrfHeader, cookie.Value)
+			r.AddCookie(cookie)
+			r.AddCookie(&http.Cookie{Name: "auth_token", Value: "x"})
+			return r
+		}, http.StatusOK},
+		{"JSON with the auth cookie and no header", func() *http.Request {
+			r := httptest.NewRequest(http.MethodPost, "/api/jobs", strings.NewReader(`{}`))
+			r.Header.Set("Content-Type", "application/json")
+			r.AddCookie(cookie)
+			r.AddCookie(&http.Cookie{Name: "auth_token", Value: "x"})
+			return r
+		}, http.StatusForbidden},
+		{"multipart upload without header", func() *http.Request {
+			r := httptest.NewRequest(http.MethodPost, "/api/datasets", strings.NewReader("--b--"))
+			r.Header.Set("Content-Type", "multipart/form-data; boundary=b")
+			r.AddCookie(cookie)
+			return r
+		}, http.StatusForbidden},
+		{"JSON without ambient credentials", func() *http.Request {
+			r := httptest.NewRequest(http.MethodPost, "/api/jobs", strings.NewReader(`{}`))
+			r.Header.Set("Content-Type", "application/json")
+			return r
+		}, http.StatusOK},
+		{"form with an API key", func() *http.Request {
+			r := formPost(url.Values{"name": {"n"}})
+			r.Header.Set(apiKeyHeader, "key")
+			return r
+		}, http.StatusOK},
+		{"GET", func() *http.Request {
+			return httptest.NewRequest(http.MethodGet, "/login", nil)
+		}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, tt.request())
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestCSRFProtectSetsToken(t *testing.T) {
+	var seen string
+	handler := csrfProtect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = csrfToken(r)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	set := rec.Result().Cookies()
+	if len(set) != 1 || set[0].Name != csrfCookieName || set[0].Value != seen || !validCSRFToken(seen) {
+		t.Fatalf("cookies = %+v, handler saw token %q", set, seen)
+	}
+	if !set[0].HttpOnly || set[0].Path != "/" || set[0].MaxAge != int(csrfTokenLifetime.Seconds()) {
+		t.Errorf("CSRF cookie = %+v, want an HTTP-only cookie for / living %v", set[0], csrfTokenLifetime)
+	}
+
+	// A valid cookie is kept; an invalid one is replaced
+	cookie := csrfCookie(t)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	if len(rec.Result().Cookies()) != 0 || seen != cookie.Value {
+		t.Errorf("valid cookie: set %+v, handler saw %q, want %q", rec.Result().Cookies(), seen, cookie.Value)
+	}
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: "short"})
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	if set := rec.Result().Cookies(); len(set) != 1 || set[0].Value == "short" {
+		t.Errorf("invalid cookie replaced by %+v", set)
+	}
+}
+
+func TestCookieOptions(t *testing.T) {
+	c := cookieConfig{Secure: true, SameSite: http.SameSiteStrictMode, Domain: "example.com"}
+	got := c.cookie("auth_token", "value", time.Hour, true)
+	want := &http.Cookie{Name: "auth_token", Value: "value", Path: "/", Domain: "example.com", MaxAge: 3600,
+		Secure: true, HttpOnly: true, SameSite: http.SameSiteStrictMode}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cookie = %+v, want %+v", *got, *want)
+	}
+	if got := defaultServerConfig().Cookies.cookie("lang", "de", time.Minute, false); got.Secure || got.HttpOnly || got.SameSite != http.SameSiteLaxMode {
+		t.Errorf("default cookie = %+v, want a lax, insecure, script-readable cookie", *got)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	cfg := defaultServerConfig()
+	rec := httptest.NewRecorder()
+	securityHeaders(cfg, okHandler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	for header, want := range map[string]string{
+		"Content-Security-Policy":   defaultCSP,
+		"X-Frame-Options":           "DENY",
+		"X-Content-Type-Options":    "nosniff",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"Strict-Transport-Security": "",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	cfg.CSP = "off"
+	cfg.TLSCertFile, cfg.TLSKeyFile = "cert.pem", "key.pem"
+	rec = httptest.NewRecorder()
+	securityHeaders(cfg, okHandler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := rec.Header().Get("Content-Security-Policy"); got != "" {
+		t.Errorf("Content-Security-Policy with CSP off = %q", got)
+	}
+	if got := rec.Header().Get("Strict-Transport-Security"); got != hstsHeader {
+		t.Errorf("Strict-Transport-Security over HTTPS = %q, want %q", got, hstsHeader)
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	handler := requireAuth(okHandler)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/api/jobs", nil))
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("anonymous request: status = %d, WWW-Authenticate = %q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/jobs", nil)
+	r.Header.Set("Authorization", "Bearer not-a-token")
+	rec = httptest.NewRecorder()
+	handler(rec, r)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("invalid token: status = %d, want 401", rec.Code)
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"uid": "user-1"}).SignedString([]byte(dal.SECRET_KEY))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = httptest.NewRequest(http.MethodGet, "/api/jobs", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	handler(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Errorf("valid token: status = %d, want 200", rec.Code)
+	}
+	r = httptest.NewRequest(http.MethodGet, "/api/jobs", nil)
+	r.AddCookie(&http.Cookie{Name: "auth_token", Value: token})
+	rec = httptest.NewRecorder()
+	handler(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Errorf("valid token cookie: status = %d, want 200", rec.Code)
+	}
+}
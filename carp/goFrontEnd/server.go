@@ -6,13 +6,25 @@ package main
 // C:\Users\Public\GoLandProjects\PredictAi\carp\goFrontEnd
 
 import (
+	"cmpscfa23team2/charts"
 	"cmpscfa23team2/dal"
+	"cmpscfa23team2/datasets"
+	"cmpscfa23team2/events"
+	"cmpscfa23team2/jobs"
+	"cmpscfa23team2/metrics"
+	"cmpscfa23team2/pipeline"
+	"cmpscfa23team2/scheduler"
+	"context"
 	"encoding/json"
-	"html/template"
+	"errors"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 // PageData struct holds data for rendering HTML pages.
@@ -42,44 +54,161 @@ func setupServer() {
 	}
 	log.Println("Current directory:", dir)
 
-	files, err := filepath.Glob(filepath.Join(dir, "templates/*.gohtml"))
+	assetCfg := loadAssetConfig()
+	if assetCfg.Dev {
+		log.Println("Dev mode: serving templates and static files from", assetCfg.Dir)
+	}
+	assets := assetCfg.FS()
+	tmpl, err := newTemplateSet(assets, assetCfg.Dev)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatal("Parsing templates: ", err)
 	}
-	log.Println("Template files found:", files)
-
-	tmpl := template.Must(template.ParseGlob("templates/*.gohtml"))
+	pages = tmpl
 	log.Println("Templates loaded:", tmpl.DefinedTemplates())
-	setupRoutes(tmpl)
 
-	log.Println("Starting server on :8080")
-	err = http.ListenAndServe(":8080", nil)
+	cfg, err := loadServerConfig()
+	if err != nil {
+		log.Fatal("Server configuration: ", err)
+	}
+	cookies = cfg.Cookies
+	siteTheme = cfg.Theme
+	if translations, err = loadTranslations(assets, cfg.Language); err != nil {
+		log.Fatal("Message catalogs: ", err)
+	}
+	datasetCfg, err := loadDatasetConfig()
+	if err != nil {
+		log.Fatal("Dataset configuration: ", err)
+	}
+	datasetRegistry, err = datasets.NewRegistry(datasetStore{}, datasetCfg.Dir)
+	if err != nil {
+		log.Fatal("Dataset registry: ", err)
+	}
+	renderer, err := newChartRenderer()
+	if err != nil {
+		log.Fatal("Chart configuration: ", err)
+	}
+	limiter, err := newRateLimiter()
 	if err != nil {
-		log.Fatal("ListenAndServe: ", err)
+		log.Fatal("Rate limit configuration: ", err)
+	}
+
+	manager := newJobManager(jobs.DefaultConfig())
+	orchestrator := newOrchestrator()
+	manager.Register("pipeline", pipelineJob(orchestrator))
+	manager.RegisterValidator("pipeline", func(payload json.RawMessage) error {
+		_, err := parsePipelinePayload(payload)
+		return err
+	})
+	publishJobStates(manager, events.Default)
+	registerEngineMetrics(manager)
+	if err := manager.Start(); err != nil {
+		log.Fatal("Starting job manager: ", err)
+	}
+	sched := scheduler.New(scheduleStore{}, manager, scheduler.Options{})
+	if err := sched.Start(); err != nil {
+		log.Fatal("Starting scheduler: ", err)
+	}
+
+	// Requests inherit baseCtx, which is cancelled when shutdown begins so that long-lived
+	// streams such as /api/events end instead of holding up the drain.
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	server := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           middleware(setupRoutes(tmpl, staticFS(assets), manager, sched, orchestrator, datasetCfg, renderer), cfg, limiter),
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		BaseContext:       func(net.Listener) context.Context { return baseCtx },
+	}
+	server.RegisterOnShutdown(cancelBase)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if cfg.TLS() {
+			log.Printf("Starting server on %s (TLS)", cfg.Addr)
+			serverErr <- server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			log.Printf("Starting server on %s", cfg.Addr)
+			serverErr <- server.ListenAndServe()
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("ListenAndServe: %v", err)
+		}
+	case sig := <-signals:
+		log.Printf("Received %s, shutting down", sig)
+	}
+	signal.Stop(signals)
+	shutdown(server, cfg.ShutdownTimeout, sched, manager)
+}
+
+// shutdown stops accepting requests and waits up to timeout for in-flight ones, then stops the
+// scheduler so no new jobs are enqueued, stops the job workers and closes the database.
+func shutdown(server *http.Server, timeout time.Duration, sched *scheduler.Scheduler, manager *jobs.Manager) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Error draining requests: %v", err)
+		server.Close()
 	}
+	sched.Stop()
+	manager.Stop()
+	dal.CloseDb()
+	log.Println("Server stopped")
 }
 
-// setupRoutes configures routes for the web server.
-func setupRoutes(tmpl *template.Template) {
-	http.HandleFunc("/", makeHandler(tmpl, "login"))
-	http.HandleFunc("/home", makeHandler(tmpl, "home"))
-	http.HandleFunc("/about", makeHandler(tmpl, "about"))
-	http.HandleFunc("/contributors", makeHandler(tmpl, "contributors"))
-	//http.HandleFunc("/login", makeHandler(tmpl, "login"))
-	http.HandleFunc("/register", makeHandler(tmpl, "register"))
-	http.HandleFunc("/documentation", makeHandler(tmpl, "documentation"))
-	http.HandleFunc("/dashboard", func(w http.ResponseWriter, r *http.Request) {
+// setupRoutes registers the routes of the web server on a new router.
+func setupRoutes(tmpl *templateSet, static fs.FS, manager *jobs.Manager, sched *scheduler.Scheduler, orchestrator *pipeline.Orchestrator, datasetCfg datasetConfig, renderer *charts.Renderer) *http.ServeMux {
+	mux := http.NewServeMux()
+	history := predictionStore{}
+	mux.HandleFunc("/", makeHandler(tmpl, "login"))
+	mux.HandleFunc("/home", makeHandler(tmpl, "home"))
+	mux.HandleFunc("/about", makeHandler(tmpl, "about"))
+	mux.HandleFunc("/contributors", makeHandler(tmpl, "contributors"))
+	//mux.HandleFunc("/login", makeHandler(tmpl, "login"))
+	mux.HandleFunc("/register", makeHandler(tmpl, "register"))
+	mux.HandleFunc("/documentation", makeHandler(tmpl, "documentation"))
+	mux.HandleFunc("/dashboard", func(w http.ResponseWriter, r *http.Request) {
 		dashHandler(tmpl, w, r) // Invoking dashHandler correctly
 	})
-	//http.HandleFunc("/dashboard", requireAdmin(dashHandler(tmpl)))
-	//http.HandleFunc("/settings", requireAdmin(makeHandler(tmpl, "settings")))
-	http.HandleFunc("/api/predictions", predictionHandler)
-	fs := http.FileServer(http.Dir("static"))
-	http.Handle("/static/", http.StripPrefix("/static/", fs))
+	//mux.HandleFunc("/dashboard", requireAdmin(dashHandler(tmpl)))
+	//mux.HandleFunc("/settings", requireAdmin(makeHandler(tmpl, "settings")))
+	mux.HandleFunc("/history", makeHandler(tmpl, "history"))
+	mux.HandleFunc("/language", languageHandler)
+	mux.HandleFunc("/api/predictions", predictionHandler)
+	mux.HandleFunc("/api/predictions/history", predictionHistoryHandler(history))
+	mux.HandleFunc("/api/predictions/history/", predictionHistoryHandler(history))
+	mux.HandleFunc("/api/predictions/domains", predictionDomainsHandler(history))
+	mux.HandleFunc("/api/predictions/compare", predictionCompareHandler(history))
+	mux.HandleFunc("/api/predictions/export", predictionExportHandler(history))
+	mux.HandleFunc("/api/query", queryHandler(newQueryProvider()))
+	mux.HandleFunc("/api/jobs", requireAuth(jobsHandler(manager)))
+	mux.HandleFunc("/api/jobs/", requireAuth(jobHandler(manager)))
+	mux.HandleFunc("/api/events", requireAuth(eventsHandler(events.Default)))
+	mux.HandleFunc("/api/schedules", requireAuth(schedulesHandler(sched)))
+	mux.HandleFunc("/api/schedules/", requireAuth(scheduleHandler(sched)))
+	mux.HandleFunc("/api/datasets", requireAuth(datasetsHandler(datasetRegistry, datasetCfg)))
+	mux.HandleFunc("/api/datasets/", requireAuth(datasetHandler(datasetRegistry)))
+	mux.HandleFunc(chartsPath, chartsHandler(renderer, history, datasetRegistry))
+	mux.HandleFunc("/api/pipelines", requireAuth(pipelinesHandler(manager)))
+	mux.HandleFunc("/api/pipelines/runs", requireAuth(pipelineRunsHandler(orchestrator, manager)))
+	mux.HandleFunc("/api/pipelines/runs/", requireAuth(pipelineRunsHandler(orchestrator, manager)))
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", readyzHandler)
+	mux.Handle("/metrics", metrics.Default.Handler())
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(static))))
+	return mux
 }
 
 // makeHandler returns a handler function for rendering HTML pages.
-func makeHandler(tmpl *template.Template, content string) http.HandlerFunc {
+func makeHandler(tmpl *templateSet, content string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "POST" && content == "register" {
 			registerHandler(tmpl, w, r)
@@ -95,11 +224,11 @@ func makeHandler(tmpl *template.Template, content string) http.HandlerFunc {
 			Title   string
 			Content string
 		}{
-			Title:   "PredictAI - " + content,
+			Title:   siteTheme.Brand + " - " + content,
 			Content: content,
 		}
 
-		err := tmpl.ExecuteTemplate(w, "layout.gohtml", data)
+		err := tmpl.RenderPage(w, r, content, data)
 		if err != nil {
 			log.Printf("Error executing template: %v", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -107,7 +236,7 @@ func makeHandler(tmpl *template.Template, content string) http.HandlerFunc {
 	}
 }
 
-//func makeHandler(tmpl *template.Template, content string) http.HandlerFunc {
+//func makeHandler(tmpl *templateSet, content string) http.HandlerFunc {
 //	return func(w http.ResponseWriter, r *http.Request) {
 //		data := struct {
 //			Title   string
@@ -116,7 +245,7 @@ func makeHandler(tmpl *template.Template, content string) http.HandlerFunc {
 //			Title:   "PredictAI - " + content,
 //			Content: content,
 //		}
-//		err := tmpl.ExecuteTemplate(w, "layout.gohtml", data)
+//		err := tmpl.Render(w, r, "layout.gohtml", data)
 //		if err != nil {
 //			log.Printf("Error executing template: %v", err)
 //			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -125,30 +254,25 @@ func makeHandler(tmpl *template.Template, content string) http.HandlerFunc {
 //}
 
 // loginHandler handles user login requests.
-func loginHandler(tmpl *template.Template, w http.ResponseWriter, r *http.Request) {
+func loginHandler(tmpl *templateSet, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html")
 
 	switch r.Method {
 	case "GET":
-		renderLoginTemplate(tmpl, w, "")
+		renderLoginTemplate(tmpl, w, r, "")
 
 	case "POST":
 		email := r.FormValue("email")
 		password := r.FormValue("password")
 
-		token, err := dal.AuthenticateUser(email, password)
+		token, err := dal.AuthenticateUser(r.Context(), email, password)
 		if err != nil {
-			renderLoginTemplate(tmpl, w, "Invalid email or password")
+			renderLoginTemplate(tmpl, w, r, "Invalid email or password")
 			return
 		}
 
-		// Set the authentication token in a cookie
-		http.SetCookie(w, &http.Cookie{
-			Name:     "auth_token",
-			Value:    token,
-			Path:     "/",
-			HttpOnly: true,
-		})
+		// Set the authentication token in a cookie that expires with the token
+		http.SetCookie(w, cookies.cookie("auth_token", token, dal.TokenLifetime, true))
 
 		// Redirect to the dashboard or home page
 		http.Redirect(w, r, "/home", http.StatusSeeOther)
@@ -159,12 +283,12 @@ func loginHandler(tmpl *template.Template, w http.ResponseWriter, r *http.Reques
 }
 
 // renderLoginTemplate renders the login page template.
-func renderLoginTemplate(tmpl *template.Template, w http.ResponseWriter, errorMessage string) {
+func renderLoginTemplate(tmpl *templateSet, w http.ResponseWriter, r *http.Request, errorMessage string) {
 	data := PageData{
 		Title:        "Login",
 		ErrorMessage: errorMessage,
 	}
-	err := tmpl.ExecuteTemplate(w, "login", data)
+	err := tmpl.Render(w, r, "login", data)
 	if err != nil {
 		log.Printf("Error executing template: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -172,13 +296,13 @@ func renderLoginTemplate(tmpl *template.Template, w http.ResponseWriter, errorMe
 }
 
 // registerHandler handles user registration requests.
-func registerHandler(tmpl *template.Template, w http.ResponseWriter, r *http.Request) {
+func registerHandler(tmpl *templateSet, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html")
 
 	switch r.Method {
 	case "GET":
 		data := RegistrationPageData{Title: "Register"}
-		err := tmpl.ExecuteTemplate(w, "register", data)
+		err := tmpl.Render(w, r, "register", data)
 		if err != nil {
 			log.Printf("Error executing register template: %v", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -201,7 +325,7 @@ func registerHandler(tmpl *template.Template, w http.ResponseWriter, r *http.Req
 
 		// Check if passwords match
 		if password != confirmPassword {
-			tmpl.ExecuteTemplate(w, "register", RegistrationPageData{
+			tmpl.Render(w, r, "register", RegistrationPageData{
 				Title:        "Register",
 				ErrorMessage: "Passwords do not match",
 			})
@@ -213,9 +337,9 @@ func registerHandler(tmpl *template.Template, w http.ResponseWriter, r *http.Req
 		active := true       // Set to false if you require email verification, etc.
 
 		// Call DAL function to register user
-		_, err := dal.RegisterUser(username, email, defaultRole, password, active)
+		_, err := dal.RegisterUser(r.Context(), username, email, defaultRole, password, active)
 		if err != nil {
-			tmpl.ExecuteTemplate(w, "register", RegistrationPageData{
+			tmpl.Render(w, r, "register", RegistrationPageData{
 				Title:        "Register",
 				ErrorMessage: "Registration failed: " + err.Error(),
 			})
@@ -259,13 +383,13 @@ func predictionHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // renderDashboardTemplate renders the dashboard with a potential error message.
-func renderDashboardTemplate(tmpl *template.Template, w http.ResponseWriter, users []*dal.User, errorMessage string) {
+func renderDashboardTemplate(tmpl *templateSet, w http.ResponseWriter, r *http.Request, users []*dal.User, errorMessage string) {
 	data := PageData{
 		Title:        "Dashboard",
 		Users:        users,
 		ErrorMessage: errorMessage,
 	}
-	err := tmpl.ExecuteTemplate(w, "dashboard", data)
+	err := tmpl.Render(w, r, "dashboard", data)
 	if err != nil {
 		log.Printf("Error executing dashboard template: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -273,26 +397,22 @@ func renderDashboardTemplate(tmpl *template.Template, w http.ResponseWriter, use
 }
 
 // dashHandler handles requests for the dashboard page.
-func dashHandler(tmpl *template.Template, w http.ResponseWriter, r *http.Request) {
+func dashHandler(tmpl *templateSet, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html")
-	log.Printf("beginning of dashHandler\n")
-	users, err := dal.GetAllUsers()
+	users, err := dal.GetAllUsers(r.Context())
 	if err != nil {
 		log.Printf("Error fetching users: %v", err)
 		http.Error(w, "Unable to fetch user data", http.StatusInternalServerError)
 		return
 	}
-	log.Printf("before being passed to template: %+v\n")
 
 	data := PageData{
-		Title: "Dashboard",
-		Users: users,
-		// Ensure you have this if you are conditionally displaying templates within "layout.gohtml"
+		Title:   "Dashboard",
+		Users:   users,
 		Content: "dashboard",
 	}
-	log.Printf("PageData being passed to template: %+v\n", data)
 
-	err = tmpl.ExecuteTemplate(w, "layout.gohtml", data) // Execute "layout", not "dashboard"
+	err = tmpl.RenderPage(w, r, "dashboard", data)
 	if err != nil {
 		log.Printf("Error executing template: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
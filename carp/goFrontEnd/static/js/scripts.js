@@ -1,4 +1,10 @@
 $(document).ready(function() {
+  // Send the CSRF token of the page with every AJAX request; the server refuses unsafe requests without it.
+  const csrfToken = $('meta[name="csrf-token"]').attr('content');
+  if (csrfToken) {
+    $.ajaxSetup({ headers: { 'X-CSRF-Token': csrfToken } });
+  }
+
   const $domainSelect = $('#domainSelect');
   const $querySelect = $('#querySelect');
   const domainToQueries = {
@@ -27,6 +33,7 @@ $(document).ready(function() {
       type: 'GET',
       success: function(response) {
         $('#predictionResult').empty();
+        $('#explanationOutput').empty();
         displayPredictionResults(domain, queryType, response);
       },
       error: function(xhr, status, error) {
@@ -35,6 +42,34 @@ $(document).ready(function() {
     });
   });
 
+  $('#askForm').submit(function(event) {
+    event.preventDefault();
+
+    const question = $('#questionInput').val().trim();
+    if (!question) {
+      return;
+    }
+
+    $.ajax({
+      url: '/api/query',
+      type: 'POST',
+      contentType: 'application/json',
+      data: JSON.stringify({ question: question }),
+      success: function(response) {
+        const intent = response.intent;
+        // Show the prepared query the question was matched to
+        $domainSelect.val(intent.domain).change();
+        $querySelect.val(intent.query);
+        displayPredictionResults(intent.domain, intent.query, response.prediction);
+        $('#explanationOutput').text(response.explanation);
+      },
+      error: function(xhr, status, error) {
+        $('#explanationOutput').empty();
+        $('#predictionResult').text(xhr.responseText || `Error: ${error}`);
+      }
+    });
+  });
+
   function displayPredictionResults(domain, queryType, response) {
     // Clear previous results for both predictions and keywords
     $('#predictionResult').empty();
@@ -0,0 +1,115 @@
+package charts
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+)
+
+// DefaultCacheSize is the number of images a Renderer keeps when none is given.
+const DefaultCacheSize = 128
+
+// Image is a rendered chart.
+type Image struct {
+	Key    string // content hash of the chart and options, usable as an ETag
+	Format Format
+	Data   []byte
+	Cached bool // served from the cache rather than rendered
+}
+
+// Key returns the content hash that identifies the image of c rendered with opts.
+func Key(c Chart, opts Options) (string, error) {
+	opts, err := opts.Normalize()
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(struct {
+		Kind    Kind    `json:"kind"`
+		Chart   Chart   `json:"chart"`
+		Options Options `json:"options"`
+	}{c.Kind(), c, opts})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Renderer renders charts and keeps the most recently used images in memory, keyed by content hash,
+// so the same chart is drawn only once.
+type Renderer struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // of *Image, most recently used first
+	entries map[string]*list.Element
+}
+
+// NewRenderer creates a renderer that caches up to size images. A size of 0 uses DefaultCacheSize and
+// a negative size disables caching.
+func NewRenderer(size int) *Renderer {
+	if size == 0 {
+		size = DefaultCacheSize
+	}
+	return &Renderer{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// Render returns the image of c, from the cache if it has been rendered before.
+func (r *Renderer) Render(c Chart, opts Options) (Image, error) {
+	opts, err := opts.Normalize()
+	if err != nil {
+		return Image{}, err
+	}
+	key, err := Key(c, opts)
+	if err != nil {
+		return Image{}, err
+	}
+	if img, ok := r.get(key); ok {
+		img.Cached = true
+		return img, nil
+	}
+	data, err := Render(c, opts)
+	if err != nil {
+		return Image{}, err
+	}
+	img := Image{Key: key, Format: opts.Format, Data: data}
+	r.put(img)
+	return img, nil
+}
+
+// Len returns the number of cached images.
+func (r *Renderer) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.order.Len()
+}
+
+func (r *Renderer) get(key string) (Image, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[key]
+	if !ok {
+		return Image{}, false
+	}
+	r.order.MoveToFront(e)
+	return *e.Value.(*Image), true
+}
+
+func (r *Renderer) put(img Image) {
+	if r.size < 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.entries[img.Key]; ok {
+		r.order.MoveToFront(e)
+		return
+	}
+	r.entries[img.Key] = r.order.PushFront(&img)
+	for r.order.Len() > r.size {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.entries, oldest.Value.(*Image).Key)
+	}
+}
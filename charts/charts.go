@@ -0,0 +1,147 @@
+// Package charts renders the prediction charts served by carp: scatter plots with a regression line,
+// KNN neighborhoods and bar charts such as the Naive Bayes skill demand. Charts are drawn with
+// gonum/plot on demand as PNG or SVG, and a Renderer caches the output by a hash of its content.
+package charts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// Format is an image format charts can be rendered in.
+type Format string
+
+const (
+	PNG Format = "png"
+	SVG Format = "svg"
+)
+
+// ParseFormat parses a format name or file extension. An empty name means PNG.
+func ParseFormat(s string) (Format, error) {
+	switch strings.TrimPrefix(strings.ToLower(s), ".") {
+	case "", "png":
+		return PNG, nil
+	case "svg":
+		return SVG, nil
+	}
+	return "", fmt.Errorf("unknown chart format %q", s)
+}
+
+// ContentType returns the MIME type of the format.
+func (f Format) ContentType() string {
+	if f == SVG {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+// Kind names a type of chart.
+type Kind string
+
+const (
+	KindScatter   Kind = "scatter"
+	KindNeighbors Kind = "neighbors"
+	KindBars      Kind = "bars"
+)
+
+// Size limits, in pixels.
+const (
+	DefaultWidth  = 720
+	DefaultHeight = 480
+	MaxSize       = 2400
+)
+
+// ErrInvalid is returned for charts or options that cannot be rendered.
+var ErrInvalid = errors.New("invalid chart")
+
+// Chart is a chart that can be rendered. Its exported fields describe it completely, so two charts
+// that encode to the same JSON render the same image.
+type Chart interface {
+	Kind() Kind
+	plot() (*plot.Plot, error)
+}
+
+// Options control how a chart is rendered.
+type Options struct {
+	Format Format `json:"format"`
+	Width  int    `json:"width"`  // pixels
+	Height int    `json:"height"` // pixels
+}
+
+// Normalize fills in defaults and checks the size.
+func (o Options) Normalize() (Options, error) {
+	if o.Format == "" {
+		o.Format = PNG
+	}
+	if o.Format != PNG && o.Format != SVG {
+		return o, fmt.Errorf("%w: unknown format %q", ErrInvalid, o.Format)
+	}
+	if o.Width == 0 {
+		o.Width = DefaultWidth
+	}
+	if o.Height == 0 {
+		o.Height = DefaultHeight
+	}
+	if o.Width < 100 || o.Height < 100 || o.Width > MaxSize || o.Height > MaxSize {
+		return o, fmt.Errorf("%w: size must be between 100 and %d pixels", ErrInvalid, MaxSize)
+	}
+	return o, nil
+}
+
+// Render draws a chart and encodes it in the requested format.
+func Render(c Chart, opts Options) ([]byte, error) {
+	opts, err := opts.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	p, err := c.plot()
+	if err != nil {
+		return nil, err
+	}
+	// Images are drawn at 96 dpi, so one pixel is 1/96 inch.
+	w, err := p.WriterTo(vg.Length(opts.Width)*vg.Inch/96, vg.Length(opts.Height)*vg.Inch/96, string(opts.Format))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if _, err := w.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode parses the JSON description of a chart of the given kind.
+func Decode(kind Kind, data []byte) (Chart, error) {
+	var c Chart
+	switch kind {
+	case KindScatter:
+		c = &Scatter{}
+	case KindNeighbors:
+		c = &Neighbors{}
+	case KindBars:
+		c = &Bars{}
+	default:
+		return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalid, kind)
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return c, nil
+}
+
+// newPlot creates a plot with the common title and axis labels.
+func newPlot(title, xLabel, yLabel string) *plot.Plot {
+	p := plot.New()
+	p.Title.Text = title
+	p.X.Label.Text = xLabel
+	p.Y.Label.Text = yLabel
+	p.Add(plotter.NewGrid())
+	return p
+}
@@ -0,0 +1,147 @@
+package charts
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cmpscfa23team2/datasets"
+)
+
+// DefaultTopBars is the number of bars a dataset bar chart shows when Top is not set.
+const DefaultTopBars = 20
+
+// Columns selects the dataset columns a chart is drawn from.
+type Columns struct {
+	X          string // x values of scatter and neighbors charts
+	Y          string // y values, or the values summed per label of a bar chart
+	Label      string // class of neighbors points, or the bars of a bar chart
+	Target     *Point // the KNN target of a neighbors chart
+	K          int    // neighbors to highlight
+	Top        int    // bars to keep, largest first
+	Regression bool   // draw the regression line of a scatter chart
+}
+
+// FromDataset builds a chart of the given kind from the records of a dataset. Records whose selected
+// values are missing or not numeric are skipped.
+func FromDataset(kind Kind, d datasets.Dataset, records []datasets.Record, cols Columns) (Chart, error) {
+	title := fmt.Sprintf("%s v%d", d.Name, d.Version)
+	switch kind {
+	case KindScatter:
+		if cols.X == "" || cols.Y == "" {
+			return nil, fmt.Errorf("%w: a scatter chart needs x and y columns", ErrInvalid)
+		}
+		s := Scatter{Title: title, XLabel: cols.X, YLabel: cols.Y, Regression: cols.Regression}
+		for _, record := range records {
+			if x, y, ok := recordXY(record, cols); ok {
+				s.Points = append(s.Points, Point{X: x, Y: y})
+			}
+		}
+		if len(s.Points) == 0 {
+			return nil, ErrNoData
+		}
+		s.Regression = s.Regression && len(s.Points) > 1
+		return s, nil
+
+	case KindNeighbors:
+		if cols.X == "" || cols.Y == "" || cols.Target == nil {
+			return nil, fmt.Errorf("%w: a neighbors chart needs x and y columns and a target", ErrInvalid)
+		}
+		n := Neighbors{Title: title, XLabel: cols.X, YLabel: cols.Y, Target: *cols.Target, K: cols.K}
+		for _, record := range records {
+			if x, y, ok := recordXY(record, cols); ok {
+				n.Points = append(n.Points, LabeledPoint{X: x, Y: y, Label: recordText(record[cols.Label])})
+			}
+		}
+		if len(n.Points) == 0 {
+			return nil, ErrNoData
+		}
+		return n, nil
+
+	case KindBars:
+		if cols.Label == "" {
+			return nil, fmt.Errorf("%w: a bar chart needs a label column", ErrInvalid)
+		}
+		b := Bars{Title: title, XLabel: cols.Label, YLabel: "Count"}
+		if cols.Y != "" {
+			b.YLabel = cols.Y
+		}
+		totals := make(map[string]float64)
+		for _, record := range records {
+			label := recordText(record[cols.Label])
+			if label == "" {
+				continue
+			}
+			value := 1.0
+			if cols.Y != "" {
+				v, ok := recordNumber(record[cols.Y])
+				if !ok {
+					continue
+				}
+				value = v
+			}
+			totals[label] += value
+		}
+		for label, total := range totals {
+			b.Bars = append(b.Bars, Bar{Label: label, Value: total})
+		}
+		if len(b.Bars) == 0 {
+			return nil, ErrNoData
+		}
+		sortBars(b.Bars)
+		top := cols.Top
+		if top <= 0 {
+			top = DefaultTopBars
+		}
+		if len(b.Bars) > top {
+			b.Bars = b.Bars[:top]
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalid, kind)
+}
+
+// sortBars orders bars by value, largest first, and then by label.
+func sortBars(bars []Bar) {
+	sort.Slice(bars, func(i, j int) bool {
+		if bars[i].Value != bars[j].Value {
+			return bars[i].Value > bars[j].Value
+		}
+		return bars[i].Label < bars[j].Label
+	})
+}
+
+func recordXY(record datasets.Record, cols Columns) (x, y float64, ok bool) {
+	x, okX := recordNumber(record[cols.X])
+	y, okY := recordNumber(record[cols.Y])
+	return x, y, okX && okY
+}
+
+// recordNumber reads a numeric record value. Text such as "$1,234.50" or "12%" is accepted.
+func recordNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		return v, true
+	case string:
+		s := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(v), "$"), "%")
+		f, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+		return f, err == nil && finite(f)
+	}
+	return 0, false
+}
+
+func recordText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
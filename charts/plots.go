@@ -0,0 +1,283 @@
+package charts
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// DefaultNeighbors is the number of neighbors highlighted when a Neighbors chart does not set K.
+const DefaultNeighbors = 3
+
+// highlight is the color of predicted values and KNN targets.
+var highlight = color.RGBA{R: 220, G: 53, B: 69, A: 255}
+
+// Point is a point of a chart.
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// LabeledPoint is a point with the class label it belongs to.
+type LabeledPoint struct {
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Label string  `json:"label"`
+}
+
+// Bar is one bar of a bar chart.
+type Bar struct {
+	Label string  `json:"label"`
+	Value float64 `json:"value"`
+}
+
+// Scatter is a scatter plot, optionally with its least squares regression line and a predicted point.
+type Scatter struct {
+	Title      string  `json:"title"`
+	XLabel     string  `json:"x_label"`
+	YLabel     string  `json:"y_label"`
+	Points     []Point `json:"points"`
+	Regression bool    `json:"regression"`
+	Prediction *Point  `json:"prediction,omitempty"`
+}
+
+// Kind implements Chart.
+func (Scatter) Kind() Kind { return KindScatter }
+
+func (s Scatter) plot() (*plot.Plot, error) {
+	if len(s.Points) == 0 {
+		return nil, fmt.Errorf("%w: no points", ErrInvalid)
+	}
+	for _, pt := range s.Points {
+		if !finite(pt.X, pt.Y) {
+			return nil, fmt.Errorf("%w: points must be finite numbers", ErrInvalid)
+		}
+	}
+	p := newPlot(s.Title, s.XLabel, s.YLabel)
+	scatter, err := plotter.NewScatter(xys(s.Points))
+	if err != nil {
+		return nil, err
+	}
+	scatter.GlyphStyle.Color = plotutil.Color(0)
+	scatter.GlyphStyle.Radius = vg.Points(2.5)
+	p.Add(scatter)
+	p.Legend.Add("Data", scatter)
+
+	if s.Regression {
+		slope, intercept, err := LinearFit(s.Points)
+		if err != nil {
+			return nil, err
+		}
+		minX, maxX := s.Points[0].X, s.Points[0].X
+		for _, pt := range s.Points {
+			minX, maxX = math.Min(minX, pt.X), math.Max(maxX, pt.X)
+		}
+		if s.Prediction != nil {
+			minX, maxX = math.Min(minX, s.Prediction.X), math.Max(maxX, s.Prediction.X)
+		}
+		line, err := plotter.NewLine(plotter.XYs{{X: minX, Y: slope*minX + intercept}, {X: maxX, Y: slope*maxX + intercept}})
+		if err != nil {
+			return nil, err
+		}
+		line.LineStyle.Color = plotutil.Color(1)
+		line.LineStyle.Width = vg.Points(1.5)
+		p.Add(line)
+		p.Legend.Add(fmt.Sprintf("y = %.4gx %+.4g", slope, intercept), line)
+	}
+
+	if s.Prediction != nil {
+		if !finite(s.Prediction.X, s.Prediction.Y) {
+			return nil, fmt.Errorf("%w: the prediction must be finite", ErrInvalid)
+		}
+		predicted, err := plotter.NewScatter(xys([]Point{*s.Prediction}))
+		if err != nil {
+			return nil, err
+		}
+		predicted.GlyphStyle = draw.GlyphStyle{Color: highlight, Radius: vg.Points(5), Shape: draw.PyramidGlyph{}}
+		p.Add(predicted)
+		p.Legend.Add("Prediction", predicted)
+	}
+	p.Legend.Top = true
+	p.Legend.Left = true
+	return p, nil
+}
+
+// Neighbors shows a KNN neighborhood: the labeled points by class, the target, and lines from the
+// target to its K nearest neighbors.
+type Neighbors struct {
+	Title  string         `json:"title"`
+	XLabel string         `json:"x_label"`
+	YLabel string         `json:"y_label"`
+	Points []LabeledPoint `json:"points"`
+	Target Point          `json:"target"`
+	K      int            `json:"k"`
+}
+
+// Kind implements Chart.
+func (Neighbors) Kind() Kind { return KindNeighbors }
+
+func (n Neighbors) plot() (*plot.Plot, error) {
+	if len(n.Points) == 0 {
+		return nil, fmt.Errorf("%w: no points", ErrInvalid)
+	}
+	if !finite(n.Target.X, n.Target.Y) {
+		return nil, fmt.Errorf("%w: the target must be finite", ErrInvalid)
+	}
+	classes := make(map[string][]Point)
+	for _, pt := range n.Points {
+		if !finite(pt.X, pt.Y) {
+			return nil, fmt.Errorf("%w: points must be finite numbers", ErrInvalid)
+		}
+		classes[pt.Label] = append(classes[pt.Label], Point{X: pt.X, Y: pt.Y})
+	}
+	k := n.K
+	if k <= 0 {
+		k = DefaultNeighbors
+	}
+	if k > len(n.Points) {
+		k = len(n.Points)
+	}
+
+	p := newPlot(n.Title, n.XLabel, n.YLabel)
+	for _, pt := range Nearest(n.Points, n.Target, k) {
+		line, err := plotter.NewLine(plotter.XYs{{X: n.Target.X, Y: n.Target.Y}, {X: pt.X, Y: pt.Y}})
+		if err != nil {
+			return nil, err
+		}
+		line.LineStyle.Color = color.Gray{Y: 160}
+		line.LineStyle.Dashes = []vg.Length{vg.Points(3), vg.Points(3)}
+		p.Add(line)
+	}
+
+	labels := make([]string, 0, len(classes))
+	for label := range classes {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for i, label := range labels {
+		scatter, err := plotter.NewScatter(xys(classes[label]))
+		if err != nil {
+			return nil, err
+		}
+		scatter.GlyphStyle.Color = plotutil.Color(i)
+		scatter.GlyphStyle.Shape = plotutil.Shape(i)
+		scatter.GlyphStyle.Radius = vg.Points(3)
+		p.Add(scatter)
+		if label == "" {
+			label = "Data"
+		}
+		p.Legend.Add(label, scatter)
+	}
+
+	target, err := plotter.NewScatter(xys([]Point{n.Target}))
+	if err != nil {
+		return nil, err
+	}
+	target.GlyphStyle = draw.GlyphStyle{Color: highlight, Radius: vg.Points(5), Shape: draw.PyramidGlyph{}}
+	p.Add(target)
+	p.Legend.Add(fmt.Sprintf("Target (k=%d)", k), target)
+	p.Legend.Top = true
+	p.Legend.Left = true
+	return p, nil
+}
+
+// Bars is a bar chart with one bar per label, in the given order.
+type Bars struct {
+	Title  string `json:"title"`
+	XLabel string `json:"x_label"`
+	YLabel string `json:"y_label"`
+	Bars   []Bar  `json:"bars"`
+}
+
+// Kind implements Chart.
+func (Bars) Kind() Kind { return KindBars }
+
+func (b Bars) plot() (*plot.Plot, error) {
+	if len(b.Bars) == 0 {
+		return nil, fmt.Errorf("%w: no bars", ErrInvalid)
+	}
+	values := make(plotter.Values, len(b.Bars))
+	labels := make([]string, len(b.Bars))
+	for i, bar := range b.Bars {
+		if !finite(bar.Value, 0) {
+			return nil, fmt.Errorf("%w: bar values must be finite numbers", ErrInvalid)
+		}
+		values[i] = bar.Value
+		labels[i] = bar.Label
+	}
+
+	p := newPlot(b.Title, b.XLabel, b.YLabel)
+	chart, err := plotter.NewBarChart(values, vg.Points(18))
+	if err != nil {
+		return nil, err
+	}
+	chart.Color = plotutil.Color(0)
+	chart.LineStyle.Width = 0
+	p.Add(chart)
+	p.NominalX(labels...)
+	if len(labels) > 6 {
+		p.X.Tick.Label.Rotation = math.Pi / 4
+		p.X.Tick.Label.XAlign = draw.XRight
+		p.X.Tick.Label.YAlign = draw.YCenter
+	}
+	return p, nil
+}
+
+// LinearFit returns the slope and intercept of the least squares line through points.
+func LinearFit(points []Point) (slope, intercept float64, err error) {
+	if len(points) < 2 {
+		return 0, 0, fmt.Errorf("%w: a regression line needs at least two points", ErrInvalid)
+	}
+	var meanX, meanY float64
+	for _, pt := range points {
+		meanX += pt.X
+		meanY += pt.Y
+	}
+	meanX /= float64(len(points))
+	meanY /= float64(len(points))
+	var sxy, sxx float64
+	for _, pt := range points {
+		sxy += (pt.X - meanX) * (pt.Y - meanY)
+		sxx += (pt.X - meanX) * (pt.X - meanX)
+	}
+	if sxx == 0 {
+		return 0, 0, fmt.Errorf("%w: a regression line needs points with different x values", ErrInvalid)
+	}
+	slope = sxy / sxx
+	return slope, meanY - slope*meanX, nil
+}
+
+// Nearest returns the k points closest to target by Euclidean distance, nearest first.
+func Nearest(points []LabeledPoint, target Point, k int) []LabeledPoint {
+	sorted := append([]LabeledPoint(nil), points...)
+	distance := func(pt LabeledPoint) float64 { return math.Hypot(pt.X-target.X, pt.Y-target.Y) }
+	sort.SliceStable(sorted, func(i, j int) bool { return distance(sorted[i]) < distance(sorted[j]) })
+	if k < len(sorted) {
+		sorted = sorted[:k]
+	}
+	return sorted
+}
+
+func xys(points []Point) plotter.XYs {
+	out := make(plotter.XYs, len(points))
+	for i, pt := range points {
+		out[i].X, out[i].Y = pt.X, pt.Y
+	}
+	return out
+}
+
+func finite(values ...float64) bool {
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
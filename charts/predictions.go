@@ -0,0 +1,159 @@
+package charts
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"cmpscfa23team2/predictions"
+)
+
+// ErrNoData is returned when a prediction holds nothing that can be charted.
+var ErrNoData = errors.New("the prediction has no chartable data")
+
+var (
+	yearPattern   = regexp.MustCompile(`\b(1[89]|2[01])\d{2}\b`)
+	amountPattern = regexp.MustCompile(`\$\s*([0-9][0-9,]*(?:\.[0-9]+)?)`)
+)
+
+// ForPrediction builds the chart of a stored KNN or linear regression prediction: the regression
+// scatter of its input data, or the neighborhood listed in its prediction info. Naive Bayes
+// predictions point to a result file and are charted by the caller.
+func ForPrediction(r predictions.Record) (Chart, error) {
+	switch r.Algorithm {
+	case "LinearRegression":
+		points := TuplePoints(r.InputData)
+		if len(points) == 0 {
+			return nil, ErrNoData
+		}
+		s := Scatter{Title: r.QueryIdentifier, XLabel: axisLabel(points), YLabel: "Price", Points: points, Regression: len(points) > 1}
+		if value, ok := predictions.PredictedValue(r.PredictionInfo); ok {
+			if year, ok := lastYear(r.QueryIdentifier); ok {
+				s.Prediction = &Point{X: year, Y: value}
+			}
+		}
+		return s, nil
+
+	case "KNN":
+		var n Neighbors
+		n.Title = r.QueryIdentifier
+		for _, pt := range TuplePoints(r.InputData) {
+			n.Points = append(n.Points, LabeledPoint{X: pt.X, Y: pt.Y, Label: "Data"})
+		}
+		var target *Point
+		for _, line := range strings.Split(r.PredictionInfo, "\n") {
+			if strings.Contains(strings.ToLower(line), "predicted") {
+				target = predictedPoint(line, r.QueryIdentifier)
+				continue
+			}
+			if pt, ok := rowPoint(line); ok {
+				n.Points = append(n.Points, LabeledPoint{X: pt.X, Y: pt.Y, Label: "Nearest"})
+				n.K++
+			}
+		}
+		if target == nil || len(n.Points) == 0 {
+			return nil, ErrNoData
+		}
+		n.Target = *target
+		if n.K == 0 {
+			n.K = DefaultNeighbors
+		}
+		n.XLabel, n.YLabel = axisLabel(points(n.Points)), "Price"
+		return n, nil
+	}
+	return nil, ErrNoData
+}
+
+// TuplePoints reads points from input data such as "(1978, 51.9, 0.652),(1979, 70.2, 0.882)": the
+// first value of each tuple is x and the last is y. Tuples that are not numeric are skipped.
+func TuplePoints(input string) []Point {
+	var out []Point
+	for _, item := range predictions.InputItems(input) {
+		if !strings.HasPrefix(item, "(") {
+			continue
+		}
+		fields := strings.Split(strings.Trim(item, "()"), ",")
+		if len(fields) < 2 {
+			continue
+		}
+		x, errX := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+		y, errY := strconv.ParseFloat(strings.TrimSpace(fields[len(fields)-1]), 64)
+		if errX == nil && errY == nil {
+			out = append(out, Point{X: x, Y: y})
+		}
+	}
+	return out
+}
+
+// rowPoint reads a table row such as "2022    $4.19   347.747" as the point (2022, 4.19).
+func rowPoint(line string) (Point, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return Point{}, false
+	}
+	var values [2]float64
+	for i := range values {
+		v, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimPrefix(fields[i], "$"), ",", ""), 64)
+		if err != nil {
+			return Point{}, false
+		}
+		values[i] = v
+	}
+	return Point{X: values[0], Y: values[1]}, true
+}
+
+// predictedPoint reads the target of a KNN prediction from a line such as "Predicted Target Price
+// closest to Year 2023: $3.82", falling back on the year in the query.
+func predictedPoint(line, query string) *Point {
+	amount := amountPattern.FindStringSubmatch(line)
+	if amount == nil {
+		return nil
+	}
+	y, err := strconv.ParseFloat(strings.ReplaceAll(amount[1], ",", ""), 64)
+	if err != nil {
+		return nil
+	}
+	x, ok := firstYear(line)
+	if !ok {
+		if x, ok = lastYear(query); !ok {
+			return nil
+		}
+	}
+	return &Point{X: x, Y: y}
+}
+
+func firstYear(s string) (float64, bool) {
+	if year := yearPattern.FindString(s); year != "" {
+		v, err := strconv.ParseFloat(year, 64)
+		return v, err == nil
+	}
+	return 0, false
+}
+
+func lastYear(s string) (float64, bool) {
+	years := yearPattern.FindAllString(s, -1)
+	if len(years) == 0 {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(years[len(years)-1], 64)
+	return v, err == nil
+}
+
+// axisLabel labels the x axis "Year" when every x value is a whole year.
+func axisLabel(pts []Point) string {
+	for _, pt := range pts {
+		if pt.X != float64(int(pt.X)) || pt.X < 1800 || pt.X > 2199 {
+			return ""
+		}
+	}
+	return "Year"
+}
+
+func points(labeled []LabeledPoint) []Point {
+	out := make([]Point, len(labeled))
+	for i, pt := range labeled {
+		out[i] = Point{X: pt.X, Y: pt.Y}
+	}
+	return out
+}
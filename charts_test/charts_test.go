@@ -0,0 +1,196 @@
+package charts_test
+
+import (
+	"bytes"
+	"cmpscfa23team2/charts"
+	"cmpscfa23team2/datasets"
+	"cmpscfa23team2/predictions"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestLinearFit(t *testing.T) {
+	slope, intercept, err := charts.LinearFit([]charts.Point{{X: 1, Y: 3}, {X: 2, Y: 5}, {X: 3, Y: 7}})
+	if err != nil || math.Abs(slope-2) > 1e-9 || math.Abs(intercept-1) > 1e-9 {
+		t.Errorf("LinearFit = %v, %v, %v; want 2, 1", slope, intercept, err)
+	}
+	if _, _, err := charts.LinearFit([]charts.Point{{X: 1, Y: 1}, {X: 1, Y: 2}}); !errors.Is(err, charts.ErrInvalid) {
+		t.Errorf("LinearFit(vertical) error = %v, want ErrInvalid", err)
+	}
+}
+
+func TestNearest(t *testing.T) {
+	points := []charts.LabeledPoint{{X: 10, Y: 10, Label: "far"}, {X: 1, Y: 1, Label: "near"}, {X: 2, Y: 2, Label: "next"}}
+	got := charts.Nearest(points, charts.Point{}, 2)
+	if len(got) != 2 || got[0].Label != "near" || got[1].Label != "next" {
+		t.Errorf("Nearest = %+v", got)
+	}
+	if points[0].Label != "far" {
+		t.Error("Nearest reordered its input")
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		chart charts.Chart
+		json  string
+	}{
+		{charts.Scatter{Title: "Gas", Points: []charts.Point{{X: 1978, Y: 0.652}, {X: 1979, Y: 0.882}, {X: 1980, Y: 1.221}}, Regression: true, Prediction: &charts.Point{X: 2023, Y: 4.34}},
+			`{"points": [{"x": 1978, "y": 0.652}, {"x": 1979, "y": 0.882}], "regression": true}`},
+		{charts.Neighbors{Points: []charts.LabeledPoint{{X: 1, Y: 1, Label: "gas"}, {X: 2, Y: 3, Label: "airfare"}}, Target: charts.Point{X: 1.5, Y: 2}, K: 1},
+			`{"points": [{"x": 1, "y": 1, "label": "gas"}], "target": {"x": 1, "y": 2}}`},
+		{charts.Bars{Title: "Skill demand", Bars: []charts.Bar{{Label: "Java", Value: 4}, {Label: "Go", Value: 2}}},
+			`{"bars": [{"label": "Java", "value": 4}]}`},
+	}
+	for _, test := range tests {
+		png, err := charts.Render(test.chart, charts.Options{Width: 320, Height: 240})
+		if err != nil {
+			t.Fatalf("Render(%s) error: %v", test.chart.Kind(), err)
+		}
+		if !bytes.HasPrefix(png, []byte("\x89PNG")) {
+			t.Errorf("Render(%s) did not return a PNG", test.chart.Kind())
+		}
+		svg, err := charts.Render(test.chart, charts.Options{Format: charts.SVG})
+		if err != nil || !bytes.Contains(svg, []byte("<svg")) {
+			t.Errorf("Render(%s, SVG) = %.40q, %v", test.chart.Kind(), svg, err)
+		}
+
+		decoded, err := charts.Decode(test.chart.Kind(), []byte(test.json))
+		if err != nil {
+			t.Fatalf("Decode(%s) error: %v", test.chart.Kind(), err)
+		}
+		if _, err := charts.Render(decoded, charts.Options{}); err != nil {
+			t.Errorf("Render(decoded %s) error: %v", test.chart.Kind(), err)
+		}
+	}
+
+	for _, bad := range []struct {
+		chart charts.Chart
+		opts  charts.Options
+	}{
+		{charts.Scatter{}, charts.Options{}},
+		{charts.Bars{Bars: []charts.Bar{{Label: "x", Value: math.NaN()}}}, charts.Options{}},
+		{charts.Scatter{Points: []charts.Point{{X: 1, Y: 1}}}, charts.Options{Width: charts.MaxSize + 1}},
+		{charts.Scatter{Points: []charts.Point{{X: 1, Y: 1}}}, charts.Options{Format: "gif"}},
+	} {
+		if _, err := charts.Render(bad.chart, bad.opts); !errors.Is(err, charts.ErrInvalid) {
+			t.Errorf("Render(%+v, %+v) error = %v, want ErrInvalid", bad.chart, bad.opts, err)
+		}
+	}
+	if _, err := charts.Decode("pie", []byte(`{}`)); !errors.Is(err, charts.ErrInvalid) {
+		t.Errorf("Decode(pie) error = %v, want ErrInvalid", err)
+	}
+}
+
+func TestRendererCache(t *testing.T) {
+	renderer := charts.NewRenderer(2)
+	chart := func(y float64) charts.Chart {
+		return charts.Scatter{Points: []charts.Point{{X: 1, Y: y}}}
+	}
+
+	first, err := renderer.Render(chart(1), charts.Options{})
+	if err != nil || first.Cached || len(first.Key) != 64 {
+		t.Fatalf("first render = %q, cached %v, %v", first.Key, first.Cached, err)
+	}
+	again, err := renderer.Render(chart(1), charts.Options{Format: charts.PNG, Width: charts.DefaultWidth})
+	if err != nil || !again.Cached || again.Key != first.Key || !bytes.Equal(again.Data, first.Data) {
+		t.Errorf("same chart = %q, cached %v, %v; want the cached %q", again.Key, again.Cached, err, first.Key)
+	}
+	svg, _ := renderer.Render(chart(1), charts.Options{Format: charts.SVG})
+	if svg.Cached || svg.Key == first.Key {
+		t.Error("SVG rendering shared the PNG cache entry")
+	}
+
+	// A third image evicts the least recently used one.
+	renderer.Render(chart(2), charts.Options{})
+	if renderer.Len() != 2 {
+		t.Errorf("cache holds %d images, want 2", renderer.Len())
+	}
+	if img, _ := renderer.Render(chart(1), charts.Options{}); img.Cached {
+		t.Error("evicted image was served from the cache")
+	}
+}
+
+func TestForPrediction(t *testing.T) {
+	lr := predictions.Record{
+		Algorithm:       "LinearRegression",
+		QueryIdentifier: "Gas Prices Prediction 2023",
+		InputData:       "(1978.000000, 51.900000, 0.652000),(1979.000000, 70.200000, 0.882000),(1980.000000, 97.500000, 1.221000)",
+		PredictionInfo:  "our linear regression model predicts that gas prices in the year 2023 is anticipated to be: $4.34",
+	}
+	chart, err := charts.ForPrediction(lr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scatter, ok := chart.(charts.Scatter)
+	if !ok || len(scatter.Points) != 3 || scatter.Points[0] != (charts.Point{X: 1978, Y: 0.652}) || !scatter.Regression ||
+		scatter.Prediction == nil || *scatter.Prediction != (charts.Point{X: 2023, Y: 4.34}) || scatter.XLabel != "Year" {
+		t.Errorf("linear regression chart = %+v", chart)
+	}
+
+	knn := predictions.Record{
+		Algorithm:       "KNN",
+		QueryIdentifier: "Gas prices target prediction for years similar to 2023 prediction",
+		PredictionInfo:  "Nearest Years:\nYear    Price   CPI\n2022    $4.19   347.747\n2012    $3.69   311.470\n\nPredicted Target Price closest to Year 2023: $3.82, Year: 2015",
+	}
+	chart, err = charts.ForPrediction(knn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := charts.Neighbors{
+		Title:  knn.QueryIdentifier,
+		XLabel: "Year",
+		YLabel: "Price",
+		Points: []charts.LabeledPoint{{X: 2022, Y: 4.19, Label: "Nearest"}, {X: 2012, Y: 3.69, Label: "Nearest"}},
+		Target: charts.Point{X: 2023, Y: 3.82},
+		K:      2,
+	}
+	if !reflect.DeepEqual(chart, want) {
+		t.Errorf("KNN chart = %+v, want %+v", chart, want)
+	}
+
+	for _, r := range []predictions.Record{
+		{Algorithm: "LinearRegression", QueryIdentifier: "Gas Prices Prediction 2024", PredictionInfo: "$4.59"},
+		{Algorithm: "NaiveBayes", PredictionInfo: "Nbc_output/Law_top_jobs.json"},
+	} {
+		if _, err := charts.ForPrediction(r); !errors.Is(err, charts.ErrNoData) {
+			t.Errorf("ForPrediction(%s %q) error = %v, want ErrNoData", r.Algorithm, r.QueryIdentifier, err)
+		}
+	}
+}
+
+func TestFromDataset(t *testing.T) {
+	d := datasets.Dataset{Name: "jobs", Version: 2}
+	records := []datasets.Record{
+		{"skill": "Java", "salary": "$120,000"},
+		{"skill": "Go", "salary": json.Number("150000")},
+		{"skill": "Java", "salary": "n/a"},
+		{"skill": "Java", "salary": 100000.0},
+		{"skill": "", "salary": "1"},
+	}
+	chart, err := charts.FromDataset(charts.KindBars, d, records, charts.Columns{Label: "skill"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bars := chart.(charts.Bars).Bars; !reflect.DeepEqual(bars, []charts.Bar{{Label: "Java", Value: 3}, {Label: "Go", Value: 1}}) {
+		t.Errorf("counted bars = %+v", bars)
+	}
+
+	chart, err = charts.FromDataset(charts.KindBars, d, records, charts.Columns{Label: "skill", Y: "salary", Top: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bars := chart.(charts.Bars).Bars; !reflect.DeepEqual(bars, []charts.Bar{{Label: "Java", Value: 220000}}) {
+		t.Errorf("summed bars = %+v", bars)
+	}
+
+	if _, err := charts.FromDataset(charts.KindScatter, d, records, charts.Columns{X: "skill", Y: "salary"}); !errors.Is(err, charts.ErrNoData) {
+		t.Errorf("scatter of text column error = %v, want ErrNoData", err)
+	}
+	if _, err := charts.FromDataset(charts.KindNeighbors, d, records, charts.Columns{X: "a", Y: "b"}); !errors.Is(err, charts.ErrInvalid) {
+		t.Errorf("neighbors without target error = %v, want ErrInvalid", err)
+	}
+}
@@ -26,7 +26,7 @@ func main() {
 
 	//begin scraper
 	fmt.Println("Available domains:")
-	for domainName := range domainConfigurations {
+	for _, domainName := range Domains.Names() {
 		fmt.Printf("- %s\n", domainName)
 	}
 
@@ -36,7 +36,7 @@ func main() {
 	fmt.Scanln(&domainName)
 
 	// Check if the chosen domain is valid
-	_, exists := domainConfigurations[domainName]
+	_, exists := GetDomainConfig(domainName)
 	if !exists {
 		fmt.Printf("Invalid domain name provided: %s\n", domainName)
 		return
@@ -1,21 +1,26 @@
 package crab
 
 import (
+	"cmpscfa23team2/canonical"
+	"cmpscfa23team2/events"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gocolly/colly"
-	"github.com/temoto/robotstxt"
-	"io/ioutil"
 	"log"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
-// InitializeCrawling starts the web crawling process. It first fetches URLs to crawl from a predefined list,
-// and then initiates a threaded crawl process with a specified number of concurrent crawlers.
+// InitializeCrawling starts the web crawling process. It first fetches URLs to crawl from the seed sites
+// and their sitemaps, and then initiates a threaded crawl process with a specified number of concurrent crawlers.
 func InitializeCrawling() {
 	log.Println("Fetching URLs to crawl...")
 	urlDataList := GetURLsToCrawl()
@@ -23,16 +28,52 @@ func InitializeCrawling() {
 	ThreadedCrawl(urlDataList, 10)
 }
 
-// / getURLsToCrawl returns a slice of URLData representing a list of URLs to be crawled.
-// This function is used internally within the InitializeCrawling function.
+// defaultSeeds are the URLs crawled when CRAB_SEEDS does not list others.
+var defaultSeeds = []string{
+	"https://www.kaggle.com/search?q=housing+prices",
+	"http://books.toscrape.com/",
+	"https://www.kaggle.com/search?q=stocks",
+	"https://www.kaggle.com/search?q=stock+market",
+	"https://www.kaggle.com/search?q=real+estate",
+}
+
+// maxSitemapSeeds is the number of pages GetURLsToCrawl takes from the sitemaps of each site.
+const maxSitemapSeeds = 20
+
+// GetURLsToCrawl returns the seed URLs, listed in CRAB_SEEDS separated by spaces or commas or else the
+// default ones, each followed by the first pages the sitemaps of its site list, those with the highest
+// priority and the most recent changes. Every URL is returned once.
 func GetURLsToCrawl() []URLData {
-	return []URLData{
-		{URL: "https://www.kaggle.com/search?q=housing+prices"},
-		{URL: "http://books.toscrape.com/"},
-		{URL: "https://www.kaggle.com/search?q=stocks"},
-		{URL: "https://www.kaggle.com/search?q=stock+market"},
-		{URL: "https://www.kaggle.com/search?q=real+estate"},
+	seeds := defaultSeeds
+	if value := os.Getenv("CRAB_SEEDS"); strings.TrimSpace(value) != "" {
+		seeds = strings.Fields(strings.ReplaceAll(value, ",", " "))
+	}
+	reader := NewSitemapReader(DefaultPoliteness)
+	var urls []URLData
+	seen := make(map[string]bool)
+	add := func(link string) {
+		if c := canonical.URL(link); !seen[c] {
+			seen[c] = true
+			urls = append(urls, URLData{URL: link})
+		}
+	}
+	sites := make(map[string]bool)
+	for _, seed := range seeds {
+		add(seed)
+		u, err := url.Parse(seed)
+		if err != nil || u.Host == "" || sites[u.Scheme+"://"+u.Host] {
+			continue
+		}
+		sites[u.Scheme+"://"+u.Host] = true
+		pages, err := reader.ReadSite(seed)
+		if err != nil {
+			log.Printf("Error reading the sitemaps of %s: %v", seed, err)
+		}
+		for _, page := range pages[:min(len(pages), maxSitemapSeeds)] {
+			add(page.Loc)
+		}
 	}
+	return urls
 }
 
 // InsertData takes structured data (ItemData) and a filename, marshals the data into JSON format,
@@ -50,32 +91,76 @@ func InsertData(data ItemData, filename string) error {
 	return nil
 }
 
+// InsertSeries writes a scraped time series to filename as JSON.
+func InsertSeries(data SeriesData, filename string) error {
+	jsonData, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, jsonData, 0644)
+}
+
 // crawlURL is the core function responsible for crawling a single URL. It takes URLData, a channel to send
 // crawled data, and a WaitGroup to handle concurrency. It uses the Colly library for crawling and processes
 // each URL based on the received HTML content.
 func CrawlURL(urlData URLData, ch chan<- URLData, wg *sync.WaitGroup) {
+	crawlURL(urlData, ch, wg, events.Default)
+}
+
+// crawlURL is CrawlURL reporting visited pages and errors to pub.
+func crawlURL(urlData URLData, ch chan<- URLData, wg *sync.WaitGroup, pub events.Publisher) {
 	defer wg.Done() // Ensure the WaitGroup counter is decremented on function exit
-	c := colly.NewCollector(
-		colly.UserAgent(GetRandomUserAgent()), // Set a random user agent
-		colly.AllowURLRevisit(),               // Allow URL revisit
-	)
+	ch <- fetchPage(urlData, DefaultPoliteness, pub)
+}
+
+// fetchPage fetches the page of urlData through politeness and returns it with the links and structured
+// data found on it. Links and the canonical URL of the page are returned in canonical form.
+func fetchPage(urlData URLData, politeness *Politeness, pub events.Publisher) URLData {
+	c := politeness.collector(colly.AllowURLRevisit())
 
 	// Handler for errors during the crawl
 	c.OnError(func(r *colly.Response, err error) {
+		if r != nil && r.StatusCode != 0 {
+			recordResponse(&urlData, r)
+		}
 		fmt.Printf("Error occurred while crawling %s: %s\n", urlData.URL, err)
+		crawlerErrors.Inc()
+		pub.Publish(events.Event{Type: events.Error, URL: urlData.URL, Message: err.Error()})
 	})
 
+	// Keep the products, articles and job postings the page describes in structured data
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		data := ExtractStructuredData(e.DOM)
+		if data.Canonical != "" {
+			urlData.Canonical = canonical.URL(e.Request.AbsoluteURL(data.Canonical))
+		}
+		urlData.Items = append(urlData.Items, data.Items(e.Request.URL.String())...)
+		urlData.Jobs = append(urlData.Jobs, data.Jobs(e.Request.URL.String())...)
+	})
+
+	seen := make(map[string]bool)
 	c.OnHTML("a[href]", func(e *colly.HTMLElement) {
 		link := e.Request.AbsoluteURL(e.Attr("href"))
-		urlData.Links = append(urlData.Links, link)
-		urlQueue <- link
+		if link == "" {
+			return
+		}
+		link = canonical.URL(link)
+		if !seen[link] {
+			seen[link] = true
+			urlData.Links = append(urlData.Links, link)
+		}
 	})
 
 	// Handler for successful HTTP responses
 	c.OnResponse(func(r *colly.Response) {
+		recordResponse(&urlData, r)
+		crawlerPages.Inc(strconv.Itoa(r.StatusCode))
+		pub.Publish(events.Event{
+			Type: events.URLVisited,
+			URL:  urlData.URL,
+			Data: map[string]interface{}{"status": r.StatusCode, "bytes": len(r.Body)},
+		})
 		if r.StatusCode == 200 {
-			// Successful crawl, process the response here
-			ch <- urlData // Send the URLData to the channel
 			fmt.Printf("Crawled URL: %s\n", urlData.URL)
 		} else {
 			// Handle cases where the status code is not 200
@@ -85,33 +170,60 @@ func CrawlURL(urlData URLData, ch chan<- URLData, wg *sync.WaitGroup) {
 
 	// Start the crawl
 	c.Visit(urlData.URL)
+	return urlData
+}
 
-	ch <- urlData
+// recordResponse keeps the status, content type and dates of the response to the page of urlData.
+func recordResponse(urlData *URLData, r *colly.Response) {
+	urlData.Status = r.StatusCode
+	urlData.Created = time.Now().UTC()
+	if r.Headers == nil {
+		return
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Headers.Get("Content-Type")); err == nil {
+		urlData.ContentType = mediaType
+	}
+	if modified, err := http.ParseTime(r.Headers.Get("Last-Modified")); err == nil {
+		urlData.LastModified = modified.UTC()
+	}
 }
 
-// createSiteMap generates a sitemap from the given slice of URLData. Each URLData contains links found
-// at a specific URL. The function marshals this data into JSON format and writes it to a file named "siteMap.json".
-// It returns an error if the marshaling or file operations fail.
+// createSiteMap writes the link graph of the given slice of URLData, the pages of a crawl and the links found
+// on them, to each of the files listed in CRAB_GRAPH_OUTPUT, separated by commas, or to siteMap.json. The
+// extension of a file picks its format: .json for the links of every page, .xml for a sitemaps.org sitemap,
+// .graphml, .dot or .gv for graph tools and .csv for an edge list. It returns the errors of the files it
+// could not write.
 func CreateSiteMap(urls []URLData) error {
-	siteMap := make(map[string][]string)
-	for _, u := range urls {
-		siteMap[u.URL] = u.Links
+	filenames := []string{DefaultGraphOutput}
+	if value := os.Getenv("CRAB_GRAPH_OUTPUT"); strings.TrimSpace(value) != "" {
+		filenames = nil
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				filenames = append(filenames, name)
+			}
+		}
 	}
+	return WriteLinkGraph(urls, filenames...)
+}
 
-	jsonData, err := json.Marshal(siteMap)
-	err = ioutil.WriteFile("siteMap.json", jsonData, 0644)
-	if err != nil {
-		log.Printf("Error writing sitemap to file: %v\n", err)
-		return err
+// WriteLinkGraph writes the link graph of the crawled pages to each of filenames, in the format of its
+// extension.
+func WriteLinkGraph(urls []URLData, filenames ...string) error {
+	graph := NewLinkGraph(urls)
+	var errs []error
+	for _, filename := range filenames {
+		if err := graph.WriteFile(filename); err != nil {
+			log.Printf("Error writing link graph to %s: %v\n", filename, err)
+			errs = append(errs, err)
+			continue
+		}
+		log.Printf("Link graph written to %s.", filename)
 	}
-
-	log.Println("Sitemap created successfully.")
-	return nil
+	return errors.Join(errs...)
 }
 
-// isURLAllowedByRobotsTXT checks if the given URL is allowed by the site's robots.txt file.
-// It parses the URL to extract the domain, fetches the robots.txt file from the domain, and tests
-// if the URL is allowed. It returns true if allowed, false otherwise.
+// isURLAllowedByRobotsTXT checks if the given URL is allowed by the site's robots.txt file, for the agent
+// of DefaultIdentity. robots.txt is fetched over the scheme of the URL and cached by DefaultPoliteness.
 func IsURLAllowedByRobotsTXT(urlStr string) bool {
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil {
@@ -123,70 +235,40 @@ func IsURLAllowedByRobotsTXT(urlStr string) bool {
 		log.Println("Invalid URL, no host found:", urlStr)
 		return false
 	}
-
-	robotsURL := "http://" + parsedURL.Host + "/robots.txt"
-
-	resp, err := http.Get(robotsURL)
-	if err != nil {
-		log.Println("Error fetching robots.txt:", err)
-		return true
-	}
-
-	data, err := robotstxt.FromResponse(resp)
-	if err != nil {
-		log.Println("Error parsing robots.txt:", err)
-		return true
-	}
-
-	return data.TestAgent(urlStr, "GoEngine")
+	return DefaultPoliteness.allowed(parsedURL)
 }
 
 //end robot.txt ========================================================================================================
 
 // threadedCrawl manages the concurrent crawling of multiple URLs. It takes a slice of URLData and
-// an integer specifying the number of concurrent crawlers. The function sets up each crawler with rate limiting
-// and starts the crawling process. The resulting crawled data is used to create a sitemap.
-func ThreadedCrawl(urls []URLData, concurrentCrawlers int) {
-	var wg sync.WaitGroup
-	ch := make(chan URLData, len(urls))
+// an integer specifying the number of concurrent crawlers, and crawls each URL once without following
+// its links. The resulting crawled data is used to create a sitemap and is returned to the caller.
+func ThreadedCrawl(urls []URLData, concurrentCrawlers int) []URLData {
+	return ThreadedCrawlWithEvents(urls, concurrentCrawlers, events.Default)
+}
 
-	rateLimitRule := &colly.LimitRule{
-		DomainGlob:  "*",             // Apply to all domains
-		Delay:       5 * time.Second, // Wait 5 seconds between requests
-		RandomDelay: 5 * time.Second, // Add up to 5 seconds of random delay
+// ThreadedCrawlWithEvents is ThreadedCrawl reporting every visited page and every error to pub, so
+// callers such as job workers can tag the events with their own ID.
+func ThreadedCrawlWithEvents(urls []URLData, concurrentCrawlers int, pub events.Publisher) []URLData {
+	config := CrawlConfig{Name: "threaded-crawl", Concurrency: max(concurrentCrawlers, 1), Scope: ScopeAny}
+	frontier, err := NewFrontier(config, NewMemoryFrontierStore())
+	if err != nil {
+		log.Println("Error starting the crawl:", err)
+		return nil
 	}
-
-	log.Println("Starting crawling...")
 	for _, urlData := range urls {
-		wg.Add(1)
-
-		go func(u URLData) {
-			c := colly.NewCollector(
-				colly.UserAgent(GetRandomUserAgent()),
-			)
-			c.Limit(rateLimitRule) // Set the rate limit rule
-
-			CrawlURL(u, ch, &wg)
-		}(urlData)
-
-		log.Println("Crawling URL:", urlData.URL)
-		if len(urls) >= concurrentCrawlers {
-			break
+		if _, err := frontier.AddSeed(urlData.URL); err != nil {
+			log.Println("Skipping seed:", err)
 		}
 	}
 
-	log.Println("Waiting for crawlers to finish...")
-	go func() {
-		wg.Wait()
-		close(ch)
-		log.Println("All goroutines finished, channel closed.")
-	}()
-
-	var crawledURLs []URLData
-	for urlData := range ch {
-		crawledURLs = append(crawledURLs, urlData)
+	log.Println("Starting crawling...")
+	crawledURLs, err := frontier.Run(context.Background(), pub)
+	if err != nil {
+		log.Println("Error crawling:", err)
 	}
 	if err := CreateSiteMap(crawledURLs); err != nil {
 		log.Println("Error creating sitemap:", err)
 	}
+	return crawledURLs
 }
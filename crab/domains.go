@@ -0,0 +1,396 @@
+package crab
+
+import (
+	"bytes"
+	"cmpscfa23team2/jsonpath"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/andybalholm/cascadia"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// builtinDomains holds the domain configurations shipped with crab. Files in the directory named by
+// CRAB_DOMAIN_DIR are read on top of them, so sites can be added or changed without recompiling.
+//
+//go:embed domains
+var builtinDomains embed.FS
+
+// FieldType says how the value of a field is built from the elements its selector matches.
+type FieldType string
+
+const (
+	FieldText   FieldType = "text"   // text of all matches, or the attribute of the first match
+	FieldURL    FieldType = "url"    // attribute (href by default) of the first match, made absolute
+	FieldNumber FieldType = "number" // text or attribute of the first match parsed as a number
+	FieldList   FieldType = "list"   // text or attribute of every match
+	FieldMap    FieldType = "map"    // "key<separator>value" lines of every match
+)
+
+// domainNamePattern restricts domain names to what can safely appear in output file names.
+var domainNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Formats of the pages a domain is scraped from.
+const (
+	FormatHTML = "html" // items are elements matched by a CSS selector
+	FormatJSON = "json" // items are values matched by a JSONPath, for JSON APIs
+)
+
+// FieldConfig declares one field of a scraped item. Its value is found with at most one of Selector,
+// XPath, Regex and JSONPath, relative to the item; without any the item itself is used. Filters are
+// applied to every value in order, as "name" or "name:argument": trim, regex, number and date.
+type FieldConfig struct {
+	Name      string    `json:"name" yaml:"name"`
+	Selector  string    `json:"selector,omitempty" yaml:"selector,omitempty"` // CSS selector
+	XPath     string    `json:"xpath,omitempty" yaml:"xpath,omitempty"`
+	Regex     string    `json:"regex,omitempty" yaml:"regex,omitempty"`         // matched against the text; first capture group
+	JSONPath  string    `json:"jsonpath,omitempty" yaml:"jsonpath,omitempty"`   // JSON domains only
+	Page      bool      `json:"page,omitempty" yaml:"page,omitempty"`           // match against the whole page, e.g. for meta tags
+	Attr      string    `json:"attr,omitempty" yaml:"attr,omitempty"`           // attribute to read instead of the text
+	Type      FieldType `json:"type,omitempty" yaml:"type,omitempty"`           // defaults to text
+	Separator string    `json:"separator,omitempty" yaml:"separator,omitempty"` // map fields only; defaults to ": "
+	Filters   []string  `json:"filters,omitempty" yaml:"filters,omitempty"`
+}
+
+// DomainConfig declares how to scrape one site: where to start, which elements are items and which
+// fields to extract from each of them. For JSON domains ItemSelector is a JSONPath. Structured domains
+// also, or only, collect the items pages describe in JSON-LD, microdata and OpenGraph tags. Table
+// domains read a time series out of HTML tables with ScrapeSeries.
+type DomainConfig struct {
+	Name         string           `json:"name" yaml:"name"`
+	Format       string           `json:"format,omitempty" yaml:"format,omitempty"` // html (default) or json
+	StartURLs    []string         `json:"start_urls,omitempty" yaml:"start_urls,omitempty"`
+	ItemSelector string           `json:"item_selector,omitempty" yaml:"item_selector,omitempty"`
+	Fields       []FieldConfig    `json:"fields,omitempty" yaml:"fields,omitempty"`
+	Structured   bool             `json:"structured,omitempty" yaml:"structured,omitempty"`
+	Table        *TableConfig     `json:"table,omitempty" yaml:"table,omitempty"`
+	Pagination   PaginationConfig `json:"pagination,omitempty" yaml:"pagination,omitempty"`
+}
+
+// genericFields are the field names stored in the GenericData struct fields rather than in its
+// Fields map, with the types they accept.
+var genericFields = map[string][]FieldType{
+	"title":                     {FieldText, FieldNumber},
+	"url":                       {FieldURL, FieldText},
+	"description":               {FieldText, FieldNumber},
+	"price":                     {FieldText, FieldNumber},
+	"factors":                   {FieldList},
+	"depreciation_rates":        {FieldMap},
+	"models_least_depreciation": {FieldList},
+	"models_most_depreciation":  {FieldList},
+}
+
+// Validate checks that the configuration is complete, that its selectors, expressions and filters
+// compile and that every field has a known type that fits where its value is stored.
+func (d DomainConfig) Validate() error {
+	var errs []error
+	if !domainNamePattern.MatchString(d.Name) {
+		errs = append(errs, fmt.Errorf("invalid name %q: use lower-case letters, digits, - and _", d.Name))
+	}
+	for _, u := range d.StartURLs {
+		if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("start URL %q is not an absolute http(s) URL", u))
+		}
+	}
+	if d.Format != "" && d.Format != FormatHTML && d.Format != FormatJSON {
+		errs = append(errs, fmt.Errorf("unknown format %q", d.Format))
+	}
+	switch {
+	case d.Structured && d.format() == FormatJSON:
+		errs = append(errs, errors.New("structured data is read from HTML pages only"))
+	case d.Table != nil && d.format() == FormatJSON:
+		errs = append(errs, errors.New("tables are read from HTML pages only"))
+	case d.ItemSelector == "" && !d.Structured && d.Table == nil:
+		errs = append(errs, errors.New("item_selector is required"))
+	case d.ItemSelector != "":
+		if err := d.checkSelector(d.ItemSelector); err != nil {
+			errs = append(errs, fmt.Errorf("item_selector: %v", err))
+		}
+	}
+	if len(d.Fields) == 0 && d.ItemSelector != "" {
+		errs = append(errs, errors.New("at least one field is required"))
+	}
+	if len(d.Fields) > 0 && d.ItemSelector == "" {
+		errs = append(errs, errors.New("fields need an item_selector"))
+	}
+	seen := make(map[string]bool)
+	for i, f := range d.Fields {
+		if _, err := compileField(d.format(), f); err != nil {
+			errs = append(errs, fmt.Errorf("field %d (%s): %w", i+1, f.Name, err))
+		}
+		if seen[f.Name] {
+			errs = append(errs, fmt.Errorf("field %q declared twice", f.Name))
+		}
+		seen[f.Name] = true
+	}
+	if d.Pagination.NextSelector != "" {
+		if err := d.checkSelector(d.Pagination.NextSelector); err != nil {
+			errs = append(errs, fmt.Errorf("pagination.next_selector: %v", err))
+		}
+	}
+	if d.Table != nil {
+		if err := d.Table.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("table: %w", err))
+		}
+	}
+	if err := d.Pagination.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("pagination: %w", err))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("domain %q: %w", d.Name, err)
+	}
+	return nil
+}
+
+// fieldType returns the type of the field, text when none is set.
+func (f FieldConfig) fieldType() FieldType {
+	if f.Type == "" {
+		return FieldText
+	}
+	return f.Type
+}
+
+func hasFieldType(types []FieldType, t FieldType) bool {
+	for _, candidate := range types {
+		if candidate == t {
+			return true
+		}
+	}
+	return false
+}
+
+// format returns the format of the domain, html when none is set.
+func (d DomainConfig) format() string {
+	if d.Format == "" {
+		return FormatHTML
+	}
+	return d.Format
+}
+
+// checkSelector reports whether sel is a CSS selector goquery can use or, for JSON domains, a JSONPath.
+func (d DomainConfig) checkSelector(sel string) error {
+	if d.format() == FormatJSON {
+		_, err := jsonpath.Compile(sel)
+		return err
+	}
+	if _, err := cascadia.ParseGroup(sel); err != nil {
+		return fmt.Errorf("invalid selector %q: %v", sel, err)
+	}
+	return nil
+}
+
+// compile prepares the fields of the domain for extraction.
+func (d DomainConfig) compile() ([]field, error) {
+	fields := make([]field, 0, len(d.Fields))
+	for _, f := range d.Fields {
+		compiled, err := compileField(d.format(), f)
+		if err != nil {
+			return nil, fmt.Errorf("domain %q: field %s: %w", d.Name, f.Name, err)
+		}
+		fields = append(fields, compiled)
+	}
+	return fields, nil
+}
+
+// ParseDomainConfig decodes and validates a domain configuration. Files ending in .json are read as
+// JSON, everything else as YAML; unknown keys are rejected so typos do not go unnoticed. Without a
+// name in the file the domain is named after it.
+func ParseDomainConfig(filename string, data []byte) (DomainConfig, error) {
+	var d DomainConfig
+	var err error
+	if strings.EqualFold(path.Ext(filename), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&d)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&d)
+	}
+	if err != nil {
+		return d, fmt.Errorf("%s: %v", filename, err)
+	}
+	if d.Name == "" {
+		base := path.Base(filename)
+		d.Name = strings.TrimSuffix(base, path.Ext(base))
+	}
+	if err := d.Validate(); err != nil {
+		return d, fmt.Errorf("%s: %w", filename, err)
+	}
+	return d, nil
+}
+
+// isDomainFile reports whether name is a domain configuration file.
+func isDomainFile(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// LoadDomainConfigs reads every .yaml, .yml and .json file in dir of fsys. It fails on the first
+// invalid file and when two files declare the same domain.
+func LoadDomainConfigs(fsys fs.FS, dir string) (map[string]DomainConfig, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	configs := make(map[string]DomainConfig)
+	files := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() || !isDomainFile(entry.Name()) {
+			continue
+		}
+		file := path.Join(dir, entry.Name())
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		d, err := ParseDomainConfig(file, data)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := files[d.Name]; ok {
+			return nil, fmt.Errorf("domain %q is defined in both %s and %s", d.Name, other, file)
+		}
+		files[d.Name] = file
+		configs[d.Name] = d
+	}
+	return configs, nil
+}
+
+// DomainRegistry holds the built-in domain configurations and those read from a directory, which
+// override built-ins of the same name. The directory is checked for changes on every lookup and
+// re-read when a file was added, removed or modified; if the new files are invalid the registry logs
+// the error and keeps serving the configurations it had.
+type DomainRegistry struct {
+	dir string
+
+	mu      sync.Mutex
+	builtin map[string]DomainConfig
+	configs map[string]DomainConfig
+	stamp   string
+	err     error
+}
+
+// Domains is the registry used by GetDomainConfig, reading CRAB_DOMAIN_DIR when it is set.
+var Domains = NewDomainRegistry(os.Getenv("CRAB_DOMAIN_DIR"))
+
+// NewDomainRegistry creates a registry of the built-in configurations and those in dir. An empty dir
+// means built-ins only.
+func NewDomainRegistry(dir string) *DomainRegistry {
+	builtin, err := LoadDomainConfigs(builtinDomains, "domains")
+	if err != nil {
+		// The embedded files are covered by the tests, so this only happens in a broken build.
+		panic(fmt.Sprintf("crab: built-in domain configurations: %v", err))
+	}
+	r := &DomainRegistry{dir: dir, builtin: builtin, configs: builtin}
+	if err := r.Reload(); err != nil {
+		log.Printf("Error loading scraper domains from %s: %v", dir, err)
+	}
+	return r
+}
+
+// Reload re-reads the directory of the registry even if it looks unchanged.
+func (r *DomainRegistry) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stamp = ""
+	return r.refresh()
+}
+
+// refresh re-reads the directory when its fingerprint changed. r.mu must be held.
+func (r *DomainRegistry) refresh() error {
+	if r.dir == "" {
+		return nil
+	}
+	stamp, err := r.fingerprint()
+	if err != nil {
+		r.err = err
+		return err
+	}
+	if stamp == r.stamp {
+		return r.err
+	}
+	r.stamp = stamp
+	loaded, err := LoadDomainConfigs(os.DirFS(r.dir), ".")
+	if err != nil {
+		r.err = err
+		return err
+	}
+	configs := make(map[string]DomainConfig, len(r.builtin)+len(loaded))
+	for name, d := range r.builtin {
+		configs[name] = d
+	}
+	for name, d := range loaded {
+		configs[name] = d
+	}
+	r.configs, r.err = configs, nil
+	return nil
+}
+
+// fingerprint summarizes the names, sizes and modification times of the files in the directory.
+func (r *DomainRegistry) fingerprint() (string, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, entry := range entries {
+		if entry.IsDir() || !isDomainFile(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// lookup refreshes the registry and returns its configurations.
+func (r *DomainRegistry) lookup() map[string]DomainConfig {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	before := r.err
+	if err := r.refresh(); err != nil && (before == nil || err.Error() != before.Error()) {
+		log.Printf("Error reloading scraper domains from %s, keeping the previous ones: %v", r.dir, err)
+	}
+	return r.configs
+}
+
+// Get returns the configuration of the named domain.
+func (r *DomainRegistry) Get(name string) (DomainConfig, bool) {
+	d, ok := r.lookup()[name]
+	return d, ok
+}
+
+// Names returns the names of all configured domains, sorted.
+func (r *DomainRegistry) Names() []string {
+	configs := r.lookup()
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Err returns the error of the last reload, nil when the directory loaded cleanly.
+func (r *DomainRegistry) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
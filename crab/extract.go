@@ -0,0 +1,398 @@
+package crab
+
+import (
+	"cmpscfa23team2/jsonpath"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/gocolly/colly"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMapSeparator splits "key: value" lines of map fields without a separator of their own.
+const defaultMapSeparator = ": "
+
+// itemScope is what the fields of one item are extracted from: the element matched by the item
+// selector of an HTML page, or the value matched by the item path of a JSON response.
+type itemScope struct {
+	sel  *goquery.Selection // HTML item
+	page *goquery.Selection // root of the page of an HTML item
+	json interface{}        // JSON item
+}
+
+// root returns the selection a field is matched against: the item or, for page fields, its page.
+func (s itemScope) root(page bool) *goquery.Selection {
+	if page && s.page != nil && s.page.Length() > 0 {
+		return s.page
+	}
+	return s.sel
+}
+
+// extractor finds the raw values of a field in an item: the text or attribute of every match, or
+// every scalar a JSONPath selects.
+type extractor interface {
+	values(item itemScope) []string
+}
+
+// cssExtractor matches a goquery CSS selector, or the item itself when there is none.
+type cssExtractor struct {
+	matcher goquery.Matcher
+	attr    string
+	page    bool
+}
+
+func (x cssExtractor) values(item itemScope) []string {
+	sel := item.root(x.page)
+	if sel == nil {
+		return nil
+	}
+	if x.matcher != nil {
+		sel = sel.FindMatcher(x.matcher)
+	}
+	var values []string
+	sel.Each(func(_ int, s *goquery.Selection) {
+		if x.attr != "" {
+			values = append(values, strings.TrimSpace(s.AttrOr(x.attr, "")))
+		} else {
+			values = append(values, strings.TrimSpace(s.Text()))
+		}
+	})
+	return values
+}
+
+// xpathExtractor evaluates an XPath expression with the item, or its page, as the root. Expressions
+// may select elements, attributes (//a/@href) or compute a string, number or boolean.
+type xpathExtractor struct {
+	expr *xpath.Expr
+	attr string
+	page bool
+}
+
+func (x xpathExtractor) values(item itemScope) []string {
+	sel := item.root(x.page)
+	if sel == nil || sel.Length() == 0 {
+		return nil
+	}
+	switch result := x.expr.Evaluate(htmlquery.CreateXPathNavigator(sel.Nodes[0])).(type) {
+	case *xpath.NodeIterator:
+		var values []string
+		for result.MoveNext() {
+			nav := result.Current().(*htmlquery.NodeNavigator)
+			switch {
+			case nav.NodeType() == xpath.AttributeNode:
+				values = append(values, strings.TrimSpace(nav.Value()))
+			case x.attr != "":
+				values = append(values, strings.TrimSpace(htmlquery.SelectAttr(nav.Current(), x.attr)))
+			default:
+				values = append(values, strings.TrimSpace(htmlquery.InnerText(nav.Current())))
+			}
+		}
+		return values
+	case string:
+		return []string{strings.TrimSpace(result)}
+	case float64:
+		return []string{strconv.FormatFloat(result, 'f', -1, 64)}
+	case bool:
+		return []string{strconv.FormatBool(result)}
+	}
+	return nil
+}
+
+// regexExtractor matches a regular expression against the text of the item, or of its page, and
+// returns the first capture group of every match, or the whole match when there is no group.
+type regexExtractor struct {
+	re   *regexp.Regexp
+	page bool
+}
+
+func (x regexExtractor) values(item itemScope) []string {
+	sel := item.root(x.page)
+	if sel == nil {
+		return nil
+	}
+	var values []string
+	for _, m := range x.re.FindAllStringSubmatch(sel.Text(), -1) {
+		if len(m) > 1 {
+			values = append(values, strings.TrimSpace(m[1]))
+		} else {
+			values = append(values, strings.TrimSpace(m[0]))
+		}
+	}
+	return values
+}
+
+// jsonExtractor evaluates a JSONPath against a JSON item. Arrays contribute each of their elements,
+// objects one "key<separator>value" line per member and other values their JSON text.
+type jsonExtractor struct {
+	path      *jsonpath.Path
+	separator string
+}
+
+func (x jsonExtractor) values(item itemScope) []string {
+	var values []string
+	for _, v := range x.path.Find(item.json) {
+		if arr, ok := v.([]interface{}); ok {
+			for _, elem := range arr {
+				values = append(values, x.format(elem))
+			}
+			continue
+		}
+		values = append(values, x.format(v))
+	}
+	return values
+}
+
+// format writes one JSON value as a field value.
+func (x jsonExtractor) format(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		lines := make([]string, len(keys))
+		for i, key := range keys {
+			lines[i] = key + x.separator + x.format(v[key])
+		}
+		return strings.Join(lines, "\n")
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// field is a FieldConfig ready to extract values.
+type field struct {
+	FieldConfig
+	extract extractor
+	filters []filter
+}
+
+// compileField checks f and builds its extractor and filters. format is the format of the domain.
+func compileField(format string, f FieldConfig) (field, error) {
+	out := field{FieldConfig: f}
+	if strings.TrimSpace(f.Name) == "" {
+		return out, errors.New("name is required")
+	}
+	fieldType := f.fieldType()
+	switch fieldType {
+	case FieldText, FieldURL, FieldNumber, FieldList, FieldMap:
+	default:
+		return out, fmt.Errorf("unknown type %q", f.Type)
+	}
+	if types, ok := genericFields[f.Name]; ok && !hasFieldType(types, fieldType) {
+		return out, fmt.Errorf("type %s does not fit %s, which takes %v", fieldType, f.Name, types)
+	}
+	if f.Separator != "" && fieldType != FieldMap {
+		return out, errors.New("only map fields take a separator")
+	}
+	separator := f.Separator
+	if separator == "" {
+		separator = defaultMapSeparator
+	}
+
+	var sources []string
+	for _, source := range []struct{ name, expr string }{{"selector", f.Selector}, {"xpath", f.XPath}, {"regex", f.Regex}, {"jsonpath", f.JSONPath}} {
+		if source.expr != "" {
+			sources = append(sources, source.name)
+		}
+	}
+	if len(sources) > 1 {
+		return out, fmt.Errorf("use only one of %s", strings.Join(sources, ", "))
+	}
+	if format == FormatJSON {
+		if f.JSONPath == "" && len(sources) > 0 {
+			return out, fmt.Errorf("fields of JSON domains use jsonpath, not %s", sources[0])
+		}
+		if f.Attr != "" || f.Page {
+			return out, errors.New("attr and page apply to HTML domains only")
+		}
+	} else if f.JSONPath != "" {
+		return out, errors.New("jsonpath applies to JSON domains only")
+	}
+	if f.Attr != "" && (f.Regex != "" || fieldType == FieldMap) {
+		return out, errors.New("regex and map fields read text, not an attribute")
+	}
+	attr := f.Attr
+	if attr == "" && fieldType == FieldURL {
+		attr = "href"
+	}
+
+	switch {
+	case format == FormatJSON:
+		path := "$"
+		if f.JSONPath != "" {
+			path = f.JSONPath
+		}
+		p, err := jsonpath.Compile(path)
+		if err != nil {
+			return out, err
+		}
+		out.extract = jsonExtractor{path: p, separator: separator}
+	case f.XPath != "":
+		expr, err := xpath.Compile(f.XPath)
+		if err != nil {
+			return out, fmt.Errorf("invalid xpath %q: %v", f.XPath, err)
+		}
+		out.extract = xpathExtractor{expr: expr, attr: attr, page: f.Page}
+	case f.Regex != "":
+		re, err := regexp.Compile(f.Regex)
+		if err != nil {
+			return out, fmt.Errorf("invalid regex %q: %v", f.Regex, err)
+		}
+		out.extract = regexExtractor{re: re, page: f.Page}
+	default:
+		x := cssExtractor{attr: attr, page: f.Page}
+		if f.Selector != "" {
+			matcher, err := cascadia.Compile(f.Selector)
+			if err != nil {
+				return out, fmt.Errorf("invalid selector %q: %v", f.Selector, err)
+			}
+			x.matcher = matcher
+		}
+		out.extract = x
+	}
+
+	for _, spec := range f.Filters {
+		filter, err := parseFilter(spec)
+		if err != nil {
+			return out, err
+		}
+		out.filters = append(out.filters, filter)
+	}
+	return out, nil
+}
+
+// value extracts the value of the field from item: a string, float64, []string or map[string]string,
+// or nil when nothing usable matched.
+func (f field) value(item itemScope, req *colly.Request) interface{} {
+	raw := f.extract.values(item)
+	switch f.fieldType() {
+	case FieldMap:
+		separator := f.Separator
+		if separator == "" {
+			separator = defaultMapSeparator
+		}
+		values := make(map[string]string)
+		for _, text := range raw {
+			for _, line := range strings.Split(text, "\n") {
+				key, value, ok := strings.Cut(line, separator)
+				if !ok {
+					continue
+				}
+				if value, ok := applyFilters(f.filters, strings.TrimSpace(value)); ok {
+					values[strings.TrimSpace(key)] = value
+				}
+			}
+		}
+		if len(values) == 0 {
+			return nil
+		}
+		return values
+	}
+
+	var values []string
+	for _, v := range raw {
+		if v, ok := applyFilters(f.filters, v); ok {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	switch f.fieldType() {
+	case FieldURL:
+		if req == nil {
+			return values[0]
+		}
+		return req.AbsoluteURL(values[0])
+	case FieldNumber:
+		n, err := parseNumber(values[0])
+		if err != nil {
+			return nil
+		}
+		return n
+	case FieldList:
+		return values
+	}
+	if f.Attr != "" {
+		return values[0]
+	}
+	return strings.Join(values, " ")
+}
+
+// extractItem builds the item found in scope on the page requested by req.
+func extractItem(fields []field, scope itemScope, req *colly.Request) GenericData {
+	item := GenericData{
+		Metadata: Metadata{
+			Source:    req.URL.String(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		},
+	}
+	for _, f := range fields {
+		if value := f.value(scope, req); value != nil {
+			item.set(f.Name, value)
+		}
+	}
+	return item
+}
+
+// set stores the value of a field in the matching GenericData field, or in Fields for fields
+// GenericData has no place for. compileField guarantees the value types fit.
+func (item *GenericData) set(name string, value interface{}) {
+	switch name {
+	case "title":
+		item.Title = stringValue(value)
+	case "url":
+		item.URL = stringValue(value)
+	case "description":
+		item.Description = stringValue(value)
+	case "price":
+		item.Price = stringValue(value)
+	case "factors":
+		item.Factors = value.([]string)
+	case "depreciation_rates":
+		item.DepreciationRates = value.(map[string]string)
+	case "models_least_depreciation":
+		item.ModelsLeastDepreciation = value.([]string)
+	case "models_most_depreciation":
+		item.ModelsMostDepreciation = value.([]string)
+	default:
+		if item.Fields == nil {
+			item.Fields = make(map[string]interface{})
+		}
+		item.Fields[name] = value
+	}
+}
+
+// parseNumber reads a number out of text such as "£51.77" or "1,234", ignoring currency symbols,
+// thousands separators and surrounding words.
+func parseNumber(text string) (float64, error) {
+	var b strings.Builder
+	for _, r := range text {
+		if (r >= '0' && r <= '9') || r == '.' || (r == '-' && b.Len() == 0) {
+			b.WriteRune(r)
+		}
+	}
+	return strconv.ParseFloat(b.String(), 64)
+}
+
+// stringValue formats a text or number field for the string fields of GenericData.
+func stringValue(value interface{}) string {
+	if n, ok := value.(float64); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return value.(string)
+}
@@ -0,0 +1,114 @@
+package crab
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// filter post-processes one extracted value. An empty result drops the value.
+type filter func(string) string
+
+// dateLayouts are tried in order by the date filter when it is given no layout.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"02 Jan 2006",
+	"01/02/2006",
+	"2006/01/02",
+}
+
+// parseFilter compiles a filter written as "name" or "name:argument":
+//
+//	trim            collapses runs of white space and trims both ends
+//	trim:<chars>    trims the given characters from both ends
+//	regex:<pattern> keeps the first capture group of the first match, or the whole match
+//	number          keeps the number in the value, dropping currency signs and separators
+//	date[:<layout>] parses a date, with a Go time layout or common formats, as YYYY-MM-DD or RFC 3339
+func parseFilter(spec string) (filter, error) {
+	name, arg, hasArg := strings.Cut(spec, ":")
+	switch strings.TrimSpace(name) {
+	case "trim":
+		if hasArg {
+			return func(s string) string { return strings.Trim(s, arg) }, nil
+		}
+		return collapseSpace, nil
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("filter %q: %v", spec, err)
+		}
+		return func(s string) string {
+			m := re.FindStringSubmatch(s)
+			switch {
+			case m == nil:
+				return ""
+			case len(m) > 1:
+				return m[1]
+			}
+			return m[0]
+		}, nil
+	case "number":
+		if hasArg {
+			return nil, fmt.Errorf("filter %q takes no argument", name)
+		}
+		return func(s string) string {
+			n, err := parseNumber(s)
+			if err != nil {
+				return ""
+			}
+			return strconv.FormatFloat(n, 'f', -1, 64)
+		}, nil
+	case "date":
+		layouts := dateLayouts
+		if hasArg {
+			layouts = []string{arg}
+		}
+		return func(s string) string {
+			if t, ok := parseDate(s, layouts); ok {
+				return formatDate(t)
+			}
+			return ""
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown filter %q", spec)
+}
+
+// parseDate parses s with the first of layouts that fits it.
+func parseDate(s string, layouts []string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// formatDate writes dates without a time of day as YYYY-MM-DD and others as RFC 3339.
+func formatDate(t time.Time) string {
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format(time.RFC3339)
+}
+
+// applyFilters runs value through filters in order and reports whether anything is left.
+func applyFilters(filters []filter, value string) (string, bool) {
+	for _, f := range filters {
+		value = f(value)
+		if value == "" {
+			return "", false
+		}
+	}
+	return value, value != ""
+}
@@ -0,0 +1,431 @@
+package crab
+
+import (
+	"cmpscfa23team2/canonical"
+	"cmpscfa23team2/events"
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Scopes limit which links a crawl follows away from its seeds.
+const (
+	ScopeHost      = "host"      // links on the host of the seed
+	ScopeSubdomain = "subdomain" // links on the host of the seed and its subdomains
+	ScopeRegex     = "regex"     // links matching CrawlConfig.Pattern
+	ScopeAny       = "any"       // every http(s) link
+)
+
+// DefaultConcurrency is the number of pages a crawl fetches at once unless configured otherwise.
+const DefaultConcurrency = 10
+
+// CrawlConfig declares how far a crawl goes. Include and Exclude are regular expressions matched against
+// discovered links on top of the scope: with Include set a link must match one of them, and a link
+// matching any of Exclude is skipped. Seeds are always crawled.
+type CrawlConfig struct {
+	Name        string   `json:"name"`                  // identifies the crawl in its FrontierStore, so a restarted crawl resumes
+	Concurrency int      `json:"concurrency,omitempty"` // pages fetched at once; 0 for DefaultConcurrency
+	MaxDepth    int      `json:"max_depth,omitempty"`   // links followed away from a seed; 0 crawls the seeds only
+	MaxPages    int      `json:"max_pages,omitempty"`   // pages fetched by one run; 0 for no limit
+	Scope       string   `json:"scope,omitempty"`       // host (default), subdomain, regex or any
+	Pattern     string   `json:"pattern,omitempty"`     // regular expression of the regex scope
+	Include     []string `json:"include,omitempty"`
+	Exclude     []string `json:"exclude,omitempty"`
+	Sitemaps    bool     `json:"sitemaps,omitempty"` // also seed from the sitemaps of the sites of the seeds
+	Since       string   `json:"since,omitempty"`    // skip sitemap pages last modified before: a date, or an age such as 30d
+}
+
+// Validate checks the limits of the crawl and compiles its patterns.
+func (c CrawlConfig) Validate() error {
+	_, err := c.compile()
+	return err
+}
+
+// crawlRules holds the compiled scope of a crawl.
+type crawlRules struct {
+	pattern *regexp.Regexp
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// compile checks the configuration and compiles its regular expressions.
+func (c CrawlConfig) compile() (crawlRules, error) {
+	var rules crawlRules
+	var errs []error
+	if strings.TrimSpace(c.Name) == "" {
+		errs = append(errs, errors.New("name is required"))
+	}
+	if c.Concurrency < 0 || c.MaxDepth < 0 || c.MaxPages < 0 {
+		errs = append(errs, errors.New("concurrency, max_depth and max_pages must not be negative"))
+	}
+	if _, err := parseSince(c.Since, time.Now()); err != nil {
+		errs = append(errs, err)
+	}
+	switch c.Scope {
+	case "", ScopeHost, ScopeSubdomain, ScopeAny:
+		if c.Pattern != "" {
+			errs = append(errs, errors.New("pattern needs the regex scope"))
+		}
+	case ScopeRegex:
+		if c.Pattern == "" {
+			errs = append(errs, errors.New("the regex scope needs a pattern"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown scope %q", c.Scope))
+	}
+	compile := func(expr string) *regexp.Regexp {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return re
+	}
+	if c.Pattern != "" {
+		rules.pattern = compile(c.Pattern)
+	}
+	for _, expr := range c.Include {
+		rules.include = append(rules.include, compile(expr))
+	}
+	for _, expr := range c.Exclude {
+		rules.exclude = append(rules.exclude, compile(expr))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return rules, fmt.Errorf("crawl %q: %w", c.Name, err)
+	}
+	return rules, nil
+}
+
+// concurrency returns the number of pages the crawl fetches at once.
+func (c CrawlConfig) concurrency() int {
+	if c.Concurrency == 0 {
+		return DefaultConcurrency
+	}
+	return c.Concurrency
+}
+
+// inScope reports whether the crawl follows link, found on a page reached from seed.
+func (c CrawlConfig) inScope(rules crawlRules, link, seed *url.URL) bool {
+	if link.Scheme != "http" && link.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(link.Hostname())
+	seedHost := strings.TrimPrefix(strings.ToLower(seed.Hostname()), "www.")
+	switch c.Scope {
+	case "", ScopeHost:
+		if strings.TrimPrefix(host, "www.") != seedHost {
+			return false
+		}
+	case ScopeSubdomain:
+		if host != seedHost && !strings.HasSuffix(host, "."+seedHost) {
+			return false
+		}
+	case ScopeRegex:
+		if !rules.pattern.MatchString(link.String()) {
+			return false
+		}
+	}
+	for _, re := range rules.exclude {
+		if re.MatchString(link.String()) {
+			return false
+		}
+	}
+	if len(rules.include) == 0 {
+		return true
+	}
+	for _, re := range rules.include {
+		if re.MatchString(link.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// FrontierEntry is one URL of a crawl: the seed it was reached from, how many links away from the seed
+// it is, and when it was crawled, the zero time while it is waiting. Entries with a higher priority are
+// crawled first, then those closer to their seed, then those found first.
+type FrontierEntry struct {
+	URL      string    `json:"url"`
+	Seed     string    `json:"seed"`
+	Depth    int       `json:"depth"`
+	Priority float64   `json:"priority,omitempty"`
+	Crawled  time.Time `json:"crawled,omitempty"`
+
+	seq int // order in which the entry was added
+}
+
+// frontierQueue is a heap of entries waiting to be crawled.
+type frontierQueue []FrontierEntry
+
+func (q frontierQueue) Len() int { return len(q) }
+
+func (q frontierQueue) Less(i, j int) bool {
+	switch {
+	case q[i].Priority != q[j].Priority:
+		return q[i].Priority > q[j].Priority
+	case q[i].Depth != q[j].Depth:
+		return q[i].Depth < q[j].Depth
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q frontierQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *frontierQueue) Push(x interface{}) { *q = append(*q, x.(FrontierEntry)) }
+
+func (q *frontierQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// Frontier holds the URLs a crawl has seen and a priority queue of those it has yet to crawl. Every
+// change is written to its FrontierStore, and a frontier created for a crawl the store already knows
+// continues it: URLs added before are not added again, and those not crawled yet are queued again.
+// A Frontier is used by one goroutine at a time; Run fetches pages concurrently itself.
+type Frontier struct {
+	config     CrawlConfig
+	rules      crawlRules
+	store      FrontierStore
+	politeness *Politeness
+	queue      frontierQueue
+	seen       map[string]bool
+	crawled    map[string]time.Time // when the crawled URLs were crawled
+	seq        int
+}
+
+// NewFrontier creates the frontier of a crawl and loads what store holds of it. Pages are fetched through
+// DefaultPoliteness unless SetPoliteness sets another.
+func NewFrontier(config CrawlConfig, store FrontierStore) (*Frontier, error) {
+	rules, err := config.compile()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := store.Load(config.Name)
+	if err != nil {
+		return nil, fmt.Errorf("loading the frontier of crawl %q: %w", config.Name, err)
+	}
+	f := &Frontier{
+		config:     config,
+		rules:      rules,
+		store:      store,
+		politeness: DefaultPoliteness,
+		seen:       make(map[string]bool),
+		crawled:    make(map[string]time.Time),
+	}
+	for _, e := range entries {
+		f.seen[e.URL] = true
+		if e.Crawled.IsZero() {
+			f.enqueue(e)
+		} else {
+			f.crawled[e.URL] = e.Crawled
+		}
+	}
+	return f, nil
+}
+
+// SetPoliteness sets the politeness layer Run fetches pages through.
+func (f *Frontier) SetPoliteness(p *Politeness) {
+	f.politeness = p
+}
+
+// enqueue queues e behind the entries of the same priority and depth.
+func (f *Frontier) enqueue(e FrontierEntry) {
+	f.seq++
+	e.seq = f.seq
+	heap.Push(&f.queue, e)
+}
+
+// Push adds an entry to the frontier unless the canonical form of its URL was seen before, and reports
+// whether it did. The entry is stored with its URL and seed in canonical form.
+func (f *Frontier) Push(e FrontierEntry) (bool, error) {
+	e.URL, e.Seed = canonical.URL(e.URL), canonical.URL(e.Seed)
+	if f.seen[e.URL] {
+		return false, nil
+	}
+	e.Crawled = time.Time{}
+	if err := f.store.Add(f.config.Name, e); err != nil {
+		return false, err
+	}
+	f.seen[e.URL] = true
+	f.enqueue(e)
+	return true, nil
+}
+
+// AddSeed adds a URL the crawl starts from.
+func (f *Frontier) AddSeed(seed string) (bool, error) {
+	u, err := url.Parse(seed)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false, fmt.Errorf("seed %q is not an absolute http(s) URL", seed)
+	}
+	return f.Push(FrontierEntry{URL: seed, Seed: seed})
+}
+
+// Add adds a link found on the page of from, if it is within the depth limit and scope of the crawl.
+func (f *Frontier) Add(link string, from FrontierEntry) (bool, error) {
+	if from.Depth >= f.config.MaxDepth {
+		return false, nil
+	}
+	u, err := url.Parse(canonical.URL(link))
+	if err != nil {
+		return false, nil
+	}
+	seed, err := url.Parse(from.Seed)
+	if err != nil || !f.config.inScope(f.rules, u, seed) {
+		return false, nil
+	}
+	return f.Push(FrontierEntry{URL: u.String(), Seed: from.Seed, Depth: from.Depth + 1})
+}
+
+// AddSitemapURLs adds pages listed by a sitemap as seeds, with the priority the sitemap gives them; pages
+// of the same priority are crawled most recently modified first. Pages modified before the Since of the
+// crawl are skipped, as are pages crawled since they were last modified. A page modified since it was
+// crawled is queued again. It returns the number of pages queued.
+func (f *Frontier) AddSitemapURLs(pages []SitemapURL) (int, error) {
+	since, err := parseSince(f.config.Since, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	pages = append([]SitemapURL(nil), pages...)
+	SortSitemapURLs(pages)
+	queued := 0
+	for _, page := range pages {
+		if !page.LastMod.IsZero() && page.LastMod.Before(since) {
+			continue
+		}
+		u, err := url.Parse(page.Loc)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			continue
+		}
+		link := canonical.URL(page.Loc)
+		e := FrontierEntry{URL: link, Seed: link, Priority: page.Priority}
+		if !f.seen[link] {
+			if ok, err := f.Push(e); err != nil {
+				return queued, err
+			} else if ok {
+				queued++
+			}
+			continue
+		}
+		crawled, ok := f.crawled[link]
+		if !ok || page.LastMod.IsZero() || !page.LastMod.After(crawled) {
+			continue
+		}
+		if err := f.store.Add(f.config.Name, e); err != nil {
+			return queued, err
+		}
+		delete(f.crawled, link)
+		f.enqueue(e)
+		queued++
+	}
+	return queued, nil
+}
+
+// AddSitemaps reads the sitemaps of the site of rawURL through the politeness layer of the frontier and
+// adds the pages they list with AddSitemapURLs. Sitemaps that cannot be read are reported in the error,
+// after the pages of the others have been added.
+func (f *Frontier) AddSitemaps(rawURL string) (int, error) {
+	pages, readErr := NewSitemapReader(f.politeness).ReadSite(rawURL)
+	queued, err := f.AddSitemapURLs(pages)
+	return queued, errors.Join(err, readErr)
+}
+
+// Next removes the entry to crawl next from the queue.
+func (f *Frontier) Next() (FrontierEntry, bool) {
+	if len(f.queue) == 0 {
+		return FrontierEntry{}, false
+	}
+	return heap.Pop(&f.queue).(FrontierEntry), true
+}
+
+// Done records that e has been crawled.
+func (f *Frontier) Done(e FrontierEntry) error {
+	if e.Crawled.IsZero() {
+		e.Crawled = time.Now().UTC()
+	}
+	if err := f.store.Done(f.config.Name, e); err != nil {
+		return err
+	}
+	f.crawled[canonical.URL(e.URL)] = e.Crawled
+	return nil
+}
+
+// alias records that the page of e declares link as its canonical URL, so that links to it found later
+// in the crawl are not followed: the page has been crawled under another URL.
+func (f *Frontier) alias(link string, e FrontierEntry) error {
+	link = canonical.URL(link)
+	if f.seen[link] {
+		return nil
+	}
+	alias := FrontierEntry{URL: link, Seed: e.Seed, Depth: e.Depth}
+	if err := f.store.Add(f.config.Name, alias); err != nil {
+		return err
+	}
+	f.seen[link] = true
+	return f.Done(alias)
+}
+
+// Len returns the number of entries waiting to be crawled.
+func (f *Frontier) Len() int {
+	return len(f.queue)
+}
+
+// crawlResult is a page fetched by a worker of Frontier.Run.
+type crawlResult struct {
+	entry FrontierEntry
+	page  URLData
+}
+
+// Run crawls the queued entries, and the links they lead to, until the queue is empty, the page limit of
+// the crawl is reached or ctx is cancelled. It returns the pages it crawled, and the first error of the
+// store, which stops the crawl. Entries being fetched when the crawl stops stay queued in the store.
+func (f *Frontier) Run(ctx context.Context, pub events.Publisher) ([]URLData, error) {
+	results := make(chan crawlResult)
+	var crawled []URLData
+	var err error
+	active, started := 0, 0
+	for {
+		for err == nil && ctx.Err() == nil && active < f.config.concurrency() &&
+			(f.config.MaxPages == 0 || started < f.config.MaxPages) {
+			e, ok := f.Next()
+			if !ok {
+				break
+			}
+			active++
+			started++
+			go func(e FrontierEntry) {
+				results <- crawlResult{entry: e, page: fetchPage(URLData{URL: e.URL, Depth: e.Depth}, f.politeness, pub)}
+			}(e)
+		}
+		if active == 0 {
+			break
+		}
+		r := <-results
+		active--
+		crawled = append(crawled, r.page)
+		if err != nil {
+			continue
+		}
+		if r.page.Canonical != "" && r.page.Canonical != r.entry.URL {
+			err = f.alias(r.page.Canonical, r.entry)
+		}
+		for _, link := range r.page.Links {
+			if err != nil {
+				break
+			}
+			_, err = f.Add(link, r.entry)
+		}
+		if err == nil {
+			err = f.Done(r.entry)
+		}
+	}
+	if err != nil {
+		return crawled, fmt.Errorf("crawl %q: %w", f.config.Name, err)
+	}
+	return crawled, nil
+}
@@ -0,0 +1,166 @@
+package crab
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FrontierStore persists the frontiers of crawls, by crawl name.
+type FrontierStore interface {
+	// Load returns every entry added to the crawl so far, crawled or not, in the order they were added.
+	Load(crawl string) ([]FrontierEntry, error)
+	// Add saves a new entry waiting to be crawled, or queues an entry crawled before again.
+	Add(crawl string, e FrontierEntry) error
+	// Done marks the entry with the URL of e as crawled at e.Crawled.
+	Done(crawl string, e FrontierEntry) error
+}
+
+// MemoryFrontierStore is a FrontierStore that keeps frontiers in memory, for crawls that need not
+// survive a restart and for tests.
+type MemoryFrontierStore struct {
+	mu     sync.Mutex
+	crawls map[string][]FrontierEntry
+}
+
+// NewMemoryFrontierStore creates an empty MemoryFrontierStore.
+func NewMemoryFrontierStore() *MemoryFrontierStore {
+	return &MemoryFrontierStore{crawls: make(map[string][]FrontierEntry)}
+}
+
+// Load returns a copy of the entries of the crawl.
+func (s *MemoryFrontierStore) Load(crawl string) ([]FrontierEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]FrontierEntry(nil), s.crawls[crawl]...), nil
+}
+
+// Add appends e to the entries of the crawl, or replaces the entry with its URL.
+func (s *MemoryFrontierStore) Add(crawl string, e FrontierEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.crawls[crawl] {
+		if s.crawls[crawl][i].URL == e.URL {
+			s.crawls[crawl][i] = e
+			return nil
+		}
+	}
+	s.crawls[crawl] = append(s.crawls[crawl], e)
+	return nil
+}
+
+// Done sets the crawl time of the entry with the URL of e.
+func (s *MemoryFrontierStore) Done(crawl string, e FrontierEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.crawls[crawl] {
+		if s.crawls[crawl][i].URL == e.URL {
+			s.crawls[crawl][i].Crawled = e.Crawled
+		}
+	}
+	return nil
+}
+
+// FileFrontierStore is a FrontierStore that keeps each crawl in a journal file in Dir, <crawl>.jsonl,
+// with one line per added or crawled entry. Appending keeps every change cheap and a crash loses at
+// most the line being written.
+type FileFrontierStore struct {
+	Dir string
+
+	mu sync.Mutex
+}
+
+// NewFileFrontierStore creates a store writing to dir, which is created when needed.
+func NewFileFrontierStore(dir string) *FileFrontierStore {
+	return &FileFrontierStore{Dir: dir}
+}
+
+// path returns the journal of the crawl. Crawl names become file names, so they are restricted like
+// domain names.
+func (s *FileFrontierStore) path(crawl string) (string, error) {
+	if !domainNamePattern.MatchString(crawl) {
+		return "", fmt.Errorf("invalid crawl name %q: use lower-case letters, digits, - and _", crawl)
+	}
+	return filepath.Join(s.Dir, crawl+".jsonl"), nil
+}
+
+// Load replays the journal of the crawl. Lines that cannot be decoded, like one cut short by a crash,
+// are skipped.
+func (s *FileFrontierStore) Load(crawl string) ([]FrontierEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path, err := s.path(crawl)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []FrontierEntry
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var e FrontierEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.URL == "" {
+			log.Printf("Skipping line %d of %s: %v", line, path, err)
+			continue
+		}
+		if i, ok := index[e.URL]; ok {
+			// A later line marks the entry crawled, or queues it again with a new priority
+			entries[i].Crawled = e.Crawled
+			if e.Crawled.IsZero() {
+				entries[i].Priority = e.Priority
+			}
+			continue
+		}
+		index[e.URL] = len(entries)
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// Add appends e to the journal of the crawl.
+func (s *FileFrontierStore) Add(crawl string, e FrontierEntry) error {
+	return s.append(crawl, e)
+}
+
+// Done appends e, with its crawl time, to the journal of the crawl.
+func (s *FileFrontierStore) Done(crawl string, e FrontierEntry) error {
+	return s.append(crawl, e)
+}
+
+func (s *FileFrontierStore) append(crawl string, e FrontierEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path, err := s.path(crawl)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,353 @@
+package crab
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats of the link graph of a crawl.
+const (
+	GraphJSON    = "json"    // {"page": ["link", ...]}, the format of siteMap.json
+	GraphSitemap = "sitemap" // sitemaps.org XML of the pages crawled successfully
+	GraphML      = "graphml"
+	GraphDOT     = "dot"
+	GraphCSV     = "csv" // one edge per line, with the attributes of its target
+)
+
+// DefaultGraphOutput is where CreateSiteMap writes the link graph unless CRAB_GRAPH_OUTPUT says otherwise.
+const DefaultGraphOutput = "siteMap.json"
+
+// maxSitemapURLs is the number of URLs a sitemap may hold.
+const maxSitemapURLs = 50000
+
+// graphFormats maps file extensions to link graph formats.
+var graphFormats = map[string]string{
+	".json":    GraphJSON,
+	".xml":     GraphSitemap,
+	".graphml": GraphML,
+	".dot":     GraphDOT,
+	".gv":      GraphDOT,
+	".csv":     GraphCSV,
+}
+
+// GraphFormat returns the link graph format for the extension of filename.
+func GraphFormat(filename string) (string, error) {
+	format, ok := graphFormats[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return "", fmt.Errorf("%s: unknown link graph format; use .json, .xml, .graphml, .dot, .gv or .csv", filename)
+	}
+	return format, nil
+}
+
+// GraphNode is a page of a link graph. Pages that were linked to but not crawled have only a URL.
+type GraphNode struct {
+	URL         string
+	Canonical   string // canonical URL the page declares, if any
+	Crawled     bool
+	Status      int
+	Depth       int
+	ContentType string
+	LastCrawled time.Time
+	Modified    time.Time // Last-Modified of the page
+}
+
+// GraphEdge is a link from one page to another.
+type GraphEdge struct {
+	From, To int // indexes in LinkGraph.Nodes
+}
+
+// LinkGraph is the graph of the pages of a crawl and the links between them.
+type LinkGraph struct {
+	Nodes []GraphNode
+	Edges []GraphEdge
+}
+
+// NewLinkGraph builds the link graph of crawled pages: a node per crawled page, then one per page
+// linked to but not crawled, in the order they were found, and an edge per link.
+func NewLinkGraph(pages []URLData) *LinkGraph {
+	g := &LinkGraph{}
+	index := make(map[string]int)
+	node := func(u string) int {
+		if i, ok := index[u]; ok {
+			return i
+		}
+		index[u] = len(g.Nodes)
+		g.Nodes = append(g.Nodes, GraphNode{URL: u})
+		return index[u]
+	}
+	for _, page := range pages {
+		i := node(page.URL)
+		g.Nodes[i] = GraphNode{
+			URL:         page.URL,
+			Canonical:   page.Canonical,
+			Crawled:     true,
+			Status:      page.Status,
+			Depth:       page.Depth,
+			ContentType: page.ContentType,
+			LastCrawled: page.Created,
+			Modified:    page.LastModified,
+		}
+	}
+	for _, page := range pages {
+		from := index[page.URL]
+		for _, link := range page.Links {
+			g.Edges = append(g.Edges, GraphEdge{From: from, To: node(link)})
+		}
+	}
+	return g
+}
+
+// Write writes the graph to w in format.
+func (g *LinkGraph) Write(w io.Writer, format string) error {
+	switch format {
+	case GraphJSON:
+		return g.writeJSON(w)
+	case GraphSitemap:
+		return g.writeSitemap(w)
+	case GraphML:
+		return g.writeGraphML(w)
+	case GraphDOT:
+		return g.writeDOT(w)
+	case GraphCSV:
+		return g.writeCSV(w)
+	}
+	return fmt.Errorf("unknown link graph format %q", format)
+}
+
+// WriteFile writes the graph to filename, in the format its extension names, creating its directory
+// when needed.
+func (g *LinkGraph) WriteFile(filename string) error {
+	format, err := GraphFormat(filename)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(file)
+	if err := g.Write(out, format); err != nil {
+		file.Close()
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	if err := out.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// links returns the targets of the links of every crawled page, by page URL.
+func (g *LinkGraph) links() map[string][]string {
+	links := make(map[string][]string)
+	for _, n := range g.Nodes {
+		if n.Crawled {
+			links[n.URL] = []string{}
+		}
+	}
+	for _, e := range g.Edges {
+		from := g.Nodes[e.From].URL
+		links[from] = append(links[from], g.Nodes[e.To].URL)
+	}
+	return links
+}
+
+func (g *LinkGraph) writeJSON(w io.Writer) error {
+	data, err := json.Marshal(g.links())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// sitemapURLSet is the document of the sitemaps.org protocol.
+type sitemapURLSet struct {
+	XMLName xml.Name          `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURLEntry `xml:"url"`
+}
+
+type sitemapURLEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// writeSitemap writes the HTML pages crawled successfully as a sitemap, with their Last-Modified dates.
+// Pages declaring another canonical URL are left out, as sitemaps list canonical URLs only.
+func (g *LinkGraph) writeSitemap(w io.Writer) error {
+	var set sitemapURLSet
+	for _, n := range g.Nodes {
+		if !n.Crawled || n.Status < 200 || n.Status > 299 || (n.ContentType != "" && n.ContentType != "text/html") {
+			continue
+		}
+		if n.Canonical != "" && n.Canonical != n.URL {
+			continue
+		}
+		entry := sitemapURLEntry{Loc: n.URL}
+		if !n.Modified.IsZero() {
+			entry.LastMod = n.Modified.UTC().Format(time.RFC3339)
+		}
+		set.URLs = append(set.URLs, entry)
+	}
+	if len(set.URLs) > maxSitemapURLs {
+		return fmt.Errorf("%d pages do not fit in a sitemap of at most %d URLs", len(set.URLs), maxSitemapURLs)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(set); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// graphMLKeys are the node attributes of the GraphML output, by key id.
+var graphMLKeys = []struct{ id, kind string }{
+	{"url", "string"},
+	{"status", "int"},
+	{"depth", "int"},
+	{"content_type", "string"},
+	{"crawled", "string"},
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"http://graphml.graphdrawing.org/xmlns graphml"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLEdge struct {
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+// attributes returns the attributes of a node by name, leaving out those a page that was not crawled
+// does not have.
+func (n GraphNode) attributes() [][2]string {
+	attrs := [][2]string{{"url", n.URL}}
+	if !n.Crawled {
+		return attrs
+	}
+	attrs = append(attrs, [2]string{"status", strconv.Itoa(n.Status)}, [2]string{"depth", strconv.Itoa(n.Depth)})
+	if n.ContentType != "" {
+		attrs = append(attrs, [2]string{"content_type", n.ContentType})
+	}
+	if !n.LastCrawled.IsZero() {
+		attrs = append(attrs, [2]string{"crawled", n.LastCrawled.UTC().Format(time.RFC3339)})
+	}
+	return attrs
+}
+
+func (g *LinkGraph) writeGraphML(w io.Writer) error {
+	doc := graphMLDocument{Graph: graphMLGraph{ID: "crawl", EdgeDefault: "directed"}}
+	for _, k := range graphMLKeys {
+		doc.Keys = append(doc.Keys, graphMLKey{ID: k.id, For: "node", Name: k.id, Type: k.kind})
+	}
+	for i, n := range g.Nodes {
+		node := graphMLNode{ID: "n" + strconv.Itoa(i)}
+		for _, attr := range n.attributes() {
+			node.Data = append(node.Data, graphMLData{Key: attr[0], Value: attr[1]})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node)
+	}
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{Source: "n" + strconv.Itoa(e.From), Target: "n" + strconv.Itoa(e.To)})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// dotQuote quotes s as a DOT string.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func (g *LinkGraph) writeDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph crawl {\n")
+	for _, n := range g.Nodes {
+		attrs := n.attributes()[1:]
+		b.WriteString("  " + dotQuote(n.URL))
+		if len(attrs) > 0 {
+			parts := make([]string, len(attrs))
+			for i, attr := range attrs {
+				parts[i] = attr[0] + "=" + dotQuote(attr[1])
+			}
+			b.WriteString(" [" + strings.Join(parts, ", ") + "]")
+		}
+		b.WriteString(";\n")
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s -> %s;\n", dotQuote(g.Nodes[e.From].URL), dotQuote(g.Nodes[e.To].URL))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (g *LinkGraph) writeCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"source", "target", "status", "depth", "content_type", "crawled"})
+	for _, e := range g.Edges {
+		to := g.Nodes[e.To]
+		record := []string{g.Nodes[e.From].URL, to.URL, "", "", to.ContentType, ""}
+		if to.Crawled {
+			record[2], record[3] = strconv.Itoa(to.Status), strconv.Itoa(to.Depth)
+		}
+		if !to.LastCrawled.IsZero() {
+			record[5] = to.LastCrawled.UTC().Format(time.RFC3339)
+		}
+		out.Write(record)
+	}
+	out.Flush()
+	return out.Error()
+}
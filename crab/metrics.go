@@ -0,0 +1,13 @@
+package crab
+
+import "cmpscfa23team2/metrics"
+
+// Crawler and scraper counters exposed on carp's /metrics endpoint.
+var (
+	crawlerPages     = metrics.Default.NewCounter("crab_crawler_pages_total", "Pages fetched by the crawler, by HTTP status code.", "status")
+	crawlerErrors    = metrics.Default.NewCounter("crab_crawler_errors_total", "Crawler requests that failed.")
+	robotsDisallowed = metrics.Default.NewCounter("crab_robots_disallowed_total", "Requests skipped because robots.txt disallows them.")
+	scraperPages     = metrics.Default.NewCounter("crab_scraper_pages_total", "Pages fetched by the scraper, by domain configuration.", "domain")
+	scraperItems     = metrics.Default.NewCounter("crab_scraper_items_total", "Items extracted by the scraper, by domain configuration.", "domain")
+	scraperErrors    = metrics.Default.NewCounter("crab_scraper_errors_total", "Pages the scraper could not fetch after all retries, by domain configuration.", "domain")
+)
@@ -0,0 +1,267 @@
+package crab
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxPages is the number of pages Scrape follows when a pagination rule sets no limit.
+const DefaultMaxPages = 10
+
+// pagePlaceholder is replaced by the page number in PaginationConfig.URLTemplate.
+const pagePlaceholder = "{page}"
+
+// PaginationConfig tells Scrape how to reach the following pages of results, with at most one of
+// NextSelector, URLTemplate and OffsetParam, and when to stop. The starting URL is always the first page.
+// Scrape stops at MaxPages, at a page without items when it computes the page URLs itself, at a page
+// without new items when StopOnNoNewItems is set, and after a page holding an item dated before Since.
+type PaginationConfig struct {
+	NextSelector     string   `json:"next_selector,omitempty" yaml:"next_selector,omitempty"`               // link to the next page; a JSONPath for JSON domains
+	URLTemplate      string   `json:"url_template,omitempty" yaml:"url_template,omitempty"`                 // URL of the next pages, with {page} for the page number
+	FirstPage        int      `json:"first_page,omitempty" yaml:"first_page,omitempty"`                     // page number of the starting URL in url_template; defaults to 1
+	OffsetParam      string   `json:"offset_param,omitempty" yaml:"offset_param,omitempty"`                 // query parameter holding the index of the first item
+	PageSize         int      `json:"page_size,omitempty" yaml:"page_size,omitempty"`                       // offset step; defaults to the number of items on the page
+	MaxPages         int      `json:"max_pages,omitempty" yaml:"max_pages,omitempty"`                       // including the first; 0 for DefaultMaxPages
+	StopOnNoNewItems bool     `json:"stop_on_no_new_items,omitempty" yaml:"stop_on_no_new_items,omitempty"` // stop when every item of a page was seen before
+	DedupBy          []string `json:"dedup_by,omitempty" yaml:"dedup_by,omitempty"`                         // fields identifying an item; repeated items are dropped
+	DateField        string   `json:"date_field,omitempty" yaml:"date_field,omitempty"`                     // field holding the date of an item
+	Since            string   `json:"since,omitempty" yaml:"since,omitempty"`                               // date cutoff: a date, or an age such as 30d or 12h
+}
+
+// Validate checks that at most one way of reaching the next page is set and that the stop conditions
+// are complete. Selectors are checked by DomainConfig.Validate, which knows the format of the pages.
+func (p PaginationConfig) Validate() error {
+	var errs []error
+	modes := 0
+	for _, set := range []bool{p.NextSelector != "", p.URLTemplate != "", p.OffsetParam != ""} {
+		if set {
+			modes++
+		}
+	}
+	if modes > 1 {
+		errs = append(errs, errors.New("next_selector, url_template and offset_param are exclusive"))
+	}
+	if p.URLTemplate != "" && !strings.Contains(p.URLTemplate, pagePlaceholder) {
+		errs = append(errs, fmt.Errorf("url_template has no %s placeholder", pagePlaceholder))
+	}
+	if p.FirstPage < 0 {
+		errs = append(errs, errors.New("first_page must not be negative"))
+	}
+	if p.FirstPage != 0 && p.URLTemplate == "" {
+		errs = append(errs, errors.New("first_page needs a url_template"))
+	}
+	if p.PageSize < 0 {
+		errs = append(errs, errors.New("page_size must not be negative"))
+	}
+	if p.PageSize != 0 && p.OffsetParam == "" {
+		errs = append(errs, errors.New("page_size needs an offset_param"))
+	}
+	if p.MaxPages < 0 {
+		errs = append(errs, errors.New("max_pages must not be negative"))
+	}
+	for _, name := range p.DedupBy {
+		if name == "" {
+			errs = append(errs, errors.New("dedup_by has an empty field name"))
+		}
+	}
+	if (p.DateField == "") != (p.Since == "") {
+		errs = append(errs, errors.New("date_field and since go together"))
+	}
+	if p.Since != "" {
+		if _, err := p.cutoff(time.Now()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// paginated reports whether the rule leads to further pages.
+func (p PaginationConfig) paginated() bool {
+	return p.NextSelector != "" || p.URLTemplate != "" || p.OffsetParam != ""
+}
+
+// maxPages returns the number of pages Scrape may visit for one start URL.
+func (p PaginationConfig) maxPages() int {
+	switch {
+	case !p.paginated():
+		return 1
+	case p.MaxPages == 0:
+		return DefaultMaxPages
+	}
+	return p.MaxPages
+}
+
+// cutoff returns the oldest date items may have when scraping at now, the zero time without one.
+func (p PaginationConfig) cutoff(now time.Time) (time.Time, error) {
+	return parseSince(p.Since, now)
+}
+
+// parseSince returns the time since designates at now: a date, or an age such as 30d or 12h. An empty
+// since is the zero time.
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if days, ok := strings.CutSuffix(since, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if age, err := time.ParseDuration(since); err == nil && age >= 0 {
+		return now.Add(-age), nil
+	}
+	if t, ok := parseDate(since, dateLayouts); ok {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("since %q is neither a date nor an age such as 30d", since)
+}
+
+// paginator follows the pagination rule of a domain over the pages of one scrape. startPage is called
+// when a page arrives, keep for every item found on it and next once it has been read.
+type paginator struct {
+	config   PaginationConfig
+	max      int
+	cutoff   time.Time
+	pages    int
+	visited  map[string]bool
+	seen     map[string]bool
+	items    int    // items found on the current page
+	newItems int    // items of the current page not seen on earlier pages
+	expired  bool   // the current page holds an item dated before the cutoff
+	link     string // next-page link found on the current page
+}
+
+// newPaginator prepares the pagination of a scrape starting at now.
+func newPaginator(config PaginationConfig, now time.Time) (*paginator, error) {
+	cutoff, err := config.cutoff(now)
+	if err != nil {
+		return nil, err
+	}
+	return &paginator{
+		config:  config,
+		max:     config.maxPages(),
+		cutoff:  cutoff,
+		visited: make(map[string]bool),
+		seen:    make(map[string]bool),
+	}, nil
+}
+
+// startPage records the arrival of the page at u and resets the counts of the previous page.
+func (p *paginator) startPage(u *url.URL) {
+	p.pages++
+	p.visited[u.String()] = true
+	p.items, p.newItems, p.expired, p.link = 0, 0, false, ""
+}
+
+// keep reports whether item should be collected: it is dropped when it repeats an item already
+// collected or is dated before the cutoff.
+func (p *paginator) keep(item GenericData) bool {
+	p.items++
+	if !p.cutoff.IsZero() {
+		if date, ok := parseDate(item.value(p.config.DateField), dateLayouts); ok && date.Before(p.cutoff) {
+			p.expired = true
+			return false
+		}
+	}
+	if len(p.config.DedupBy) > 0 {
+		key := make([]string, len(p.config.DedupBy))
+		empty := true
+		for i, name := range p.config.DedupBy {
+			key[i] = item.value(name)
+			empty = empty && key[i] == ""
+		}
+		if !empty {
+			k := strings.Join(key, "\x00")
+			if p.seen[k] {
+				return false
+			}
+			p.seen[k] = true
+		}
+	}
+	p.newItems++
+	return true
+}
+
+// setLink records the next-page link of the current page; only the first one counts.
+func (p *paginator) setLink(link string) {
+	if p.link == "" {
+		p.link = link
+	}
+}
+
+// next returns the URL of the page after the one at current, which has been read, and reports
+// whether to visit it.
+func (p *paginator) next(current *url.URL) (string, bool) {
+	if p.pages >= p.max || p.expired || (p.config.StopOnNoNewItems && p.newItems == 0) {
+		return "", false
+	}
+	var next string
+	switch {
+	case p.config.NextSelector != "":
+		if p.link == "" {
+			return "", false
+		}
+		ref, err := current.Parse(p.link)
+		if err != nil {
+			return "", false
+		}
+		next = ref.String()
+	case p.config.URLTemplate != "":
+		if p.items == 0 {
+			return "", false
+		}
+		first := p.config.FirstPage
+		if first == 0 {
+			first = 1
+		}
+		ref, err := current.Parse(strings.ReplaceAll(p.config.URLTemplate, pagePlaceholder, strconv.Itoa(first+p.pages)))
+		if err != nil {
+			return "", false
+		}
+		next = ref.String()
+	case p.config.OffsetParam != "":
+		step := p.config.PageSize
+		if step == 0 {
+			step = p.items
+		}
+		if p.items == 0 || step == 0 {
+			return "", false
+		}
+		query := current.Query()
+		offset, _ := strconv.Atoi(query.Get(p.config.OffsetParam))
+		query.Set(p.config.OffsetParam, strconv.Itoa(offset+step))
+		ref := *current
+		ref.RawQuery = query.Encode()
+		next = ref.String()
+	default:
+		return "", false
+	}
+	return next, !p.visited[next]
+}
+
+// value returns the named field of the item as text: the struct fields of GenericData by their field
+// names, anything else from Fields.
+func (item GenericData) value(name string) string {
+	switch name {
+	case "title":
+		return item.Title
+	case "url":
+		return item.URL
+	case "description":
+		return item.Description
+	case "price":
+		return item.Price
+	}
+	switch v := item.Fields[name].(type) {
+	case nil:
+		return ""
+	case string, float64:
+		return stringValue(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,344 @@
+package crab
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gocolly/colly"
+	"github.com/temoto/robotstxt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Politeness defaults, used for the settings a PolitenessConfig leaves at zero.
+const (
+	DefaultRobotsTTL       = 24 * time.Hour
+	DefaultHostConcurrency = 2
+)
+
+// MaxCrawlDelay caps the Crawl-delay a robots.txt file may ask for, so one site cannot stall a crawl.
+const MaxCrawlDelay = time.Minute
+
+// robotsErrorTTL is how long a robots.txt file that could not be fetched counts as empty before it is
+// fetched again.
+const robotsErrorTTL = 5 * time.Minute
+
+// ErrDisallowed is the error of requests robots.txt does not allow crab to make.
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+// PolitenessConfig limits how hard crab hits a single host.
+type PolitenessConfig struct {
+	RobotsTTL       time.Duration // how long a robots.txt file is cached; 0 for DefaultRobotsTTL
+	HostConcurrency int           // requests in flight to one host; 0 for DefaultHostConcurrency
+	HostDelay       time.Duration // time between the starts of two requests to one host, raised by Crawl-delay
+}
+
+// Politeness makes requests the way a well-behaved crawler does: it skips the URLs robots.txt disallows
+// for its identity, honors Crawl-delay and limits the requests in flight to each host. robots.txt files
+// are fetched once per scheme and host and cached. A Politeness is safe for concurrent use, and shared
+// by the crawls and scrapes that should take turns on the same hosts.
+type Politeness struct {
+	identity Identity
+	config   PolitenessConfig
+	client   *http.Client // fetches robots.txt files
+
+	mu     sync.Mutex
+	robots map[string]*robotsEntry // by scheme://host
+	hosts  map[string]*hostLimit
+}
+
+// robotsEntry is a cached robots.txt file. ready is closed once the file has been fetched; data is nil
+// when it could not be, which allows everything.
+type robotsEntry struct {
+	ready   chan struct{}
+	data    *robotstxt.RobotsData
+	expires time.Time
+}
+
+// hostLimit holds the requests in flight to a host, and when the next one may start.
+type hostLimit struct {
+	slots chan struct{}
+	mu    sync.Mutex
+	next  time.Time
+}
+
+// NewPoliteness creates a politeness layer for the given identity.
+func NewPoliteness(identity Identity, config PolitenessConfig) *Politeness {
+	if config.RobotsTTL == 0 {
+		config.RobotsTTL = DefaultRobotsTTL
+	}
+	if config.HostConcurrency == 0 {
+		config.HostConcurrency = DefaultHostConcurrency
+	}
+	return &Politeness{
+		identity: identity,
+		config:   config,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		robots:   make(map[string]*robotsEntry),
+		hosts:    make(map[string]*hostLimit),
+	}
+}
+
+// DefaultPoliteness is the politeness layer of the crawler and the scraper, with DefaultIdentity and the
+// limits set by CRAB_ROBOTS_TTL, CRAB_HOST_CONCURRENCY and CRAB_HOST_DELAY.
+var DefaultPoliteness = NewPoliteness(DefaultIdentity, politenessConfigFromEnv())
+
+// politenessConfigFromEnv reads the politeness limits from the environment, logging and skipping
+// invalid values.
+func politenessConfigFromEnv() PolitenessConfig {
+	var config PolitenessConfig
+	durations := []struct {
+		env   string
+		field *time.Duration
+	}{
+		{"CRAB_ROBOTS_TTL", &config.RobotsTTL},
+		{"CRAB_HOST_DELAY", &config.HostDelay},
+	}
+	for _, d := range durations {
+		value := os.Getenv(d.env)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			log.Printf("%s: invalid duration %q", d.env, value)
+			continue
+		}
+		*d.field = parsed
+	}
+	if value := os.Getenv("CRAB_HOST_CONCURRENCY"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			log.Printf("CRAB_HOST_CONCURRENCY: invalid number %q", value)
+		} else {
+			config.HostConcurrency = n
+		}
+	}
+	return config
+}
+
+// Identity returns the identity the politeness layer makes requests with.
+func (p *Politeness) Identity() Identity {
+	return p.identity
+}
+
+// robotsFor returns the robots.txt file of the site of u, fetching it unless it is cached. Concurrent
+// callers wait for a single fetch.
+func (p *Politeness) robotsFor(u *url.URL) *robotstxt.RobotsData {
+	site := u.Scheme + "://" + u.Host
+	p.mu.Lock()
+	e := p.robots[site]
+	if e != nil {
+		select {
+		case <-e.ready:
+			if time.Now().After(e.expires) {
+				e = nil
+			}
+		default:
+		}
+	}
+	if e != nil {
+		p.mu.Unlock()
+		<-e.ready
+		return e.data
+	}
+	e = &robotsEntry{ready: make(chan struct{})}
+	p.robots[site] = e
+	p.mu.Unlock()
+
+	var err error
+	e.data, err = p.fetchRobots(site)
+	if err != nil {
+		log.Printf("Error fetching robots.txt of %s: %v", site, err)
+		e.expires = time.Now().Add(min(robotsErrorTTL, p.config.RobotsTTL))
+	} else {
+		e.expires = time.Now().Add(p.config.RobotsTTL)
+	}
+	close(e.ready)
+	return e.data
+}
+
+// fetchRobots fetches and parses the robots.txt file of site. A missing file allows everything and a
+// server error disallows everything, until it is fetched again.
+func (p *Politeness) fetchRobots(site string) (*robotstxt.RobotsData, error) {
+	req, err := http.NewRequest(http.MethodGet, site+"/robots.txt", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", p.identity.UserAgent)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 512<<10))
+	if err != nil {
+		return nil, err
+	}
+	return robotstxt.FromStatusAndBytes(resp.StatusCode, body)
+}
+
+// group returns the robots.txt rules that apply to crab on the site of u, nil when there are none.
+func (p *Politeness) group(u *url.URL) *robotstxt.Group {
+	robots := p.robotsFor(u)
+	if robots == nil {
+		return nil
+	}
+	return robots.FindGroup(p.identity.robotsAgent())
+}
+
+// allowed reports whether robots.txt allows crab to fetch u. robots.txt itself is always allowed.
+func (p *Politeness) allowed(u *url.URL) bool {
+	if u.Path == "/robots.txt" {
+		return true
+	}
+	robots := p.robotsFor(u)
+	return robots == nil || robots.TestAgent(u.RequestURI(), p.identity.robotsAgent())
+}
+
+// Allowed reports whether the robots.txt file of its site allows crab to fetch rawURL.
+func (p *Politeness) Allowed(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return p.allowed(u)
+}
+
+// CrawlDelay returns the time crab leaves between two requests to the host of rawURL: the larger of
+// the configured host delay and the Crawl-delay of its robots.txt file, up to MaxCrawlDelay.
+func (p *Politeness) CrawlDelay(rawURL string) time.Duration {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return p.config.HostDelay
+	}
+	return p.delay(u)
+}
+
+// delay is CrawlDelay for a parsed URL.
+func (p *Politeness) delay(u *url.URL) time.Duration {
+	delay := p.config.HostDelay
+	if g := p.group(u); g != nil {
+		delay = max(delay, min(g.CrawlDelay, MaxCrawlDelay))
+	}
+	return delay
+}
+
+// Sitemaps returns the sitemaps listed by the robots.txt file of the site of rawURL.
+func (p *Politeness) Sitemaps(rawURL string) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	if robots := p.robotsFor(u); robots != nil {
+		return robots.Sitemaps
+	}
+	return nil
+}
+
+// host returns the limits of host.
+func (p *Politeness) host(host string) *hostLimit {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h := p.hosts[host]
+	if h == nil {
+		h = &hostLimit{slots: make(chan struct{}, p.config.HostConcurrency)}
+		p.hosts[host] = h
+	}
+	return h
+}
+
+// acquire waits until a request to the host of req may start, and returns the function that ends it.
+func (p *Politeness) acquire(req *http.Request, delay time.Duration) (func(), error) {
+	h := p.host(req.URL.Host)
+	ctx := req.Context()
+	select {
+	case h.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	h.mu.Lock()
+	now := time.Now()
+	start := now
+	if h.next.After(now) {
+		start = h.next
+	}
+	h.next = start.Add(delay)
+	h.mu.Unlock()
+
+	if wait := start.Sub(now); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			<-h.slots
+			return nil, ctx.Err()
+		}
+	}
+	var once sync.Once
+	return func() { once.Do(func() { <-h.slots }) }, nil
+}
+
+// Transport returns a RoundTripper making the requests of base with the identity of p, once robots.txt
+// allows them and the host they go to is free. Disallowed requests fail with ErrDisallowed.
+func (p *Politeness) Transport(base http.RoundTripper) http.RoundTripper {
+	return &politeTransport{politeness: p, base: base}
+}
+
+// Client returns an HTTP client going through the politeness layer.
+func (p *Politeness) Client() *http.Client {
+	return &http.Client{Transport: p.Transport(http.DefaultTransport), Timeout: time.Minute}
+}
+
+// collector creates a colly collector making its requests through the politeness layer.
+func (p *Politeness) collector(options ...func(*colly.Collector)) *colly.Collector {
+	c := colly.NewCollector(append([]func(*colly.Collector){colly.UserAgent(p.identity.UserAgent)}, options...)...)
+	c.WithTransport(p.Transport(http.DefaultTransport))
+	return c
+}
+
+// politeTransport is the RoundTripper of Politeness.Transport.
+type politeTransport struct {
+	politeness *Politeness
+	base       http.RoundTripper
+}
+
+func (t *politeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	p := t.politeness
+	if !p.allowed(req.URL) {
+		robotsDisallowed.Inc()
+		return nil, fmt.Errorf("%s: %w", req.URL, ErrDisallowed)
+	}
+	release, err := p.acquire(req, p.delay(req.URL))
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", p.identity.UserAgent)
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	// The request holds its slot of the host until its body has been read
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releaseBody ends a request when its body is closed.
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
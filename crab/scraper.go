@@ -1,8 +1,12 @@
 package crab
 
 import (
+	"cmpscfa23team2/events"
+	"cmpscfa23team2/jsonpath"
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly"
@@ -15,58 +19,9 @@ import (
 	"time"
 )
 
-// urlQueue is a channel used for queuing URLs to be processed by the scraper.
-var urlQueue = make(chan string, 1000)
-
-// visited is a map used for keeping track of URLs that have already been visited by the scraper.
-var visited = make(map[string]bool)
-
-// domainConfigurations maps domain names to their respective scraping configurations. Each domain
-// has specific selectors and structures defined for scraping its relevant data.
-var domainConfigurations = map[string]DomainConfig{
-	"airfare": {
-		Name:                "airfare",
-		ItemSelector:        "div.article-content",      // Adjust this selector based on the structure of the page
-		TitleSelector:       "h1",                       // Adjust this selector based on the structure of the page
-		URLSelector:         "meta[property='og:url']",  // Adjust this selector based on the structure of the page
-		DescriptionSelector: "meta[name='description']", // Adjust this selector based on the structure of the page
-		PriceSelector:       "span.airfare-price",       // Adjust this selector based on the structure of the page
-	},
-	"books": {
-		Name:                "books",
-		ItemSelector:        "article.product_pod",
-		TitleSelector:       "h3 a",
-		URLSelector:         "h3 a",
-		DescriptionSelector: "p.description", // Selector assumed, replace with the actual selector
-		PriceSelector:       "div p.price_color",
-	},
-	"job-market": {
-		Name:                "job-market",
-		ItemSelector:        "div.job-posting",
-		TitleSelector:       "h2.job-title",
-		URLSelector:         "a.job-apply-link",
-		DescriptionSelector: "div.job-description",
-		PriceSelector:       "", // In job market, you might have salary rather than price
-	},
-	"nascar-predictem": {
-		Name:                "nascar-predictem",
-		ItemSelector:        "article",               // Selector for the main article
-		TitleSelector:       "h1.entry-title",        // Selector for the title
-		URLSelector:         "link[rel='canonical']", // Selector for the URL
-		DescriptionSelector: "p",                     // Selector for the article paragraphs
-		PriceSelector:       "",                      // Price selector might not be applicable here
-	},
-	"car-depreciation": {
-		Name:                            "car-depreciation",
-		ItemSelector:                    "div#content",
-		TitleSelector:                   "h1.entry-title",
-		URLSelector:                     "meta[property='og:url']",
-		DescriptionSelector:             "div#content p:first-of-type",
-		FactorsSelector:                 "div#content h2:contains('What causes a vehicle to depreciate?') + p, div#content h2:contains('What causes a vehicle to depreciate?') + ul > li",
-		DepreciationRatesSelector:       "div#content h2:contains('How much does a car depreciate per year?') + p",
-		ModelsLeastDepreciationSelector: "div#content h2:contains('Top 10 cars that depreciate the least') + p, div#content h2:contains('Top 10 cars that depreciate the least') + ul > li",
-		ModelsMostDepreciationSelector:  "div#content h2:contains('Top 10 cars that depreciate the most') + p, div#content h2:contains('Top 10 cars that depreciate the most') + ul > li",
-	},
+// GetDomainConfig returns the scraping configuration registered for the given domain name in Domains.
+func GetDomainConfig(domainName string) (DomainConfig, bool) {
+	return Domains.Get(domainName)
 }
 
 // ReadCSV reads a CSV file from the given file path, parses the data, and returns a slice of PropertyData.
@@ -127,136 +82,248 @@ func ReadCSV(filePath string) ([]PropertyData, error) {
 
 // Scrape performs the web scraping process for a given domain. It takes a URL to start scraping from,
 // a DomainConfig for scraping rules, and a WaitGroup for concurrency control. The function collects
-// scraped data and saves it to a JSON file.
+// scraped data and saves it to a JSON file, the time series of table domains to <name>_series.json.
 func Scrape(startingURL string, domainConfig DomainConfig, wg *sync.WaitGroup) {
 	defer wg.Done()
-	c := colly.NewCollector(
-		colly.UserAgent(GetRandomUserAgent()),
-	)
+	if domainConfig.Table != nil {
+		series, err := ScrapeSeries(startingURL, domainConfig)
+		if err != nil {
+			fmt.Printf("Error scraping %s: %v\n", startingURL, err)
+		}
+		filename := fmt.Sprintf("%s_series.json", domainConfig.Name)
+		if err := InsertSeries(SeriesData{Domain: domainConfig.Name, Data: series}, filename); err != nil {
+			fmt.Printf("Error saving data to JSON file: %v\n", err)
+		}
+		return
+	}
+	allData, err := ScrapeItems(startingURL, domainConfig)
+	if err != nil {
+		fmt.Printf("Error scraping %s: %v\n", startingURL, err)
+	}
 
-	// Container for scraped data
-	var allData []GenericData
+	// Save data to JSON file
+	filename := fmt.Sprintf("%s_data.json", domainConfig.Name)
+	err = InsertData(ItemData{
+		Domain: domainConfig.Name,
+		Data:   allData,
+	}, filename)
+	if err != nil {
+		fmt.Printf("Error saving data to JSON file: %v\n", err)
+	}
+}
 
-	// Define scraping logic based on the domain
-	switch domainConfig.Name {
-	case "car-depreciation":
-		c.OnHTML(domainConfig.ItemSelector, func(e *colly.HTMLElement) {
-			var factors, modelsLeast, modelsMost []string
-			var depreciationRates = make(map[string]string)
+// ScrapeItems visits startingURL, and the pages its pagination rule leads to, and returns the items
+// found with the given DomainConfig. It returns the error of the last attempt if the page could not be
+// fetched after all retries.
+func ScrapeItems(startingURL string, domainConfig DomainConfig) ([]GenericData, error) {
+	return ScrapeItemsWithEvents(startingURL, domainConfig, events.Default)
+}
 
-			// Extracting factors
-			e.ForEach(domainConfig.FactorsSelector, func(_ int, el *colly.HTMLElement) {
-				factors = append(factors, el.Text)
-			})
+// ScrapeItemsWithEvents is ScrapeItems reporting visited pages, scraped items and errors to pub.
+func ScrapeItemsWithEvents(startingURL string, domainConfig DomainConfig, pub events.Publisher) ([]GenericData, error) {
+	fields, err := domainConfig.compile()
+	if err != nil {
+		return nil, err
+	}
+	pager, err := newPaginator(domainConfig.Pagination, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	c := DefaultPoliteness.collector()
+	c.OnResponse(func(r *colly.Response) {
+		pager.startPage(r.Request.URL)
+		scraperPages.Inc(domainConfig.Name)
+		pub.Publish(events.Event{
+			Type: events.URLVisited,
+			URL:  r.Request.URL.String(),
+			Data: map[string]interface{}{"status": r.StatusCode, "bytes": len(r.Body), "domain": domainConfig.Name},
+		})
+	})
+	// Container for scraped data
+	var allData []GenericData
+	collect := func(item GenericData) {
+		if !pager.keep(item) {
+			return
+		}
+		allData = append(allData, item)
+		scraperItems.Inc(domainConfig.Name)
+		pub.Publish(events.Event{
+			Type:    events.ItemScraped,
+			URL:     item.URL,
+			Message: item.Title,
+			Data:    map[string]interface{}{"domain": domainConfig.Name, "source": item.Metadata.Source},
+		})
+	}
 
-			// Extracting models with least and most depreciation
-			e.ForEach(domainConfig.ModelsLeastDepreciationSelector, func(_ int, el *colly.HTMLElement) {
-				modelsLeast = append(modelsLeast, el.Text)
+	if domainConfig.format() == FormatJSON {
+		if err := onJSONItems(c, domainConfig, fields, collect, pager.setLink); err != nil {
+			return nil, err
+		}
+	} else {
+		// Every element matching the item selector becomes one item
+		if domainConfig.ItemSelector != "" {
+			c.OnHTML(domainConfig.ItemSelector, func(e *colly.HTMLElement) {
+				scope := itemScope{sel: e.DOM, page: e.DOM.Parents().Last()}
+				collect(extractItem(fields, scope, e.Request))
 			})
-			e.ForEach(domainConfig.ModelsMostDepreciationSelector, func(_ int, el *colly.HTMLElement) {
-				modelsMost = append(modelsMost, el.Text)
+		}
+		if domainConfig.Structured {
+			c.OnHTML("html", func(e *colly.HTMLElement) {
+				for _, item := range ExtractStructuredData(e.DOM).Items(e.Request.URL.String()) {
+					collect(item)
+				}
 			})
-
-			// Handling depreciation rates
-			e.ForEach(domainConfig.DepreciationRatesSelector, func(_ int, el *colly.HTMLElement) {
-				// Here you might need to parse the text to extract meaningful data
-				// This is an example, adjust it as per the actual text format
-				lines := strings.Split(el.Text, "\n")
-				for _, line := range lines {
-					parts := strings.SplitN(line, ": ", 2)
-					if len(parts) == 2 {
-						depreciationRates[parts[0]] = parts[1]
-					}
+		}
+		if domainConfig.Pagination.NextSelector != "" {
+			c.OnHTML(domainConfig.Pagination.NextSelector, func(e *colly.HTMLElement) {
+				if next := e.Attr("href"); next != "" {
+					pager.setLink(e.Request.AbsoluteURL(next))
 				}
 			})
+		}
+	}
+	// Once a page has been read, go on to the next one unless a stop condition of the domain is met
+	c.OnScraped(func(r *colly.Response) {
+		if next, ok := pager.next(r.Request.URL); ok {
+			r.Request.Visit(next)
+		}
+	})
 
-			// Constructing the item
-			currentItem := GenericData{
-				Title:                   e.ChildText(domainConfig.TitleSelector),
-				URL:                     e.Request.AbsoluteURL(e.ChildAttr(domainConfig.URLSelector, "href")),
-				Description:             e.ChildText(domainConfig.DescriptionSelector),
-				Price:                   "", // No specific price data for this domain
-				Factors:                 factors,
-				DepreciationRates:       depreciationRates,
-				ModelsLeastDepreciation: modelsLeast,
-				ModelsMostDepreciation:  modelsMost,
-				Metadata: Metadata{
-					Source:    e.Request.URL.String(),
-					Timestamp: time.Now().Format(time.RFC3339),
-				},
-			}
-
-			allData = append(allData, currentItem)
-		})
-	case "airfare", "books", "job-market", "nascar-predictem":
-		// General scraping logic for other domains
-		c.OnHTML(domainConfig.ItemSelector, func(e *colly.HTMLElement) {
-			itemURL := e.Request.AbsoluteURL(e.ChildAttr(domainConfig.URLSelector, "href"))
-
-			currentItem := GenericData{
-				Title:       e.ChildText(domainConfig.TitleSelector),
-				URL:         itemURL,
-				Description: e.ChildText(domainConfig.DescriptionSelector),
-				Price:       e.ChildText(domainConfig.PriceSelector),
-				Metadata: Metadata{
-					Source:    e.Request.URL.String(),
-					Timestamp: time.Now().Format(time.RFC3339),
-				},
-			}
-			allData = append(allData, currentItem)
-		})
+	err = visitWithRetries(c, startingURL)
+	if err != nil {
+		scraperErrors.Inc(domainConfig.Name)
+		pub.Publish(events.Event{Type: events.Error, URL: startingURL, Message: err.Error()})
 	}
+	return allData, err
+}
 
-	// Visit the URL with retry logic
+// visitWithRetries visits url with c, retrying failed attempts, and returns the error of the last one.
+// Colly marks a URL visited before fetching it, so retries are allowed to visit it again. URLs robots.txt
+// disallows are not retried.
+func visitWithRetries(c *colly.Collector, url string) error {
+	var err error
 	maxRetries := 6
+	revisit := c.AllowURLRevisit
+	defer func() { c.AllowURLRevisit = revisit }()
 	for i := 0; i < maxRetries; i++ {
-		err := c.Visit(startingURL)
-		if err == nil {
+		c.AllowURLRevisit = revisit || i > 0
+		err = c.Visit(url)
+		if err == nil || errors.Is(err, ErrDisallowed) {
 			break
 		}
-		fmt.Printf("Error visiting %s: %s, retrying (%d/%d)\n", startingURL, err, i+1, maxRetries)
+		fmt.Printf("Error visiting %s: %s, retrying (%d/%d)\n", url, err, i+1, maxRetries)
 		if i < maxRetries-1 {
 			time.Sleep(time.Second * 10)
 		}
 	}
+	return err
+}
 
-	// Save data to JSON file
-	filename := fmt.Sprintf("%s_data.json", domainConfig.Name)
-	err := InsertData(ItemData{
-		Domain: domainConfig.Name,
-		Data:   allData,
-	}, filename)
+// ScrapeSeries visits startingURL and reads the time series in its tables with the table mapping of
+// domainConfig. Tables missing a mapped column are reported in the error, after the values of the
+// other tables.
+func ScrapeSeries(startingURL string, domainConfig DomainConfig) ([]Observation, error) {
+	return ScrapeSeriesWithEvents(startingURL, domainConfig, events.Default)
+}
+
+// ScrapeSeriesWithEvents is ScrapeSeries reporting the visited page and errors to pub.
+func ScrapeSeriesWithEvents(startingURL string, domainConfig DomainConfig, pub events.Publisher) ([]Observation, error) {
+	if domainConfig.Table == nil {
+		return nil, fmt.Errorf("domain %q has no table mapping", domainConfig.Name)
+	}
+	c := DefaultPoliteness.collector()
+	c.OnResponse(func(r *colly.Response) {
+		scraperPages.Inc(domainConfig.Name)
+		pub.Publish(events.Event{
+			Type: events.URLVisited,
+			URL:  r.Request.URL.String(),
+			Data: map[string]interface{}{"status": r.StatusCode, "bytes": len(r.Body), "domain": domainConfig.Name},
+		})
+	})
+	var series []Observation
+	var errs []error
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		source := e.Request.URL.String()
+		tables := domainConfig.Table.Tables(e.DOM)
+		if len(tables) == 0 {
+			errs = append(errs, fmt.Errorf("%s: no table matches %q", source, domainConfig.Table.selector()))
+		}
+		for _, t := range tables {
+			observations, err := domainConfig.Table.Observations(t, source)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", source, err))
+				continue
+			}
+			series = append(series, observations...)
+			scraperItems.Add(float64(len(observations)), domainConfig.Name)
+		}
+	})
+	if err := visitWithRetries(c, startingURL); err != nil {
+		errs = append(errs, err)
+	}
+	err := errors.Join(errs...)
 	if err != nil {
-		fmt.Printf("Error saving data to JSON file: %v\n", err)
+		scraperErrors.Inc(domainConfig.Name)
+		pub.Publish(events.Event{Type: events.Error, URL: startingURL, Message: err.Error()})
 	}
+	return series, err
+}
+
+// onJSONItems makes c read the responses of a JSON domain: every value matched by the item path of
+// domainConfig becomes an item, and the first URL at its next-page path is passed to setLink.
+func onJSONItems(c *colly.Collector, domainConfig DomainConfig, fields []field, collect func(GenericData), setLink func(string)) error {
+	items, err := jsonpath.Compile(domainConfig.ItemSelector)
+	if err != nil {
+		return err
+	}
+	var next *jsonpath.Path
+	if domainConfig.Pagination.NextSelector != "" {
+		if next, err = jsonpath.Compile(domainConfig.Pagination.NextSelector); err != nil {
+			return err
+		}
+	}
+	c.OnResponse(func(r *colly.Response) {
+		var doc interface{}
+		if err := json.Unmarshal(r.Body, &doc); err != nil {
+			fmt.Printf("Error decoding JSON from %s: %v\n", r.Request.URL, err)
+			return
+		}
+		for _, v := range items.Find(doc) {
+			collect(extractItem(fields, itemScope{json: v}, r.Request))
+		}
+		if next == nil {
+			return
+		}
+		for _, v := range next.Find(doc) {
+			if link, ok := v.(string); ok && link != "" {
+				setLink(link)
+				return
+			}
+		}
+	})
+	return nil
 }
 
 //end scrape ===========================================================================================================
 
-// testScrape is a testing function for the scraper. It takes a domain name and triggers the Scrape
-// function using predefined test URLs for the domain. This function helps in validating the scraping logic
+// TestScrape is a testing function for the scraper. It takes a domain name and triggers the Scrape
+// function for each start URL of the domain. This function helps in validating the scraping logic
 // for different domains.
 func TestScrape(domainName string) {
-	domainConfig, exists := domainConfigurations[domainName]
+	domainConfig, exists := GetDomainConfig(domainName)
 	if !exists {
 		fmt.Printf("Invalid domain name provided: %s\n", domainName)
 		return
 	}
-
-	// Test URLs for the specified domain
-	testURLs := map[string][]string{
-		"airfare":          {"https://www.usinflationcalculator.com/inflation/airfare-inflation/"},
-		"books":            {"http://books.toscrape.com/catalogue/category/books/fiction_10/index.html"},
-		"job-market":       {"https://www.example.com/job-market"},
-		"nascar-predictem": {"https://www.predictem.com/nascar/xfinity-500-race-preview-picks/"},
-		"car-depreciation": {"https://www.thinkinsure.ca/insurance-help-centre/car-deprecation.html"},
+	if len(domainConfig.StartURLs) == 0 {
+		fmt.Printf("Domain %s has no start URLs\n", domainName)
+		return
 	}
 
-	startingURLs := testURLs[domainName]
 	var wg sync.WaitGroup
 
 	// Launch a goroutine for each URL
-	for _, url := range startingURLs {
+	for _, url := range domainConfig.StartURLs {
 		wg.Add(1)
 		go Scrape(url, domainConfig, &wg)
 	}
@@ -270,231 +337,193 @@ func TestScrape(domainName string) {
 // The following functions (airdatatest, scrapeInflationData, scrapeGasInflationData, scrapeHousingData)
 // are specific scraper implementations for different types of data like airfare, inflation, gasoline prices,
 // and housing data. Each function fetches data from specific URLs and processes it according to predefined
-// scraping rules and selectors, then writes the scraped data to JSON files.
-func Airdatatest() {
-	scrapeurl := "https://www.usinflationcalculator.com/inflation/airfare-inflation/"
-	res, err := http.Get(scrapeurl)
+// scraping rules and selectors, then writes the scraped data to JSON files. The table scrapers keep the file
+// formats the CUDA models read; the inflation and gasoline domains give the same tables as a time series.
+
+// fetchDocument downloads and parses an HTML page.
+func fetchDocument(pageURL string) (*goquery.Document, error) {
+	return fetchDocumentContext(context.Background(), pageURL)
+}
+
+// fetchDocumentContext is fetchDocument giving up when ctx is cancelled.
+func fetchDocumentContext(ctx context.Context, pageURL string) (*goquery.Document, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	res, err := DefaultPoliteness.Client().Do(req)
+	if err != nil {
+		return nil, err
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode != 200 {
-		log.Fatalf("status code error: %d %s", res.StatusCode, res.Status)
+		return nil, fmt.Errorf("status code error: %d %s", res.StatusCode, res.Status)
 	}
+	return goquery.NewDocumentFromReader(res.Body)
+}
 
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+// writeJSON writes v as indented JSON to filename.
+func writeJSON(filename string, v interface{}) error {
+	jsonData, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	if err := os.WriteFile(filename, jsonData, 0644); err != nil {
+		return fmt.Errorf("failed to write JSON data to file: %s", err)
+	}
+	return nil
+}
 
-	const switchYear = "2023" // Replace with the actual year
-	const switchMonth = "Dec" // Replace with the actual month
-
-	var months = []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
-	var isSecondTable = false
-	var file *os.File
+// months are the month columns of the usinflationcalculator.com tables, matched as header prefixes.
+var months = []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
 
-	// Open the first file
-	file, err = os.OpenFile("airfare_data_inflation.json", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+// Airdatatest downloads the airfare inflation page, which holds a table of monthly inflation rates and a
+// table of monthly average prices, and writes one AirfareData object per year of each to
+// airfare_data_inflation.json and airfare_data_price.json.
+func Airdatatest() {
+	scrapeurl := "https://www.usinflationcalculator.com/inflation/airfare-inflation/"
+	doc, err := fetchDocument(scrapeurl)
 	if err != nil {
-		log.Fatalf("Failed to open first JSON file: %s", err)
+		log.Fatal(err)
 	}
 
-	doc.Find("table tbody tr").Each(func(rowIndex int, rowHtml *goquery.Selection) {
-		if rowIndex == 0 {
-			return
-		}
-
-		var airfareData AirfareData
-		airfareData.Domain = "airfare"
-		airfareData.URL = scrapeurl
-		airfareData.Data.Title = "Airfare Inflation Data"
-		airfareData.Data.Location = "United States"
-		airfareData.Data.Features = []string{"Month", "Inflation Rate"}
-		airfareData.Data.AdditionalInfo.Country = "USA"
-		airfareData.Data.Metadata.Source = scrapeurl
-		airfareData.Data.Metadata.Timestamp = time.Now().Format(time.RFC3339)
-		airfareData.Data.AdditionalInfo.MonthsData = make([]MonthData, 0)
-
-		rowHtml.Find("td").Each(func(cellIndex int, cellHtml *goquery.Selection) {
-			cellText := cellHtml.Text()
-			if cellIndex == 0 {
-				airfareData.Data.Year = cellText
-			} else if cellIndex >= 1 && cellIndex <= 12 {
-				monthData := MonthData{
-					Month: months[cellIndex-1], // Correct usage of months array
-					Rate:  cellText,
-				}
-				airfareData.Data.AdditionalInfo.MonthsData = append(airfareData.Data.AdditionalInfo.MonthsData, monthData)
+	tables := ParseTables(doc.Selection)
+	files := []string{"airfare_data_inflation.json", "airfare_data_price.json"}
+	if len(tables) < len(files) {
+		log.Fatalf("found %d tables on %s, want %d", len(tables), scrapeurl, len(files))
+	}
+	for i, filename := range files {
+		var b strings.Builder
+		for j, row := range tables[i].Rows {
+			var airfareData AirfareData
+			airfareData.Domain = "airfare"
+			airfareData.URL = scrapeurl
+			airfareData.Data.Title = "Airfare Inflation Data"
+			airfareData.Data.Year = tables[i].Cell(row, "Year")
+			airfareData.Data.Location = "United States"
+			airfareData.Data.Features = []string{"Month", "Inflation Rate"}
+			airfareData.Data.AdditionalInfo.Country = "USA"
+			airfareData.Data.Metadata.Source = scrapeurl
+			airfareData.Data.Metadata.Timestamp = time.Now().Format(time.RFC3339)
+			airfareData.Data.AdditionalInfo.MonthsData = make([]MonthData, 0, len(months))
+			for _, month := range months {
+				airfareData.Data.AdditionalInfo.MonthsData = append(airfareData.Data.AdditionalInfo.MonthsData,
+					MonthData{Month: month, Rate: tables[i].Cell(row, month)})
 			}
-		})
 
-		// Switching logic for files
-		if airfareData.Data.Year == switchYear && !isSecondTable {
-			for _, monthData := range airfareData.Data.AdditionalInfo.MonthsData {
-				if monthData.Month == switchMonth {
-					file.Close()
-					file, err = os.OpenFile("airfare_data_price.json", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-					if err != nil {
-						log.Fatalf("Failed to open second JSON file: %s", err)
-					}
-					isSecondTable = true
-					break
-				}
+			jsonData, err := json.MarshalIndent(airfareData, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+			b.Write(jsonData)
+			if j < len(tables[i].Rows)-1 {
+				b.WriteString(",\n")
+			} else {
+				b.WriteString("\n")
 			}
 		}
-
-		jsonData, err := json.MarshalIndent(airfareData, "", "  ")
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		if _, err := file.Write(jsonData); err != nil {
+		if err := os.WriteFile(filename, []byte(b.String()), 0644); err != nil {
 			log.Fatalf("Failed to write JSON data to file: %s", err)
 		}
-		if rowIndex < doc.Find("table tbody tr").Length()-1 {
-			file.WriteString(",\n")
-		} else {
-			file.WriteString("\n")
-		}
-	})
-
-	file.Close()
+	}
 	log.Println("Airfare data written to respective files")
 }
 
 //end airfare scraper ==================================================================================================
 
 // begin inflation scraper ==============================================================================================
-func ScrapeInflationData() {
+// ScrapeInflationData downloads the current US inflation table and writes it to inflation_data.json.
+// It returns an error instead of exiting so it can run as a scheduled job.
+func ScrapeInflationData() error {
+	return ScrapeInflationDataContext(context.Background())
+}
+
+// ScrapeInflationDataContext is ScrapeInflationData stopping when ctx is cancelled.
+func ScrapeInflationDataContext(ctx context.Context) error {
 	scrapeurl := "https://www.usinflationcalculator.com/inflation/current-inflation-rates/"
-	res, err := http.Get(scrapeurl)
+	doc, err := fetchDocumentContext(ctx, scrapeurl)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	defer res.Body.Close()
-
-	if res.StatusCode != 200 {
-		log.Fatalf("status code error: %d %s", res.StatusCode, res.Status)
+	tables := ParseTables(doc.Selection)
+	if len(tables) == 0 {
+		return fmt.Errorf("no table found on %s", scrapeurl)
 	}
 
-	doc, err := goquery.NewDocumentFromReader(res.Body)
-	if err != nil {
-		log.Fatal(err)
+	t := tables[0]
+	// A column the site renamed would otherwise be written as empty values
+	months := []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+	if err := t.CheckColumns(append([]string{"Year", "Ave"}, months...)...); err != nil {
+		return fmt.Errorf("%s: %w", scrapeurl, err)
 	}
-
 	var data []YearData
-	doc.Find("table tbody tr").Each(func(rowIndex int, rowHtml *goquery.Selection) {
-		if rowIndex == 0 { // Skip the header row
-			return
-		}
-
-		var yearData YearData
-		rowHtml.Find("td").Each(func(cellIndex int, cellHtml *goquery.Selection) {
-			text := cellHtml.Text()
-			switch cellIndex {
-			case 0:
-				yearData.Year = cellHtml.Text()
-			case 1:
-				yearData.Jan = cellHtml.Text()
-			case 2:
-				yearData.Feb = cellHtml.Text()
-			case 3:
-				yearData.Mar = cellHtml.Text()
-			case 4:
-				yearData.Apr = cellHtml.Text()
-			case 5:
-				yearData.May = cellHtml.Text()
-			case 6:
-				yearData.Jun = cellHtml.Text()
-			case 7:
-				yearData.July = cellHtml.Text()
-			case 8:
-				yearData.Aug = cellHtml.Text()
-			case 9:
-				yearData.Sept = cellHtml.Text()
-			case 10:
-				yearData.Oct = cellHtml.Text()
-			case 11:
-				yearData.Nov = cellHtml.Text()
-			case 12:
-				yearData.Dec = cellHtml.Text()
-			case 13:
-				yearData.Avg = cellHtml.Text()
-				yearData.Avg = text
-			}
+	for _, row := range t.Rows {
+		data = append(data, YearData{
+			Year: t.Cell(row, "Year"),
+			Jan:  t.Cell(row, "Jan"),
+			Feb:  t.Cell(row, "Feb"),
+			Mar:  t.Cell(row, "Mar"),
+			Apr:  t.Cell(row, "Apr"),
+			May:  t.Cell(row, "May"),
+			Jun:  t.Cell(row, "Jun"),
+			July: t.Cell(row, "Jul"),
+			Aug:  t.Cell(row, "Aug"),
+			Sept: t.Cell(row, "Sep"),
+			Oct:  t.Cell(row, "Oct"),
+			Nov:  t.Cell(row, "Nov"),
+			Dec:  t.Cell(row, "Dec"),
+			Avg:  t.Cell(row, "Ave"),
 		})
-		data = append(data, yearData)
-	})
-
-	jsonData, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		log.Fatal(err)
 	}
-
-	err = ioutil.WriteFile("inflation_data.json", jsonData, 0644)
-	if err != nil {
-		log.Fatalf("Failed to write JSON data to file: %s", err)
+	if err := writeJSON("inflation_data.json", data); err != nil {
+		return err
 	}
 
 	fmt.Println("Inflation data written to inflation_data.json")
+	return nil
 }
 
 //end inflation scraper ================================================================================================
 
 // begin gasoline scraper =================================================================================================
-func ScrapeGasInflationData() {
+// ScrapeGasInflationData downloads the gasoline price table and writes it to gasoline_data.json.
+// It returns an error instead of exiting so it can run as a scheduled job.
+func ScrapeGasInflationData() error {
+	return ScrapeGasInflationDataContext(context.Background())
+}
+
+// ScrapeGasInflationDataContext is ScrapeGasInflationData stopping when ctx is cancelled.
+func ScrapeGasInflationDataContext(ctx context.Context) error {
 	scrapeurl := "https://www.usinflationcalculator.com/gasoline-prices-adjusted-for-inflation/"
-	res, err := http.Get(scrapeurl)
+	doc, err := fetchDocumentContext(ctx, scrapeurl)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	defer res.Body.Close()
-
-	if res.StatusCode != 200 {
-		log.Fatalf("status code error: %d %s", res.StatusCode, res.Status)
+	tables := ParseTables(doc.Selection)
+	if len(tables) == 0 {
+		return fmt.Errorf("no table found on %s", scrapeurl)
 	}
 
-	doc, err := goquery.NewDocumentFromReader(res.Body)
-	if err != nil {
-		log.Fatal(err)
+	t := tables[0]
+	if err := t.CheckColumns("Year", "Average Gasoline Price", "CPI", "Adjusted"); err != nil {
+		return fmt.Errorf("%s: %w", scrapeurl, err)
 	}
-
 	var data []GasolineData
-	doc.Find("table tbody tr").Each(func(rowIndex int, rowHtml *goquery.Selection) {
-		if rowIndex == 0 { // Skip the header row
-			return
-		}
-
-		var gasData GasolineData
-		rowHtml.Find("td").Each(func(cellIndex int, cellHtml *goquery.Selection) {
-			text := cellHtml.Text()
-			switch cellIndex {
-			case 0:
-				gasData.Year = text
-			case 1:
-				gasData.AverageGasolinePrices = text
-			case 2:
-				gasData.AverageAnnualCPIForGas = text
-			case 3:
-				gasData.GasPricesAdjustedForInfl = text
-			}
+	for _, row := range t.Rows {
+		data = append(data, GasolineData{
+			Year:                     t.Cell(row, "Year"),
+			AverageGasolinePrices:    t.Cell(row, "Average Gasoline Price"),
+			AverageAnnualCPIForGas:   t.Cell(row, "CPI"),
+			GasPricesAdjustedForInfl: t.Cell(row, "Adjusted"),
 		})
-		data = append(data, gasData)
-	})
-
-	jsonData, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		log.Fatal(err)
 	}
-
-	err = ioutil.WriteFile("gasoline_data.json", jsonData, 0644)
-	if err != nil {
-		log.Fatalf("Failed to write JSON data to file: %s", err)
+	if err := writeJSON("gasoline_data.json", data); err != nil {
+		return err
 	}
 
 	fmt.Println("Gasoline data written to gasoline_data.json")
+	return nil
 }
 
 //end gasoline scraper =================================================================================================
@@ -502,7 +531,7 @@ func ScrapeGasInflationData() {
 // begin housing scraper =================================================================================================
 func ScrapeHousingData() {
 	scrapeurl := "https://www.kaggle.com/datasets/ahmedshahriarsakib/usa-real-estate-dataset"
-	res, err := http.Get(scrapeurl)
+	res, err := DefaultPoliteness.Client().Get(scrapeurl)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,257 @@
+package crab
+
+import (
+	"bufio"
+	"bytes"
+	"cmpscfa23team2/canonical"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// wellKnownSitemaps are the paths tried on a site whose robots.txt lists no sitemap.
+var wellKnownSitemaps = []string{"/sitemap.xml", "/sitemap_index.xml", "/sitemap.xml.gz"}
+
+// Limits on the sitemaps a SitemapReader reads; a sitemap may hold 50MB by the protocol at sitemaps.org.
+const (
+	maxSitemapBytes     = 50 << 20
+	DefaultSitemapFiles = 100
+	maxSitemapDepth     = 3 // sitemap indexes within sitemap indexes
+	defaultSitemapScore = 0.5
+)
+
+// sitemapDateLayouts are the W3C datetime formats of lastmod.
+var sitemapDateLayouts = []string{time.RFC3339, "2006-01-02T15:04Z07:00", "2006-01-02T15:04:05", "2006-01-02", "2006-01", "2006"}
+
+// SitemapURL is a page listed by a sitemap.
+type SitemapURL struct {
+	Loc        string    `json:"loc"`
+	LastMod    time.Time `json:"lastmod,omitempty"` // zero when the sitemap does not say
+	ChangeFreq string    `json:"changefreq,omitempty"`
+	Priority   float64   `json:"priority"` // 0 to 1; 0.5 when the sitemap does not say
+}
+
+// sitemapDocument is a sitemap or a sitemap index. Namespaces are ignored, as many sites get them wrong.
+type sitemapDocument struct {
+	XMLName xml.Name
+	URLs    []struct {
+		Loc        string `xml:"loc"`
+		LastMod    string `xml:"lastmod"`
+		ChangeFreq string `xml:"changefreq"`
+		Priority   string `xml:"priority"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// SitemapReader discovers and reads the sitemaps of sites through a politeness layer.
+type SitemapReader struct {
+	politeness *Politeness
+	client     *http.Client
+	MaxFiles   int // sitemap files read per site, indexes included; 0 for DefaultSitemapFiles
+}
+
+// NewSitemapReader creates a reader fetching sitemaps through p.
+func NewSitemapReader(p *Politeness) *SitemapReader {
+	return &SitemapReader{politeness: p, client: p.Client()}
+}
+
+// Discover returns the sitemaps of the site of rawURL: those its robots.txt lists, or the well-known
+// sitemap paths of the site when it lists none.
+func (r *SitemapReader) Discover(rawURL string) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	if sitemaps := r.politeness.Sitemaps(rawURL); len(sitemaps) > 0 {
+		return sitemaps
+	}
+	var sitemaps []string
+	for _, p := range wellKnownSitemaps {
+		sitemaps = append(sitemaps, u.Scheme+"://"+u.Host+p)
+	}
+	return sitemaps
+}
+
+// ReadSite returns the pages listed by the sitemaps of the site of rawURL, following sitemap indexes,
+// with the pages of the highest priority and the most recently modified first and every page once.
+// Pages on other hosts than the site are dropped, as the sitemaps.org protocol requires, even when
+// robots.txt lists a sitemap on another host. Missing sitemaps are skipped. Sitemaps listed by
+// robots.txt that cannot be read are reported in the error, after the pages of the others; well-known
+// paths that turn out not to be sitemaps are not.
+func (r *SitemapReader) ReadSite(rawURL string) ([]SitemapURL, error) {
+	site, err := url.Parse(rawURL)
+	if err != nil || site.Host == "" {
+		return nil, fmt.Errorf("%q is not an absolute URL", rawURL)
+	}
+	var pages []SitemapURL
+	var errs []error
+	listed := len(r.politeness.Sitemaps(rawURL)) > 0
+	seen := make(map[string]bool)
+	budget := r.MaxFiles
+	if budget == 0 {
+		budget = DefaultSitemapFiles
+	}
+	for _, sitemap := range r.Discover(rawURL) {
+		err := r.read(sitemap, site.Host, 0, &budget, seen, &pages)
+		if err != nil && listed && !errors.Is(err, errSitemapMissing) {
+			errs = append(errs, err)
+		}
+	}
+	SortSitemapURLs(pages)
+	unique := pages[:0]
+	locs := make(map[string]bool)
+	for _, page := range pages {
+		if loc := canonical.URL(page.Loc); !locs[loc] {
+			locs[loc] = true
+			unique = append(unique, page)
+		}
+	}
+	return unique, errors.Join(errs...)
+}
+
+// Read returns the pages listed by the sitemap at sitemapURL, following sitemap indexes. Pages on other
+// hosts than the sitemap are dropped.
+func (r *SitemapReader) Read(sitemapURL string) ([]SitemapURL, error) {
+	u, err := url.Parse(sitemapURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an absolute URL", sitemapURL)
+	}
+	var pages []SitemapURL
+	budget := r.MaxFiles
+	if budget == 0 {
+		budget = DefaultSitemapFiles
+	}
+	err = r.read(sitemapURL, u.Host, 0, &budget, make(map[string]bool), &pages)
+	return pages, err
+}
+
+// errSitemapMissing is the error of a sitemap the server does not have.
+var errSitemapMissing = errors.New("no sitemap")
+
+// read appends the pages on host of the sitemap at sitemapURL to pages, and reads the sitemaps on host
+// it lists when it is an index. seen holds the sitemaps read so far and budget the number still allowed.
+func (r *SitemapReader) read(sitemapURL, host string, depth int, budget *int, seen map[string]bool, pages *[]SitemapURL) error {
+	if seen[sitemapURL] || *budget <= 0 {
+		return nil
+	}
+	seen[sitemapURL] = true
+	*budget--
+	body, err := r.fetch(sitemapURL)
+	if err != nil {
+		return err
+	}
+	base, _ := url.Parse(sitemapURL)
+
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] != '<' {
+		// A text sitemap lists one URL per line
+		scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "http://") && !strings.HasPrefix(line, "https://") {
+				continue
+			}
+			if loc := resolveLoc(base, line, host); loc != "" {
+				*pages = append(*pages, SitemapURL{Loc: loc, Priority: defaultSitemapScore})
+			}
+		}
+		return nil
+	}
+
+	var doc sitemapDocument
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return fmt.Errorf("sitemap %s: %v", sitemapURL, err)
+	}
+	for _, u := range doc.URLs {
+		loc := resolveLoc(base, u.Loc, host)
+		if loc == "" {
+			continue
+		}
+		page := SitemapURL{Loc: loc, ChangeFreq: strings.TrimSpace(u.ChangeFreq), Priority: defaultSitemapScore}
+		page.LastMod, _ = parseDate(strings.TrimSpace(u.LastMod), sitemapDateLayouts)
+		if p, err := strconv.ParseFloat(strings.TrimSpace(u.Priority), 64); err == nil && p >= 0 && p <= 1 {
+			page.Priority = p
+		}
+		*pages = append(*pages, page)
+	}
+	if len(doc.Sitemaps) > 0 && depth >= maxSitemapDepth {
+		return fmt.Errorf("sitemap %s: sitemap indexes nested more than %d deep", sitemapURL, maxSitemapDepth)
+	}
+	var errs []error
+	for _, s := range doc.Sitemaps {
+		if loc := resolveLoc(base, s.Loc, host); loc != "" {
+			if err := r.read(loc, host, depth+1, budget, seen, pages); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fetch downloads a sitemap, gunzipping it when it is compressed.
+func (r *SitemapReader) fetch(sitemapURL string) ([]byte, error) {
+	resp, err := r.client.Get(sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return nil, fmt.Errorf("sitemap %s: %w", sitemapURL, errSitemapMissing)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("sitemap %s: status %s", sitemapURL, resp.Status)
+	}
+	body := bufio.NewReader(resp.Body)
+	var reader io.Reader = body
+	// Compressed sitemaps are files of their own, not a Content-Encoding, so look at the content
+	if magic, err := body.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("sitemap %s: %v", sitemapURL, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, maxSitemapBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("sitemap %s: %v", sitemapURL, err)
+	}
+	if len(data) > maxSitemapBytes {
+		return nil, fmt.Errorf("sitemap %s: larger than %d bytes", sitemapURL, maxSitemapBytes)
+	}
+	return data, nil
+}
+
+// resolveLoc returns loc as an absolute http(s) URL on host, "" when it is not one.
+func resolveLoc(base *url.URL, loc, host string) string {
+	loc = strings.TrimSpace(loc)
+	if loc == "" || base == nil {
+		return ""
+	}
+	u, err := base.Parse(loc)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !strings.EqualFold(u.Host, host) {
+		return ""
+	}
+	return u.String()
+}
+
+// SortSitemapURLs orders pages by priority, then by how recently they were modified, highest and most
+// recent first. Pages without a lastmod come after the others of their priority.
+func SortSitemapURLs(pages []SitemapURL) {
+	sort.SliceStable(pages, func(i, j int) bool {
+		if pages[i].Priority != pages[j].Priority {
+			return pages[i].Priority > pages[j].Priority
+		}
+		return pages[i].LastMod.After(pages[j].LastMod)
+	})
+}
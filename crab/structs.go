@@ -5,13 +5,20 @@ import "time"
 // URLData holds information about a specific URL to be crawled, including the URL itself, creation timestamp,
 // and any discovered links.
 type URLData struct {
-	URL     string    // The URL to be crawled
-	Created time.Time // Timestamp of URL creation or retrieval
-	Links   []string  // URLs found on this page
+	URL          string        // The URL to be crawled
+	Canonical    string        // Canonical URL the page declares with rel=canonical, if any
+	Created      time.Time     // Timestamp of URL creation or retrieval
+	Status       int           // HTTP status code of the response, 0 when the page could not be fetched
+	ContentType  string        // Media type of the response, without parameters
+	LastModified time.Time     // Last-Modified header of the response, if any
+	Depth        int           // Links followed from the seed of the crawl to reach the page
+	Links        []string      // Canonical forms of the URLs found on this page, without repeats
+	Items        []GenericData // Products, offers, articles and job postings the page describes in structured data
+	Jobs         []JobData     // Job postings the page describes in structured data
 }
 
-// MonthData, AirfareData, YearData, GasolineData, PropertyData, ScraperConfig, DomainConfig, Metadata,
-// GenericData, and ItemData are struct definitions used across the scraper and crawler functionalities.
+// MonthData, AirfareData, YearData, GasolineData, PropertyData, ScraperConfig, Metadata,
+// GenericData, JobData and ItemData are struct definitions used across the scraper and crawler functionalities.
 // Each struct is tailored to hold specific types of data, ranging from URL information to scraped data
 // for various domains.
 
@@ -86,37 +93,35 @@ type ScraperConfig struct {
 	StartingURLs []string
 }
 
-// DomainConfig represents configuration for different domains.
-type DomainConfig struct {
-	Name                            string
-	ItemSelector                    string
-	TitleSelector                   string
-	URLSelector                     string
-	DescriptionSelector             string
-	PriceSelector                   string
-	FactorsSelector                 string
-	DepreciationRatesSelector       string
-	ModelsLeastDepreciationSelector string
-	ModelsMostDepreciationSelector  string
-}
-
 // Metadata represents metadata for scraped data.
 type Metadata struct {
 	Source    string `json:"source"`
 	Timestamp string `json:"timestamp"`
 }
 
-// GenericData represents generic data structure for items.
+// GenericData represents generic data structure for items. Fields holds the values of configured
+// fields that have no struct field of their own, by field name.
 type GenericData struct {
-	Title                   string            `json:"title"`
-	URL                     string            `json:"url"`
-	Description             string            `json:"description"`
-	Price                   string            `json:"price"`
-	Factors                 []string          `json:"factors"`
-	DepreciationRates       map[string]string `json:"depreciation_rates"`
-	ModelsLeastDepreciation []string          `json:"models_least_depreciation"`
-	ModelsMostDepreciation  []string          `json:"models_most_depreciation"`
-	Metadata                Metadata          `json:"metadata"`
+	Title                   string                 `json:"title"`
+	URL                     string                 `json:"url"`
+	Description             string                 `json:"description"`
+	Price                   string                 `json:"price"`
+	Factors                 []string               `json:"factors"`
+	DepreciationRates       map[string]string      `json:"depreciation_rates"`
+	ModelsLeastDepreciation []string               `json:"models_least_depreciation"`
+	ModelsMostDepreciation  []string               `json:"models_most_depreciation"`
+	Fields                  map[string]interface{} `json:"fields,omitempty"`
+	Metadata                Metadata               `json:"metadata"`
+}
+
+// JobData represents a job posting, in the format of the job files read by the CUDA Naive Bayes classifier.
+type JobData struct {
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Description string `json:"description"`
+	Salary      string `json:"salary"`
+	Company     string `json:"company"`
+	Location    string `json:"location"`
 }
 
 // ItemData represents data for an item.
@@ -124,3 +129,9 @@ type ItemData struct {
 	Domain string        `json:"domain"`
 	Data   []GenericData `json:"data"`
 }
+
+// SeriesData represents the time series read from the tables of a domain.
+type SeriesData struct {
+	Domain string        `json:"domain"`
+	Data   []Observation `json:"data"`
+}
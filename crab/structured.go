@@ -0,0 +1,470 @@
+package crab
+
+import (
+	"encoding/json"
+	"github.com/PuerkitoBio/goquery"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StructuredData is the machine-readable data a page embeds for search engines and social networks.
+// Microdata items are stored in the shape of JSON-LD, with their type under "@type", so both can be
+// read the same way.
+type StructuredData struct {
+	JSONLD    []map[string]interface{} `json:"json_ld,omitempty"`
+	Microdata []map[string]interface{} `json:"microdata,omitempty"`
+	Meta      map[string]string        `json:"meta,omitempty"` // og:*, twitter:*, article:*, product:* and description
+	Title     string                   `json:"title,omitempty"`
+	Canonical string                   `json:"canonical,omitempty"`
+}
+
+// articleTypes are the schema.org types mapped as articles.
+var articleTypes = map[string]bool{
+	"Article": true, "NewsArticle": true, "BlogPosting": true, "Report": true, "ScholarlyArticle": true, "TechArticle": true,
+}
+
+// ExtractStructuredData reads every JSON-LD block, every top-level microdata item and the OpenGraph
+// and Twitter meta tags of the page rooted at doc. Blocks that are not valid JSON are skipped.
+func ExtractStructuredData(doc *goquery.Selection) StructuredData {
+	var data StructuredData
+	doc.Find(`script[type="application/ld+json"]`).Each(func(_ int, s *goquery.Selection) {
+		var v interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(s.Text())), &v); err != nil {
+			return
+		}
+		data.JSONLD = append(data.JSONLD, jsonLDEntities(v)...)
+	})
+	doc.Find("[itemscope]:not([itemprop])").Each(func(_ int, s *goquery.Selection) {
+		data.Microdata = append(data.Microdata, microdataItem(s))
+	})
+	doc.Find("meta[property], meta[name]").Each(func(_ int, s *goquery.Selection) {
+		key := strings.ToLower(s.AttrOr("property", s.AttrOr("name", "")))
+		content := strings.TrimSpace(s.AttrOr("content", ""))
+		if content == "" || !isStructuredMeta(key) {
+			return
+		}
+		if data.Meta == nil {
+			data.Meta = make(map[string]string)
+		}
+		if _, ok := data.Meta[key]; !ok {
+			data.Meta[key] = content
+		}
+	})
+	data.Title = collapseSpace(doc.Find("title").First().Text())
+	data.Canonical = strings.TrimSpace(doc.Find(`link[rel="canonical"]`).First().AttrOr("href", ""))
+	return data
+}
+
+// isStructuredMeta reports whether a meta tag is one ExtractStructuredData keeps.
+func isStructuredMeta(key string) bool {
+	for _, prefix := range []string{"og:", "twitter:", "article:", "product:"} {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return key == "description"
+}
+
+// jsonLDEntities returns the objects of a JSON-LD block: the block itself, the elements of a top-level
+// array, or the members of an @graph.
+func jsonLDEntities(v interface{}) []map[string]interface{} {
+	var out []map[string]interface{}
+	switch v := v.(type) {
+	case []interface{}:
+		for _, elem := range v {
+			out = append(out, jsonLDEntities(elem)...)
+		}
+	case map[string]interface{}:
+		if graph, ok := v["@graph"]; ok {
+			return jsonLDEntities(graph)
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+// microdataItem converts the microdata item rooted at s into a JSON-LD style object.
+func microdataItem(s *goquery.Selection) map[string]interface{} {
+	item := make(map[string]interface{})
+	if itemType := strings.Fields(s.AttrOr("itemtype", "")); len(itemType) > 0 {
+		item["@type"] = itemType[0]
+	}
+	if id, ok := s.Attr("itemid"); ok {
+		item["@id"] = id
+	}
+	collectMicrodata(s.Children(), item)
+	return item
+}
+
+// collectMicrodata adds the properties found in sel, without entering nested items, to item.
+func collectMicrodata(sel *goquery.Selection, item map[string]interface{}) {
+	sel.Each(func(_ int, s *goquery.Selection) {
+		_, scope := s.Attr("itemscope")
+		if props, ok := s.Attr("itemprop"); ok {
+			var value interface{}
+			if scope {
+				value = microdataItem(s)
+			} else {
+				value = microdataValue(s)
+			}
+			for _, prop := range strings.Fields(props) {
+				switch existing := item[prop].(type) {
+				case nil:
+					item[prop] = value
+				case []interface{}:
+					item[prop] = append(existing, value)
+				default:
+					item[prop] = []interface{}{existing, value}
+				}
+			}
+		}
+		if !scope {
+			collectMicrodata(s.Children(), item)
+		}
+	})
+}
+
+// microdataValue returns the value of a property element as the microdata spec defines it.
+func microdataValue(s *goquery.Selection) string {
+	if content, ok := s.Attr("content"); ok {
+		return strings.TrimSpace(content)
+	}
+	var attr string
+	switch goquery.NodeName(s) {
+	case "a", "area", "link":
+		attr = "href"
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		attr = "src"
+	case "object":
+		attr = "data"
+	case "data", "meter":
+		attr = "value"
+	case "time":
+		attr = "datetime"
+	}
+	if value, ok := s.Attr(attr); attr != "" && ok {
+		return strings.TrimSpace(value)
+	}
+	return collapseSpace(s.Text())
+}
+
+// Entities returns the JSON-LD objects and microdata items of the page.
+func (d StructuredData) Entities() []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(d.JSONLD)+len(d.Microdata))
+	out = append(out, d.JSONLD...)
+	return append(out, d.Microdata...)
+}
+
+// Items maps the products, offers, articles and job postings of the page onto GenericData. A page
+// without any of them yields one item built from its OpenGraph and Twitter tags, if it has a title.
+// pageURL is used for items that name no URL of their own.
+func (d StructuredData) Items(pageURL string) []GenericData {
+	var items []GenericData
+	for _, entity := range d.Entities() {
+		t := schemaType(entity)
+		if t == "" {
+			continue
+		}
+		item := GenericData{
+			Title:       text(entity["name"]),
+			URL:         text(entity["url"]),
+			Description: plainText(text(entity["description"])),
+			Fields:      map[string]interface{}{"type": t},
+		}
+		switch {
+		case t == "Product":
+			price, currency, availability := offerDetails(entity["offers"])
+			item.Price = price
+			setField(&item, "currency", currency)
+			setField(&item, "availability", availability)
+			setField(&item, "brand", text(entity["brand"]))
+			setField(&item, "sku", text(entity["sku"]))
+			setField(&item, "image", text(entity["image"]))
+			if rating, ok := entity["aggregateRating"].(map[string]interface{}); ok {
+				setField(&item, "rating", text(rating["ratingValue"]))
+			}
+		case t == "Offer" || t == "AggregateOffer":
+			price, currency, availability := offerDetails(entity)
+			item.Price = price
+			setField(&item, "currency", currency)
+			setField(&item, "availability", availability)
+		case articleTypes[t]:
+			if headline := text(entity["headline"]); headline != "" {
+				item.Title = headline
+			}
+			setField(&item, "author", text(entity["author"]))
+			setField(&item, "publisher", text(entity["publisher"]))
+			setField(&item, "date_published", text(entity["datePublished"]))
+			setField(&item, "date_modified", text(entity["dateModified"]))
+			setField(&item, "image", text(entity["image"]))
+		case t == "JobPosting":
+			job := jobPosting(entity)
+			item.Title, item.Description, item.Price = job.Title, job.Description, job.Salary
+			setField(&item, "company", job.Company)
+			setField(&item, "location", job.Location)
+			setField(&item, "date_posted", text(entity["datePosted"]))
+			setField(&item, "valid_through", text(entity["validThrough"]))
+			setField(&item, "employment_type", text(entity["employmentType"]))
+		default:
+			continue
+		}
+		items = append(items, d.complete(item, pageURL))
+	}
+	if len(items) == 0 {
+		if item, ok := d.metaItem(pageURL); ok {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Jobs maps the schema.org JobPosting entities of the page onto JobData.
+func (d StructuredData) Jobs(pageURL string) []JobData {
+	var jobs []JobData
+	for _, entity := range d.Entities() {
+		if schemaType(entity) != "JobPosting" {
+			continue
+		}
+		job := jobPosting(entity)
+		if job.URL == "" {
+			job.URL = d.pageURL(pageURL)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// jobPosting maps a JobPosting entity onto JobData.
+func jobPosting(entity map[string]interface{}) JobData {
+	job := JobData{
+		Title:       text(entity["title"]),
+		URL:         text(entity["url"]),
+		Description: plainText(text(entity["description"])),
+		Salary:      salary(entity["baseSalary"]),
+		Company:     text(entity["hiringOrganization"]),
+		Location:    location(entity["jobLocation"]),
+	}
+	if job.Title == "" {
+		job.Title = text(entity["name"])
+	}
+	if job.Salary == "" {
+		job.Salary = salary(entity["estimatedSalary"])
+	}
+	if job.Location == "" && text(entity["jobLocationType"]) == "TELECOMMUTE" {
+		job.Location = "Remote"
+	}
+	return job
+}
+
+// complete fills what an entity left out from the meta tags of the page and stamps the item.
+func (d StructuredData) complete(item GenericData, pageURL string) GenericData {
+	if item.Title == "" {
+		item.Title = d.metaTitle()
+	}
+	if item.URL == "" {
+		item.URL = d.pageURL(pageURL)
+	}
+	if item.Description == "" {
+		item.Description = d.metaDescription()
+	}
+	item.Metadata = Metadata{Source: pageURL, Timestamp: time.Now().Format(time.RFC3339)}
+	return item
+}
+
+// metaItem builds an item from the OpenGraph and Twitter tags of the page.
+func (d StructuredData) metaItem(pageURL string) (GenericData, bool) {
+	item := GenericData{Title: d.metaTitle(), Price: d.first("product:price:amount", "og:price:amount")}
+	if item.Title == "" {
+		return item, false
+	}
+	setField(&item, "type", d.Meta["og:type"])
+	setField(&item, "site_name", d.Meta["og:site_name"])
+	setField(&item, "image", d.first("og:image", "twitter:image"))
+	setField(&item, "currency", d.first("product:price:currency", "og:price:currency"))
+	return d.complete(item, pageURL), true
+}
+
+func (d StructuredData) metaTitle() string {
+	if title := d.first("og:title", "twitter:title"); title != "" {
+		return title
+	}
+	return d.Title
+}
+
+func (d StructuredData) metaDescription() string {
+	return d.first("og:description", "twitter:description", "description")
+}
+
+// pageURL returns the URL the page names for itself, or pageURL.
+func (d StructuredData) pageURL(pageURL string) string {
+	if u := d.first("og:url"); u != "" {
+		return u
+	}
+	if d.Canonical != "" {
+		return d.Canonical
+	}
+	return pageURL
+}
+
+// first returns the first of the given meta tags the page has.
+func (d StructuredData) first(keys ...string) string {
+	for _, key := range keys {
+		if value := d.Meta[key]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// setField stores a non-empty value in the Fields of item.
+func setField(item *GenericData, name, value string) {
+	if value == "" {
+		return
+	}
+	if item.Fields == nil {
+		item.Fields = make(map[string]interface{})
+	}
+	item.Fields[name] = value
+}
+
+// schemaType returns the first schema.org type of an entity without the vocabulary prefix, e.g.
+// "Product" for "https://schema.org/Product".
+func schemaType(entity map[string]interface{}) string {
+	t := entity["@type"]
+	if types, ok := t.([]interface{}); ok && len(types) > 0 {
+		t = types[0]
+	}
+	name, _ := t.(string)
+	if i := strings.LastIndexAny(name, "/#"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// text returns a property as text: strings and numbers as they are, the name (or @id) of an object
+// and the first element of an array.
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		if len(v) > 0 {
+			return text(v[0])
+		}
+	case map[string]interface{}:
+		for _, key := range []string{"name", "url", "@id"} {
+			if s := text(v[key]); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// offerDetails returns the price, currency and availability of an Offer, an AggregateOffer or the
+// first of a list of offers.
+func offerDetails(v interface{}) (price, currency, availability string) {
+	if offers, ok := v.([]interface{}); ok && len(offers) > 0 {
+		v = offers[0]
+	}
+	offer, ok := v.(map[string]interface{})
+	if !ok {
+		return "", "", ""
+	}
+	price = text(offer["price"])
+	if price == "" {
+		price = text(offer["lowPrice"])
+	}
+	if spec, ok := offer["priceSpecification"].(map[string]interface{}); ok && price == "" {
+		price, currency = text(spec["price"]), text(spec["priceCurrency"])
+	}
+	if c := text(offer["priceCurrency"]); c != "" {
+		currency = c
+	}
+	availability = text(offer["availability"])
+	if i := strings.LastIndexAny(availability, "/#"); i >= 0 {
+		availability = availability[i+1:]
+	}
+	return price, currency, availability
+}
+
+// salary formats a MonetaryAmount such as {"currency": "USD", "value": {"minValue": 90000,
+// "maxValue": 120000, "unitText": "YEAR"}} as "USD 90000-120000 per year".
+func salary(v interface{}) string {
+	amount, ok := v.(map[string]interface{})
+	if !ok {
+		return text(v)
+	}
+	value := amount["value"]
+	var figure, unit string
+	if q, ok := value.(map[string]interface{}); ok {
+		unit = text(q["unitText"])
+		low, high := text(q["minValue"]), text(q["maxValue"])
+		switch {
+		case text(q["value"]) != "":
+			figure = text(q["value"])
+		case low != "" && high != "" && low != high:
+			figure = low + "-" + high
+		case low != "":
+			figure = low
+		default:
+			figure = high
+		}
+	} else {
+		figure = text(value)
+	}
+	if figure == "" {
+		return ""
+	}
+	if currency := text(amount["currency"]); currency != "" {
+		figure = currency + " " + figure
+	}
+	if unit != "" {
+		figure += " per " + strings.ToLower(unit)
+	}
+	return figure
+}
+
+// location formats the address of a jobLocation Place as "locality, region, country".
+func location(v interface{}) string {
+	if places, ok := v.([]interface{}); ok && len(places) > 0 {
+		v = places[0]
+	}
+	place, ok := v.(map[string]interface{})
+	if !ok {
+		return text(v)
+	}
+	address, ok := place["address"].(map[string]interface{})
+	if !ok {
+		return text(place["address"])
+	}
+	var parts []string
+	for _, key := range []string{"addressLocality", "addressRegion", "addressCountry"} {
+		if part := text(address[key]); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// plainText strips the markup job boards put in JobPosting descriptions.
+func plainText(s string) string {
+	if !strings.Contains(s, "<") {
+		return s
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(s))
+	if err != nil {
+		return s
+	}
+	return collapseSpace(doc.Text())
+}
+
+// collapseSpace trims s and replaces runs of white space with single spaces.
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,408 @@
+package crab
+
+import (
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// UnitPercent is the unit ParseValue reports for percentages; currencies are reported by ISO 4217 code.
+const UnitPercent = "percent"
+
+// maxColspan bounds the colspan and rowspan of a cell, so a broken attribute cannot blow up a table.
+const maxColspan = 100
+
+// currencySymbols maps the currency signs ParseValue understands to their ISO 4217 codes, longest first.
+var currencySymbols = [][2]string{
+	{"US$", "USD"},
+	{"$", "USD"},
+	{"£", "GBP"},
+	{"€", "EUR"},
+	{"¥", "JPY"},
+}
+
+var (
+	// currencyPrefix and currencySuffix match ISO 4217 codes, as in "USD 95,000" or "95,000 EUR".
+	currencyPrefix = regexp.MustCompile(`^([A-Z]{3})\s*`)
+	currencySuffix = regexp.MustCompile(`\s*([A-Z]{3})$`)
+	// numberPattern accepts plain decimals and decimals with comma thousands separators.
+	numberPattern = regexp.MustCompile(`^(\d{1,3}(,\d{3})+|\d+)?(\.\d+)?$`)
+)
+
+// Table holds the text of an HTML table: the header cells and the cells of every data row. A cell
+// spanning several columns or rows is repeated in each of them, so all rows line up with the header.
+type Table struct {
+	Header []string   `json:"header"`
+	Rows   [][]string `json:"rows"`
+}
+
+// ParseTables parses every table in doc, in document order.
+func ParseTables(doc *goquery.Selection) []Table {
+	var tables []Table
+	doc.Find("table").Each(func(_ int, s *goquery.Selection) {
+		tables = append(tables, ParseTable(s))
+	})
+	return tables
+}
+
+// ParseTable parses one table element. The header is the last row of thead, else the first row made of
+// th cells only, else a first row without any number in it, as many sites put their header in tbody
+// with td cells. Tables whose first row holds numbers have no header. Empty rows and repetitions of
+// the header further down the table are dropped.
+func ParseTable(table *goquery.Selection) Table {
+	trs := table.ChildrenFiltered("thead, tbody, tfoot").ChildrenFiltered("tr")
+	rows := tableRows(trs)
+	var t Table
+	headerRow := -1
+	if head := table.ChildrenFiltered("thead").ChildrenFiltered("tr").Length(); head > 0 {
+		headerRow = head - 1
+	} else if len(rows) > 0 {
+		if allHeaderCells(trs.First()) || (len(rows) > 1 && !hasValue(rows[0])) {
+			headerRow = 0
+		}
+	}
+	if headerRow >= 0 {
+		t.Header = rows[headerRow]
+	}
+	for i, row := range rows {
+		if i <= headerRow || isEmptyRow(row) || (t.Header != nil && sameRow(row, t.Header)) {
+			continue
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t
+}
+
+// tableRows returns the cell texts of the tr elements of a table, applying the colspan and rowspan of
+// the cells. Only direct children of the rows are read, so nested tables do not add cells.
+func tableRows(trs *goquery.Selection) [][]string {
+	var rows [][]string
+	// spans holds, by column, the text and remaining rows of cells spanning down from rows above
+	type span struct {
+		text string
+		rows int
+	}
+	spans := make(map[int]*span)
+	trs.Each(func(_ int, tr *goquery.Selection) {
+		var row []string
+		fill := func() {
+			for s := spans[len(row)]; s != nil && s.rows > 0; s = spans[len(row)] {
+				row = append(row, s.text)
+				if s.rows--; s.rows == 0 {
+					delete(spans, len(row)-1)
+				}
+			}
+		}
+		tr.ChildrenFiltered("th, td").Each(func(_ int, cell *goquery.Selection) {
+			fill()
+			text := collapseSpace(cell.Text())
+			cols := spanAttr(cell, "colspan")
+			down := spanAttr(cell, "rowspan")
+			for i := 0; i < cols; i++ {
+				if down > 1 {
+					spans[len(row)] = &span{text: text, rows: down - 1}
+				}
+				row = append(row, text)
+			}
+		})
+		fill()
+		rows = append(rows, row)
+	})
+	return rows
+}
+
+// spanAttr returns the colspan or rowspan of a cell, 1 when it is missing or invalid.
+func spanAttr(cell *goquery.Selection, attr string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(cell.AttrOr(attr, "1")))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return min(n, maxColspan)
+}
+
+// allHeaderCells reports whether the row has cells and all of them are th elements.
+func allHeaderCells(tr *goquery.Selection) bool {
+	cells := tr.ChildrenFiltered("th, td")
+	return cells.Length() > 0 && cells.Length() == cells.Filter("th").Length()
+}
+
+// hasValue reports whether any cell of the row holds a number.
+func hasValue(row []string) bool {
+	for _, cell := range row {
+		if _, _, ok := ParseValue(cell); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmptyRow(row []string) bool {
+	for _, cell := range row {
+		if cell != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func sameRow(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Column returns the index of the column whose header matches name, or -1. Headers are compared
+// without regard to case and white space: an exact match wins over a header starting with name, which
+// wins over a header containing it, so "Jan" finds a "January" column and "CPI" finds "Average Annual
+// CPI for Gas". Among equally good matches the leftmost column is used.
+func (t Table) Column(name string) int {
+	name = strings.ToLower(collapseSpace(name))
+	if name == "" {
+		return -1
+	}
+	for _, match := range []func(header string) bool{
+		func(header string) bool { return header == name },
+		func(header string) bool { return strings.HasPrefix(header, name) },
+		func(header string) bool { return strings.Contains(header, name) },
+	} {
+		for i, header := range t.Header {
+			if match(strings.ToLower(header)) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// CheckColumns returns an error naming every one of the given columns the table has no match for.
+func (t Table) CheckColumns(names ...string) error {
+	var errs []error
+	for _, name := range names {
+		if t.Column(name) < 0 {
+			errs = append(errs, fmt.Errorf("table has no %q column", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Cell returns the text of row in the column matching name, or "" when there is no such column.
+func (t Table) Cell(row []string, name string) string {
+	if i := t.Column(name); i >= 0 && i < len(row) {
+		return row[i]
+	}
+	return ""
+}
+
+// ParseValue reads a numeric table cell such as "3.2", "1,234.5", "$4.37", "-£12", "(1,200)", "6.4%" or
+// "USD 95,000". unit is UnitPercent, the ISO 4217 code of the currency, or "" for plain numbers.
+// Parentheses and the Unicode minus sign mark negative numbers. Cells with anything else in them, like
+// "N/A", "-" or "Avail. Dec. 12", are not values.
+func ParseValue(text string) (value float64, unit string, ok bool) {
+	s := strings.ReplaceAll(collapseSpace(text), "−", "-")
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative, s = true, strings.TrimSpace(s[1:len(s)-1])
+	}
+	sign := func() {
+		if strings.HasPrefix(s, "-") {
+			negative, s = !negative, strings.TrimSpace(s[1:])
+		} else if strings.HasPrefix(s, "+") {
+			s = strings.TrimSpace(s[1:])
+		}
+	}
+	sign()
+	if strings.HasSuffix(s, "%") {
+		unit, s = UnitPercent, strings.TrimSpace(strings.TrimSuffix(s, "%"))
+	} else if m := currencySuffix.FindStringSubmatch(s); m != nil {
+		unit, s = m[1], s[:len(s)-len(m[0])]
+	} else if m := currencyPrefix.FindStringSubmatch(s); m != nil {
+		unit, s = m[1], s[len(m[0]):]
+	} else {
+		for _, symbol := range currencySymbols {
+			if strings.HasPrefix(s, symbol[0]) {
+				unit, s = symbol[1], strings.TrimSpace(s[len(symbol[0]):])
+				break
+			}
+		}
+	}
+	sign()
+	if s == "" || s == "." || !numberPattern.MatchString(s) {
+		return 0, "", false
+	}
+	value, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+	if err != nil {
+		return 0, "", false
+	}
+	if negative {
+		value = -value
+	}
+	return value, unit, true
+}
+
+// Observation is one value of a long-format time series: the value of metric for entity in period.
+type Observation struct {
+	Entity string  `json:"entity"`
+	Period string  `json:"period"`
+	Metric string  `json:"metric"`
+	Value  float64 `json:"value"`
+	Unit   string  `json:"unit,omitempty"`
+	Raw    string  `json:"raw"`    // the cell text the value was read from
+	Source string  `json:"source"` // URL of the page holding the table
+}
+
+// TableConfig maps the columns of the tables on a page onto a time series. Every row holds one period;
+// every value column becomes one metric, or one month or quarter of a metric when the columns are the
+// sub-periods of the row period. Without columns every other column is a metric named after its header.
+type TableConfig struct {
+	Selector     string        `json:"selector,omitempty" yaml:"selector,omitempty"`           // CSS selector of the tables; defaults to "table"
+	Index        int           `json:"index,omitempty" yaml:"index,omitempty"`                 // which of the matching tables to read, from 0
+	All          bool          `json:"all,omitempty" yaml:"all,omitempty"`                     // read every matching table instead
+	Entity       string        `json:"entity,omitempty" yaml:"entity,omitempty"`               // entity of every value
+	EntityColumn string        `json:"entity_column,omitempty" yaml:"entity_column,omitempty"` // header of the column naming the entity of a row
+	PeriodColumn string        `json:"period_column" yaml:"period_column"`                     // header of the column naming the period of a row
+	Columns      []TableColumn `json:"columns,omitempty" yaml:"columns,omitempty"`
+}
+
+// TableColumn maps one table column, found by header text as Table.Column does, onto a metric.
+type TableColumn struct {
+	Header string `json:"header" yaml:"header"`
+	Metric string `json:"metric,omitempty" yaml:"metric,omitempty"` // defaults to the header text
+	Period string `json:"period,omitempty" yaml:"period,omitempty"` // appended to the row period, as in "2023-01" for "01"
+}
+
+// Validate checks that the configuration names the columns it needs.
+func (c TableConfig) Validate() error {
+	var errs []error
+	if c.Selector != "" {
+		if _, err := cascadia.ParseGroup(c.Selector); err != nil {
+			errs = append(errs, fmt.Errorf("selector %q: %v", c.Selector, err))
+		}
+	}
+	if c.Index < 0 {
+		errs = append(errs, errors.New("index must not be negative"))
+	}
+	if c.All && c.Index != 0 {
+		errs = append(errs, errors.New("index and all are exclusive"))
+	}
+	if c.Entity != "" && c.EntityColumn != "" {
+		errs = append(errs, errors.New("entity and entity_column are exclusive"))
+	}
+	if c.PeriodColumn == "" {
+		errs = append(errs, errors.New("period_column is required"))
+	}
+	seen := make(map[string]bool)
+	for i, col := range c.Columns {
+		if strings.TrimSpace(col.Header) == "" {
+			errs = append(errs, fmt.Errorf("column %d has no header", i+1))
+		}
+		key := col.metric() + "\x00" + col.Period
+		if seen[key] {
+			errs = append(errs, fmt.Errorf("column %d (%s) repeats metric %q", i+1, col.Header, col.metric()))
+		}
+		seen[key] = true
+	}
+	return errors.Join(errs...)
+}
+
+// metric returns the metric of the column, its header when none is set.
+func (col TableColumn) metric() string {
+	if col.Metric == "" {
+		return collapseSpace(col.Header)
+	}
+	return col.Metric
+}
+
+// selector returns the CSS selector of the tables to read.
+func (c TableConfig) selector() string {
+	if c.Selector == "" {
+		return "table"
+	}
+	return c.Selector
+}
+
+// Tables parses the tables of the page the configuration reads.
+func (c TableConfig) Tables(doc *goquery.Selection) []Table {
+	var tables []Table
+	doc.Find(c.selector()).Filter("table").Each(func(_ int, s *goquery.Selection) {
+		tables = append(tables, ParseTable(s))
+	})
+	if c.All {
+		return tables
+	}
+	if c.Index >= len(tables) {
+		return nil
+	}
+	return tables[c.Index : c.Index+1]
+}
+
+// Observations turns the rows of t into observations read from source. Rows without a period and
+// cells that hold no value are skipped; a missing column is an error.
+func (c TableConfig) Observations(t Table, source string) ([]Observation, error) {
+	period := t.Column(c.PeriodColumn)
+	if period < 0 {
+		return nil, fmt.Errorf("table has no %q column", c.PeriodColumn)
+	}
+	entity := -1
+	if c.EntityColumn != "" {
+		if entity = t.Column(c.EntityColumn); entity < 0 {
+			return nil, fmt.Errorf("table has no %q column", c.EntityColumn)
+		}
+	}
+	type column struct {
+		index  int
+		metric string
+		period string
+	}
+	var columns []column
+	if len(c.Columns) == 0 {
+		for i, header := range t.Header {
+			if i != period && i != entity {
+				columns = append(columns, column{index: i, metric: header})
+			}
+		}
+	}
+	for _, col := range c.Columns {
+		i := t.Column(col.Header)
+		if i < 0 {
+			return nil, fmt.Errorf("table has no %q column", col.Header)
+		}
+		columns = append(columns, column{index: i, metric: col.metric(), period: col.Period})
+	}
+
+	var series []Observation
+	for _, row := range t.Rows {
+		if period >= len(row) || row[period] == "" {
+			continue
+		}
+		obs := Observation{Entity: c.Entity, Source: source}
+		if entity >= 0 && entity < len(row) {
+			obs.Entity = row[entity]
+		}
+		for _, col := range columns {
+			if col.index >= len(row) {
+				continue
+			}
+			value, unit, ok := ParseValue(row[col.index])
+			if !ok {
+				continue
+			}
+			obs.Period = row[period]
+			if col.period != "" {
+				obs.Period += "-" + col.period
+			}
+			obs.Metric, obs.Value, obs.Unit, obs.Raw = col.metric, value, unit, row[col.index]
+			series = append(series, obs)
+		}
+	}
+	return series, nil
+}
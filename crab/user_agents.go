@@ -2,12 +2,46 @@ package crab
 
 import (
 	"math/rand"
+	"os"
+	"strings"
 	"time"
 )
 
-// GetRandomUserAgent randomly selects and returns a user agent string from a predefined list. This function
-// is used to set the user agent in HTTP requests made by the crawler or scraper, helping to mimic real browser
-// behavior and avoid detection by web servers.
+// DefaultUserAgent is the User-Agent header of crab's requests unless CRAB_USER_AGENT sets another.
+const DefaultUserAgent = "GoEngine/1.0"
+
+// Identity is how crab introduces itself to the sites it visits: the User-Agent header of its requests,
+// and the agent whose rules it follows in robots.txt files.
+type Identity struct {
+	UserAgent   string
+	RobotsAgent string // defaults to the product name of UserAgent, such as GoEngine for GoEngine/1.0
+}
+
+// robotsAgent returns the agent looked up in robots.txt files.
+func (id Identity) robotsAgent() string {
+	if id.RobotsAgent != "" {
+		return id.RobotsAgent
+	}
+	product, _, _ := strings.Cut(id.UserAgent, " ")
+	product, _, _ = strings.Cut(product, "/")
+	return product
+}
+
+// DefaultIdentity is the identity of crab, set by CRAB_USER_AGENT and CRAB_ROBOTS_AGENT.
+var DefaultIdentity = identityFromEnv()
+
+// identityFromEnv reads the identity of crab from the environment.
+func identityFromEnv() Identity {
+	id := Identity{UserAgent: DefaultUserAgent, RobotsAgent: os.Getenv("CRAB_ROBOTS_AGENT")}
+	if agent := os.Getenv("CRAB_USER_AGENT"); agent != "" {
+		id.UserAgent = agent
+	}
+	return id
+}
+
+// GetRandomUserAgent randomly selects and returns a user agent string from a predefined list of browsers.
+// The crawler and scraper no longer use it: they introduce themselves with DefaultIdentity, the agent
+// they check robots.txt for.
 func GetRandomUserAgent() string {
 	userAgents := []string{
 		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/99.0.4844.51 Safari/537.36",
@@ -0,0 +1,168 @@
+package crab_test
+
+import (
+	"cmpscfa23team2/crab"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuiltinDomains(t *testing.T) {
+	want := []string{"airfare", "books", "car-depreciation", "gasoline", "inflation", "job-market", "nascar-predictem", "structured"}
+	if got := crab.NewDomainRegistry("").Names(); !reflect.DeepEqual(got, want) {
+		t.Errorf("built-in domains = %v, want %v", got, want)
+	}
+	for _, name := range want {
+		d, ok := crab.GetDomainConfig(name)
+		if !ok || d.Validate() != nil || (!d.Structured && (len(d.StartURLs) == 0 || (len(d.Fields) == 0 && d.Table == nil))) {
+			t.Errorf("GetDomainConfig(%q) = %+v, %v", name, d, ok)
+		}
+	}
+}
+
+func TestParseDomainConfig(t *testing.T) {
+	yamlConfig := `
+start_urls: [https://example.com/list]
+item_selector: div.item
+fields:
+  - {name: title, selector: h2}
+  - {name: price, selector: .price, type: number}
+  - {name: tags, selector: li, type: list}
+pagination: {next_selector: a.next}
+`
+	d, err := crab.ParseDomainConfig("shop.yaml", []byte(yamlConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Name != "shop" || len(d.Fields) != 3 || d.Fields[1].Type != crab.FieldNumber || d.Pagination.NextSelector != "a.next" {
+		t.Errorf("parsed YAML = %+v", d)
+	}
+
+	jsonConfig := `{"name": "shop", "item_selector": "div.item", "fields": [{"name": "title", "selector": "h2"}]}`
+	if _, err := crab.ParseDomainConfig("other.json", []byte(jsonConfig)); err != nil {
+		t.Errorf("ParseDomainConfig(JSON) error: %v", err)
+	}
+
+	for name, bad := range map[string]string{
+		"unknown key":       "item_selector: div\nfields: [{name: a, selectr: b}]",
+		"no fields":         "item_selector: div",
+		"no item selector":  "fields: [{name: a}]",
+		"bad selector":      "item_selector: div\nfields: [{name: a, selector: 'p:nope('}]",
+		"unknown type":      "item_selector: div\nfields: [{name: a, type: date}]",
+		"title as list":     "item_selector: div\nfields: [{name: title, type: list}]",
+		"duplicate field":   "item_selector: div\nfields: [{name: a}, {name: a}]",
+		"relative start":    "start_urls: [/list]\nitem_selector: div\nfields: [{name: a}]",
+		"negative maxpages": "item_selector: div\nfields: [{name: a}]\npagination: {next_selector: a, max_pages: -1}",
+	} {
+		if _, err := crab.ParseDomainConfig("bad.yaml", []byte(bad)); err == nil {
+			t.Errorf("ParseDomainConfig accepted a config with %s", name)
+		}
+	}
+}
+
+func TestDomainRegistryReload(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// Make sure the change is visible even on file systems with coarse modification times.
+		later := time.Now().Add(time.Duration(len(content)) * time.Second)
+		os.Chtimes(path, later, later)
+	}
+	write("shop.yaml", "item_selector: div.item\nfields: [{name: title, selector: h2}]")
+	write("books.json", `{"name": "books", "item_selector": "li", "fields": [{"name": "title"}]}`)
+	write("notes.txt", "not a domain")
+
+	r := crab.NewDomainRegistry(dir)
+	if d, ok := r.Get("shop"); !ok || d.ItemSelector != "div.item" {
+		t.Fatalf("Get(shop) = %+v, %v", d, ok)
+	}
+	if d, _ := r.Get("books"); d.ItemSelector != "li" {
+		t.Errorf("directory did not override the built-in books domain: %+v", d)
+	}
+	if _, ok := r.Get("airfare"); !ok {
+		t.Error("built-in airfare domain missing")
+	}
+
+	write("shop.yaml", "item_selector: article.product\nfields: [{name: title, selector: h3}]")
+	if d, _ := r.Get("shop"); d.ItemSelector != "article.product" {
+		t.Errorf("edited domain not reloaded: %+v", d)
+	}
+
+	write("broken.yaml", "item_selector: div\nfields: []")
+	if d, ok := r.Get("shop"); !ok || d.ItemSelector != "article.product" {
+		t.Errorf("invalid file dropped the loaded domains: %+v, %v", d, ok)
+	}
+	if r.Err() == nil || !strings.Contains(r.Err().Error(), "broken.yaml") {
+		t.Errorf("Err() = %v, want the error of broken.yaml", r.Err())
+	}
+
+	os.Remove(filepath.Join(dir, "broken.yaml"))
+	os.Remove(filepath.Join(dir, "shop.yaml"))
+	if _, ok := r.Get("shop"); ok {
+		t.Error("removed domain still configured")
+	}
+	if r.Err() != nil {
+		t.Errorf("Err() after fixing the directory = %v", r.Err())
+	}
+}
+
+func TestScrapeItemsFields(t *testing.T) {
+	page := `<html><head><meta property="og:site_name" content="Test shop"></head><body>
+<div class="item"><h2> Book %[1]d </h2><a href="/books/%[1]d">more</a><span class="price">£1%[1]d.50</span>
+<ul><li>new</li><li>paperback</li></ul><p class="rates">Year 1: 20%%
+Year 2: 15%%</p></div>
+<a class="next" href="/page/%[2]d">next</a></body></html>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(r.URL.Path, "/page/%d", &n)
+		fmt.Fprintf(w, page, n, n+1)
+	}))
+	defer server.Close()
+
+	d, err := crab.ParseDomainConfig("shop.yaml", []byte(`
+item_selector: div.item
+fields:
+  - {name: title, selector: h2}
+  - {name: url, selector: a, type: url}
+  - {name: price, selector: span.price, type: number}
+  - {name: tags, selector: li, type: list}
+  - {name: rates, selector: p.rates, type: map}
+  - {name: site, selector: "meta[property='og:site_name']", attr: content, page: true}
+pagination: {next_selector: a.next, max_pages: 3}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := crab.ScrapeItems(server.URL+"/page/1", d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("scraped %d items over the pages, want 3: %+v", len(items), items)
+	}
+	first := items[0]
+	if first.Title != "Book 1" || first.URL != server.URL+"/books/1" || first.Price != "11.5" {
+		t.Errorf("first item = %+v", first)
+	}
+	want := map[string]interface{}{
+		"tags":  []string{"new", "paperback"},
+		"rates": map[string]string{"Year 1": "20%", "Year 2": "15%"},
+		"site":  "Test shop",
+	}
+	if !reflect.DeepEqual(first.Fields, want) {
+		t.Errorf("first item fields = %#v, want %#v", first.Fields, want)
+	}
+	if items[2].Title != "Book 3" || items[2].Metadata.Source != server.URL+"/page/3" {
+		t.Errorf("last item = %+v", items[2])
+	}
+}
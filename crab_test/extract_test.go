@@ -0,0 +1,168 @@
+package crab_test
+
+import (
+	"cmpscfa23team2/crab"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const depreciationPage = `<html><head><meta property="og:url" content="https://example.com/guide"></head><body>
+<div id="content">
+<h1 class="entry-title">Car depreciation</h1>
+<p>How cars lose value.</p>
+<h2>What causes a vehicle to depreciate?</h2>
+<p>Several things:</p>
+<ul><li> Mileage </li><li>Condition</li></ul>
+<h2>How much does a car depreciate per year?</h2>
+<p>Year 1: 20%
+Year 2:   15% </p>
+<h2>Top 10 cars that depreciate the least</h2>
+<ul><li>Toyota Tacoma</li><li>Jeep Wrangler</li></ul>
+<h2>Top 10 cars that depreciate the most</h2>
+<ol><li>BMW 7 Series</li></ol>
+</div></body></html>`
+
+func TestXPathFields(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, depreciationPage)
+	}))
+	defer server.Close()
+
+	d, ok := crab.GetDomainConfig("car-depreciation")
+	if !ok {
+		t.Fatal("car-depreciation domain missing")
+	}
+	items, err := crab.ScrapeItems(server.URL, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("scraped %d items, want 1", len(items))
+	}
+	got := items[0]
+	if got.Title != "Car depreciation" || got.URL != "https://example.com/guide" || got.Description != "How cars lose value." {
+		t.Errorf("item = %+v", got)
+	}
+	if want := []string{"Several things:", "Mileage", "Condition"}; !reflect.DeepEqual(got.Factors, want) {
+		t.Errorf("factors = %q, want %q", got.Factors, want)
+	}
+	if want := map[string]string{"Year 1": "20%", "Year 2": "15%"}; !reflect.DeepEqual(got.DepreciationRates, want) {
+		t.Errorf("depreciation rates = %q, want %q", got.DepreciationRates, want)
+	}
+	if want := []string{"Toyota Tacoma", "Jeep Wrangler"}; !reflect.DeepEqual(got.ModelsLeastDepreciation, want) {
+		t.Errorf("least depreciation = %q, want %q", got.ModelsLeastDepreciation, want)
+	}
+	if want := []string{"BMW 7 Series"}; !reflect.DeepEqual(got.ModelsMostDepreciation, want) {
+		t.Errorf("most depreciation = %q, want %q", got.ModelsMostDepreciation, want)
+	}
+}
+
+func TestRegexAndFilters(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<div class="job"><h2>  Go   developer </h2><p>Posted on March 4, 2024. Salary: USD 95,000 a year. Ref #A-17</p>
+<ul><li>go</li><li>sql</li></ul></div>`)
+	}))
+	defer server.Close()
+
+	d, err := crab.ParseDomainConfig("jobs.yaml", []byte(`
+item_selector: div.job
+fields:
+  - {name: title, selector: h2, filters: [trim]}
+  - {name: posted, selector: p, filters: ['regex:Posted on ([^.]+)', date]}
+  - {name: salary, regex: 'Salary: ([^.]+)', type: number}
+  - {name: ref, regex: 'Ref #([\w-]+)', filters: ['trim:#A-']}
+  - {name: skills, xpath: './/li', type: list}
+  - {name: skill_count, xpath: 'count(.//li)', type: number}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := crab.ScrapeItems(server.URL, d)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("ScrapeItems = %+v, %v", items, err)
+	}
+	if items[0].Title != "Go developer" {
+		t.Errorf("title = %q", items[0].Title)
+	}
+	want := map[string]interface{}{
+		"posted":      "2024-03-04",
+		"salary":      95000.0,
+		"ref":         "17",
+		"skills":      []string{"go", "sql"},
+		"skill_count": 2.0,
+	}
+	if !reflect.DeepEqual(items[0].Fields, want) {
+		t.Errorf("fields = %#v, want %#v", items[0].Fields, want)
+	}
+}
+
+func TestJSONDomain(t *testing.T) {
+	pages := map[string]string{
+		"/api/jobs":        `{"next": "/api/jobs?page=2", "results": [{"title": "Go developer", "link": "/jobs/1", "pay": {"min": 90000}, "tags": ["go", "sql"], "posted": "2024-03-04T10:00:00Z"}]}`,
+		"/api/jobs?page=2": `{"next": null, "results": [{"title": "Data analyst", "link": "/jobs/2", "pay": {"min": "70,000"}, "tags": []}]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, pages[r.URL.RequestURI()])
+	}))
+	defer server.Close()
+
+	d, err := crab.ParseDomainConfig("api.json", []byte(`{
+		"format": "json",
+		"item_selector": "$.results[*]",
+		"fields": [
+			{"name": "title", "jsonpath": "$.title"},
+			{"name": "url", "jsonpath": "$.link", "type": "url"},
+			{"name": "price", "jsonpath": "$.pay.min", "type": "number"},
+			{"name": "tags", "jsonpath": "$.tags", "type": "list"},
+			{"name": "pay", "jsonpath": "$.pay", "type": "map"},
+			{"name": "posted", "jsonpath": "$.posted", "filters": ["date"]}
+		],
+		"pagination": {"next_selector": "$.next"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := crab.ScrapeItems(server.URL+"/api/jobs", d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("scraped %d items, want 2: %+v", len(items), items)
+	}
+	first, second := items[0], items[1]
+	if first.Title != "Go developer" || first.URL != server.URL+"/jobs/1" || first.Price != "90000" || second.Price != "70000" {
+		t.Errorf("items = %+v, %+v", first, second)
+	}
+	want := map[string]interface{}{
+		"tags":   []string{"go", "sql"},
+		"pay":    map[string]string{"min": "90000"},
+		"posted": "2024-03-04T10:00:00Z",
+	}
+	if !reflect.DeepEqual(first.Fields, want) {
+		t.Errorf("first fields = %#v, want %#v", first.Fields, want)
+	}
+}
+
+func TestFieldSourceErrors(t *testing.T) {
+	for name, bad := range map[string]string{
+		"two sources":        "item_selector: div\nfields: [{name: a, selector: p, xpath: //p}]",
+		"bad xpath":          "item_selector: div\nfields: [{name: a, xpath: '//p['}]",
+		"bad regex":          "item_selector: div\nfields: [{name: a, regex: '('}]",
+		"jsonpath in html":   "item_selector: div\nfields: [{name: a, jsonpath: $.a}]",
+		"selector in json":   "format: json\nitem_selector: $.items\nfields: [{name: a, selector: p}]",
+		"bad item path":      "format: json\nitem_selector: items\nfields: [{name: a, jsonpath: $.a}]",
+		"unknown filter":     "item_selector: div\nfields: [{name: a, filters: [upper]}]",
+		"bad filter regex":   "item_selector: div\nfields: [{name: a, filters: ['regex:(']}]",
+		"attr on regex":      "item_selector: div\nfields: [{name: a, regex: x, attr: href}]",
+		"unknown format":     "format: xml\nitem_selector: div\nfields: [{name: a}]",
+		"number filter args": "item_selector: div\nfields: [{name: a, filters: ['number:2']}]",
+	} {
+		if _, err := crab.ParseDomainConfig("bad.yaml", []byte(bad)); err == nil {
+			t.Errorf("ParseDomainConfig accepted a config with %s", name)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package crab_test
+
+import (
+	"cmpscfa23team2/crab"
+	"cmpscfa23team2/events"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestFrontierScope(t *testing.T) {
+	from := crab.FrontierEntry{URL: "https://www.shop.example/", Seed: "https://www.shop.example/"}
+	links := []string{
+		"https://shop.example/a",
+		"https://www.shop.example/b#reviews",
+		"https://blog.shop.example/c",
+		"https://other.example/d",
+		"mailto:shop@shop.example",
+		"https://shop.example/private/e",
+	}
+	for _, test := range []struct {
+		config crab.CrawlConfig
+		want   []string
+	}{
+		{crab.CrawlConfig{}, []string{"https://shop.example/a", "https://www.shop.example/b", "https://shop.example/private/e"}},
+		{crab.CrawlConfig{Scope: crab.ScopeSubdomain, Exclude: []string{"/private/"}}, []string{"https://shop.example/a", "https://www.shop.example/b", "https://blog.shop.example/c"}},
+		{crab.CrawlConfig{Scope: crab.ScopeRegex, Pattern: `/[cd]$`}, []string{"https://blog.shop.example/c", "https://other.example/d"}},
+		{crab.CrawlConfig{Scope: crab.ScopeAny, Include: []string{`/[ad]$`}}, []string{"https://shop.example/a", "https://other.example/d"}},
+	} {
+		test.config.Name, test.config.MaxDepth = "scope", 1
+		f, err := crab.NewFrontier(test.config, crab.NewMemoryFrontierStore())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, link := range links {
+			if ok, err := f.Add(link, from); err != nil {
+				t.Fatal(err)
+			} else if ok {
+				got = append(got, link)
+			}
+		}
+		var queued []string
+		for e, ok := f.Next(); ok; e, ok = f.Next() {
+			queued = append(queued, e.URL)
+			if e.Depth != 1 || e.Seed != from.Seed {
+				t.Errorf("entry = %+v", e)
+			}
+		}
+		if fmt.Sprint(queued) != fmt.Sprint(test.want) {
+			t.Errorf("scope %q: queued %v, want %v", test.config.Scope, queued, test.want)
+		}
+	}
+}
+
+func TestFrontierResume(t *testing.T) {
+	dir := t.TempDir()
+	config := crab.CrawlConfig{Name: "resume", MaxDepth: 2}
+	f, err := crab.NewFrontier(config, crab.NewFileFrontierStore(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed := "https://shop.example/"
+	if ok, err := f.AddSeed(seed); !ok || err != nil {
+		t.Fatalf("AddSeed = %v, %v", ok, err)
+	}
+	if _, err := f.AddSeed("/relative"); err == nil {
+		t.Error("AddSeed accepted a relative URL")
+	}
+	root, _ := f.Next()
+	for _, link := range []string{"https://shop.example/a", "https://shop.example/b", "https://shop.example/a"} {
+		f.Add(link, root)
+	}
+	f.Push(crab.FrontierEntry{URL: "https://shop.example/sale", Seed: seed, Depth: 1, Priority: 1})
+	if err := f.Done(root); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := f.Next()
+	if first.URL != "https://shop.example/sale" {
+		t.Errorf("first entry = %+v, want the one with the highest priority", first)
+	}
+	if err := f.Done(first); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := f.Add("https://shop.example/c", crab.FrontierEntry{URL: "https://shop.example/b", Seed: seed, Depth: 2}); ok {
+		t.Error("Add followed a link beyond the maximum depth")
+	}
+
+	// Simulate a crash: the journal ends in a torn line and /a and /b were never crawled
+	journal := filepath.Join(dir, "resume.jsonl")
+	file, err := os.OpenFile(journal, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"url": "https://shop.ex`)
+	file.Close()
+
+	resumed, err := crab.NewFrontier(config, crab.NewFileFrontierStore(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := resumed.AddSeed(seed); ok {
+		t.Error("resumed frontier added its seed again")
+	}
+	var pending []string
+	for e, ok := resumed.Next(); ok; e, ok = resumed.Next() {
+		pending = append(pending, e.URL)
+	}
+	if want := "[https://shop.example/a https://shop.example/b]"; fmt.Sprint(pending) != want {
+		t.Errorf("resumed frontier queued %v, want %s", pending, want)
+	}
+
+	if _, err := crab.NewFrontier(crab.CrawlConfig{Name: "../escape"}, crab.NewFileFrontierStore(dir)); err == nil {
+		t.Error("FileFrontierStore accepted a crawl name with a path in it")
+	}
+}
+
+func TestFrontierRun(t *testing.T) {
+	// Page n links to pages 2n and 2n+1 up to page 15, and to another site
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(r.URL.Path, "/page/%d", &n)
+		for _, child := range []int{2 * n, 2*n + 1} {
+			if child <= 15 {
+				fmt.Fprintf(w, `<a href="/page/%d">page %d</a>`, child, child)
+			}
+		}
+		fmt.Fprint(w, `<a href="https://elsewhere.example/">elsewhere</a>`)
+	}))
+	defer server.Close()
+
+	run := func(config crab.CrawlConfig, store crab.FrontierStore) []string {
+		t.Helper()
+		f, err := crab.NewFrontier(config, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.AddSeed(server.URL + "/page/1")
+		crawled, err := f.Run(context.Background(), events.NewBus(10))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, page := range crawled {
+			paths = append(paths, page.URL[len(server.URL):])
+		}
+		sort.Strings(paths)
+		return paths
+	}
+
+	got := run(crab.CrawlConfig{Name: "tree", MaxDepth: 2, Concurrency: 3}, crab.NewMemoryFrontierStore())
+	if want := "[/page/1 /page/2 /page/3 /page/4 /page/5 /page/6 /page/7]"; fmt.Sprint(got) != want {
+		t.Errorf("crawled %v, want %s", got, want)
+	}
+
+	store := crab.NewMemoryFrontierStore()
+	config := crab.CrawlConfig{Name: "limited", MaxDepth: 1, MaxPages: 2, Concurrency: 1}
+	if got := run(config, store); fmt.Sprint(got) != "[/page/1 /page/2]" {
+		t.Errorf("first run crawled %v", got)
+	}
+	if got := run(config, store); fmt.Sprint(got) != "[/page/3]" {
+		t.Errorf("resumed run crawled %v, want the page left over", got)
+	}
+}
+
+func TestFrontierCanonical(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/a?utm_source=news">a</a> <a href="/a#top">a</a> <a href="/b/">b</a> <a href="./b?fbclid=1">b</a>`)
+		case "/a":
+			fmt.Fprint(w, `<html><head><link rel="canonical" href="/products/a"></head><body><a href="/products/a/">a</a></body></html>`)
+		}
+	}))
+	defer server.Close()
+
+	f, err := crab.NewFrontier(crab.CrawlConfig{Name: "canonical", MaxDepth: 2}, crab.NewMemoryFrontierStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.AddSeed(server.URL + "/?utm_campaign=spring")
+	crawled, err := f.Run(context.Background(), events.NewBus(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := make(map[string]crab.URLData)
+	var paths []string
+	for _, page := range crawled {
+		pages[page.URL[len(server.URL):]] = page
+		paths = append(paths, page.URL[len(server.URL):])
+	}
+	sort.Strings(paths)
+	if fmt.Sprint(paths) != "[/ /a /b]" {
+		t.Errorf("crawled %v, want every page once and not the canonical URL of /a", paths)
+	}
+	if want := fmt.Sprint([]string{server.URL + "/a", server.URL + "/b"}); fmt.Sprint(pages["/"].Links) != want {
+		t.Errorf("links of / = %v, want %s", pages["/"].Links, want)
+	}
+	if got := pages["/a"].Canonical; got != server.URL+"/products/a" {
+		t.Errorf("canonical URL of /a = %q", got)
+	}
+}
+
+func TestCrawlConfigValidate(t *testing.T) {
+	for name, config := range map[string]crab.CrawlConfig{
+		"no name":          {},
+		"negative depth":   {Name: "c", MaxDepth: -1},
+		"unknown scope":    {Name: "c", Scope: "planet"},
+		"regex no pattern": {Name: "c", Scope: crab.ScopeRegex},
+		"stray pattern":    {Name: "c", Pattern: "x"},
+		"bad include":      {Name: "c", Include: []string{"("}},
+	} {
+		if err := config.Validate(); err == nil {
+			t.Errorf("Validate accepted a crawl with %s", name)
+		}
+	}
+}
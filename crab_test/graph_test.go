@@ -0,0 +1,146 @@
+package crab_test
+
+import (
+	"cmpscfa23team2/crab"
+	"cmpscfa23team2/events"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLinkGraph(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Last-Modified", "Mon, 04 Mar 2024 10:00:00 GMT")
+			fmt.Fprint(w, `<a href="/a">a</a> <a href="/report.pdf">report</a> <a href="/gone">gone</a>`)
+		case "/a":
+			fmt.Fprint(w, `<html><head><link rel="canonical" href="/"></head><body><a href="/">home</a></body></html>`)
+		case "/report.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Write([]byte("%PDF-1.4"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	f, err := crab.NewFrontier(crab.CrawlConfig{Name: "graph", MaxDepth: 1, Concurrency: 1}, crab.NewMemoryFrontierStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.SetPoliteness(crab.NewPoliteness(crab.DefaultIdentity, crab.PolitenessConfig{}))
+	f.AddSeed(server.URL + "/")
+	crawled, err := f.Run(context.Background(), events.NewBus(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := crab.NewLinkGraph(crawled)
+	if len(g.Nodes) != 4 || len(g.Edges) != 4 {
+		t.Fatalf("graph has %d nodes and %d edges, want 4 and 4: %+v", len(g.Nodes), len(g.Edges), g)
+	}
+	nodes := make(map[string]crab.GraphNode)
+	for _, n := range g.Nodes {
+		nodes[strings.TrimPrefix(n.URL, server.URL)] = n
+	}
+	if root := nodes["/"]; root.Status != 200 || root.Depth != 0 || root.ContentType != "text/html" || root.LastCrawled.IsZero() {
+		t.Errorf("root node = %+v", root)
+	}
+	if gone := nodes["/gone"]; gone.Status != 404 || gone.Depth != 1 {
+		t.Errorf("missing page node = %+v", gone)
+	}
+
+	dir := t.TempDir()
+	var files []string
+	for _, name := range []string{"links.json", "sitemap.xml", "graph.graphml", "graph.dot", "out/edges.csv"} {
+		files = append(files, filepath.Join(dir, name))
+	}
+	if err := crab.WriteLinkGraph(crawled, files...); err != nil {
+		t.Fatal(err)
+	}
+	read := func(name string) []byte {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	var links map[string][]string
+	if err := json.Unmarshal(read("links.json"), &links); err != nil || len(links) != 4 || len(links[server.URL+"/"]) != 3 {
+		t.Errorf("links.json = %v, %v", links, err)
+	}
+
+	// Only the HTML pages found, under their canonical URL
+	var sitemap struct {
+		URLs []struct {
+			Loc     string `xml:"loc"`
+			LastMod string `xml:"lastmod"`
+		} `xml:"url"`
+	}
+	if err := xml.Unmarshal(read("sitemap.xml"), &sitemap); err != nil {
+		t.Fatal(err)
+	}
+	if len(sitemap.URLs) != 1 || sitemap.URLs[0].Loc != server.URL+"/" || sitemap.URLs[0].LastMod != "2024-03-04T10:00:00Z" {
+		t.Errorf("sitemap = %+v", sitemap)
+	}
+
+	var graphML struct {
+		Keys []struct {
+			ID string `xml:"id,attr"`
+		} `xml:"key"`
+		Nodes []struct {
+			ID   string `xml:"id,attr"`
+			Data []struct {
+				Key   string `xml:"key,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"data"`
+		} `xml:"graph>node"`
+		Edges []struct {
+			Source string `xml:"source,attr"`
+			Target string `xml:"target,attr"`
+		} `xml:"graph>edge"`
+	}
+	if err := xml.Unmarshal(read("graph.graphml"), &graphML); err != nil {
+		t.Fatal(err)
+	}
+	if len(graphML.Keys) != 5 || len(graphML.Nodes) != 4 || len(graphML.Edges) != 4 ||
+		graphML.Nodes[0].Data[1].Key != "status" || graphML.Nodes[0].Data[1].Value != "200" || graphML.Edges[0].Target != "n1" {
+		t.Errorf("graphml = %+v", graphML)
+	}
+
+	dot := string(read("graph.dot"))
+	for _, want := range []string{
+		"digraph crawl {",
+		fmt.Sprintf(`"%s/" -> "%s/a";`, server.URL, server.URL),
+		fmt.Sprintf(`"%s/report.pdf" [status="200", depth="1", content_type="application/pdf"`, server.URL),
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("graph.dot lacks %s:\n%s", want, dot)
+		}
+	}
+
+	records, err := csv.NewReader(strings.NewReader(string(read("out/edges.csv")))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 5 || !reflect.DeepEqual(records[0], []string{"source", "target", "status", "depth", "content_type", "crawled"}) ||
+		records[3][1] != server.URL+"/gone" || records[3][2] != "404" {
+		t.Errorf("edges.csv = %v", records)
+	}
+
+	if err := crab.WriteLinkGraph(crawled, filepath.Join(dir, "graph.png")); err == nil {
+		t.Error("WriteLinkGraph accepted an unknown format")
+	}
+}
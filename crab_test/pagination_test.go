@@ -0,0 +1,112 @@
+package crab_test
+
+import (
+	"cmpscfa23team2/crab"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listingServer serves numbered items two per page as /list/<n>, where page 3 repeats page 2, and from
+// a given index as /list?start=<index>. Past the last item pages are empty. Item dates go back one day
+// per item from today.
+func listingServer(t *testing.T, items int) (*httptest.Server, *[]string) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		requests = append(requests, r.URL.RequestURI())
+		first := 1
+		if page, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/list/")); err == nil && page > 1 {
+			if page >= 3 {
+				page--
+			}
+			first = 2*page - 1
+		}
+		if start, err := strconv.Atoi(r.URL.Query().Get("start")); err == nil {
+			first = start + 1
+		}
+		for i := first; i <= first+1 && i <= items; i++ {
+			date := time.Now().AddDate(0, 0, -i).Format("2006-01-02")
+			fmt.Fprintf(w, `<div class="item"><a href="/items/%d">Item %d</a><time>%s</time></div>`, i, i, date)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func scrapeListing(t *testing.T, url, config string) []crab.GenericData {
+	t.Helper()
+	d, err := crab.ParseDomainConfig("listing.yaml", []byte(`
+item_selector: div.item
+fields:
+  - {name: title, selector: a}
+  - {name: url, selector: a, type: url}
+  - {name: date, selector: time}
+`+config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := crab.ScrapeItems(url, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return items
+}
+
+func titles(items []crab.GenericData) string {
+	var s []string
+	for _, item := range items {
+		s = append(s, item.Title)
+	}
+	return strings.Join(s, ", ")
+}
+
+func TestPagination(t *testing.T) {
+	for _, test := range []struct {
+		name, config string
+		want         string
+		requests     int
+	}{
+		{"template", "pagination: {url_template: '/list/{page}'}", "Item 1, Item 2, Item 3, Item 4, Item 3, Item 4, Item 5, Item 6, Item 7", 6},
+		{"max pages", "pagination: {url_template: '/list/{page}', max_pages: 2}", "Item 1, Item 2, Item 3, Item 4", 2},
+		{"dedup", "pagination: {url_template: '/list/{page}', dedup_by: [url]}", "Item 1, Item 2, Item 3, Item 4, Item 5, Item 6, Item 7", 6},
+		{"no new items", "pagination: {url_template: '/list/{page}', dedup_by: [url], stop_on_no_new_items: true}", "Item 1, Item 2, Item 3, Item 4", 3},
+		{"offset", "pagination: {offset_param: start}", "Item 1, Item 2, Item 3, Item 4, Item 5, Item 6, Item 7", 5},
+		{"offset page size", "pagination: {offset_param: start, page_size: 4}", "Item 1, Item 2, Item 5, Item 6", 3},
+		{"date cutoff", "pagination: {url_template: '/list/{page}', date_field: date, since: 3d}", "Item 1, Item 2", 2},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server, requests := listingServer(t, 7)
+			items := scrapeListing(t, server.URL+"/list/1", test.config)
+			if got := titles(items); got != test.want {
+				t.Errorf("items = %s, want %s", got, test.want)
+			}
+			if len(*requests) != test.requests {
+				t.Errorf("requested %d pages, want %d: %v", len(*requests), test.requests, *requests)
+			}
+		})
+	}
+}
+
+func TestPaginationErrors(t *testing.T) {
+	for name, bad := range map[string]string{
+		"two modes":            "pagination: {next_selector: a, url_template: '/p/{page}'}",
+		"template placeholder": "pagination: {url_template: '/p/2'}",
+		"first page alone":     "pagination: {first_page: 2}",
+		"page size alone":      "pagination: {page_size: 20}",
+		"date without since":   "pagination: {next_selector: a, date_field: date}",
+		"bad since":            "pagination: {next_selector: a, date_field: date, since: yesterday}",
+		"empty dedup field":    "pagination: {next_selector: a, dedup_by: ['']}",
+	} {
+		if _, err := crab.ParseDomainConfig("bad.yaml", []byte("item_selector: div\nfields: [{name: a}]\n"+bad)); err == nil {
+			t.Errorf("ParseDomainConfig accepted a config with %s", name)
+		}
+	}
+}
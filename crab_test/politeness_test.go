@@ -0,0 +1,107 @@
+package crab_test
+
+import (
+	"cmpscfa23team2/crab"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPolitenessRobots(t *testing.T) {
+	var robotsFetches int32
+	var agents sync.Map
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents.Store(r.UserAgent(), true)
+		if r.URL.Path == "/robots.txt" {
+			atomic.AddInt32(&robotsFetches, 1)
+			fmt.Fprint(w, "User-agent: *\nDisallow: /\n\nUser-agent: TestBot\nDisallow: /private\nCrawl-delay: 0.2\n\nSitemap: https://shop.example/sitemap.xml\n")
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+
+	p := crab.NewPoliteness(crab.Identity{UserAgent: "TestBot/2.0 (+tests)"}, crab.PolitenessConfig{})
+	if !p.Allowed(server.URL+"/public") || p.Allowed(server.URL+"/private/1") {
+		t.Error("TestBot rules not applied")
+	}
+	other := crab.NewPoliteness(crab.Identity{UserAgent: "Mozilla/5.0", RobotsAgent: "OtherBot"}, crab.PolitenessConfig{})
+	if other.Allowed(server.URL + "/public") {
+		t.Error("OtherBot allowed despite the rules for every agent")
+	}
+	if got := p.CrawlDelay(server.URL + "/public"); got != 200*time.Millisecond {
+		t.Errorf("crawl delay = %v, want the Crawl-delay of robots.txt", got)
+	}
+	if got := p.Sitemaps(server.URL); !reflect.DeepEqual(got, []string{"https://shop.example/sitemap.xml"}) {
+		t.Errorf("sitemaps = %v", got)
+	}
+
+	client := p.Client()
+	if _, err := client.Get(server.URL + "/private/2"); !errors.Is(err, crab.ErrDisallowed) {
+		t.Errorf("disallowed request error = %v, want ErrDisallowed", err)
+	}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL + "/public")
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("3 requests took %v, want at least 2 crawl delays", elapsed)
+	}
+	if n := atomic.LoadInt32(&robotsFetches); n != 2 {
+		t.Errorf("robots.txt fetched %d times, want once per politeness layer", n)
+	}
+	if _, ok := agents.Load("TestBot/2.0 (+tests)"); !ok {
+		t.Error("requests were not made with the configured user agent")
+	}
+}
+
+func TestPolitenessHostConcurrency(t *testing.T) {
+	var active, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := crab.NewPoliteness(crab.DefaultIdentity, crab.PolitenessConfig{HostConcurrency: 2}).Client()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := client.Get(fmt.Sprintf("%s/page/%d", server.URL, i))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}(i)
+	}
+	wg.Wait()
+	if peak != 2 {
+		t.Errorf("%d requests in flight at once, want 2", peak)
+	}
+}
@@ -0,0 +1,138 @@
+package crab_test
+
+import (
+	"cmpscfa23team2/crab"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// sitemapServer serves a robots.txt listing a sitemap index, which points at a gzipped sitemap, a
+// plain one, itself and a sitemap on another host. The sitemaps also list pages of another host.
+func sitemapServer(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintf(w, "User-agent: *\nDisallow: /private\n\nSitemap: %s/sitemap_index.xml\n", server.URL)
+		case "/sitemap_index.xml":
+			fmt.Fprint(w, `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc>/sitemap-pages.xml.gz</loc></sitemap>
+	<sitemap><loc>/sitemap-more.xml</loc></sitemap>
+	<sitemap><loc>/sitemap_index.xml</loc></sitemap>
+	<sitemap><loc>https://elsewhere.example/sitemap.xml</loc></sitemap>
+</sitemapindex>`)
+		case "/sitemap-pages.xml.gz":
+			w.Header().Set("Content-Type", "application/x-gzip")
+			gz := gzip.NewWriter(w)
+			fmt.Fprintf(gz, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url><loc>%[1]s/old</loc><lastmod>2023-06-01</lastmod></url>
+	<url><loc>%[1]s/spring</loc><lastmod>2024-03-01T10:00:00+00:00</lastmod><priority>0.5</priority></url>
+	<url><loc>%[1]s/summer</loc><lastmod>2024-05-01</lastmod><changefreq>weekly</changefreq></url>
+	<url><loc>%[1]s/</loc><lastmod>2024-02-01</lastmod><priority>0.9</priority></url>
+	<url><loc>https://elsewhere.example/</loc><priority>1.0</priority></url>
+</urlset>`, server.URL)
+			gz.Close()
+		case "/sitemap-more.xml":
+			fmt.Fprintf(w, `<urlset><url><loc>%s/summer?utm_source=sitemap</loc></url>
+<url><loc>ftp://shop.example/files</loc></url></urlset>`, server.URL)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSitemapReader(t *testing.T) {
+	server := sitemapServer(t)
+	pages, err := crab.NewSitemapReader(crab.NewPoliteness(crab.DefaultIdentity, crab.PolitenessConfig{})).ReadSite(server.URL + "/products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, page := range pages {
+		got = append(got, fmt.Sprintf("%s %.1f %s", page.Loc, page.Priority, page.LastMod.Format("2006-01-02")))
+	}
+	want := []string{
+		server.URL + "/ 0.9 2024-02-01",
+		server.URL + "/summer 0.5 2024-05-01",
+		server.URL + "/spring 0.5 2024-03-01",
+		server.URL + "/old 0.5 2023-06-01",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("pages = %q, want %q", got, want)
+	}
+	if pages[1].ChangeFreq != "weekly" {
+		t.Errorf("changefreq = %q", pages[1].ChangeFreq)
+	}
+
+	// Without a Sitemap directive the well-known paths are tried
+	var plain *httptest.Server
+	plain = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprintf(w, "%[1]s/a\nnot a URL\n%[1]s/b\nhttps://elsewhere.example/c\n", plain.URL)
+		case "/sitemap_index.xml":
+			fmt.Fprint(w, "<html><body>Not found</body></html>")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer plain.Close()
+	reader := crab.NewSitemapReader(crab.NewPoliteness(crab.DefaultIdentity, crab.PolitenessConfig{}))
+	if got := reader.Discover(plain.URL); len(got) != 3 || got[0] != plain.URL+"/sitemap.xml" {
+		t.Errorf("discovered %v", got)
+	}
+	pages, err = reader.ReadSite(plain.URL)
+	if err != nil || len(pages) != 2 || pages[0].Loc != plain.URL+"/a" {
+		t.Errorf("text sitemap = %+v, %v", pages, err)
+	}
+}
+
+func TestFrontierSitemaps(t *testing.T) {
+	server := sitemapServer(t)
+	store := crab.NewMemoryFrontierStore()
+	config := crab.CrawlConfig{Name: "sitemaps", Since: "2024-01-01"}
+	f, err := crab.NewFrontier(config, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.SetPoliteness(crab.NewPoliteness(crab.DefaultIdentity, crab.PolitenessConfig{}))
+	if queued, err := f.AddSitemaps(server.URL); queued != 3 || err != nil {
+		t.Fatalf("AddSitemaps = %d, %v; want the 3 pages changed since 2024", queued, err)
+	}
+	crawled := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	var order []string
+	for e, ok := f.Next(); ok; e, ok = f.Next() {
+		order = append(order, e.URL)
+		e.Crawled = crawled
+		if err := f.Done(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := fmt.Sprintf("[%[1]s/ %[1]s/summer %[1]s/spring]", server.URL); fmt.Sprint(order) != want {
+		t.Errorf("crawl order %v, want %s", order, want)
+	}
+
+	// A later crawl only goes back to the page modified since it was crawled
+	again, err := crab.NewFrontier(config, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again.SetPoliteness(crab.NewPoliteness(crab.DefaultIdentity, crab.PolitenessConfig{}))
+	if queued, err := again.AddSitemaps(server.URL); queued != 1 || err != nil {
+		t.Fatalf("AddSitemaps = %d, %v; want 1", queued, err)
+	}
+	if e, _ := again.Next(); e.URL != server.URL+"/summer" || e.Priority != 0.5 {
+		t.Errorf("queued %+v, want the summer page", e)
+	}
+
+	if err := (crab.CrawlConfig{Name: "c", Since: "last week"}).Validate(); err == nil {
+		t.Error("Validate accepted an invalid since")
+	}
+}
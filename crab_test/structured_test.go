@@ -0,0 +1,123 @@
+package crab_test
+
+import (
+	"cmpscfa23team2/crab"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const structuredPage = `<html><head>
+<title> Shop | Widgets </title>
+<link rel="canonical" href="https://shop.example/widgets">
+<meta property="og:title" content="Widgets at Shop">
+<meta property="og:url" content="https://shop.example/widgets?ref=og">
+<meta name="description" content="All the widgets.">
+<meta name="viewport" content="width=device-width">
+<script type="application/ld+json">
+{"@context": "https://schema.org", "@graph": [
+	{"@type": "Product", "name": "Blue widget", "sku": "W-1", "brand": {"@type": "Brand", "name": "Acme"},
+	 "offers": {"@type": "Offer", "price": "19.99", "priceCurrency": "USD", "availability": "https://schema.org/InStock"},
+	 "aggregateRating": {"ratingValue": 4.5}},
+	{"@type": "BreadcrumbList", "name": "ignored"}
+]}
+</script>
+<script type="application/ld+json">
+[{"@type": ["JobPosting"], "title": "Widget engineer", "description": "<p>Build <b>widgets</b>.</p>",
+  "hiringOrganization": {"@type": "Organization", "name": "Acme"},
+  "jobLocation": {"@type": "Place", "address": {"addressLocality": "Erie", "addressRegion": "PA", "addressCountry": "US"}},
+  "baseSalary": {"@type": "MonetaryAmount", "currency": "USD", "value": {"minValue": 90000, "maxValue": 120000, "unitText": "YEAR"}},
+  "datePosted": "2024-03-04", "url": "https://shop.example/jobs/1"}]
+</script>
+<script type="application/ld+json">{not json</script>
+</head><body>
+<article itemscope itemtype="https://schema.org/NewsArticle">
+	<h1 itemprop="headline">Widgets are back</h1>
+	<span itemprop="author" itemscope itemtype="https://schema.org/Person"><span itemprop="name">Ana</span></span>
+	<time itemprop="datePublished" datetime="2024-02-01">February 1</time>
+	<a itemprop="url" href="https://shop.example/news/1">Read</a>
+	<div><span itemprop="keywords">widgets</span> <span itemprop="keywords">news</span></div>
+</article>
+</body></html>`
+
+func TestExtractStructuredData(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(structuredPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := crab.ExtractStructuredData(doc.Selection)
+	if len(data.JSONLD) != 3 || len(data.Microdata) != 1 {
+		t.Fatalf("found %d JSON-LD and %d microdata entities, want 3 and 1", len(data.JSONLD), len(data.Microdata))
+	}
+	article := data.Microdata[0]
+	if article["@type"] != "https://schema.org/NewsArticle" || article["datePublished"] != "2024-02-01" ||
+		!reflect.DeepEqual(article["keywords"], []interface{}{"widgets", "news"}) ||
+		!reflect.DeepEqual(article["author"], map[string]interface{}{"@type": "https://schema.org/Person", "name": "Ana"}) {
+		t.Errorf("microdata item = %#v", article)
+	}
+	if want := map[string]string{"og:title": "Widgets at Shop", "og:url": "https://shop.example/widgets?ref=og", "description": "All the widgets."}; !reflect.DeepEqual(data.Meta, want) {
+		t.Errorf("meta = %v, want %v", data.Meta, want)
+	}
+	if data.Title != "Shop | Widgets" || data.Canonical != "https://shop.example/widgets" {
+		t.Errorf("title, canonical = %q, %q", data.Title, data.Canonical)
+	}
+
+	items := data.Items("https://shop.example/widgets")
+	if len(items) != 3 {
+		t.Fatalf("mapped %d items, want 3: %+v", len(items), items)
+	}
+	product, job, news := items[0], items[1], items[2]
+	if product.Title != "Blue widget" || product.Price != "19.99" || product.URL != "https://shop.example/widgets?ref=og" || product.Description != "All the widgets." {
+		t.Errorf("product = %+v", product)
+	}
+	if want := map[string]interface{}{"type": "Product", "currency": "USD", "availability": "InStock", "brand": "Acme", "sku": "W-1", "rating": "4.5"}; !reflect.DeepEqual(product.Fields, want) {
+		t.Errorf("product fields = %v, want %v", product.Fields, want)
+	}
+	if job.Title != "Widget engineer" || job.Description != "Build widgets." || job.Price != "USD 90000-120000 per year" || job.Fields["location"] != "Erie, PA, US" {
+		t.Errorf("job item = %+v", job)
+	}
+	if news.Title != "Widgets are back" || news.URL != "https://shop.example/news/1" || news.Fields["author"] != "Ana" || news.Fields["date_published"] != "2024-02-01" {
+		t.Errorf("article = %+v", news)
+	}
+
+	want := []crab.JobData{{
+		Title:       "Widget engineer",
+		URL:         "https://shop.example/jobs/1",
+		Description: "Build widgets.",
+		Salary:      "USD 90000-120000 per year",
+		Company:     "Acme",
+		Location:    "Erie, PA, US",
+	}}
+	if jobs := data.Jobs("https://shop.example/widgets"); !reflect.DeepEqual(jobs, want) {
+		t.Errorf("jobs = %+v, want %+v", jobs, want)
+	}
+}
+
+func TestStructuredDomain(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><title>Fallback</title><meta property="og:title" content="Spring sale">
+<meta property="og:type" content="website"><meta property="og:image" content="/sale.png"></head><body></body></html>`)
+	}))
+	defer server.Close()
+
+	d, ok := crab.GetDomainConfig("structured")
+	if !ok {
+		t.Fatal("structured domain missing")
+	}
+	items, err := crab.ScrapeItems(server.URL+"/sale", d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Title != "Spring sale" || items[0].URL != server.URL+"/sale" ||
+		!reflect.DeepEqual(items[0].Fields, map[string]interface{}{"type": "website", "image": "/sale.png"}) {
+		t.Errorf("items = %+v", items)
+	}
+
+	if _, err := crab.ParseDomainConfig("bad.yaml", []byte("structured: true\nfields: [{name: a}]")); err == nil {
+		t.Error("ParseDomainConfig accepted fields without an item selector")
+	}
+}
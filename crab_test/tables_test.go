@@ -0,0 +1,158 @@
+package crab_test
+
+import (
+	"cmpscfa23team2/crab"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseValue(t *testing.T) {
+	for text, want := range map[string]struct {
+		value float64
+		unit  string
+	}{
+		"3.2":          {3.2, ""},
+		" 1,234.5 ":    {1234.5, ""},
+		"$4.37 ":       {4.37, "USD"},
+		"-£12":         {-12, "GBP"},
+		"€ 0.99":       {0.99, "EUR"},
+		"(1,200)":      {-1200, ""},
+		"6.4%":         {6.4, crab.UnitPercent},
+		"−0.5 %":       {-0.5, crab.UnitPercent},
+		"USD 95,000":   {95000, "USD"},
+		"95,000 EUR":   {95000, "EUR"},
+		"+.75":         {0.75, ""},
+		"US$1,000,000": {1e6, "USD"},
+	} {
+		value, unit, ok := crab.ParseValue(text)
+		if !ok || value != want.value || unit != want.unit {
+			t.Errorf("ParseValue(%q) = %v, %q, %v, want %v, %q", text, value, unit, ok, want.value, want.unit)
+		}
+	}
+	for _, text := range []string{"", "-", "N/A", "Avail.Dec.12", "12,34", "1.2.3", "$", "about 5", "%"} {
+		if value, unit, ok := crab.ParseValue(text); ok {
+			t.Errorf("ParseValue(%q) = %v, %q, true, want no value", text, value, unit)
+		}
+	}
+}
+
+func parseTables(t *testing.T, html string) []crab.Table {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crab.ParseTables(doc.Selection)
+}
+
+func TestParseTable(t *testing.T) {
+	tables := parseTables(t, `
+<table><thead><tr><th colspan="3">Prices</th></tr><tr><th>Region</th><th>Year</th><th>Price</th></tr></thead>
+<tbody>
+<tr><td rowspan="2">North</td><td>2022</td><td>$1.10</td></tr>
+<tr><td>2023</td><td>$1.20 <table><tr><td>nested</td></tr></table></td></tr>
+<tr><td></td><td> </td><td></td></tr>
+<tr><th>Region</th><th>Year</th><th>Price</th></tr>
+<tr><td>South</td><td colspan="2">n/a</td></tr>
+</tbody></table>
+<table><tr><td>Year</td><td>Jan</td><td>Feb</td></tr><tr><td>2023</td><td>6.4</td><td>6.0</td></tr></table>
+<table><tr><td>1</td><td>2</td></tr></table>`)
+	if len(tables) != 4 {
+		t.Fatalf("parsed %d tables, want 4 including the nested one", len(tables))
+	}
+	want := crab.Table{
+		Header: []string{"Region", "Year", "Price"},
+		Rows: [][]string{
+			{"North", "2022", "$1.10"},
+			{"North", "2023", "$1.20 nested"},
+			{"South", "n/a", "n/a"},
+		},
+	}
+	if !reflect.DeepEqual(tables[0], want) {
+		t.Errorf("table with thead = %+v, want %+v", tables[0], want)
+	}
+	if want := []string{"Year", "Jan", "Feb"}; !reflect.DeepEqual(tables[2].Header, want) || len(tables[2].Rows) != 1 {
+		t.Errorf("table with a td header = %+v", tables[2])
+	}
+	if tables[3].Header != nil || len(tables[3].Rows) != 1 {
+		t.Errorf("table without a header = %+v", tables[3])
+	}
+
+	gas := crab.Table{Header: []string{"Year", "Average Gasoline Prices", "Average Annual CPI for Gas", "Gas Prices Adjusted for Inflation"}}
+	for name, want := range map[string]int{"year": 0, "Average gasoline": 1, "CPI": 2, "adjusted": 3, "Month": -1, "": -1} {
+		if got := gas.Column(name); got != want {
+			t.Errorf("Column(%q) = %d, want %d", name, got, want)
+		}
+	}
+	if got := gas.Cell([]string{"1978", "0.652"}, "CPI"); got != "" {
+		t.Errorf("Cell of a short row = %q", got)
+	}
+	if err := gas.CheckColumns("Year", "CPI", "Adjusted"); err != nil {
+		t.Errorf("CheckColumns of present columns = %v", err)
+	}
+	err := gas.CheckColumns("Year", "Month", "Region")
+	if err == nil || !strings.Contains(err.Error(), `"Month"`) || !strings.Contains(err.Error(), `"Region"`) {
+		t.Errorf("CheckColumns of missing columns = %v, want both named", err)
+	}
+}
+
+func TestScrapeSeries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><table><tbody>
+<tr><td>Year</td><td>Jan</td><td>Feb</td><td>Mar</td><td>Apr</td><td>May</td><td>Jun</td><td>Jul</td><td>Aug</td><td>Sep</td><td>Oct</td><td>Nov</td><td>Dec</td><td>Ave</td></tr>
+<tr><td>2023</td><td>6.4</td><td>6.0</td><td>5.0</td><td>4.9</td><td>4.0</td><td>3.0</td><td>3.2</td><td>3.7</td><td>3.7</td><td>3.2</td><td>Avail.Dec.12</td><td> </td><td> </td></tr>
+<tr><td>2022</td><td>7.5</td><td>7.9</td><td>8.5</td><td>8.3</td><td>8.6</td><td>9.1</td><td>8.5</td><td>8.3</td><td>8.2</td><td>7.7</td><td>7.1</td><td>6.5</td><td>8.0</td></tr>
+</tbody></table></body></html>`)
+	}))
+	defer server.Close()
+
+	d, ok := crab.GetDomainConfig("inflation")
+	if !ok {
+		t.Fatal("inflation domain missing")
+	}
+	series, err := crab.ScrapeSeries(server.URL, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 10+13 {
+		t.Fatalf("read %d observations, want 23: %+v", len(series), series)
+	}
+	want := crab.Observation{Entity: "United States", Period: "2023-01", Metric: "inflation_rate", Value: 6.4, Raw: "6.4", Source: server.URL}
+	if series[0] != want {
+		t.Errorf("first observation = %+v, want %+v", series[0], want)
+	}
+	if last := series[len(series)-1]; last.Period != "2022" || last.Metric != "inflation_rate_average" || last.Value != 8 {
+		t.Errorf("last observation = %+v", last)
+	}
+
+	d, err = crab.ParseDomainConfig("prices.yaml", []byte(`
+table: {entity_column: Region, period_column: Year, columns: [{header: Price}, {header: Volume}]}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := crab.ScrapeSeries(server.URL, d); err == nil || !strings.Contains(err.Error(), `no "Region" column`) {
+		t.Errorf("ScrapeSeries with missing columns: err = %v", err)
+	}
+
+	for name, bad := range map[string]string{
+		"no period column":  "table: {columns: [{header: Jan}]}",
+		"two entities":      "table: {entity: US, entity_column: Region, period_column: Year}",
+		"empty header":      "table: {period_column: Year, columns: [{header: ' '}]}",
+		"repeated metric":   "table: {period_column: Year, columns: [{header: Jan, metric: a}, {header: Feb, metric: a}]}",
+		"bad selector":      "table: {selector: 'table:nope(', period_column: Year}",
+		"negative index":    "table: {index: -1, period_column: Year}",
+		"table in json":     "format: json\ntable: {period_column: Year}",
+		"index and all":     "table: {index: 1, all: true, period_column: Year}",
+		"unknown table key": "table: {period_colum: Year}",
+	} {
+		if _, err := crab.ParseDomainConfig("bad.yaml", []byte(bad)); err == nil {
+			t.Errorf("ParseDomainConfig accepted a table with %s", name)
+		}
+	}
+}
@@ -185,6 +185,27 @@ func (nbc *NaiveBayesClassifier) PredictBestMatchingJob(domain string, data []Jo
 	return topJobTitles
 }
 
+// SkillDemand counts the jobs whose title or description mentions each skill of the domain's skill set,
+// most demanded first.
+func (nbc *NaiveBayesClassifier) SkillDemand(domain string, data []JobData) []SkillData {
+	var demand []SkillData
+	for _, skill := range nbc.skillSets[domain] {
+		skillLower := strings.ToLower(skill)
+		entry := SkillData{Skill: skill}
+		for _, job := range data {
+			if strings.Contains(strings.ToLower(job.Description), skillLower) || strings.Contains(strings.ToLower(job.Title), skillLower) {
+				entry.Demand++
+				entry.Matches = append(entry.Matches, job)
+			}
+		}
+		demand = append(demand, entry)
+	}
+	sort.SliceStable(demand, func(i, j int) bool {
+		return demand[i].Demand > demand[j].Demand
+	})
+	return demand
+}
+
 // SearchJobByTitle searches for a job by its title and prints its details.
 func SearchJobByTitle(data []JobData, title string) {
 	for _, job := range data {
@@ -1,6 +1,7 @@
 package dal
 
 import (
+	"context"
 	"fmt"
 	"github.com/golang-jwt/jwt"
 	"golang.org/x/crypto/bcrypt"
@@ -11,6 +12,10 @@ import (
 
 const SECRET_KEY = "SECRETKEY123!"
 
+// TokenLifetime is how long a token from GenerateToken stays valid. Cookies holding the token
+// should expire at the same time.
+const TokenLifetime = time.Hour
+
 // Add the bcrypt hashing utility functions
 //
 // It defines function that hashes a provided password using the bcrypt hashing algorithm with a default cost and returns the hashed password as a byte slice or an error if encountered.
@@ -40,61 +45,61 @@ func ComparePassword(hashedPassword []byte, password string) error {
 // It takes a username and password as input, retrieves the hashed password from the database,
 // and compares it with the provided password. If the credentials are valid, it generates a JWT token
 // for the user and returns it. If authentication fails, it returns an error.
-func AuthenticateUser(username string, password string) (string, error) {
+func AuthenticateUser(ctx context.Context, username string, password string) (string, error) {
 	var userID, hashedPasswordStr string
 
-	err := DB.QueryRow("CALL authenticate_user(?)", username).Scan(&userID, &hashedPasswordStr)
+	err := DB.QueryRowContext(ctx, "CALL authenticate_user(?)", username).Scan(&userID, &hashedPasswordStr)
 	if err != nil {
-		InsertLog("400", "Error in DB Query during authentication", "AuthenticateUser()")
+		InsertLogContext(ctx, "400", "Error in DB Query during authentication", "AuthenticateUser()")
 		return "", err
 	}
 
 	if userID == "" {
-		InsertLog("400", "User not found during authentication", "AuthenticateUser()")
+		InsertLogContext(ctx, "400", "User not found during authentication", "AuthenticateUser()")
 		return "", fmt.Errorf("user not found")
 	}
 
 	hashedPassword := []byte(hashedPasswordStr)
 
 	if !strings.HasPrefix(hashedPasswordStr, "$2a$") {
-		InsertLog("400", "Invalid bcrypt hash format", "AuthenticateUser()")
+		InsertLogContext(ctx, "400", "Invalid bcrypt hash format", "AuthenticateUser()")
 		return "", fmt.Errorf("invalid bcrypt hash format")
 	}
 
 	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
 	if err != nil {
-		InsertLog("400", "Password comparison failed during authentication", "AuthenticateUser()")
+		InsertLogContext(ctx, "400", "Password comparison failed during authentication", "AuthenticateUser()")
 		return "", err
 	}
 
-	token, err := GenerateToken(userID)
+	token, err := GenerateToken(ctx, userID)
 	if err != nil {
-		InsertLog("400", "Error generating token during authentication", "AuthenticateUser()")
+		InsertLogContext(ctx, "400", "Error generating token during authentication", "AuthenticateUser()")
 		return "", err
 	}
 
 	if token == "" {
-		InsertLog("400", "Generated token is empty during authentication", "AuthenticateUser()")
+		InsertLogContext(ctx, "400", "Generated token is empty during authentication", "AuthenticateUser()")
 		return "", fmt.Errorf("generated token is empty")
 	}
 
-	InsertLog("200", fmt.Sprintf("Generated token for user %s", username), "AuthenticateUser()")
+	InsertLogContext(ctx, "200", fmt.Sprintf("Generated token for user %s", username), "AuthenticateUser()")
 	return token, nil
 }
 
 // This code generates a JWT token with a user ID and expiration time, using HMAC-SHA256 for signing.
-func GenerateToken(userID string) (string, error) {
+func GenerateToken(ctx context.Context, userID string) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"uid": userID,
-		"exp": time.Now().Add(time.Hour * 1).Unix(),
+		"exp": time.Now().Add(TokenLifetime).Unix(),
 	})
 
 	tokenString, err := token.SignedString([]byte(SECRET_KEY))
 	if err != nil {
-		InsertLog("400", "Error signing token", "GenerateToken()")
+		InsertLogContext(ctx, "400", "Error signing token", "GenerateToken()")
 		return "", err
 	}
-	InsertLog("200", "Token generated successfully", "GenerateToken()")
+	InsertLogContext(ctx, "200", "Token generated successfully", "GenerateToken()")
 	return tokenString, nil
 }
 
@@ -180,32 +185,32 @@ func ExtractToken(r *http.Request) (string, error) {
 // This code defines a function called LogoutUser that takes a userID as a parameter and it uses the database connection.
 // (DB) to execute a SQL stored procedure to log out a user with the specified userID,
 // returning any potential errors encountered during the database operation.
-func LogoutUser(userID string) error {
-	_, err := DB.Exec("CALL logout_user(?)", userID)
+func LogoutUser(ctx context.Context, userID string) error {
+	_, err := DB.ExecContext(ctx, "CALL logout_user(?)", userID)
 	if err != nil {
-		InsertLog("400", "Failed to logout user", "LogoutUser()")
+		InsertLogContext(ctx, "400", "Failed to logout user", "LogoutUser()")
 		return err
 	}
-	InsertLog("200", "User logged out successfully", "LogoutUser()")
+	InsertLogContext(ctx, "200", "User logged out successfully", "LogoutUser()")
 	return nil
 }
 
 // It defines a function "RegisterUser" that securely registers a user by hashing their password
 // and storing their information in a database, returning a user ID or an error.
-func RegisterUser(username string, login string, role string, password string, active bool) (string, error) {
+func RegisterUser(ctx context.Context, username string, login string, role string, password string, active bool) (string, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		InsertLog("400", "Failed to hash password during registration", "RegisterUser()")
+		InsertLogContext(ctx, "400", "Failed to hash password during registration", "RegisterUser()")
 		return "", err
 	}
 
 	var userID string
-	err = DB.QueryRow("CALL user_registration(?, ?, ?, ?, ?)", username, login, role, hashedPassword, active).Scan(&userID)
+	err = DB.QueryRowContext(ctx, "CALL user_registration(?, ?, ?, ?, ?)", username, login, role, hashedPassword, active).Scan(&userID)
 	if err != nil {
-		InsertLog("400", "Failed to register user", "RegisterUser()")
+		InsertLogContext(ctx, "400", "Failed to register user", "RegisterUser()")
 		return "", err
 	}
-	InsertLog("200", "User registered successfully", "RegisterUser()")
+	InsertLogContext(ctx, "200", "User registered successfully", "RegisterUser()")
 
 	return userID, nil
 }
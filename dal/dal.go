@@ -55,6 +55,11 @@ func InitDB() error {
 	// if you testing dal:
 	//path := filepath.Join(cwd, "/../mysql/config.json")
 
+	// MYSQL_CONFIG points at the config file when the binary runs from any other directory
+	if configPath := os.Getenv("MYSQL_CONFIG"); configPath != "" {
+		path = configPath
+	}
+
 	config, err := readJSONConfig(path)
 	if err != nil {
 		log.Printf("Error initializing DB from config: %s", err)
@@ -1,6 +1,7 @@
 package dal
 
 import (
+	"context"
 	"database/sql"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -127,16 +128,16 @@ func GetUsersByRole(role string) ([]*User, error) {
 //
 // This code defines a function, GetAllUsers, that retrieves user data from a database, processes it,
 // and returns a  user objects while handling potential errors and resource cleanup.
-func GetAllUsers() ([]*User, error) {
-	rows, err := DB.Query("CALL get_users()")
+func GetAllUsers(ctx context.Context) ([]*User, error) {
+	rows, err := DB.QueryContext(ctx, "CALL get_users()")
 	if err != nil {
-		InsertLog("400", "Error getting all users: "+err.Error(), "GetAllUsers()")
+		InsertLogContext(ctx, "400", "Error getting all users: "+err.Error(), "GetAllUsers()")
 		return nil, err
 	}
 	defer func(rows *sql.Rows) {
 		err := rows.Close()
 		if err != nil {
-			InsertLog("400", "Error closing rows: "+err.Error(), "GetAllUsers()")
+			InsertLogContext(ctx, "400", "Error closing rows: "+err.Error(), "GetAllUsers()")
 		}
 	}(rows)
 	log.Printf("Closing Rows: %+v", rows)
@@ -144,13 +145,13 @@ func GetAllUsers() ([]*User, error) {
 	for rows.Next() {
 		var u User
 		if err := rows.Scan(&u.UserID, &u.UserName, &u.UserLogin, &u.UserRole, &u.UserPassword, &u.ActiveOrNot, &u.UserDateAdded); err != nil {
-			InsertLog("400", "Error scanning rows: "+err.Error(), "GetAllUsers()")
+			InsertLogContext(ctx, "400", "Error scanning rows: "+err.Error(), "GetAllUsers()")
 			return nil, err
 		}
 		users = append(users, &u)
 		log.Printf("User: %+v", u)
 	}
-	InsertLog("200", "Get All Users: %+v", "GetAllUsers()")
+	InsertLogContext(ctx, "200", "Get All Users: %+v", "GetAllUsers()")
 	return users, rows.Err()
 }
 
@@ -168,3 +169,31 @@ func FetchUserIDByName(userName string) (string, error) {
 	log.Printf("User ID: %s", userID)
 	return userID, nil
 }
+
+// WebService is the part of a web_service row used to authorize API keys.
+type WebService struct {
+	WebServiceID string
+	CustomerID   string
+	Active       bool
+	DailyQuota   int64 // 0 means unlimited
+}
+
+// GetWebServiceByToken returns the web service whose access token is the given API key. It returns
+// sql.ErrNoRows if the key is unknown.
+func GetWebServiceByToken(ctx context.Context, accessToken string) (*WebService, error) {
+	var ws WebService
+	var customerID sql.NullString
+	var active sql.NullBool
+	var quota sql.NullInt64
+	err := DB.QueryRowContext(ctx, "CALL get_web_service_by_token(?)", accessToken).Scan(&ws.WebServiceID, &customerID, &active, &quota)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			InsertLogContext(ctx, "400", "Error fetching web service by token: "+err.Error(), "GetWebServiceByToken()")
+		}
+		return nil, err
+	}
+	ws.CustomerID = customerID.String
+	ws.Active = active.Valid && active.Bool
+	ws.DailyQuota = quota.Int64
+	return &ws, nil
+}
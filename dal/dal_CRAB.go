@@ -1,10 +1,14 @@
 package dal
 
 import (
+	"cmpscfa23team2/canonical"
+	"context"
+	"database/sql"
 	"encoding/json"
 	_ "errors"
 	_ "github.com/go-sql-driver/mysql"
 	"log"
+	"time"
 )
 
 // Function to create a new web crawler
@@ -42,23 +46,26 @@ func CreateScraperEngine(engineName, engineDescription string) (string, error) {
 // Function to insert a new URL
 //
 // Function "InsertURL," inserts a URL into a database along with associated tags and logs the operation, returning the generated ID or an error.
-func InsertURL(url, domain string, tags map[string]interface{}) (string, error) {
+// The URL is stored once, in canonical form: inserting it again returns the existing ID and moves the URL to the new tags
+// and domain, so it is found under the domain that stored it last.
+func InsertURL(ctx context.Context, url, domain string, tags map[string]interface{}) (string, error) {
 	var id string
+	url = canonical.URL(url)
 	jsonTags, err := json.Marshal(tags)
 	if err != nil {
-		InsertLog("400", "Error marshalling tags: "+err.Error(), "InsertURL()")
+		InsertLogContext(ctx, "400", "Error marshalling tags: "+err.Error(), "InsertURL()")
 		return "", err
 	} else {
-		InsertLog("200", "URL inserted successfully", "InsertURL()")
+		InsertLogContext(ctx, "200", "URL inserted successfully", "InsertURL()")
 		log.Printf("URL inserted with tags: %v", tags)
 	}
 
-	err = DB.QueryRow("CALL insert_url(?, ?, ?)", url, string(jsonTags), domain).Scan(&id)
+	err = DB.QueryRowContext(ctx, "CALL insert_url(?, ?, ?)", url, string(jsonTags), domain).Scan(&id)
 	if err != nil {
-		InsertLog("400", "Error inserting URL: "+err.Error(), "InsertURL()")
+		InsertLogContext(ctx, "400", "Error inserting URL: "+err.Error(), "InsertURL()")
 		return "", err
 	} else {
-		InsertLog("200", "URL inserted with ID: "+id, "InsertURL()")
+		InsertLogContext(ctx, "200", "URL inserted with ID: "+id, "InsertURL()")
 		log.Printf("URL inserted with tags: %v", tags)
 	}
 	return id, nil
@@ -68,6 +75,7 @@ func InsertURL(url, domain string, tags map[string]interface{}) (string, error)
 //
 // It defines a function UpdateURL that updates a URL record in a database, converting tags into JSON format and logging the update action.
 func UpdateURL(id, url, domain string, tags map[string]interface{}) error {
+	url = canonical.URL(url)
 	jsonTags, err := json.Marshal(tags)
 	if err != nil {
 		InsertLog("400", "Error marshalling tags: "+err.Error(), "UpdateURL()")
@@ -113,10 +121,10 @@ func GetURLTagsAndDomain(id string) (map[string]interface{}, string, error) {
 // Function to fetch URLs from a specific domain
 //
 // Defines a function that queries a database to retrieve URLs associated with a given domain, processes the results, and returns the URLs in a slice while handling potential errors and logging.
-func GetURLsFromDomain(domain string) ([]string, error) {
-	rows, err := DB.Query("CALL get_urls_from_domain(?)", domain)
+func GetURLsFromDomain(ctx context.Context, domain string) ([]string, error) {
+	rows, err := DB.QueryContext(ctx, "CALL get_urls_from_domain(?)", domain)
 	if err != nil {
-		InsertLog("400", "Error getting URLs from domain: "+err.Error(), "GetURLsFromDomain()")
+		InsertLogContext(ctx, "400", "Error getting URLs from domain: "+err.Error(), "GetURLsFromDomain()")
 		return nil, err
 	}
 	log.Println("Closing Rows: %+v", rows)
@@ -127,13 +135,132 @@ func GetURLsFromDomain(domain string) ([]string, error) {
 		var id, url, tags, domain string
 		var createdTime []byte // <-- Change this line
 		if err := rows.Scan(&id, &url, &tags, &domain, &createdTime); err != nil {
-			InsertLog("400", "Error scanning rows: "+err.Error(), "GetURLsFromDomain()")
+			InsertLogContext(ctx, "400", "Error scanning rows: "+err.Error(), "GetURLsFromDomain()")
 			return nil, err
 		} else {
-			InsertLog("200", "URLs from domain extracted successfully", "GetURLsFromDomain()")
+			InsertLogContext(ctx, "200", "URLs from domain extracted successfully", "GetURLsFromDomain()")
 			log.Printf("URLs from domain: %+v", urls)
 		}
 		urls = append(urls, url)
 	}
 	return urls, rows.Err()
 }
+
+// ScrapedItem models a row of the scrapedData table.
+type ScrapedItem struct {
+	Domain      string
+	Title       string
+	URL         string
+	Description string
+	Price       string
+	Source      string
+	Timestamp   time.Time
+	RunID       string // Pipeline run that stored the item; empty outside pipelines
+	Stage       string // Pipeline stage that stored the item
+}
+
+// InsertScrapedItem stores one item produced by the scraper.
+func InsertScrapedItem(ctx context.Context, item ScrapedItem) error {
+	_, err := DB.ExecContext(ctx, "CALL insert_scraped_item(?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		item.Domain, item.Title, item.URL, item.Description, item.Price, item.Source, nullTime(item.Timestamp),
+		nullString(item.RunID), nullString(item.Stage))
+	if err != nil {
+		InsertLogContext(ctx, "400", "Error inserting scraped item: "+err.Error(), "InsertScrapedItem()")
+		return err
+	}
+	return nil
+}
+
+// DeleteScrapedItemsOfRun deletes the items a stage of a pipeline run stored, so that a resumed or
+// rerun stage replaces them instead of storing them twice.
+func DeleteScrapedItemsOfRun(ctx context.Context, runID, stage string) error {
+	_, err := DB.ExecContext(ctx, "CALL delete_scraped_items_of_run(?, ?)", runID, stage)
+	if err != nil {
+		InsertLogContext(ctx, "400", "Error deleting scraped items of run: "+err.Error(), "DeleteScrapedItemsOfRun()")
+		return err
+	}
+	return nil
+}
+
+// GetScrapedItems returns the stored items of a domain, oldest first.
+func GetScrapedItems(ctx context.Context, domain string) ([]ScrapedItem, error) {
+	rows, err := DB.QueryContext(ctx, "CALL get_scraped_items_by_domain(?)", domain)
+	if err != nil {
+		InsertLogContext(ctx, "400", "Error getting scraped items: "+err.Error(), "GetScrapedItems()")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []ScrapedItem
+	for rows.Next() {
+		var item ScrapedItem
+		var title, url, description, price, source sql.NullString
+		var timestamp []uint8
+		if err := rows.Scan(&item.Domain, &title, &url, &description, &price, &source, &timestamp); err != nil {
+			InsertLogContext(ctx, "400", "Error scanning scraped items: "+err.Error(), "GetScrapedItems()")
+			return nil, err
+		}
+		item.Title = title.String
+		item.URL = url.String
+		item.Description = description.String
+		item.Price = price.String
+		item.Source = source.String
+		item.Timestamp = parseDBTime(timestamp)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// FrontierURL models a row of the crawl_frontier table: a URL a crawl has found, and when it was
+// crawled, the zero time while it is waiting.
+type FrontierURL struct {
+	Crawl    string
+	URL      string
+	Seed     string
+	Depth    int
+	Priority float64
+	Crawled  time.Time
+}
+
+// InsertFrontierURL adds a URL to the frontier of a crawl. A URL the crawl already has is queued again with the new priority.
+func InsertFrontierURL(u FrontierURL) error {
+	_, err := DB.Exec("CALL insert_frontier_url(?, ?, ?, ?, ?)", u.Crawl, u.URL, u.Seed, u.Depth, u.Priority)
+	if err != nil {
+		InsertLog("400", "Error inserting frontier URL: "+err.Error(), "InsertFrontierURL()")
+		return err
+	}
+	return nil
+}
+
+// MarkFrontierURLCrawled records when a URL of the frontier of a crawl was crawled.
+func MarkFrontierURLCrawled(crawl, url string, crawled time.Time) error {
+	_, err := DB.Exec("CALL mark_frontier_url_crawled(?, ?, ?)", crawl, url, crawled.UTC())
+	if err != nil {
+		InsertLog("400", "Error marking frontier URL crawled: "+err.Error(), "MarkFrontierURLCrawled()")
+		return err
+	}
+	return nil
+}
+
+// GetFrontierURLs returns the frontier of a crawl, crawled URLs included, in the order they were added.
+func GetFrontierURLs(crawl string) ([]FrontierURL, error) {
+	rows, err := DB.Query("CALL get_frontier_urls(?)", crawl)
+	if err != nil {
+		InsertLog("400", "Error getting frontier URLs: "+err.Error(), "GetFrontierURLs()")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var urls []FrontierURL
+	for rows.Next() {
+		u := FrontierURL{Crawl: crawl}
+		var crawled []uint8
+		if err := rows.Scan(&u.URL, &u.Seed, &u.Depth, &u.Priority, &crawled); err != nil {
+			InsertLog("400", "Error scanning frontier URLs: "+err.Error(), "GetFrontierURLs()")
+			return nil, err
+		}
+		u.Crawled = parseDBTime(crawled)
+		urls = append(urls, u)
+	}
+	return urls, rows.Err()
+}
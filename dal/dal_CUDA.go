@@ -64,23 +64,24 @@ type SkillData struct {
 	Matches []JobData
 }
 
+// InsertPrediction stores a prediction, taking its domain from the query identifier (see PredictionDomain).
 func InsertPrediction(algorithm, queryIdentifier, fileName, predictionInfo, skills string) error {
+	return InsertDomainPrediction(algorithm, PredictionDomain(queryIdentifier), queryIdentifier, predictionInfo, skills)
+}
+
+// InsertDomainPrediction stores a prediction of the given algorithm under domain, so that it shows up
+// in the prediction history of that domain. An empty domain is stored as NULL.
+func InsertDomainPrediction(algorithm, domain, queryIdentifier, predictionInfo, inputData string) error {
 	// Generate a new UUID for the prediction
 	newUUID := uuid.New().String()
 
-	var query string
-	switch algorithm {
-	case "KNN":
-		query = "INSERT INTO knn_predictions (prediction_id, query_identifier, input_data, prediction_info) VALUES (?, ?, ?, ?)"
-	case "LinearRegression":
-		query = "INSERT INTO linear_regression_predictions (prediction_id, query_identifier, input_data, prediction_info) VALUES (?, ?, ?, ?)"
-	case "NaiveBayes":
-		query = "INSERT INTO naive_bayes_predictions (prediction_id, query_identifier, input_data, prediction_info) VALUES (?, ?, ?, ?)"
-	default:
+	table, ok := predictionTables[algorithm]
+	if !ok {
 		return fmt.Errorf("Unrecognized algorithm: %v", algorithm)
 	}
+	query := "INSERT INTO " + table + " (prediction_id, query_identifier, domain, input_data, prediction_info) VALUES (?, ?, ?, ?, ?)"
 
-	_, err := DB.Exec(query, newUUID, queryIdentifier, skills, predictionInfo)
+	_, err := DB.Exec(query, newUUID, queryIdentifier, nullString(domain), inputData, predictionInfo)
 	if err != nil {
 		return fmt.Errorf("Error storing prediction for %v: %v", algorithm, err)
 	}
@@ -273,13 +274,13 @@ func FetchPredictionData(queryIdentifier, domain string) (PredictionData, error)
 				}
 
 				// Set the image path for KNN
-				data.ImagePath = fmt.Sprintf("/static/Assets/MachineLearning/LinearRegression/%s_scatter_plot.png", queryIdentifier)
+				data.ImagePath = PredictionChartPath("KNN", queryIdentifier)
 			} else {
 				return handleDBError(err, queryIdentifier)
 			}
 		} else {
 			// Set the image path for linear regression
-			data.ImagePath = fmt.Sprintf("/static/Assets/MachineLearning/LinearRegression/%s_scatter_plot.png", queryIdentifier)
+			data.ImagePath = PredictionChartPath("LinearRegression", queryIdentifier)
 		}
 
 	case "Airfare Prices":
@@ -295,14 +296,14 @@ func FetchPredictionData(queryIdentifier, domain string) (PredictionData, error)
 					return handleDBError(err, queryIdentifier)
 				}
 
-				// Set the image path for KNN
-				data.ImagePath = fmt.Sprintf("/static/Assets/MachineLearning/LinearRegression/%s_scatter_plot.png", queryIdentifier)
+				// Set the image path for linear regression
+				data.ImagePath = PredictionChartPath("LinearRegression", queryIdentifier)
 			} else {
 				return handleDBError(err, queryIdentifier)
 			}
 		} else {
-			// Set the image path for linear regression
-			data.ImagePath = fmt.Sprintf("/static/Assets/MachineLearning/LinearRegression/%s_scatter_plot.png", queryIdentifier)
+			// Set the image path for KNN
+			data.ImagePath = PredictionChartPath("KNN", queryIdentifier)
 		}
 
 	case "Job Market":
@@ -329,6 +330,7 @@ func FetchPredictionData(queryIdentifier, domain string) (PredictionData, error)
 
 		data.JobListings = container.Data
 		data.SpecificJob = SearchJobByTitle(container.Data, jobTitle)
+		data.ImagePath = PredictionChartPath("NaiveBayes", queryIdentifier)
 
 	default:
 		return PredictionData{}, fmt.Errorf("unrecognized domain: %s", domain)
@@ -0,0 +1,109 @@
+package dal
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/go-sql-driver/mysql"
+	"log"
+	"time"
+)
+
+// Dataset models a row of the datasets table: one version of an uploaded training dataset. Schema
+// holds the JSON encoded column list.
+type Dataset struct {
+	DatasetID   string
+	Name        string
+	Version     int
+	Domain      string
+	Source      string
+	Format      string
+	FilePath    string
+	SizeBytes   int64
+	RowCount    int
+	Checksum    string
+	Schema      string
+	CreatedTime time.Time
+}
+
+// CreateDataset registers a dataset version.
+func CreateDataset(ctx context.Context, d Dataset) error {
+	_, err := DB.ExecContext(ctx, "CALL create_dataset(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		d.DatasetID, d.Name, d.Version, nullString(d.Domain), nullString(d.Source), d.Format, d.FilePath,
+		d.SizeBytes, d.RowCount, d.Checksum, nullString(d.Schema), nullTime(d.CreatedTime))
+	if err != nil {
+		InsertLogContext(ctx, "400", "Error creating dataset: "+err.Error(), "CreateDataset()")
+		return err
+	}
+	InsertLogContext(ctx, "200", "Dataset created: "+d.Name, "CreateDataset()")
+	return nil
+}
+
+// GetDataset fetches a dataset version by ID. It returns sql.ErrNoRows if it does not exist.
+func GetDataset(ctx context.Context, datasetID string) (*Dataset, error) {
+	d, err := scanDataset(DB.QueryRowContext(ctx, "CALL get_dataset(?)", datasetID))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			InsertLogContext(ctx, "400", "Error getting dataset: "+err.Error(), "GetDataset()")
+		}
+		return nil, err
+	}
+	return d, nil
+}
+
+// GetDatasets lists the versions of a dataset, newest first. An empty name lists every dataset by name.
+func GetDatasets(ctx context.Context, name string) ([]*Dataset, error) {
+	rows, err := DB.QueryContext(ctx, "CALL get_datasets(?)", name)
+	if err != nil {
+		InsertLogContext(ctx, "400", "Error getting datasets: "+err.Error(), "GetDatasets()")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*Dataset
+	for rows.Next() {
+		d, err := scanDataset(rows)
+		if err != nil {
+			InsertLogContext(ctx, "400", "Error scanning datasets: "+err.Error(), "GetDatasets()")
+			return nil, err
+		}
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+// DeleteDataset removes a dataset version. It returns sql.ErrNoRows if it does not exist.
+func DeleteDataset(ctx context.Context, datasetID string) error {
+	var deleted int64
+	err := DB.QueryRowContext(ctx, "CALL delete_dataset(?)", datasetID).Scan(&deleted)
+	if err != nil {
+		InsertLogContext(ctx, "400", "Error deleting dataset: "+err.Error(), "DeleteDataset()")
+		return err
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	InsertLogContext(ctx, "200", "Dataset deleted: "+datasetID, "DeleteDataset()")
+	log.Printf("Dataset deleted: %s", datasetID)
+	return nil
+}
+
+// scanDataset reads one datasets row.
+func scanDataset(row rowScanner) (*Dataset, error) {
+	var d Dataset
+	var domain, source, schema sql.NullString
+	var sizeBytes sql.NullInt64
+	var rowCount sql.NullInt32
+	var createdTime []uint8
+	err := row.Scan(&d.DatasetID, &d.Name, &d.Version, &domain, &source, &d.Format, &d.FilePath,
+		&sizeBytes, &rowCount, &d.Checksum, &schema, &createdTime)
+	if err != nil {
+		return nil, err
+	}
+	d.Domain = domain.String
+	d.Source = source.String
+	d.Schema = schema.String
+	d.SizeBytes = sizeBytes.Int64
+	d.RowCount = int(rowCount.Int32)
+	d.CreatedTime = parseDBTime(createdTime)
+	return &d, nil
+}
@@ -0,0 +1,97 @@
+package dal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+)
+
+// ErrNotConnected is returned by the health checks when InitDB has not opened a connection.
+var ErrNotConnected = errors.New("dal: database not initialized")
+
+// Ping checks that the database can be reached.
+func Ping(ctx context.Context) error {
+	if DB == nil {
+		return ErrNotConnected
+	}
+	return DB.PingContext(ctx)
+}
+
+// PoolStats returns the connection pool statistics, or zero values when there is no connection.
+func PoolStats() sql.DBStats {
+	if DB == nil {
+		return sql.DBStats{}
+	}
+	return DB.Stats()
+}
+
+// MissingTables returns the tables from the given list that do not exist in the current schema,
+// which means scripts.sql has not been applied since they were added.
+func MissingTables(ctx context.Context, tables ...string) ([]string, error) {
+	if DB == nil {
+		return nil, ErrNotConnected
+	}
+	rows, err := DB.QueryContext(ctx, "SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE()")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		existing[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, table := range tables {
+		if !existing[table] {
+			missing = append(missing, table)
+		}
+	}
+	return missing, nil
+}
+
+// MissingColumns returns the columns, as table.column, from the given columns by table that do not
+// exist in the current schema, which means scripts.sql has not been applied since they were added.
+// Columns of missing tables are reported as missing too.
+func MissingColumns(ctx context.Context, columns map[string][]string) ([]string, error) {
+	if DB == nil {
+		return nil, ErrNotConnected
+	}
+	rows, err := DB.QueryContext(ctx, "SELECT table_name, column_name FROM information_schema.columns WHERE table_schema = DATABASE()")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, err
+		}
+		existing[table+"."+column] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for table, names := range columns {
+		for _, column := range names {
+			if !existing[table+"."+column] {
+				missing = append(missing, table+"."+column)
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
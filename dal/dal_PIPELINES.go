@@ -0,0 +1,79 @@
+package dal
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/go-sql-driver/mysql"
+	"time"
+)
+
+// PipelineRun models a row of the pipeline_runs table. Definition and Stages hold the JSON encoded
+// pipeline definition and stage checkpoints.
+type PipelineRun struct {
+	RunID        string
+	PipelineName string
+	Status       string
+	Definition   string
+	Stages       string
+	CreatedTime  time.Time
+	UpdatedTime  time.Time
+}
+
+// SavePipelineRun inserts a pipeline run or updates its status and checkpoints if it already exists.
+func SavePipelineRun(ctx context.Context, run PipelineRun) error {
+	_, err := DB.ExecContext(ctx, "CALL save_pipeline_run(?, ?, ?, ?, ?, ?)",
+		run.RunID, run.PipelineName, run.Status, run.Definition, run.Stages, nullTime(run.CreatedTime))
+	if err != nil {
+		InsertLogContext(ctx, "400", "Error saving pipeline run: "+err.Error(), "SavePipelineRun()")
+		return err
+	}
+	return nil
+}
+
+// GetPipelineRun fetches a pipeline run by ID. It returns sql.ErrNoRows if the run does not exist.
+func GetPipelineRun(ctx context.Context, runID string) (*PipelineRun, error) {
+	run, err := scanPipelineRun(DB.QueryRowContext(ctx, "CALL get_pipeline_run(?)", runID))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			InsertLogContext(ctx, "400", "Error getting pipeline run: "+err.Error(), "GetPipelineRun()")
+		}
+		return nil, err
+	}
+	return run, nil
+}
+
+// GetPipelineRuns lists the runs of a pipeline, newest first. An empty name lists every run.
+func GetPipelineRuns(ctx context.Context, pipelineName string) ([]*PipelineRun, error) {
+	rows, err := DB.QueryContext(ctx, "CALL get_pipeline_runs(?)", pipelineName)
+	if err != nil {
+		InsertLogContext(ctx, "400", "Error getting pipeline runs: "+err.Error(), "GetPipelineRuns()")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []*PipelineRun
+	for rows.Next() {
+		run, err := scanPipelineRun(rows)
+		if err != nil {
+			InsertLogContext(ctx, "400", "Error scanning pipeline runs: "+err.Error(), "GetPipelineRuns()")
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// scanPipelineRun reads one pipeline_runs row.
+func scanPipelineRun(row rowScanner) (*PipelineRun, error) {
+	var run PipelineRun
+	var definition, stages sql.NullString
+	var createdTime, updatedTime []uint8
+	if err := row.Scan(&run.RunID, &run.PipelineName, &run.Status, &definition, &stages, &createdTime, &updatedTime); err != nil {
+		return nil, err
+	}
+	run.Definition = definition.String
+	run.Stages = stages.String
+	run.CreatedTime = parseDBTime(createdTime)
+	run.UpdatedTime = parseDBTime(updatedTime)
+	return &run, nil
+}
@@ -0,0 +1,150 @@
+package dal
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/go-sql-driver/mysql"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// PredictionAlgorithms are the algorithms whose predictions are stored, each in its own table.
+var PredictionAlgorithms = []string{"KNN", "LinearRegression", "NaiveBayes"}
+
+// predictionTables maps each algorithm to its predictions table.
+var predictionTables = map[string]string{
+	"KNN":              "knn_predictions",
+	"LinearRegression": "linear_regression_predictions",
+	"NaiveBayes":       "naive_bayes_predictions",
+}
+
+// predictionDomainRules map the query identifiers of the prediction page to its domains.
+var predictionDomainRules = []struct {
+	prefix, suffix, domain string
+}{
+	{"gas prices", "", "Gas Prices"},
+	{"airfare prices", "", "Airfare Prices"},
+	{"top ", " jobs", "Job Market"},
+}
+
+// PredictionDomain guesses the domain of a query identifier such as "Gas Prices Prediction 2024" or
+// "Top 3 Tech Jobs". It returns "" for identifiers that belong to none of the known domains.
+func PredictionDomain(queryIdentifier string) string {
+	lower := strings.ToLower(queryIdentifier)
+	for _, rule := range predictionDomainRules {
+		if strings.HasPrefix(lower, rule.prefix) && strings.HasSuffix(lower, rule.suffix) {
+			return rule.domain
+		}
+	}
+	return ""
+}
+
+// PredictionChartPath returns the URL of the chart that carp renders for the latest prediction of an
+// algorithm for a query identifier.
+func PredictionChartPath(algorithm, queryIdentifier string) string {
+	return "/api/v1/charts/predictions.png?" + url.Values{"algorithm": {algorithm}, "query": {queryIdentifier}}.Encode()
+}
+
+// PredictionRecord is one stored prediction of any algorithm.
+type PredictionRecord struct {
+	PredictionID    string    `json:"prediction_id"`
+	Algorithm       string    `json:"algorithm"`
+	Domain          string    `json:"domain"`
+	QueryIdentifier string    `json:"query_identifier"`
+	InputData       string    `json:"input_data"`
+	PredictionInfo  string    `json:"prediction_info"`
+	PredictionTime  time.Time `json:"prediction_time"`
+}
+
+// PredictionFilter selects predictions from the history. Empty fields match everything; Until is
+// exclusive.
+type PredictionFilter struct {
+	Domain          string
+	Algorithm       string
+	QueryIdentifier string
+	Since           time.Time
+	Until           time.Time
+	Limit           int
+	Offset          int
+}
+
+// DefaultPredictionLimit is the page size used when a PredictionFilter has no Limit.
+const DefaultPredictionLimit = 50
+
+// GetPredictionHistory lists the predictions that match filter, newest first.
+func GetPredictionHistory(ctx context.Context, filter PredictionFilter) ([]*PredictionRecord, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultPredictionLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	rows, err := DB.QueryContext(ctx, "CALL get_prediction_history(?, ?, ?, ?, ?, ?, ?)",
+		nullString(filter.Domain), nullString(filter.Algorithm), nullString(filter.QueryIdentifier),
+		nullTime(filter.Since), nullTime(filter.Until), filter.Limit, filter.Offset)
+	if err != nil {
+		InsertLogContext(ctx, "400", "Error getting prediction history: "+err.Error(), "GetPredictionHistory()")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*PredictionRecord
+	for rows.Next() {
+		record, err := scanPrediction(rows)
+		if err != nil {
+			InsertLogContext(ctx, "400", "Error scanning prediction history: "+err.Error(), "GetPredictionHistory()")
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// GetPredictionByID fetches a prediction of any algorithm. It returns sql.ErrNoRows if there is none.
+func GetPredictionByID(ctx context.Context, predictionID string) (*PredictionRecord, error) {
+	record, err := scanPrediction(DB.QueryRowContext(ctx, "CALL get_prediction_by_id(?)", predictionID))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			InsertLogContext(ctx, "400", "Error getting prediction: "+err.Error(), "GetPredictionByID()")
+		}
+		return nil, err
+	}
+	return record, nil
+}
+
+// GetPredictionDomains lists the domains that have at least one prediction.
+func GetPredictionDomains(ctx context.Context) ([]string, error) {
+	rows, err := DB.QueryContext(ctx, "CALL get_prediction_domains()")
+	if err != nil {
+		InsertLogContext(ctx, "400", "Error getting prediction domains: "+err.Error(), "GetPredictionDomains()")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var domains []string
+	for rows.Next() {
+		var domain string
+		if err := rows.Scan(&domain); err != nil {
+			return nil, err
+		}
+		domains = append(domains, domain)
+	}
+	return domains, rows.Err()
+}
+
+// scanPrediction reads one row of the prediction history sprocs.
+func scanPrediction(row rowScanner) (*PredictionRecord, error) {
+	var record PredictionRecord
+	var domain, queryIdentifier, inputData, predictionInfo sql.NullString
+	var predictionTime []uint8
+	if err := row.Scan(&record.PredictionID, &record.Algorithm, &domain, &queryIdentifier, &inputData, &predictionInfo, &predictionTime); err != nil {
+		return nil, err
+	}
+	record.Domain = domain.String
+	record.QueryIdentifier = queryIdentifier.String
+	record.InputData = inputData.String
+	record.PredictionInfo = predictionInfo.String
+	record.PredictionTime = parseDBTime(predictionTime)
+	return &record, nil
+}
@@ -0,0 +1,96 @@
+package dal
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/go-sql-driver/mysql"
+	"log"
+	"time"
+)
+
+// ScheduleRow models a row of the schedules table used by the recurring job scheduler.
+type ScheduleRow struct {
+	ScheduleID   string
+	ScheduleName string
+	CronExpr     string
+	JobType      string
+	Params       string
+	Priority     int
+	Enabled      bool
+	LastRun      time.Time
+	NextRun      time.Time
+	LastTaskID   string
+}
+
+// nullTime returns nil for the zero time so it is stored as NULL.
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
+
+// nullString returns nil for the empty string so it is stored as NULL.
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// SaveSchedule inserts a schedule or updates it if the ID already exists.
+func SaveSchedule(ctx context.Context, s ScheduleRow) error {
+	_, err := DB.ExecContext(ctx, "CALL save_schedule(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		s.ScheduleID, s.ScheduleName, s.CronExpr, s.JobType, nullString(s.Params), s.Priority, s.Enabled,
+		nullTime(s.LastRun), nullTime(s.NextRun), nullString(s.LastTaskID))
+	if err != nil {
+		InsertLogContext(ctx, "400", "Error saving schedule: "+err.Error(), "SaveSchedule()")
+		return err
+	}
+	return nil
+}
+
+// GetSchedules returns every stored schedule.
+func GetSchedules(ctx context.Context) ([]ScheduleRow, error) {
+	rows, err := DB.QueryContext(ctx, "CALL get_schedules()")
+	if err != nil {
+		InsertLogContext(ctx, "400", "Error getting schedules: "+err.Error(), "GetSchedules()")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []ScheduleRow
+	for rows.Next() {
+		var s ScheduleRow
+		var name, params, lastTaskID sql.NullString
+		var lastRun, nextRun []uint8
+		if err := rows.Scan(&s.ScheduleID, &name, &s.CronExpr, &s.JobType, &params, &s.Priority, &s.Enabled,
+			&lastRun, &nextRun, &lastTaskID); err != nil {
+			InsertLogContext(ctx, "400", "Error scanning schedule rows: "+err.Error(), "GetSchedules()")
+			return nil, err
+		}
+		s.ScheduleName = name.String
+		s.Params = params.String
+		s.LastTaskID = lastTaskID.String
+		s.LastRun = parseDBTime(lastRun)
+		s.NextRun = parseDBTime(nextRun)
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+// DeleteSchedule removes a schedule. It returns sql.ErrNoRows if no schedule had the given ID.
+func DeleteSchedule(ctx context.Context, scheduleID string) error {
+	var deleted int64
+	err := DB.QueryRowContext(ctx, "CALL delete_schedule(?)", scheduleID).Scan(&deleted)
+	if err != nil {
+		InsertLogContext(ctx, "400", "Error deleting schedule: "+err.Error(), "DeleteSchedule()")
+		return err
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	InsertLogContext(ctx, "200", "Schedule deleted: "+scheduleID, "DeleteSchedule()")
+	log.Printf("Schedule deleted: %s", scheduleID)
+	return nil
+}
@@ -0,0 +1,131 @@
+package dal
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/go-sql-driver/mysql"
+	"log"
+	"time"
+)
+
+// Task models a row of the tasks table, which backs the asynchronous job system.
+//
+// Payload holds the job parameters as JSON; Progress runs from 0 to 1.
+type Task struct {
+	TaskID       string
+	TaskName     string
+	Priority     int
+	Status       string
+	Payload      string
+	Progress     float64
+	Message      string
+	ErrorMessage string
+	Attempts     int
+	MaxAttempts  int
+	RunAt        time.Time
+	CreatedTime  time.Time
+	UpdatedTime  time.Time
+}
+
+// dbTimeLayout is the layout MySQL uses when DATETIME and TIMESTAMP columns are read without parseTime.
+const dbTimeLayout = "2006-01-02 15:04:05"
+
+// parseDBTime converts a DATETIME/TIMESTAMP column scanned as bytes into a time.Time. NULL or malformed values give the zero time.
+func parseDBTime(value []uint8) time.Time {
+	if len(value) == 0 {
+		return time.Time{}
+	}
+	t, err := time.ParseInLocation(dbTimeLayout, string(value), time.UTC)
+	if err != nil {
+		log.Printf("Error parsing time %q: %v", value, err)
+		return time.Time{}
+	}
+	return t
+}
+
+// CreateTask inserts a new task row using the create_task stored procedure and returns its ID.
+func CreateTask(ctx context.Context, task Task) (string, error) {
+	var taskID string
+	var payload interface{}
+	if task.Payload != "" {
+		payload = task.Payload
+	}
+	err := DB.QueryRowContext(ctx, "CALL create_task(?, ?, ?, ?, ?, ?, ?)",
+		task.TaskID, task.TaskName, task.Priority, task.Status, payload, task.MaxAttempts, task.RunAt.UTC()).Scan(&taskID)
+	if err != nil {
+		InsertLogContext(ctx, "400", "Error creating task: "+err.Error(), "CreateTask()")
+		return "", err
+	}
+	InsertLogContext(ctx, "200", "Task created: "+taskID, "CreateTask()")
+	log.Printf("Task created: %s (%s)", taskID, task.TaskName)
+	return taskID, nil
+}
+
+// UpdateTask saves the status, progress and retry information of an existing task.
+func UpdateTask(ctx context.Context, task Task) error {
+	_, err := DB.ExecContext(ctx, "CALL update_task(?, ?, ?, ?, ?, ?, ?, ?)",
+		task.TaskID, task.Priority, task.Status, task.Progress, task.Message, task.ErrorMessage, task.Attempts, task.RunAt.UTC())
+	if err != nil {
+		InsertLogContext(ctx, "400", "Error updating task: "+err.Error(), "UpdateTask()")
+		return err
+	}
+	return nil
+}
+
+// GetTask fetches a single task by its ID. It returns sql.ErrNoRows if the task does not exist.
+func GetTask(ctx context.Context, taskID string) (*Task, error) {
+	row := DB.QueryRowContext(ctx, "CALL get_task(?)", taskID)
+	task, err := scanTask(row)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			InsertLogContext(ctx, "400", "Error getting task: "+err.Error(), "GetTask()")
+		}
+		return nil, err
+	}
+	return task, nil
+}
+
+// GetTasksByStatus lists tasks with the given status, newest first. An empty status returns every task.
+func GetTasksByStatus(ctx context.Context, status string) ([]*Task, error) {
+	rows, err := DB.QueryContext(ctx, "CALL get_tasks_by_status(?)", status)
+	if err != nil {
+		InsertLogContext(ctx, "400", "Error getting tasks by status: "+err.Error(), "GetTasksByStatus()")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []*Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			InsertLogContext(ctx, "400", "Error scanning task rows: "+err.Error(), "GetTasksByStatus()")
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTask reads one task row in the column order returned by get_task and get_tasks_by_status.
+func scanTask(row rowScanner) (*Task, error) {
+	var t Task
+	var payload, message, errorMessage sql.NullString
+	var runAt, created, updated []uint8
+	err := row.Scan(&t.TaskID, &t.TaskName, &t.Priority, &t.Status, &payload, &t.Progress, &message, &errorMessage,
+		&t.Attempts, &t.MaxAttempts, &runAt, &created, &updated)
+	if err != nil {
+		return nil, err
+	}
+	t.Payload = payload.String
+	t.Message = message.String
+	t.ErrorMessage = errorMessage.String
+	t.RunAt = parseDBTime(runAt)
+	t.CreatedTime = parseDBTime(created)
+	t.UpdatedTime = parseDBTime(updated)
+	return &t, nil
+}
@@ -1,6 +1,7 @@
 package dal
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -30,6 +31,7 @@ type LogStatusCodes struct {
 // Function to insert a log entry into the database
 //
 // It  inserts a log entry into a database using a SQL stored procedure, handling any errors that may occur during the execution.
+// Functions called while serving a request log through InsertLogContext instead, so the row carries the request ID.
 func InsertLog(statusCode, message, goEngineArea string) {
 	_, err := DB.Exec("CALL insert_log(?, ?, ?)", statusCode, message, goEngineArea)
 	if err != nil {
@@ -37,6 +39,58 @@ func InsertLog(statusCode, message, goEngineArea string) {
 	}
 }
 
+// requestIDKey is the context key under which WithRequestID stores the request ID.
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the ID of the HTTP request being served, so log rows
+// written with InsertLogContext can be traced back to it.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID stored in ctx, or "" if there is none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// InsertLogContext inserts a log entry tagged with the request ID from ctx. Without a request ID it
+// behaves like InsertLog.
+func InsertLogContext(ctx context.Context, statusCode, message, goEngineArea string) {
+	requestID := RequestID(ctx)
+	if requestID == "" {
+		InsertLog(statusCode, message, goEngineArea)
+		return
+	}
+	if len(message) > 250 {
+		message = message[:250]
+	}
+	_, err := DB.ExecContext(ctx, "CALL insert_request_log(?, ?, ?, ?)", statusCode, message, goEngineArea, requestID)
+	if err != nil {
+		log.Printf("Error inserting log for request %s: %v", requestID, err)
+	}
+}
+
+// GetLogsByRequestID returns the log entries written while serving the given request, oldest first.
+func GetLogsByRequestID(requestID string) ([]Log, error) {
+	rows, err := DB.Query("CALL select_logs_by_request_id(?)", requestID)
+	if err != nil {
+		InsertLog("400", "Failed to query logs by request ID", "GetLogsByRequestID()")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []Log
+	for rows.Next() {
+		var logItem Log
+		if err := rows.Scan(&logItem.LogID, &logItem.status_code, &logItem.Message, &logItem.GoEngineArea, &logItem.DateTime); err != nil {
+			return nil, err
+		}
+		logs = append(logs, logItem)
+	}
+	return logs, rows.Err()
+}
+
 // This function creates & adds the log entries to a TextFile if the database is down
 func init() {
 	// Initialize the database first
@@ -2,6 +2,7 @@ package dal_test
 
 import (
 	"cmpscfa23team2/dal"
+	"context"
 	_ "github.com/golang-jwt/jwt"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/crypto/bcrypt"
@@ -52,7 +53,7 @@ func TestComparePassword(t *testing.T) {
 func TestGenerateToken(t *testing.T) {
 	// replace with test values
 	userID := "testUserID"
-	token, err := dal.GenerateToken(userID)
+	token, err := dal.GenerateToken(context.Background(), userID)
 	if err != nil {
 		t.Errorf("Token generation failed: %v", err)
 		dal.InsertLog("400", "Failed to generate token", "TestGenerateToken()")
@@ -89,7 +90,7 @@ func TestGenerateToken(t *testing.T) {
 
 func TestValidateToken(t *testing.T) {
 	// if it is a valid token
-	validToken, _ := dal.GenerateToken("testUserID")
+	validToken, _ := dal.GenerateToken(context.Background(), "testUserID")
 	isValid, err := dal.ValidateToken(validToken)
 	assert.Nil(t, err)      //assert that there is no error
 	assert.True(t, isValid) //assert that the token is valid
@@ -120,7 +121,7 @@ func TestAuthenticateUser(t *testing.T) {
 	}
 
 	// Authenticate the user with plain text password
-	_, authErr := dal.AuthenticateUser(username, plainPassword)
+	_, authErr := dal.AuthenticateUser(context.Background(), username, plainPassword)
 	if authErr != nil {
 		t.Errorf("Authentication failed: %v", authErr)
 		dal.InsertLog("400", "Authentication failed", "TestAuthenticateUser()")
@@ -176,7 +177,7 @@ func TestRegisterUser(t *testing.T) {
 	login := "jmf6913"
 	role := "DEV"
 	password := "std447"
-	userID, err := dal.RegisterUser(username, login, role, password, true)
+	userID, err := dal.RegisterUser(context.Background(), username, login, role, password, true)
 	if err != nil {
 		t.Errorf("User registration failed: %v", err)
 	}
@@ -187,7 +188,7 @@ func TestRegisterUser(t *testing.T) {
 
 func TestLogoutUser(t *testing.T) {
 	userID := "testUserID"
-	err := dal.LogoutUser(userID)
+	err := dal.LogoutUser(context.Background(), userID)
 	if err != nil {
 		t.Errorf("User logout failed: %v", err)
 	}
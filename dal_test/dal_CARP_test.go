@@ -2,6 +2,7 @@ package dal_test
 
 import (
 	"cmpscfa23team2/dal"
+	"context"
 	"database/sql"
 	"errors"
 	"testing"
@@ -123,7 +124,7 @@ func TestGetUsersByRole(t *testing.T) {
 
 // test getting all users
 func TestGetAllUsers(t *testing.T) {
-	users, err := dal.GetAllUsers()
+	users, err := dal.GetAllUsers(context.Background())
 	if err != nil {
 		dal.InsertLog("400", "Failed to get all users", "TestGetAllUsers()")
 		t.Errorf("Expected no error, but got an error: %v", err)
@@ -2,6 +2,7 @@ package dal_test
 
 import (
 	"cmpscfa23team2/dal"
+	"context"
 	"reflect"
 	"testing"
 )
@@ -35,7 +36,7 @@ func TestInsertURL(t *testing.T) {
 	url := "http://example.com"
 	domain := "example.com"
 	tags := map[string]interface{}{"tag1": "value1", "tag2": "value2"}
-	_, err := dal.InsertURL(url, domain, tags)
+	_, err := dal.InsertURL(context.Background(), url, domain, tags)
 	if err != nil {
 		dal.InsertLog("400", "Failed to insert URL", "TestInsertURL()")
 		t.Errorf("Couldn't insert URL: %v", err)
@@ -83,7 +84,7 @@ func TestGetURLTagsAndDomain(t *testing.T) {
 func TestGetURLsFromDomain(t *testing.T) {
 	domain := "example.com"
 	//expectedURLs := []string{"http://example.com/page1", "http://example.com/page2"}
-	_, err := dal.GetURLsFromDomain(domain)
+	_, err := dal.GetURLsFromDomain(context.Background(), domain)
 	if err != nil {
 		dal.InsertLog("400", "Failed to get URLs from domain", "TestGetURLsFromDomain()")
 		t.Errorf("Unexpected error: %v", err)
@@ -94,3 +95,23 @@ func TestGetURLsFromDomain(t *testing.T) {
 	//	t.Errorf("Expected urls: %v, got: %v", expectedURLs, urls)
 	//}
 }
+
+func TestDeleteScrapedItemsOfRun(t *testing.T) {
+	item := dal.ScrapedItem{Domain: "test-rerun.com", Title: "Item", URL: "http://test-rerun.com/item", RunID: "test-run", Stage: "store"}
+	// Storing the items of a stage twice, as a resumed run does, leaves one copy
+	for i := 0; i < 2; i++ {
+		if err := dal.DeleteScrapedItemsOfRun(context.Background(), item.RunID, item.Stage); err != nil {
+			t.Fatalf("DeleteScrapedItemsOfRun() error = %v", err)
+		}
+		if err := dal.InsertScrapedItem(context.Background(), item); err != nil {
+			t.Fatalf("InsertScrapedItem() error = %v", err)
+		}
+	}
+	items, err := dal.GetScrapedItems(context.Background(), item.Domain)
+	if err != nil {
+		t.Fatalf("GetScrapedItems() error = %v", err)
+	}
+	if len(items) != 1 {
+		t.Errorf("stored %d items, want 1", len(items))
+	}
+}
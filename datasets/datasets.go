@@ -0,0 +1,109 @@
+// Package datasets is the registry of training data. Uploaded CSV, JSON and JSON Lines files are
+// stored in a directory, and their metadata (schema, row count, domain, source and checksum) in a
+// Store. Uploading a file under an existing name adds a new version of that dataset, so jobs and
+// pipelines can refer to a dataset by ID instead of a file name and keep working on the same data.
+package datasets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Format is the file format of a dataset.
+type Format string
+
+const (
+	CSV   Format = "csv"
+	JSON  Format = "json"
+	JSONL Format = "jsonl"
+)
+
+// ParseFormat parses a format name.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimPrefix(name, "."))); f {
+	case CSV, JSON, JSONL:
+		return f, nil
+	case "ndjson":
+		return JSONL, nil
+	}
+	return "", fmt.Errorf("unsupported dataset format %q: use csv, json or jsonl", name)
+}
+
+// DetectFormat picks the format of an uploaded file from its extension, falling back to its content type.
+func DetectFormat(filename, contentType string) (Format, error) {
+	if ext := filepath.Ext(filename); ext != "" {
+		if f, err := ParseFormat(ext); err == nil {
+			return f, nil
+		}
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return CSV, nil
+	case "application/json":
+		return JSON, nil
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return JSONL, nil
+	}
+	return "", fmt.Errorf("cannot tell the format of %q: use a .csv, .json or .jsonl file", filename)
+}
+
+// Column types found by schema inference.
+const (
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeMixed   = "mixed"
+	TypeNull    = "null" // only empty values were seen
+)
+
+// Column describes one field of a dataset's records.
+type Column struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+}
+
+// Dataset is the metadata of one version of a dataset.
+type Dataset struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Version     int       `json:"version"`
+	Domain      string    `json:"domain"`
+	Source      string    `json:"source"`
+	Format      Format    `json:"format"`
+	Path        string    `json:"-"`
+	Size        int64     `json:"size_bytes"`
+	Rows        int       `json:"row_count"`
+	Checksum    string    `json:"checksum"` // hex SHA-256 of the file
+	Schema      []Column  `json:"schema"`
+	CreatedTime time.Time `json:"created_time"`
+}
+
+// ErrNotFound is returned by a Store when no dataset has the requested ID.
+var ErrNotFound = errors.New("datasets: dataset not found")
+
+// ErrInvalid wraps the errors of uploads the registry rejects: a bad name or format, or a file
+// that does not parse.
+var ErrInvalid = errors.New("invalid dataset")
+
+// Store keeps dataset metadata.
+type Store interface {
+	// Create adds a dataset version.
+	Create(ctx context.Context, d Dataset) error
+	// Get returns a dataset version by ID, or ErrNotFound.
+	Get(ctx context.Context, id string) (Dataset, error)
+	// List returns the versions of the named dataset, newest first; an empty name lists every version
+	// of every dataset, ordered by name.
+	List(ctx context.Context, name string) ([]Dataset, error)
+	// Delete removes a dataset version, or returns ErrNotFound.
+	Delete(ctx context.Context, id string) error
+}
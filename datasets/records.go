@@ -0,0 +1,253 @@
+package datasets
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Record is one row of a dataset. Values of CSV files are strings; values of JSON files keep their
+// JSON types, with numbers as json.Number.
+type Record map[string]interface{}
+
+// errStop ends a walk over the records early.
+var errStop = errors.New("stop")
+
+// Each calls fn for every record of a dataset file in the given format, stopping at the first error.
+//
+// A JSON file holds an array of records, an object whose "data" array holds the records, as in the
+// files CRAB writes, or a single record.
+func Each(r io.Reader, format Format, fn func(Record) error) error {
+	return walk(r, format, nil, fn)
+}
+
+// walk is Each, also passing the header of a CSV file to onHeader, if set, before the first record.
+func walk(r io.Reader, format Format, onHeader func([]string), fn func(Record) error) error {
+	switch format {
+	case CSV:
+		return eachCSV(r, onHeader, fn)
+	case JSON:
+		return eachJSON(r, fn)
+	case JSONL:
+		return eachJSONL(r, fn)
+	}
+	return fmt.Errorf("unsupported dataset format %q", format)
+}
+
+func eachCSV(r io.Reader, onHeader func([]string), fn func(Record) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading CSV header: %v", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+	if onHeader != nil {
+		onHeader(header)
+	}
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading CSV: %v", err)
+		}
+		if len(row) > len(header) {
+			return fmt.Errorf("CSV line %d has %d fields, the header has %d", line, len(row), len(header))
+		}
+		record := make(Record, len(header))
+		for i, name := range header {
+			if i < len(row) {
+				record[name] = row[i]
+			} else {
+				record[name] = ""
+			}
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+func eachJSON(r io.Reader, fn func(Record) error) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("reading JSON: %v", err)
+	}
+	var rows []interface{}
+	switch v := doc.(type) {
+	case []interface{}:
+		rows = v
+	case map[string]interface{}:
+		if data, ok := v["data"].([]interface{}); ok {
+			rows = data
+		} else {
+			rows = []interface{}{v}
+		}
+	default:
+		return errors.New("JSON dataset must be an array of objects or an object")
+	}
+	for i, row := range rows {
+		record, ok := row.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("JSON record %d is not an object", i+1)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func eachJSONL(r io.Reader, fn func(Record) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		dec := json.NewDecoder(bytes.NewReader(text))
+		dec.UseNumber()
+		var record map[string]interface{}
+		if err := dec.Decode(&record); err != nil || record == nil {
+			return fmt.Errorf("JSONL line %d is not a JSON object", line)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Read returns up to limit records from the start of a dataset file; a limit of 0 or less reads all.
+func Read(r io.Reader, format Format, limit int) ([]Record, error) {
+	var records []Record
+	err := Each(r, format, func(record Record) error {
+		records = append(records, record)
+		if limit > 0 && len(records) >= limit {
+			return errStop
+		}
+		return nil
+	})
+	if err != nil && err != errStop {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Inspect reads a whole dataset file and returns its schema and number of records. Columns appear in
+// CSV header order, or sorted by name for JSON.
+func Inspect(r io.Reader, format Format) ([]Column, int, error) {
+	var header []string
+	types := make(map[string]string)
+	nullable := make(map[string]bool)
+	rows := 0
+	err := walk(r, format, func(h []string) { header = h }, func(record Record) error {
+		rows++
+		for name, value := range record {
+			if _, seen := types[name]; !seen {
+				types[name] = TypeNull
+				// A field that first appears after the first record is missing from the earlier ones.
+				nullable[name] = rows > 1
+			}
+			t := valueType(value, format == CSV)
+			if t == TypeNull {
+				nullable[name] = true
+				continue
+			}
+			types[name] = mergeTypes(types[name], t)
+		}
+		for name := range types {
+			if _, ok := record[name]; !ok {
+				nullable[name] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	names := header
+	if names == nil {
+		for name := range types {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	columns := make([]Column, 0, len(names))
+	for _, name := range names {
+		t, ok := types[name]
+		if !ok {
+			t = TypeNull // a CSV file with a header but no records
+		}
+		columns = append(columns, Column{Name: name, Type: t, Nullable: nullable[name] || !ok})
+	}
+	return columns, rows, nil
+}
+
+// valueType returns the schema type of a value. CSV values are strings, so their type is guessed from
+// their text; an empty CSV value counts as null.
+func valueType(v interface{}, fromText bool) string {
+	switch v := v.(type) {
+	case nil:
+		return TypeNull
+	case bool:
+		return TypeBoolean
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return TypeInteger
+		}
+		return TypeNumber
+	case map[string]interface{}:
+		return TypeObject
+	case []interface{}:
+		return TypeArray
+	case string:
+		if !fromText {
+			return TypeString
+		}
+		s := strings.TrimSpace(v)
+		if s == "" {
+			return TypeNull
+		}
+		if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return TypeInteger
+		}
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			return TypeNumber
+		}
+		if _, err := strconv.ParseBool(s); err == nil && len(s) > 1 {
+			return TypeBoolean
+		}
+		return TypeString
+	}
+	return TypeString
+}
+
+// mergeTypes combines the type seen so far with the type of another value.
+func mergeTypes(current, next string) string {
+	switch {
+	case current == TypeNull || current == next:
+		return next
+	case (current == TypeInteger && next == TypeNumber) || (current == TypeNumber && next == TypeInteger):
+		return TypeNumber
+	}
+	return TypeMixed
+}
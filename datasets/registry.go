@@ -0,0 +1,206 @@
+package datasets
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxNameLength is the longest dataset name the registry accepts.
+const MaxNameLength = 100
+
+// Upload describes a file being added to the registry.
+type Upload struct {
+	Name     string // dataset name; versions share it
+	Domain   string
+	Source   string // where the data came from, e.g. a URL or "upload"
+	Filename string // original file name, used to detect the format
+	Format   Format // overrides detection when set
+}
+
+// Registry stores dataset files in a directory and their metadata in a Store.
+type Registry struct {
+	store Store
+	dir   string
+	now   func() time.Time
+
+	mu sync.Mutex // serializes version numbering
+}
+
+// NewRegistry creates a registry that keeps files in dir, creating it if needed.
+func NewRegistry(store Store, dir string) (*Registry, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Registry{store: store, dir: dir, now: time.Now}, nil
+}
+
+// Add reads an uploaded file into the registry as the next version of its dataset. If the file is
+// identical to the latest version, nothing is stored and that version is returned with created false.
+func (reg *Registry) Add(ctx context.Context, u Upload, r io.Reader) (d Dataset, created bool, err error) {
+	u.Name = strings.TrimSpace(u.Name)
+	if u.Name == "" {
+		u.Name = strings.TrimSuffix(filepath.Base(u.Filename), filepath.Ext(u.Filename))
+	}
+	if u.Name == "" || u.Name == "." || len(u.Name) > MaxNameLength {
+		return Dataset{}, false, fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalid, MaxNameLength)
+	}
+	if u.Format == "" {
+		if u.Format, err = DetectFormat(u.Filename, ""); err != nil {
+			return Dataset{}, false, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+	}
+
+	// Copy the upload to a temporary file, hashing it on the way, then check that it parses.
+	tmp, err := os.CreateTemp(reg.dir, ".upload-*")
+	if err != nil {
+		return Dataset{}, false, err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name()) // fails harmlessly once the file has been renamed
+	}()
+	hash := sha256.New()
+	size, err := io.Copy(tmp, io.TeeReader(r, hash))
+	if err != nil {
+		return Dataset{}, false, err
+	}
+	if size == 0 {
+		return Dataset{}, false, fmt.Errorf("%w: the file is empty", ErrInvalid)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return Dataset{}, false, err
+	}
+	schema, rows, err := Inspect(tmp, u.Format)
+	if err != nil {
+		return Dataset{}, false, fmt.Errorf("%w: not a valid %s file: %v", ErrInvalid, u.Format, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return Dataset{}, false, err
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	versions, err := reg.store.List(ctx, u.Name)
+	if err != nil {
+		return Dataset{}, false, err
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	version := 1
+	if len(versions) > 0 {
+		latest := versions[0]
+		if latest.Checksum == checksum {
+			return latest, false, nil
+		}
+		version = latest.Version + 1
+	}
+
+	d = Dataset{
+		ID:          uuid.New().String(),
+		Name:        u.Name,
+		Version:     version,
+		Domain:      u.Domain,
+		Source:      u.Source,
+		Format:      u.Format,
+		Size:        size,
+		Rows:        rows,
+		Checksum:    checksum,
+		Schema:      schema,
+		CreatedTime: reg.now().UTC(),
+	}
+	d.Path = filepath.Join(reg.dir, d.ID+"."+string(d.Format))
+	if err := os.Rename(tmp.Name(), d.Path); err != nil {
+		return Dataset{}, false, err
+	}
+	if err := reg.store.Create(ctx, d); err != nil {
+		os.Remove(d.Path)
+		return Dataset{}, false, err
+	}
+	return d, true, nil
+}
+
+// Get returns a dataset version by ID.
+func (reg *Registry) Get(ctx context.Context, id string) (Dataset, error) {
+	return reg.store.Get(ctx, id)
+}
+
+// List returns the versions of the named dataset, newest first, or of every dataset by name.
+func (reg *Registry) List(ctx context.Context, name string) ([]Dataset, error) {
+	return reg.store.List(ctx, name)
+}
+
+// Resolve returns the dataset that ref names: a dataset ID, or "name" or "name@version" for the
+// latest or a given version of a named dataset.
+func (reg *Registry) Resolve(ctx context.Context, ref string) (Dataset, error) {
+	if d, err := reg.store.Get(ctx, ref); err == nil || !errors.Is(err, ErrNotFound) {
+		return d, err
+	}
+	name, version, hasVersion := strings.Cut(ref, "@")
+	if name == "" {
+		return Dataset{}, ErrNotFound
+	}
+	versions, err := reg.store.List(ctx, name)
+	if err != nil {
+		return Dataset{}, err
+	}
+	for _, d := range versions {
+		if !hasVersion || fmt.Sprint(d.Version) == version {
+			return d, nil
+		}
+	}
+	return Dataset{}, ErrNotFound
+}
+
+// Open opens the file of a dataset version.
+func (reg *Registry) Open(ctx context.Context, id string) (Dataset, *os.File, error) {
+	d, err := reg.store.Get(ctx, id)
+	if err != nil {
+		return Dataset{}, nil, err
+	}
+	f, err := os.Open(d.Path)
+	if err != nil {
+		return Dataset{}, nil, err
+	}
+	return d, f, nil
+}
+
+// Preview returns the first n records of a dataset version.
+func (reg *Registry) Preview(ctx context.Context, id string, n int) (Dataset, []Record, error) {
+	d, f, err := reg.Open(ctx, id)
+	if err != nil {
+		return Dataset{}, nil, err
+	}
+	defer f.Close()
+	records, err := Read(f, d.Format, n)
+	return d, records, err
+}
+
+// Records returns every record of a dataset version.
+func (reg *Registry) Records(ctx context.Context, id string) (Dataset, []Record, error) {
+	return reg.Preview(ctx, id, 0)
+}
+
+// Delete removes a dataset version and its file.
+func (reg *Registry) Delete(ctx context.Context, id string) error {
+	d, err := reg.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := reg.store.Delete(ctx, id); err != nil {
+		return err
+	}
+	if err := os.Remove(d.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
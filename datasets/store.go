@@ -0,0 +1,67 @@
+package datasets
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryStore is a Store that keeps dataset metadata in a map. It is used when no database is available and in tests.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	datasets map[string]Dataset
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{datasets: make(map[string]Dataset)}
+}
+
+// Create adds a dataset version.
+func (m *MemoryStore) Create(ctx context.Context, d Dataset) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.datasets[d.ID] = d
+	return nil
+}
+
+// Get returns a dataset version by ID, or ErrNotFound.
+func (m *MemoryStore) Get(ctx context.Context, id string) (Dataset, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	d, ok := m.datasets[id]
+	if !ok {
+		return Dataset{}, ErrNotFound
+	}
+	return d, nil
+}
+
+// List returns the versions of the named dataset, newest first, or of every dataset by name.
+func (m *MemoryStore) List(ctx context.Context, name string) ([]Dataset, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var list []Dataset
+	for _, d := range m.datasets {
+		if name == "" || d.Name == name {
+			list = append(list, d)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Version > list[j].Version
+	})
+	return list, nil
+}
+
+// Delete removes a dataset version, or returns ErrNotFound.
+func (m *MemoryStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.datasets[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.datasets, id)
+	return nil
+}
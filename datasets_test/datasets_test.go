@@ -0,0 +1,148 @@
+package datasets_test
+
+import (
+	"cmpscfa23team2/datasets"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInspectCSV(t *testing.T) {
+	csv := "\"sepal.length\",\"petal.width\",variety,in_bloom\n5.1,.2,Setosa,true\n4.9,3,Setosa,\n6,1.5,Versicolor,false\n"
+	schema, rows, err := datasets.Inspect(strings.NewReader(csv), datasets.CSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []datasets.Column{
+		{Name: "sepal.length", Type: datasets.TypeNumber},
+		{Name: "petal.width", Type: datasets.TypeNumber},
+		{Name: "variety", Type: datasets.TypeString},
+		{Name: "in_bloom", Type: datasets.TypeBoolean, Nullable: true},
+	}
+	if rows != 3 || !reflect.DeepEqual(schema, want) {
+		t.Errorf("Inspect = %d rows, %+v; want 3 rows, %+v", rows, schema, want)
+	}
+}
+
+func TestInspectJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		format datasets.Format
+		input  string
+		rows   int
+		want   []datasets.Column
+	}{
+		{"array", datasets.JSON, `[{"year": "1978", "price": 0.652}, {"year": "1979", "price": 1, "note": null}]`, 2,
+			[]datasets.Column{{Name: "note", Type: datasets.TypeNull, Nullable: true}, {Name: "price", Type: datasets.TypeNumber}, {Name: "year", Type: datasets.TypeString}}},
+		{"crab container", datasets.JSON, `{"domain": "jobs", "data": [{"title": "Engineer", "tags": ["go"]}, {"title": 3}]}`, 2,
+			[]datasets.Column{{Name: "tags", Type: datasets.TypeArray, Nullable: true}, {Name: "title", Type: datasets.TypeMixed}}},
+		{"single object", datasets.JSON, `{"title": "Airfare", "data": {"year": "2023"}}`, 1,
+			[]datasets.Column{{Name: "data", Type: datasets.TypeObject}, {Name: "title", Type: datasets.TypeString}}},
+		{"lines", datasets.JSONL, "{\"a\": 1}\n\n{\"a\": 2, \"b\": true}\n", 2,
+			[]datasets.Column{{Name: "a", Type: datasets.TypeInteger}, {Name: "b", Type: datasets.TypeBoolean, Nullable: true}}},
+	}
+	for _, test := range tests {
+		schema, rows, err := datasets.Inspect(strings.NewReader(test.input), test.format)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if rows != test.rows || !reflect.DeepEqual(schema, test.want) {
+			t.Errorf("%s: Inspect = %d rows, %+v; want %d rows, %+v", test.name, rows, schema, test.rows, test.want)
+		}
+	}
+
+	for _, bad := range []struct {
+		format datasets.Format
+		input  string
+	}{
+		{datasets.JSON, `[1, 2]`},
+		{datasets.JSON, `"text"`},
+		{datasets.JSONL, "{\"a\": 1}\nnot json\n"},
+		{datasets.CSV, "a,b\n1,2,3\n"},
+	} {
+		if _, _, err := datasets.Inspect(strings.NewReader(bad.input), bad.format); err == nil {
+			t.Errorf("Inspect(%s %q) expected an error", bad.format, bad.input)
+		}
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		filename, contentType string
+		want                  datasets.Format
+	}{
+		{"iris.csv", "", datasets.CSV},
+		{"gasoline_data.JSON", "", datasets.JSON},
+		{"events.ndjson", "", datasets.JSONL},
+		{"upload", "text/csv; charset=utf-8", datasets.CSV},
+	}
+	for _, test := range tests {
+		if got, err := datasets.DetectFormat(test.filename, test.contentType); err != nil || got != test.want {
+			t.Errorf("DetectFormat(%q, %q) = %q, %v; want %q", test.filename, test.contentType, got, err, test.want)
+		}
+	}
+	if _, err := datasets.DetectFormat("data.xlsx", ""); err == nil {
+		t.Error("DetectFormat(data.xlsx) expected an error")
+	}
+}
+
+func TestRegistryVersions(t *testing.T) {
+	dir := t.TempDir()
+	reg, err := datasets.NewRegistry(datasets.NewMemoryStore(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload := datasets.Upload{Name: "gas", Domain: "Gas Prices", Source: "upload", Filename: "gas.json"}
+	v1, created, err := reg.Add(context.Background(), upload, strings.NewReader(`[{"year": "1978", "price": 0.652}]`))
+	if err != nil || !created {
+		t.Fatalf("Add = %v, %v", created, err)
+	}
+	if v1.Version != 1 || v1.Rows != 1 || v1.Format != datasets.JSON || len(v1.Checksum) != 64 {
+		t.Errorf("first version = %+v", v1)
+	}
+
+	// The same content again is not a new version.
+	again, created, err := reg.Add(context.Background(), upload, strings.NewReader(`[{"year": "1978", "price": 0.652}]`))
+	if err != nil || created || again.ID != v1.ID {
+		t.Errorf("re-upload = %s, created %v, %v; want %s, false", again.ID, created, err, v1.ID)
+	}
+
+	v2, created, err := reg.Add(context.Background(), upload, strings.NewReader(`[{"year": "1978", "price": 0.652}, {"year": "1979", "price": 0.882}]`))
+	if err != nil || !created || v2.Version != 2 || v2.Rows != 2 {
+		t.Fatalf("second version = %+v, created %v, %v", v2, created, err)
+	}
+
+	for ref, want := range map[string]string{v1.ID: v1.ID, "gas": v2.ID, "gas@1": v1.ID} {
+		d, err := reg.Resolve(context.Background(), ref)
+		if err != nil || d.ID != want {
+			t.Errorf("Resolve(%q) = %s, %v; want %s", ref, d.ID, err, want)
+		}
+	}
+	if _, err := reg.Resolve(context.Background(), "gas@3"); !errors.Is(err, datasets.ErrNotFound) {
+		t.Errorf("Resolve(gas@3) error = %v, want ErrNotFound", err)
+	}
+
+	_, preview, err := reg.Preview(context.Background(), v2.ID, 1)
+	if err != nil || len(preview) != 1 || preview[0]["price"] != json.Number("0.652") {
+		t.Errorf("Preview = %v, %v", preview, err)
+	}
+
+	if _, _, err := reg.Add(context.Background(), datasets.Upload{Name: "broken", Filename: "broken.json"}, strings.NewReader(`{"a": `)); !errors.Is(err, datasets.ErrInvalid) {
+		t.Errorf("Add(invalid JSON) error = %v, want ErrInvalid", err)
+	}
+	if err := reg.Delete(context.Background(), v1.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(v1.Path); !os.IsNotExist(err) {
+		t.Errorf("file of deleted version still exists: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("registry dir holds %d files, want only the second version", len(entries))
+	}
+}
@@ -0,0 +1,178 @@
+// Package events is an in-process publish/subscribe bus for live progress: pages visited by the
+// crawler, items found by the scraper, errors and job state changes. Publishers never block; a
+// subscriber that cannot keep up loses events instead of slowing the crawl down.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Type identifies what an event reports.
+type Type string
+
+const (
+	URLVisited  Type = "url.visited"
+	ItemScraped Type = "item.scraped"
+	Error       Type = "error"
+	JobState    Type = "job.state"
+)
+
+// Event is one notification on the bus. ID and Time are set by the bus when the event is published.
+type Event struct {
+	ID      uint64                 `json:"id"`
+	Type    Type                   `json:"type"`
+	JobID   string                 `json:"job_id,omitempty"`
+	Time    time.Time              `json:"time"`
+	URL     string                 `json:"url,omitempty"`
+	Message string                 `json:"message,omitempty"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+// Publisher accepts events.
+type Publisher interface {
+	Publish(e Event)
+}
+
+// Filter selects the events a subscriber receives. A nil Filter accepts every event.
+type Filter func(e Event) bool
+
+// ForJobID returns a filter that accepts only the events of the given job.
+func ForJobID(jobID string) Filter {
+	return func(e Event) bool { return e.JobID == jobID }
+}
+
+// Default is the bus shared by the crawler, the scraper and the job workers.
+var Default = NewBus(500)
+
+// subscriberBuffer is how many events a subscriber may fall behind before events are dropped.
+const subscriberBuffer = 256
+
+// Bus fans events out to subscribers and keeps the most recent ones so that a client that
+// reconnects can catch up on what it missed.
+type Bus struct {
+	mu      sync.Mutex
+	nextID  uint64
+	subs    map[*Subscription]struct{}
+	history []Event
+	size    int
+}
+
+// NewBus creates a bus that remembers the last history events.
+func NewBus(history int) *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{}), size: history}
+}
+
+// Publish stamps the event with the next ID and the current time and delivers it to every matching subscriber.
+func (b *Bus) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	e.ID = b.nextID
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if b.size > 0 {
+		if len(b.history) == b.size {
+			copy(b.history, b.history[1:])
+			b.history = b.history[:b.size-1]
+		}
+		b.history = append(b.history, e)
+	}
+	for s := range b.subs {
+		s.deliver(e)
+	}
+}
+
+// Subscribe returns a subscription receiving the events accepted by filter. When since is non-zero,
+// remembered events with a greater ID are replayed first, e.g. the ID from an SSE Last-Event-ID header.
+func (b *Bus) Subscribe(filter Filter, since uint64) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Event
+	if since > 0 {
+		for _, e := range b.history {
+			if e.ID > since && (filter == nil || filter(e)) {
+				replay = append(replay, e)
+			}
+		}
+	}
+	ch := make(chan Event, subscriberBuffer+len(replay))
+	for _, e := range replay {
+		ch <- e
+	}
+	s := &Subscription{C: ch, ch: ch, bus: b, filter: filter}
+	b.subs[s] = struct{}{}
+	return s
+}
+
+// Recent returns the remembered events accepted by filter, oldest first.
+func (b *Bus) Recent(filter Filter) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var events []Event
+	for _, e := range b.history {
+		if filter == nil || filter(e) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// Subscription is a live feed of events from a Bus.
+type Subscription struct {
+	C <-chan Event
+
+	ch      chan Event
+	bus     *Bus
+	filter  Filter
+	dropped uint64
+	closed  bool
+}
+
+// deliver hands an event to the subscriber without blocking. It is called with the bus lock held.
+func (s *Subscription) deliver(e Event) {
+	if s.filter != nil && !s.filter(e) {
+		return
+	}
+	select {
+	case s.ch <- e:
+	default:
+		s.dropped++
+	}
+}
+
+// Dropped returns how many events were lost because the subscriber fell behind.
+func (s *Subscription) Dropped() uint64 {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	return s.dropped
+}
+
+// Close unsubscribes and closes C.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(s.bus.subs, s)
+	close(s.ch)
+}
+
+// ForJob returns a publisher that stamps every event with jobID before passing it on.
+func ForJob(p Publisher, jobID string) Publisher {
+	return jobPublisher{p: p, jobID: jobID}
+}
+
+type jobPublisher struct {
+	p     Publisher
+	jobID string
+}
+
+func (j jobPublisher) Publish(e Event) {
+	e.JobID = j.jobID
+	j.p.Publish(e)
+}
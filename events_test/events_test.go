@@ -0,0 +1,103 @@
+package events_test
+
+import (
+	"cmpscfa23team2/events"
+	"testing"
+	"time"
+)
+
+// receive waits for the next event on a subscription.
+func receive(t *testing.T, sub *events.Subscription) events.Event {
+	t.Helper()
+	select {
+	case e := <-sub.C:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+		return events.Event{}
+	}
+}
+
+func TestPublishDeliversToSubscribers(t *testing.T) {
+	bus := events.NewBus(10)
+	a := bus.Subscribe(nil, 0)
+	b := bus.Subscribe(nil, 0)
+	defer a.Close()
+	defer b.Close()
+
+	bus.Publish(events.Event{Type: events.URLVisited, URL: "http://example.com"})
+	for _, sub := range []*events.Subscription{a, b} {
+		e := receive(t, sub)
+		if e.ID != 1 || e.Type != events.URLVisited || e.URL != "http://example.com" || e.Time.IsZero() {
+			t.Errorf("received %+v", e)
+		}
+	}
+}
+
+func TestFilterByJob(t *testing.T) {
+	bus := events.NewBus(10)
+	sub := bus.Subscribe(events.ForJobID("job-1"), 0)
+	defer sub.Close()
+
+	events.ForJob(bus, "job-2").Publish(events.Event{Type: events.ItemScraped})
+	events.ForJob(bus, "job-1").Publish(events.Event{Type: events.Error, Message: "boom"})
+
+	e := receive(t, sub)
+	if e.JobID != "job-1" || e.Message != "boom" {
+		t.Errorf("received %+v, want the job-1 error", e)
+	}
+	select {
+	case e := <-sub.C:
+		t.Errorf("unexpected event %+v", e)
+	default:
+	}
+}
+
+func TestSubscribeReplaysSince(t *testing.T) {
+	bus := events.NewBus(3)
+	for i := 0; i < 5; i++ {
+		bus.Publish(events.Event{Type: events.URLVisited})
+	}
+
+	sub := bus.Subscribe(nil, 3)
+	defer sub.Close()
+	for _, want := range []uint64{4, 5} {
+		if e := receive(t, sub); e.ID != want {
+			t.Errorf("replayed ID %d, want %d", e.ID, want)
+		}
+	}
+
+	recent := bus.Recent(nil)
+	if len(recent) != 3 || recent[0].ID != 3 || recent[2].ID != 5 {
+		t.Errorf("Recent() = %+v, want IDs 3..5", recent)
+	}
+}
+
+func TestSlowSubscriberDropsEvents(t *testing.T) {
+	bus := events.NewBus(0)
+	sub := bus.Subscribe(nil, 0)
+	defer sub.Close()
+
+	const published = 1000
+	for i := 0; i < published; i++ {
+		bus.Publish(events.Event{Type: events.URLVisited})
+	}
+	if sub.Dropped() == 0 {
+		t.Error("Dropped() = 0, want events dropped for a subscriber that never reads")
+	}
+	if got := uint64(len(sub.C)) + sub.Dropped(); got != published {
+		t.Errorf("buffered + dropped = %d, want %d", got, published)
+	}
+}
+
+func TestCloseStopsDelivery(t *testing.T) {
+	bus := events.NewBus(0)
+	sub := bus.Subscribe(nil, 0)
+	sub.Close()
+	sub.Close()
+
+	bus.Publish(events.Event{Type: events.JobState})
+	if _, open := <-sub.C; open {
+		t.Error("subscription channel still open after Close")
+	}
+}
@@ -0,0 +1,178 @@
+// Package i18n holds the message catalogs of the carp front end and picks the language of a request.
+// A catalog maps message keys to fmt format strings; lookups fall back from a regional language such
+// as "es-MX" to its base language, then to the default language, and finally to the key itself, so a
+// missing translation shows up as English rather than as an error.
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Catalog maps message keys to format strings in one language.
+type Catalog map[string]string
+
+// Bundle is a set of catalogs with a default language.
+type Bundle struct {
+	fallback string
+	catalogs map[string]Catalog
+}
+
+// NewBundle creates an empty bundle whose default language is fallback.
+func NewBundle(fallback string) *Bundle {
+	return &Bundle{fallback: Normalize(fallback), catalogs: make(map[string]Catalog)}
+}
+
+// Load reads every <language>.json file in dir of fsys into a new bundle. The catalog of the fallback
+// language must be among them.
+func Load(fsys fs.FS, dir, fallback string) (*Bundle, error) {
+	b := NewBundle(fallback)
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		var c Catalog
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		b.Add(strings.TrimSuffix(path.Base(file), ".json"), c)
+	}
+	if _, ok := b.catalogs[b.fallback]; !ok {
+		return nil, fmt.Errorf("no catalog for the default language %q in %s", b.fallback, dir)
+	}
+	return b, nil
+}
+
+// Add sets the catalog of a language, replacing any earlier one.
+func (b *Bundle) Add(lang string, c Catalog) {
+	b.catalogs[Normalize(lang)] = c
+}
+
+// Default returns the default language.
+func (b *Bundle) Default() string {
+	return b.fallback
+}
+
+// Languages returns the languages with a catalog, sorted.
+func (b *Bundle) Languages() []string {
+	langs := make([]string, 0, len(b.catalogs))
+	for lang := range b.catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Missing returns the keys of the default catalog that lang does not translate, sorted.
+func (b *Bundle) Missing(lang string) []string {
+	c := b.catalogs[Normalize(lang)]
+	var missing []string
+	for key := range b.catalogs[b.fallback] {
+		if _, ok := c[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// T translates key into lang, formatting args into the message with fmt.Sprintf.
+func (b *Bundle) T(lang, key string, args ...interface{}) string {
+	msg, ok := b.lookup(Normalize(lang), key)
+	if !ok {
+		msg = key
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+func (b *Bundle) lookup(lang, key string) (string, bool) {
+	for _, candidate := range []string{lang, base(lang), b.fallback} {
+		if msg, ok := b.catalogs[candidate][key]; ok {
+			return msg, true
+		}
+	}
+	return "", false
+}
+
+// Match returns the first of the preferred languages the bundle has a catalog for, or its base
+// language, or else the default language. Empty preferences are skipped.
+func (b *Bundle) Match(preferred ...string) string {
+	for _, lang := range preferred {
+		lang = Normalize(lang)
+		if lang == "" {
+			continue
+		}
+		if _, ok := b.catalogs[lang]; ok {
+			return lang
+		}
+		if _, ok := b.catalogs[base(lang)]; ok {
+			return base(lang)
+		}
+	}
+	return b.fallback
+}
+
+// Has reports whether the bundle has a catalog for lang.
+func (b *Bundle) Has(lang string) bool {
+	_, ok := b.catalogs[Normalize(lang)]
+	return ok
+}
+
+// ParseAcceptLanguage returns the languages of an Accept-Language header, most preferred first.
+// Languages with q=0 and the "*" wildcard are left out.
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+	var langs []weighted
+	for _, part := range strings.Split(header, ",") {
+		lang, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang = strings.TrimSpace(lang)
+		if lang == "" || lang == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if name == "q" {
+				if v, err := strconv.ParseFloat(value, 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			langs = append(langs, weighted{lang, q})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+	out := make([]string, len(langs))
+	for i, l := range langs {
+		out[i] = l.lang
+	}
+	return out
+}
+
+// Normalize lower-cases a language tag and uses "-" as its separator, so "pt_BR" becomes "pt-br".
+func Normalize(lang string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(lang)), "_", "-")
+}
+
+// base returns the primary language of a tag: "es" for "es-mx".
+func base(lang string) string {
+	primary, _, _ := strings.Cut(lang, "-")
+	return primary
+}
@@ -0,0 +1,88 @@
+package i18n_test
+
+import (
+	"cmpscfa23team2/i18n"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func testBundle(t *testing.T) *i18n.Bundle {
+	t.Helper()
+	fsys := fstest.MapFS{
+		"locales/en.json":    {Data: []byte(`{"nav.home": "Home", "auth.welcome": "Welcome, %s!", "nav.history": "History"}`)},
+		"locales/es.json":    {Data: []byte(`{"nav.home": "Inicio", "auth.welcome": "¡Bienvenido, %s!"}`)},
+		"locales/pt_BR.json": {Data: []byte(`{"nav.home": "Início"}`)},
+		"locales/notes.txt":  {Data: []byte(`not a catalog`)},
+	}
+	b, err := i18n.Load(fsys, "locales", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestLoad(t *testing.T) {
+	b := testBundle(t)
+	if got, want := b.Languages(), []string{"en", "es", "pt-br"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Languages = %v, want %v", got, want)
+	}
+	if got := b.Missing("es"); !reflect.DeepEqual(got, []string{"nav.history"}) {
+		t.Errorf("Missing(es) = %v", got)
+	}
+
+	if _, err := i18n.Load(fstest.MapFS{"locales/es.json": {Data: []byte(`{}`)}}, "locales", "en"); err == nil {
+		t.Error("Load without a catalog for the default language succeeded")
+	}
+	if _, err := i18n.Load(fstest.MapFS{"locales/en.json": {Data: []byte(`{"a": 1}`)}}, "locales", "en"); err == nil {
+		t.Error("Load accepted a catalog with a non-string message")
+	}
+}
+
+func TestT(t *testing.T) {
+	b := testBundle(t)
+	tests := []struct {
+		lang, key string
+		args      []interface{}
+		want      string
+	}{
+		{"es", "nav.home", nil, "Inicio"},
+		{"es-MX", "nav.home", nil, "Inicio"},
+		{"es", "auth.welcome", []interface{}{"Ana"}, "¡Bienvenido, Ana!"},
+		{"es", "nav.history", nil, "History"},
+		{"PT_br", "nav.home", nil, "Início"},
+		{"fr", "nav.home", nil, "Home"},
+		{"en", "nav.unknown", nil, "nav.unknown"},
+	}
+	for _, test := range tests {
+		if got := b.T(test.lang, test.key, test.args...); got != test.want {
+			t.Errorf("T(%q, %q) = %q, want %q", test.lang, test.key, got, test.want)
+		}
+	}
+}
+
+func TestNegotiation(t *testing.T) {
+	if got, want := i18n.ParseAcceptLanguage("fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5"), []string{"fr-CH", "fr", "en", "de"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseAcceptLanguage = %v, want %v", got, want)
+	}
+	if got, want := i18n.ParseAcceptLanguage("en;q=0.2, es-MX, de;q=0"), []string{"es-MX", "en"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseAcceptLanguage with weights = %v, want %v", got, want)
+	}
+
+	b := testBundle(t)
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"es-MX,es;q=0.9,en;q=0.8", "es"},
+		{"pt-BR", "pt-br"},
+		{"fr-CH, fr;q=0.9", "en"},
+		{"", "en"},
+		{"de, es;q=0.5", "es"},
+	}
+	for _, test := range tests {
+		if got := b.Match(i18n.ParseAcceptLanguage(test.header)...); got != test.want {
+			t.Errorf("Match(%q) = %q, want %q", test.header, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Status is the lifecycle state of a job. The values are stored verbatim in the status column of the tasks table.
+type Status string
+
+const (
+	StatusQueued    Status = "queued"    // waiting for a worker (or for its retry time)
+	StatusRunning   Status = "running"   // picked up by a worker
+	StatusSucceeded Status = "succeeded" // handler returned without error
+	StatusFailed    Status = "failed"    // handler failed and no attempts are left
+	StatusCancelled Status = "cancelled" // cancelled by a caller before it finished
+)
+
+// Done reports whether the status is terminal, i.e. the job will not run again.
+func (s Status) Done() bool {
+	return s == StatusSucceeded || s == StatusFailed || s == StatusCancelled
+}
+
+// ErrNotFound is returned by a Store when no job has the requested ID.
+var ErrNotFound = errors.New("jobs: job not found")
+
+// ErrUnknownType is returned by Enqueue when no handler is registered for the job type.
+var ErrUnknownType = errors.New("jobs: no handler registered for job type")
+
+// ErrInvalidPayload is returned by Enqueue when the validator of the job type rejects the payload.
+var ErrInvalidPayload = errors.New("jobs: invalid payload")
+
+// Job holds a single unit of background work together with its tracking information.
+//
+// Payload is handler-specific JSON; Progress runs from 0 to 1 and Message carries the last
+// progress note reported by the handler.
+type Job struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Priority    int             `json:"priority"`
+	Status      Status          `json:"status"`
+	Progress    float64         `json:"progress"`
+	Message     string          `json:"message,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// ProgressFunc lets a running handler report how far along it is (0..1) with a short note.
+type ProgressFunc func(progress float64, message string)
+
+// Handler runs one job. It should return promptly once ctx is cancelled; a returned error
+// makes the job eligible for a retry until MaxAttempts is reached.
+type Handler func(ctx context.Context, job Job, progress ProgressFunc) error
+
+// Validator checks the payload of a job before it is queued, so that a payload its handler would
+// reject is refused up front instead of failing every attempt. An empty payload is passed as nil.
+type Validator func(payload json.RawMessage) error
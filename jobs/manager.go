@@ -0,0 +1,499 @@
+package jobs
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Config controls the worker pool and the retry policy of a Manager.
+type Config struct {
+	Concurrency int           // number of workers running jobs at the same time
+	MaxAttempts int           // attempts per job before it is marked failed
+	BaseBackoff time.Duration // delay before the first retry, doubled on every further attempt
+	MaxBackoff  time.Duration // upper bound for the retry delay
+}
+
+// DefaultConfig returns the settings used when carp does not override them.
+func DefaultConfig() Config {
+	return Config{
+		Concurrency: 4,
+		MaxAttempts: 3,
+		BaseBackoff: 5 * time.Second,
+		MaxBackoff:  5 * time.Minute,
+	}
+}
+
+// Backoff returns how long to wait before retrying a job that has failed the given number of attempts.
+func (c Config) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := c.BaseBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if c.MaxBackoff > 0 && delay >= c.MaxBackoff {
+			return c.MaxBackoff
+		}
+	}
+	if c.MaxBackoff > 0 && delay > c.MaxBackoff {
+		return c.MaxBackoff
+	}
+	return delay
+}
+
+// retry is a failed job waiting for its backoff to expire.
+type retry struct {
+	job   Job
+	timer *time.Timer
+}
+
+// Manager owns the job queue and the worker pool. Jobs are enqueued by type, picked up by
+// priority, and every state change is written through to the Store.
+type Manager struct {
+	cfg   Config
+	store Store
+
+	mu        sync.Mutex
+	handlers  map[string]Handler
+	validate  map[string]Validator
+	queue     jobQueue
+	retries   map[string]*retry
+	running   map[string]context.CancelFunc
+	cancelled map[string]bool
+	observers []func(Job)
+
+	wake    chan struct{}
+	ctx     context.Context
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+	started bool
+}
+
+// NewManager creates a Manager backed by the given store. Zero values in cfg fall back to DefaultConfig.
+func NewManager(store Store, cfg Config) *Manager {
+	def := DefaultConfig()
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = def.Concurrency
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = def.MaxAttempts
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = def.BaseBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = def.MaxBackoff
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	return &Manager{
+		cfg:       cfg,
+		store:     store,
+		handlers:  make(map[string]Handler),
+		validate:  make(map[string]Validator),
+		retries:   make(map[string]*retry),
+		running:   make(map[string]context.CancelFunc),
+		cancelled: make(map[string]bool),
+		wake:      make(chan struct{}, 1),
+		ctx:       ctx,
+		stop:      stop,
+	}
+}
+
+// Register installs the handler that runs jobs of the given type, replacing any previous one.
+func (m *Manager) Register(jobType string, handler Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[jobType] = handler
+}
+
+// RegisterValidator installs the function that checks the payloads of jobs of the given type before
+// they are queued, replacing any previous one.
+func (m *Manager) RegisterValidator(jobType string, validator Validator) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.validate[jobType] = validator
+}
+
+// Check reports whether Enqueue would accept a job of the given type and payload: the type needs a
+// handler and the payload must pass the validator of the type, if it has one.
+func (m *Manager) Check(jobType string, payload json.RawMessage) error {
+	m.mu.Lock()
+	_, ok := m.handlers[jobType]
+	validator := m.validate[jobType]
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownType, jobType)
+	}
+	if validator == nil {
+		return nil
+	}
+	if len(payload) == 0 {
+		payload = nil
+	}
+	if err := validator(payload); err != nil {
+		return fmt.Errorf("%w for %s job: %v", ErrInvalidPayload, jobType, err)
+	}
+	return nil
+}
+
+// Types returns the registered job types.
+func (m *Manager) Types() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var types []string
+	for t := range m.handlers {
+		types = append(types, t)
+	}
+	return types
+}
+
+// Observe registers fn to be called with the new state of a job after every change that is written
+// to the store: when it is queued, started, reports progress, finishes, is retried or cancelled.
+// Observers run on the goroutine making the change and must not block.
+func (m *Manager) Observe(fn func(Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observers = append(m.observers, fn)
+}
+
+// notify passes the job to every observer.
+func (m *Manager) notify(job Job) {
+	m.mu.Lock()
+	observers := m.observers
+	m.mu.Unlock()
+	for _, fn := range observers {
+		fn(job)
+	}
+}
+
+// Enqueue creates a job of the given type and queues it. The payload is marshalled to JSON
+// unless it already is a json.RawMessage, and checked as Check does.
+func (m *Manager) Enqueue(ctx context.Context, jobType string, payload interface{}, priority int) (Job, error) {
+	raw, ok := payload.(json.RawMessage)
+	if !ok && payload != nil {
+		var err error
+		raw, err = json.Marshal(payload)
+		if err != nil {
+			return Job{}, fmt.Errorf("jobs: marshalling payload: %v", err)
+		}
+	}
+	if err := m.Check(jobType, raw); err != nil {
+		return Job{}, err
+	}
+
+	now := time.Now()
+	job := Job{
+		ID:          uuid.New().String(),
+		Type:        jobType,
+		Payload:     raw,
+		Priority:    priority,
+		Status:      StatusQueued,
+		MaxAttempts: m.cfg.MaxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := m.store.Create(ctx, job); err != nil {
+		return Job{}, err
+	}
+
+	m.mu.Lock()
+	heap.Push(&m.queue, job)
+	m.mu.Unlock()
+	m.signal()
+	m.notify(job)
+	log.Printf("Job %s (%s) queued with priority %d", job.ID, job.Type, job.Priority)
+	return job, nil
+}
+
+// Get returns the current state of a job.
+func (m *Manager) Get(ctx context.Context, id string) (Job, error) {
+	return m.store.Get(ctx, id)
+}
+
+// List returns the jobs with the given status, or every job when status is empty.
+func (m *Manager) List(ctx context.Context, status Status) ([]Job, error) {
+	return m.store.List(ctx, status)
+}
+
+// QueueDepth returns how many jobs are waiting to run, including those waiting for a retry.
+func (m *Manager) QueueDepth() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.queue) + len(m.retries)
+}
+
+// Cancel stops a job. A queued job is removed from the queue; a running job has its context
+// cancelled and is marked cancelled once its handler returns.
+func (m *Manager) Cancel(ctx context.Context, id string) error {
+	m.mu.Lock()
+	if cancel, ok := m.running[id]; ok {
+		m.cancelled[id] = true
+		m.mu.Unlock()
+		cancel()
+		return nil
+	}
+	job, ok := m.queue.remove(id)
+	if !ok {
+		if r, waiting := m.retries[id]; waiting {
+			r.timer.Stop()
+			delete(m.retries, id)
+			job, ok = r.job, true
+		}
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		job, err := m.store.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("jobs: job %s is already %s", id, job.Status)
+	}
+	job.Status = StatusCancelled
+	job.UpdatedAt = time.Now()
+	if err := m.store.Update(ctx, job); err != nil {
+		return err
+	}
+	m.notify(job)
+	return nil
+}
+
+// Start launches the workers. Jobs left queued or running by a previous process are picked up again.
+func (m *Manager) Start() error {
+	m.mu.Lock()
+	if m.started {
+		m.mu.Unlock()
+		return nil
+	}
+	m.started = true
+	queued := make(map[string]bool)
+	for _, job := range m.queue {
+		queued[job.ID] = true
+	}
+	m.mu.Unlock()
+
+	for _, status := range []Status{StatusQueued, StatusRunning} {
+		pending, err := m.store.List(context.Background(), status)
+		if err != nil {
+			return err
+		}
+		for _, job := range pending {
+			if queued[job.ID] {
+				continue
+			}
+			job.Status = StatusQueued
+			if err := m.store.Update(context.Background(), job); err != nil {
+				log.Printf("Error requeueing job %s: %v", job.ID, err)
+			}
+			// A job waiting out its retry backoff keeps waiting until its RunAt
+			if delay := time.Until(job.RunAt); delay > 0 {
+				m.scheduleRetry(job, delay)
+				continue
+			}
+			m.mu.Lock()
+			heap.Push(&m.queue, job)
+			m.mu.Unlock()
+		}
+	}
+
+	for i := 0; i < m.cfg.Concurrency; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+	m.signal()
+	log.Printf("Job manager started with %d workers", m.cfg.Concurrency)
+	return nil
+}
+
+// Stop cancels running jobs and waits for the workers to exit. Interrupted jobs stay queued
+// in the store so the next Start resumes them.
+func (m *Manager) Stop() {
+	m.stop()
+	m.mu.Lock()
+	for id, r := range m.retries {
+		r.timer.Stop()
+		delete(m.retries, id)
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+	log.Println("Job manager stopped")
+}
+
+// signal wakes one idle worker.
+func (m *Manager) signal() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// worker takes ready jobs off the queue until the manager is stopped.
+func (m *Manager) worker() {
+	defer m.wg.Done()
+	for {
+		job, ctx, cancel, ok := m.next()
+		if !ok {
+			return
+		}
+		m.run(ctx, cancel, job)
+	}
+}
+
+// next blocks until a job is ready or the manager stops. The job is taken off the queue and marked
+// running in one step, so that Cancel always finds it in one or the other.
+func (m *Manager) next() (Job, context.Context, context.CancelFunc, bool) {
+	for {
+		if m.ctx.Err() != nil {
+			return Job{}, nil, nil, false
+		}
+		m.mu.Lock()
+		if len(m.queue) > 0 {
+			job := heap.Pop(&m.queue).(Job)
+			ctx, cancel := context.WithCancel(m.ctx)
+			m.running[job.ID] = cancel
+			more := len(m.queue) > 0
+			m.mu.Unlock()
+			if more {
+				m.signal()
+			}
+			return job, ctx, cancel, true
+		}
+		m.mu.Unlock()
+
+		select {
+		case <-m.wake:
+		case <-m.ctx.Done():
+			return Job{}, nil, nil, false
+		}
+	}
+}
+
+// run executes one attempt of a job and records the outcome. A job cancelled between leaving the
+// queue and starting is marked cancelled without running.
+func (m *Manager) run(ctx context.Context, cancel context.CancelFunc, job Job) {
+	defer cancel()
+	m.mu.Lock()
+	handler := m.handlers[job.Type]
+	if m.cancelled[job.ID] {
+		delete(m.running, job.ID)
+		delete(m.cancelled, job.ID)
+		m.mu.Unlock()
+		job.Status = StatusCancelled
+		job.UpdatedAt = time.Now()
+		m.save(job)
+		log.Printf("Job %s (%s) cancelled", job.ID, job.Type)
+		return
+	}
+	m.mu.Unlock()
+
+	var jobMu sync.Mutex
+	job.Status = StatusRunning
+	job.Attempts++
+	job.Error = ""
+	job.UpdatedAt = time.Now()
+	m.save(job)
+
+	progress := func(p float64, message string) {
+		jobMu.Lock()
+		defer jobMu.Unlock()
+		if p < 0 {
+			p = 0
+		} else if p > 1 {
+			p = 1
+		}
+		job.Progress = p
+		job.Message = message
+		job.UpdatedAt = time.Now()
+		m.save(job)
+	}
+
+	var err error
+	if handler == nil {
+		err = fmt.Errorf("%w: %s", ErrUnknownType, job.Type)
+	} else {
+		err = safeRun(ctx, handler, job, progress)
+	}
+
+	m.mu.Lock()
+	delete(m.running, job.ID)
+	wasCancelled := m.cancelled[job.ID]
+	delete(m.cancelled, job.ID)
+	m.mu.Unlock()
+
+	jobMu.Lock()
+	defer jobMu.Unlock()
+	job.UpdatedAt = time.Now()
+	switch {
+	case wasCancelled:
+		job.Status = StatusCancelled
+		log.Printf("Job %s (%s) cancelled", job.ID, job.Type)
+	case err == nil:
+		job.Status = StatusSucceeded
+		job.Progress = 1
+		log.Printf("Job %s (%s) succeeded", job.ID, job.Type)
+	case m.ctx.Err() != nil:
+		// Shutting down: leave the job queued so it is resumed on the next start.
+		job.Status = StatusQueued
+		job.Attempts--
+		job.Error = err.Error()
+	case job.Attempts < job.MaxAttempts:
+		delay := m.cfg.Backoff(job.Attempts)
+		job.Status = StatusQueued
+		job.Error = err.Error()
+		job.RunAt = time.Now().Add(delay)
+		m.scheduleRetry(job, delay)
+		log.Printf("Job %s (%s) failed attempt %d/%d, retrying in %s: %v", job.ID, job.Type, job.Attempts, job.MaxAttempts, delay, err)
+	default:
+		job.Status = StatusFailed
+		job.Error = err.Error()
+		log.Printf("Job %s (%s) failed: %v", job.ID, job.Type, err)
+	}
+	m.save(job)
+}
+
+// scheduleRetry puts the job back on the queue once the delay has passed.
+func (m *Manager) scheduleRetry(job Job, delay time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := &retry{job: job}
+	r.timer = time.AfterFunc(delay, func() {
+		m.mu.Lock()
+		if _, ok := m.retries[job.ID]; !ok {
+			m.mu.Unlock()
+			return
+		}
+		delete(m.retries, job.ID)
+		heap.Push(&m.queue, job)
+		m.mu.Unlock()
+		m.signal()
+	})
+	m.retries[job.ID] = r
+}
+
+// save writes the job to the store, logging rather than failing the run on store errors. It does not
+// use the job's context, which is cancelled by then when the job was cancelled or the manager stopped.
+func (m *Manager) save(job Job) {
+	if err := m.store.Update(context.Background(), job); err != nil {
+		log.Printf("Error saving job %s: %v", job.ID, err)
+	}
+	m.notify(job)
+}
+
+// safeRun calls the handler and turns a panic into an error so one bad job cannot take down a worker.
+func safeRun(ctx context.Context, handler Handler, job Job, progress ProgressFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return handler(ctx, job, progress)
+}
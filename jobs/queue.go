@@ -0,0 +1,42 @@
+package jobs
+
+import "container/heap"
+
+// jobQueue is a heap of jobs that are ready to run. Higher priority comes first; jobs with the
+// same priority run in the order they became ready.
+type jobQueue []Job
+
+func (q jobQueue) Len() int { return len(q) }
+
+func (q jobQueue) Less(i, j int) bool {
+	if q[i].Priority != q[j].Priority {
+		return q[i].Priority > q[j].Priority
+	}
+	if !q[i].RunAt.Equal(q[j].RunAt) {
+		return q[i].RunAt.Before(q[j].RunAt)
+	}
+	return q[i].CreatedAt.Before(q[j].CreatedAt)
+}
+
+func (q jobQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *jobQueue) Push(x interface{}) { *q = append(*q, x.(Job)) }
+
+func (q *jobQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	job := old[n-1]
+	*q = old[:n-1]
+	return job
+}
+
+// remove takes the job with the given ID out of the queue and reports whether it was there.
+func (q *jobQueue) remove(id string) (Job, bool) {
+	for i, job := range *q {
+		if job.ID == id {
+			heap.Remove(q, i)
+			return job, true
+		}
+	}
+	return Job{}, false
+}
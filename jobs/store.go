@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Store persists jobs. The manager keeps the queue ordering in memory and calls the store
+// on every state change, so an implementation only has to save and load rows. The context is the one
+// of the request that caused the call, or a background context for the manager's own bookkeeping.
+type Store interface {
+	// Create saves a new job. The job's ID is already set.
+	Create(ctx context.Context, job Job) error
+	// Update overwrites the stored copy of the job.
+	Update(ctx context.Context, job Job) error
+	// Get returns the job with the given ID or ErrNotFound.
+	Get(ctx context.Context, id string) (Job, error)
+	// List returns the stored jobs, newest first. An empty status returns every job.
+	List(ctx context.Context, status Status) ([]Job, error)
+}
+
+// MemoryStore is a Store that keeps jobs in a map. It is used when no database is available and in tests.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	jobs map[string]Job
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[string]Job)}
+}
+
+// Create saves a new job.
+func (s *MemoryStore) Create(ctx context.Context, job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = job
+	return nil
+}
+
+// Update overwrites the stored job, returning ErrNotFound if it was never created.
+func (s *MemoryStore) Update(ctx context.Context, job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[job.ID]; !ok {
+		return ErrNotFound
+	}
+	s.jobs[job.ID] = job
+	return nil
+}
+
+// Get returns the job with the given ID.
+func (s *MemoryStore) Get(ctx context.Context, id string) (Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return job, nil
+}
+
+// List returns the stored jobs with the given status (or all of them), newest first.
+func (s *MemoryStore) List(ctx context.Context, status Status) ([]Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var list []Job
+	for _, job := range s.jobs {
+		if status == "" || job.Status == status {
+			list = append(list, job)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list, nil
+}
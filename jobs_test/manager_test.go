@@ -0,0 +1,296 @@
+package jobs_test
+
+import (
+	"cmpscfa23team2/jobs"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitForStatus polls the manager until the job reaches the wanted status or the timeout expires.
+func waitForStatus(t *testing.T, m *jobs.Manager, id string, want jobs.Status) jobs.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.Get(context.Background(), id)
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", id, err)
+		}
+		if job.Status == want {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	job, _ := m.Get(context.Background(), id)
+	t.Fatalf("job %s status = %s, want %s", id, job.Status, want)
+	return job
+}
+
+func TestEnqueueRunsJob(t *testing.T) {
+	m := jobs.NewManager(jobs.NewMemoryStore(), jobs.Config{Concurrency: 2})
+	m.Register("echo", func(ctx context.Context, job jobs.Job, progress jobs.ProgressFunc) error {
+		progress(0.5, "halfway")
+		return nil
+	})
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	job, err := m.Enqueue(context.Background(), "echo", map[string]string{"hello": "world"}, 0)
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	done := waitForStatus(t, m, job.ID, jobs.StatusSucceeded)
+	if done.Progress != 1 || done.Attempts != 1 {
+		t.Errorf("finished job = %+v, want progress 1 after 1 attempt", done)
+	}
+	if string(done.Payload) != `{"hello":"world"}` {
+		t.Errorf("payload = %s", done.Payload)
+	}
+}
+
+func TestEnqueueUnknownType(t *testing.T) {
+	m := jobs.NewManager(jobs.NewMemoryStore(), jobs.Config{})
+	if _, err := m.Enqueue(context.Background(), "missing", nil, 0); !errors.Is(err, jobs.ErrUnknownType) {
+		t.Errorf("Enqueue() error = %v, want ErrUnknownType", err)
+	}
+}
+
+func TestEnqueueValidatesPayload(t *testing.T) {
+	m := jobs.NewManager(jobs.NewMemoryStore(), jobs.Config{})
+	m.Register("echo", func(ctx context.Context, job jobs.Job, progress jobs.ProgressFunc) error { return nil })
+	m.RegisterValidator("echo", func(payload json.RawMessage) error {
+		var p struct{ Message string }
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		if p.Message == "" {
+			return errors.New("missing message")
+		}
+		return nil
+	})
+
+	for _, payload := range []interface{}{nil, map[string]int{"message": 1}, map[string]string{"other": "x"}} {
+		if _, err := m.Enqueue(context.Background(), "echo", payload, 0); !errors.Is(err, jobs.ErrInvalidPayload) {
+			t.Errorf("Enqueue(%v) error = %v, want ErrInvalidPayload", payload, err)
+		}
+	}
+	if list, _ := m.List(context.Background(), ""); len(list) != 0 {
+		t.Errorf("stored jobs = %+v, want none", list)
+	}
+	if _, err := m.Enqueue(context.Background(), "echo", map[string]string{"message": "hi"}, 0); err != nil {
+		t.Errorf("Enqueue of a valid payload error = %v", err)
+	}
+	if err := m.Check("missing", nil); !errors.Is(err, jobs.ErrUnknownType) {
+		t.Errorf("Check(missing) error = %v, want ErrUnknownType", err)
+	}
+}
+
+func TestPriorityOrder(t *testing.T) {
+	m := jobs.NewManager(jobs.NewMemoryStore(), jobs.Config{Concurrency: 1})
+	var mu sync.Mutex
+	var order []string
+	m.Register("record", func(ctx context.Context, job jobs.Job, progress jobs.ProgressFunc) error {
+		mu.Lock()
+		order = append(order, string(job.Payload))
+		mu.Unlock()
+		return nil
+	})
+
+	var last jobs.Job
+	for _, p := range []int{1, 5, 3} {
+		job, err := m.Enqueue(context.Background(), "record", p, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		last = job
+	}
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+	waitForStatus(t, m, last.ID, jobs.StatusSucceeded)
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"5", "3", "1"}
+	for i := range want {
+		if i >= len(order) || order[i] != want[i] {
+			t.Fatalf("run order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestRetryThenSucceed(t *testing.T) {
+	m := jobs.NewManager(jobs.NewMemoryStore(), jobs.Config{Concurrency: 1, MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+	m.Register("flaky", func(ctx context.Context, job jobs.Job, progress jobs.ProgressFunc) error {
+		if job.Attempts < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	job, _ := m.Enqueue(context.Background(), "flaky", nil, 0)
+	done := waitForStatus(t, m, job.ID, jobs.StatusSucceeded)
+	if done.Attempts != 3 {
+		t.Errorf("attempts = %d, want 3", done.Attempts)
+	}
+}
+
+func TestFailAfterMaxAttempts(t *testing.T) {
+	m := jobs.NewManager(jobs.NewMemoryStore(), jobs.Config{Concurrency: 1, MaxAttempts: 2, BaseBackoff: time.Millisecond})
+	m.Register("broken", func(ctx context.Context, job jobs.Job, progress jobs.ProgressFunc) error {
+		panic("boom")
+	})
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	job, _ := m.Enqueue(context.Background(), "broken", nil, 0)
+	done := waitForStatus(t, m, job.ID, jobs.StatusFailed)
+	if done.Attempts != 2 || done.Error == "" {
+		t.Errorf("failed job = %+v, want 2 attempts and an error", done)
+	}
+}
+
+func TestCancelRunningJob(t *testing.T) {
+	m := jobs.NewManager(jobs.NewMemoryStore(), jobs.Config{Concurrency: 1})
+	started := make(chan struct{})
+	m.Register("wait", func(ctx context.Context, job jobs.Job, progress jobs.ProgressFunc) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	job, _ := m.Enqueue(context.Background(), "wait", nil, 0)
+	<-started
+	if err := m.Cancel(context.Background(), job.ID); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	waitForStatus(t, m, job.ID, jobs.StatusCancelled)
+
+	if err := m.Cancel(context.Background(), job.ID); err == nil {
+		t.Error("Cancel() of a finished job should fail")
+	}
+}
+
+func TestCancelQueuedJob(t *testing.T) {
+	m := jobs.NewManager(jobs.NewMemoryStore(), jobs.Config{})
+	m.Register("noop", func(ctx context.Context, job jobs.Job, progress jobs.ProgressFunc) error { return nil })
+
+	job, _ := m.Enqueue(context.Background(), "noop", nil, 0)
+	if err := m.Cancel(context.Background(), job.ID); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	got, _ := m.Get(context.Background(), job.ID)
+	if got.Status != jobs.StatusCancelled || m.QueueDepth() != 0 {
+		t.Errorf("status = %s, depth = %d, want cancelled and empty queue", got.Status, m.QueueDepth())
+	}
+}
+
+func TestStartKeepsRetryBackoff(t *testing.T) {
+	store := jobs.NewMemoryStore()
+	waiting := jobs.Job{ID: "retry", Type: "noop", Status: jobs.StatusQueued, Attempts: 1, MaxAttempts: 3, RunAt: time.Now().Add(200 * time.Millisecond)}
+	if err := store.Create(context.Background(), waiting); err != nil {
+		t.Fatal(err)
+	}
+	m := jobs.NewManager(store, jobs.Config{Concurrency: 1})
+	var ran time.Time
+	m.Register("noop", func(ctx context.Context, job jobs.Job, progress jobs.ProgressFunc) error {
+		ran = time.Now()
+		return nil
+	})
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	if got, _ := m.Get(context.Background(), waiting.ID); got.Status != jobs.StatusQueued || m.QueueDepth() != 1 {
+		t.Fatalf("status = %s, depth = %d, want the job waiting for its retry", got.Status, m.QueueDepth())
+	}
+	waitForStatus(t, m, waiting.ID, jobs.StatusSucceeded)
+	if ran.Before(waiting.RunAt) {
+		t.Errorf("job ran %v before its RunAt", waiting.RunAt.Sub(ran))
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cfg := jobs.Config{BaseBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{10, 5 * time.Second},
+	}
+	for _, test := range tests {
+		if got := cfg.Backoff(test.attempt); got != test.want {
+			t.Errorf("Backoff(%d) = %s, want %s", test.attempt, got, test.want)
+		}
+	}
+}
+
+func TestObserveSeesEveryStateChange(t *testing.T) {
+	m := jobs.NewManager(jobs.NewMemoryStore(), jobs.Config{})
+	m.Register("echo", func(ctx context.Context, job jobs.Job, progress jobs.ProgressFunc) error {
+		progress(0.5, "halfway")
+		return nil
+	})
+	var mu sync.Mutex
+	var seen []jobs.Status
+	m.Observe(func(job jobs.Job) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, job.Status)
+	})
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	job, err := m.Enqueue(context.Background(), "echo", nil, 0)
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	waitForStatus(t, m, job.ID, jobs.StatusSucceeded)
+
+	// Observers are notified right after the store is written, so wait for the last call.
+	want := []jobs.Status{jobs.StatusQueued, jobs.StatusRunning, jobs.StatusRunning, jobs.StatusSucceeded}
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := len(seen)
+		mu.Unlock()
+		if n >= len(want) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != len(want) {
+		t.Fatalf("observed %v, want %v", seen, want)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Errorf("observed %v, want %v", seen, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Package jsonpath evaluates JSONPath expressions against decoded JSON, the maps, slices and scalars
+// encoding/json produces for an interface{}. It supports the subset scrapers need: the root ($ or @),
+// child names (.name and ['name']), wildcards (.* and [*]), array indexes counted from either end
+// ([0], [-1]), slices ([1:3], [:2], [-2:]) and recursive descent (..name, ..*).
+package jsonpath
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrSyntax is returned, wrapped, for expressions that cannot be parsed.
+var ErrSyntax = errors.New("invalid JSONPath")
+
+type stepKind int
+
+const (
+	stepName stepKind = iota
+	stepWildcard
+	stepIndex
+	stepSlice
+)
+
+// step is one segment of a path.
+type step struct {
+	kind      stepKind
+	recursive bool // preceded by ..
+	name      string
+	index     int
+	start     *int
+	end       *int
+}
+
+// Path is a compiled JSONPath expression.
+type Path struct {
+	expr  string
+	steps []step
+}
+
+// Compile parses a JSONPath expression.
+func Compile(expr string) (*Path, error) {
+	rest := strings.TrimSpace(expr)
+	if !strings.HasPrefix(rest, "$") && !strings.HasPrefix(rest, "@") {
+		return nil, fmt.Errorf("%w %q: must start with $", ErrSyntax, expr)
+	}
+	rest = rest[1:]
+	p := &Path{expr: expr}
+	for rest != "" {
+		var s step
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			s.recursive = true
+			rest = rest[2:]
+			if strings.HasPrefix(rest, "[") {
+				break
+			}
+			fallthrough
+		case strings.HasPrefix(rest, "."):
+			rest = strings.TrimPrefix(rest, ".")
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			rest = rest[end:]
+			switch name {
+			case "":
+				return nil, fmt.Errorf("%w %q: empty name", ErrSyntax, expr)
+			case "*":
+				s.kind = stepWildcard
+			default:
+				s.kind, s.name = stepName, name
+			}
+			p.steps = append(p.steps, s)
+			continue
+		case !strings.HasPrefix(rest, "["):
+			return nil, fmt.Errorf("%w %q: unexpected %q", ErrSyntax, expr, rest)
+		}
+		end := strings.Index(rest, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("%w %q: missing ]", ErrSyntax, expr)
+		}
+		if err := parseBracket(strings.TrimSpace(rest[1:end]), &s); err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrSyntax, expr, err)
+		}
+		rest = rest[end+1:]
+		p.steps = append(p.steps, s)
+	}
+	return p, nil
+}
+
+// MustCompile is Compile for expressions known to be valid; it panics on errors.
+func MustCompile(expr string) *Path {
+	p, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// parseBracket reads the contents of a [...] segment into s.
+func parseBracket(inner string, s *step) error {
+	switch {
+	case inner == "*":
+		s.kind = stepWildcard
+	case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+		s.kind, s.name = stepName, inner[1:len(inner)-1]
+	case strings.Contains(inner, ":"):
+		from, to, _ := strings.Cut(inner, ":")
+		s.kind = stepSlice
+		for _, bound := range []struct {
+			text string
+			dst  **int
+		}{{from, &s.start}, {to, &s.end}} {
+			if text := strings.TrimSpace(bound.text); text != "" {
+				n, err := strconv.Atoi(text)
+				if err != nil {
+					return fmt.Errorf("invalid slice bound %q", text)
+				}
+				*bound.dst = &n
+			}
+		}
+	default:
+		n, err := strconv.Atoi(inner)
+		if err != nil {
+			return fmt.Errorf("invalid index %q", inner)
+		}
+		s.kind, s.index = stepIndex, n
+	}
+	return nil
+}
+
+// String returns the expression the path was compiled from.
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Find returns the values the path selects in doc, in document order. Object members are visited in
+// key order, so results are deterministic.
+func (p *Path) Find(doc interface{}) []interface{} {
+	current := []interface{}{doc}
+	for _, s := range p.steps {
+		var next []interface{}
+		for _, v := range current {
+			if s.recursive {
+				for _, d := range descendants(v, nil) {
+					next = s.apply(d, next)
+				}
+			} else {
+				next = s.apply(v, next)
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// apply appends the values s selects from v to out.
+func (s step) apply(v interface{}, out []interface{}) []interface{} {
+	switch s.kind {
+	case stepName:
+		if obj, ok := v.(map[string]interface{}); ok {
+			if child, ok := obj[s.name]; ok {
+				out = append(out, child)
+			}
+		}
+	case stepWildcard:
+		out = append(out, children(v)...)
+	case stepIndex:
+		if arr, ok := v.([]interface{}); ok {
+			i := s.index
+			if i < 0 {
+				i += len(arr)
+			}
+			if i >= 0 && i < len(arr) {
+				out = append(out, arr[i])
+			}
+		}
+	case stepSlice:
+		if arr, ok := v.([]interface{}); ok {
+			start, end := bound(s.start, 0, len(arr)), bound(s.end, len(arr), len(arr))
+			if start < end {
+				out = append(out, arr[start:end]...)
+			}
+		}
+	}
+	return out
+}
+
+// bound resolves an optional, possibly negative, slice bound against an array of length n.
+func bound(b *int, def, n int) int {
+	if b == nil {
+		return def
+	}
+	i := *b
+	if i < 0 {
+		i += n
+	}
+	return min(max(i, 0), n)
+}
+
+// children returns the elements of an array or the members of an object in key order.
+func children(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		return v
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		out := make([]interface{}, len(keys))
+		for i, key := range keys {
+			out[i] = v[key]
+		}
+		return out
+	}
+	return nil
+}
+
+// descendants appends v and everything below it to out, depth first.
+func descendants(v interface{}, out []interface{}) []interface{} {
+	out = append(out, v)
+	for _, child := range children(v) {
+		out = descendants(child, out)
+	}
+	return out
+}
@@ -0,0 +1,60 @@
+package jsonpath_test
+
+import (
+	"cmpscfa23team2/jsonpath"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const jobsJSON = `{
+	"meta": {"next": "/api/jobs?page=2", "total": 3},
+	"jobs": [
+		{"title": "Go developer", "salary": {"min": 90000, "max": 120000}, "tags": ["go", "sql"]},
+		{"title": "Data analyst", "salary": {"min": 70000}, "tags": ["sql"]},
+		{"title": "Nurse", "company name": "Clinic"}
+	]
+}`
+
+func TestFind(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(jobsJSON), &doc); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		expr string
+		want []interface{}
+	}{
+		{"$", []interface{}{doc}},
+		{"$.meta.next", []interface{}{"/api/jobs?page=2"}},
+		{"$.jobs[*].title", []interface{}{"Go developer", "Data analyst", "Nurse"}},
+		{"$['jobs'][0]['title']", []interface{}{"Go developer"}},
+		{"$.jobs[-1]['company name']", []interface{}{"Clinic"}},
+		{"$.jobs[1:].title", []interface{}{"Data analyst", "Nurse"}},
+		{"$.jobs[:1].tags[*]", []interface{}{"go", "sql"}},
+		{"$..min", []interface{}{90000.0, 70000.0}},
+		{"$.jobs[0].salary.*", []interface{}{120000.0, 90000.0}},
+		{"$.jobs[5].title", nil},
+		{"$.meta.missing", nil},
+		{"@.meta.total", []interface{}{3.0}},
+	}
+	for _, test := range tests {
+		p, err := jsonpath.Compile(test.expr)
+		if err != nil {
+			t.Errorf("Compile(%q) error: %v", test.expr, err)
+			continue
+		}
+		if got := p.Find(doc); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Find(%q) = %#v, want %#v", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, expr := range []string{"jobs", "$.", "$.jobs[", "$.jobs[x]", "$.jobs[1:y]", "$jobs"} {
+		if _, err := jsonpath.Compile(expr); !errors.Is(err, jsonpath.ErrSyntax) {
+			t.Errorf("Compile(%q) error = %v, want ErrSyntax", expr, err)
+		}
+	}
+}
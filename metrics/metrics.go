@@ -0,0 +1,327 @@
+// Package metrics keeps counters, gauges and histograms in memory and writes them in the Prometheus
+// text exposition format, so the engine can be scraped without pulling in a client library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are latency buckets in seconds, from 5ms to 10s.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector is a metric family that can write itself out.
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds metric families by name.
+type Registry struct {
+	mu         sync.Mutex
+	collectors map[string]collector
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// Default is the registry the engine's packages register their metrics with.
+var Default = NewRegistry()
+
+// register adds c, or returns the collector already registered under the same name so that
+// package-level metrics can be declared more than once without panicking.
+func (r *Registry) register(c collector) collector {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.collectors[c.name()]; ok {
+		return existing
+	}
+	r.collectors[c.name()] = c
+	return c
+}
+
+// WriteText writes every metric in the Prometheus text format, sorted by name.
+func (r *Registry) WriteText(w io.Writer) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	collectors := make(map[string]collector, len(r.collectors))
+	for k, v := range r.collectors {
+		collectors[k] = v
+	}
+	r.mu.Unlock()
+
+	sort.Strings(names)
+	for _, name := range names {
+		collectors[name].write(w)
+	}
+}
+
+// Handler serves the registry for Prometheus to scrape.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// family holds what every metric type shares: its name, help text, label names and one series
+// per distinct combination of label values.
+type family struct {
+	metricName string
+	help       string
+	labels     []string
+
+	mu     sync.Mutex
+	series map[string][]string // key -> label values
+}
+
+func newFamily(name, help string, labels []string) family {
+	return family{metricName: name, help: help, labels: labels, series: make(map[string][]string)}
+}
+
+func (f *family) name() string { return f.metricName }
+
+// key returns the series key for the label values, recording them the first time they are seen.
+// It must be called with f.mu held.
+func (f *family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.metricName, len(f.labels), len(values)))
+	}
+	k := strings.Join(values, "\xff")
+	if _, ok := f.series[k]; !ok {
+		f.series[k] = append([]string(nil), values...)
+	}
+	return k
+}
+
+// keys returns the series keys ordered by their label values. It must be called with f.mu held.
+func (f *family) keys() []string {
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := f.series[keys[i]], f.series[keys[j]]
+		for n := range a {
+			if a[n] != b[n] {
+				return a[n] < b[n]
+			}
+		}
+		return false
+	})
+	return keys
+}
+
+func (f *family) header(w io.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.metricName, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.metricName, typ)
+}
+
+// labelString formats label pairs as {a="x",b="y"}, appending extra pairs such as le for buckets.
+func (f *family) labelString(values []string, extra ...string) string {
+	if len(values) == 0 && len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(values)+len(extra)/2)
+	for i, v := range values {
+		pairs = append(pairs, f.labels[i]+`="`+escapeLabel(v)+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter is a monotonically increasing value per label combination.
+type Counter struct {
+	family
+	values map[string]float64
+}
+
+// NewCounter registers a counter with the registry. Label values are passed to Inc and Add in the
+// order of labels.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{family: newFamily(name, help, labels), values: make(map[string]float64)}
+	return r.register(c).(*Counter)
+}
+
+// Inc adds one to the series with the given label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the series with the given label values.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[c.key(labelValues)] += v
+}
+
+// Value returns the current value of a series.
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[strings.Join(labelValues, "\xff")]
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header(w, "counter")
+	for _, k := range c.keys() {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, c.labelString(c.series[k]), formatFloat(c.values[k]))
+	}
+}
+
+// Gauge is a value per label combination that can go up and down.
+type Gauge struct {
+	family
+	values map[string]float64
+}
+
+// NewGauge registers a gauge with the registry.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{family: newFamily(name, help, labels), values: make(map[string]float64)}
+	return r.register(g).(*Gauge)
+}
+
+// Set sets the series with the given label values to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values[g.key(labelValues)] = v
+}
+
+// Add adds v, which may be negative, to the series with the given label values.
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values[g.key(labelValues)] += v
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.header(w, "gauge")
+	for _, k := range g.keys() {
+		fmt.Fprintf(w, "%s%s %s\n", g.metricName, g.labelString(g.series[k]), formatFloat(g.values[k]))
+	}
+}
+
+// gaugeFunc is a gauge whose value is read when the registry is scraped.
+type gaugeFunc struct {
+	family
+	fn func() float64
+}
+
+// NewGaugeFunc registers a gauge without labels whose value is computed by fn on every scrape, e.g.
+// a queue length or a connection pool statistic.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&gaugeFunc{family: newFamily(name, help, nil), fn: fn})
+}
+
+func (g *gaugeFunc) write(w io.Writer) {
+	g.header(w, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatFloat(g.fn()))
+}
+
+// Histogram counts observations, such as latencies, into cumulative buckets.
+type Histogram struct {
+	family
+	buckets []float64
+	counts  map[string][]uint64 // per series, one count per bucket
+	sums    map[string]float64
+	totals  map[string]uint64
+}
+
+// NewHistogram registers a histogram with the given upper bucket bounds; nil means DefaultBuckets.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &Histogram{
+		family:  newFamily(name, help, labels),
+		buckets: buckets,
+		counts:  make(map[string][]uint64),
+		sums:    make(map[string]float64),
+		totals:  make(map[string]uint64),
+	}
+	return r.register(h).(*Histogram)
+}
+
+// Observe records v in the series with the given label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	k := h.key(labelValues)
+	counts, ok := h.counts[k]
+	if !ok {
+		counts = make([]uint64, len(h.buckets))
+		h.counts[k] = counts
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			counts[i]++
+		}
+	}
+	h.sums[k] += v
+	h.totals[k]++
+}
+
+// Count returns how many values were observed in a series.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.totals[strings.Join(labelValues, "\xff")]
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w, "histogram")
+	for _, k := range h.keys() {
+		values := h.series[k]
+		for i, upper := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelString(values, "le", formatFloat(upper)), h.counts[k][i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelString(values, "le", "+Inf"), h.totals[k])
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.labelString(values), formatFloat(h.sums[k]))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.labelString(values), h.totals[k])
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
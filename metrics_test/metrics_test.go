@@ -0,0 +1,79 @@
+package metrics_test
+
+import (
+	"cmpscfa23team2/metrics"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := metrics.NewRegistry()
+	requests := r.NewCounter("http_requests_total", "Requests handled.", "route", "status")
+	requests.Inc("/api/jobs", "200")
+	requests.Add(2, "/api/jobs", "200")
+	requests.Inc("/", "500")
+	r.NewGaugeFunc("queue_depth", "Jobs waiting.", func() float64 { return 4 })
+	latency := r.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1}, "algorithm")
+	latency.Observe(0.05, "KNN")
+	latency.Observe(0.5, "KNN")
+	latency.Observe(3, "KNN")
+
+	var b strings.Builder
+	r.WriteText(&b)
+	want := `# HELP http_requests_total Requests handled.
+# TYPE http_requests_total counter
+http_requests_total{route="/",status="500"} 1
+http_requests_total{route="/api/jobs",status="200"} 3
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{algorithm="KNN",le="0.1"} 1
+latency_seconds_bucket{algorithm="KNN",le="1"} 2
+latency_seconds_bucket{algorithm="KNN",le="+Inf"} 3
+latency_seconds_sum{algorithm="KNN"} 3.55
+latency_seconds_count{algorithm="KNN"} 3
+# HELP queue_depth Jobs waiting.
+# TYPE queue_depth gauge
+queue_depth 4
+`
+	if b.String() != want {
+		t.Errorf("WriteText() =\n%s\nwant\n%s", b.String(), want)
+	}
+	if got := requests.Value("/api/jobs", "200"); got != 3 {
+		t.Errorf("Value() = %v, want 3", got)
+	}
+	if got := latency.Count("KNN"); got != 3 {
+		t.Errorf("Count() = %d, want 3", got)
+	}
+}
+
+func TestLabelEscapingAndGauge(t *testing.T) {
+	r := metrics.NewRegistry()
+	g := r.NewGauge("temperature", "Line one\nline two.", "name")
+	g.Set(10, `a "quoted" \ value`)
+	g.Add(-2.5, `a "quoted" \ value`)
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, `# HELP temperature Line one\nline two.`) {
+		t.Errorf("help not escaped:\n%s", body)
+	}
+	if !strings.Contains(body, `temperature{name="a \"quoted\" \\ value"} 7.5`) {
+		t.Errorf("label not escaped:\n%s", body)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
+
+func TestRegisterTwiceReturnsSameMetric(t *testing.T) {
+	r := metrics.NewRegistry()
+	a := r.NewCounter("items_total", "Items.")
+	b := r.NewCounter("items_total", "Items.")
+	a.Inc()
+	b.Inc()
+	if a.Value() != 2 {
+		t.Errorf("Value() = %v, want 2 from both handles", a.Value())
+	}
+}
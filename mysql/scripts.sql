@@ -53,7 +53,9 @@ CREATE TABLE IF NOT EXISTS log (
                                    FOREIGN KEY (status_code) REFERENCES log_status_codes (status_code),
                                    message VARCHAR(255),
                                    go_engine_area VARCHAR(255),
-                                   date_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+                                   request_id VARCHAR(64) NULL, -- X-Request-ID of the HTTP request that wrote the row
+                                   date_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                                   INDEX idx_log_request_id (request_id)
 );
 
 
@@ -64,16 +66,33 @@ CREATE TABLE IF NOT EXISTS web_service(
                                           customer_ID CHAR(36), -- We are using CHAR(36) for our GUID's, but other options exist
                                           access_token LONGTEXT, -- This lets the customer access the website. LONGTEXT is used to store JWT's of varying lengths
                                           date_active DATE, -- When the token is activated
-                                          is_active BOOLEAN -- If the webservice is currently active or not
+                                          is_active BOOLEAN, -- If the webservice is currently active or not
+                                          daily_quota INT NULL -- Requests allowed per day with this access token, NULL for unlimited
 );
 
 -- Creating url table for CRAB
 CREATE TABLE IF NOT EXISTS urls (
                                     id CHAR(36) PRIMARY KEY, -- Unique identifier for the URLs using GUID
-                                    url LONGTEXT NOT NULL, -- The URL string for storing the urls
+                                    url_hash CHAR(64) NOT NULL, -- SHA-256 of the canonical URL, which is too long to index
+                                    url LONGTEXT NOT NULL, -- The canonical form of the URL
                                     tags JSON, -- Optional JSON field for storing tags related to the URL
                                     domain LONGTEXT, --
-                                    created_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP() -- The timestamp of when the URL was created/added
+                                    created_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP(), -- The timestamp of when the URL was created/added
+                                    UNIQUE INDEX idx_urls_url_hash (url_hash)
+);
+
+-- Table for the frontiers of CRAB crawls, so an interrupted crawl resumes where it left off
+CREATE TABLE IF NOT EXISTS crawl_frontier (
+                                              id BIGINT AUTO_INCREMENT PRIMARY KEY, -- Keeps the order URLs were found in
+                                              crawl_name VARCHAR(100) NOT NULL,
+                                              url_hash CHAR(64) NOT NULL, -- SHA-256 of the URL, which is too long to index
+                                              url LONGTEXT NOT NULL,
+                                              seed LONGTEXT NOT NULL, -- Seed URL the crawl reached this URL from
+                                              depth INT NOT NULL, -- Links followed from the seed
+                                              priority DOUBLE DEFAULT 0, -- Higher priorities are crawled first
+                                              crawled_time DATETIME NULL, -- NULL while the URL waits to be crawled
+                                              created_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP(),
+                                              UNIQUE INDEX idx_crawl_frontier_url (crawl_name, url_hash)
 );
 
 CREATE TABLE scrapedData (
@@ -84,17 +103,74 @@ CREATE TABLE scrapedData (
                              description TEXT,
                              price VARCHAR(100),
                              source VARCHAR(255),
-                             timestamp DATETIME
+                             timestamp DATETIME,
+                             run_id VARCHAR(100) NULL, -- Pipeline run that stored the item
+                             stage VARCHAR(20) NULL, -- Pipeline stage that stored the item, e.g. crawl or store
+                             INDEX idx_scraped_data_run (run_id, stage)
 );
 
 -- Table for TaskManager
 CREATE TABLE IF NOT EXISTS tasks (
 
                                      task_id CHAR(36) PRIMARY KEY,
-                                     task_name NVARCHAR(50),
+                                     task_name NVARCHAR(50), -- Job type, e.g. crawl, scrape, train-nbc
                                      priority INT,
-                                     status NVARCHAR(20),
-                                     created_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP()
+                                     status NVARCHAR(20), -- queued, running, succeeded, failed, cancelled
+                                     payload JSON, -- Job-specific parameters
+                                     progress DOUBLE DEFAULT 0, -- 0..1 as reported by the running job
+                                     message VARCHAR(255), -- Last progress message
+                                     error_message TEXT, -- Error of the last failed attempt
+                                     attempts INT DEFAULT 0,
+                                     max_attempts INT DEFAULT 3,
+                                     run_at DATETIME(3), -- Earliest time the job may run (used for retry backoff)
+                                     created_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP(),
+                                     updated_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP() ON UPDATE CURRENT_TIMESTAMP(),
+                                     INDEX idx_tasks_status (status)
+);
+
+-- Table for the recurring job scheduler
+CREATE TABLE IF NOT EXISTS schedules (
+                                         schedule_id CHAR(36) PRIMARY KEY,
+                                         schedule_name NVARCHAR(100),
+                                         cron_expr VARCHAR(100) NOT NULL, -- Five-field cron expression or @daily style shorthand
+                                         job_type NVARCHAR(50) NOT NULL, -- Job type fired into the tasks table
+                                         params JSON, -- Payload passed to the job
+                                         priority INT DEFAULT 0,
+                                         enabled BOOLEAN DEFAULT TRUE,
+                                         last_run DATETIME NULL,
+                                         next_run DATETIME NULL,
+                                         last_task_id CHAR(36) NULL, -- Task started by the last run, used to prevent overlapping runs
+                                         created_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP()
+);
+
+-- Table for pipeline runs and their stage checkpoints
+CREATE TABLE IF NOT EXISTS pipeline_runs (
+                                             run_id CHAR(36) PRIMARY KEY,
+                                             pipeline_name NVARCHAR(100) NOT NULL,
+                                             status NVARCHAR(20), -- pending, running, succeeded, failed
+                                             definition JSON, -- Pipeline definition the run started with
+                                             stages JSON, -- Per-stage checkpoints: status, output, error, timings
+                                             created_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP(),
+                                             updated_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP() ON UPDATE CURRENT_TIMESTAMP(),
+                                             INDEX idx_pipeline_runs_name (pipeline_name)
+);
+
+-- Table for the dataset registry: one row per uploaded version of a training dataset
+CREATE TABLE IF NOT EXISTS datasets (
+                                        dataset_id CHAR(36) PRIMARY KEY,
+                                        name NVARCHAR(100) NOT NULL,
+                                        version INT NOT NULL,
+                                        domain NVARCHAR(100),
+                                        source NVARCHAR(255),
+                                        format VARCHAR(10) NOT NULL, -- csv, json or jsonl
+                                        file_path VARCHAR(512) NOT NULL,
+                                        size_bytes BIGINT,
+                                        row_count INT,
+                                        checksum CHAR(64) NOT NULL, -- hex SHA-256 of the file
+                                        schema_json JSON, -- inferred columns: name, type, nullable
+                                        created_time DATETIME NOT NULL,
+                                        UNIQUE KEY uq_datasets_name_version (name, version),
+                                        INDEX idx_datasets_checksum (checksum)
 );
 
 -- Table for MachineLearningModels
@@ -120,27 +196,33 @@ CREATE TABLE IF NOT EXISTS webcrawlers (
 CREATE TABLE IF NOT EXISTS knn_predictions (
                                                prediction_id VARCHAR(36) PRIMARY KEY,
                                                query_identifier VARCHAR(255),
+                                               domain VARCHAR(100) NULL,
                                                input_data VARCHAR(255),
                                                prediction_info TEXT(255),
-                                               prediction_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP()
+                                               prediction_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP(),
+                                               INDEX idx_knn_domain_time (domain, prediction_time)
 );
 
 -- Table for Linear Regression Predictions
 CREATE TABLE IF NOT EXISTS linear_regression_predictions (
                                                              prediction_id VARCHAR(36) PRIMARY KEY,
                                                              query_identifier VARCHAR(255),
+                                                             domain VARCHAR(100) NULL,
                                                              input_data TEXT(255),
                                                              prediction_info TEXT(255),
-                                                             prediction_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP()
+                                                             prediction_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP(),
+                                                             INDEX idx_linear_regression_domain_time (domain, prediction_time)
 );
 
 -- Table for Naive Bayes Predictions
 CREATE TABLE IF NOT EXISTS naive_bayes_predictions (
                                                        prediction_id VARCHAR(36) PRIMARY KEY,
                                                        query_identifier VARCHAR(255),
+                                                       domain VARCHAR(100) NULL,
                                                        input_data VARCHAR(255),
                                                        prediction_info LONGTEXT,
-                                                       prediction_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP()
+                                                       prediction_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP(),
+                                                       INDEX idx_naive_bayes_domain_time (domain, prediction_time)
 );
 
 
@@ -198,15 +280,18 @@ CREATE TABLE ETFs (
 -- Stored Procedure to add a new task
 DELIMITER //
 CREATE PROCEDURE create_task(
+    IN p_task_id CHAR(36),
     IN p_task_name NVARCHAR(50),
     IN p_priority INT,
-    IN p_status NVARCHAR(20)
+    IN p_status NVARCHAR(20),
+    IN p_payload JSON,
+    IN p_max_attempts INT,
+    IN p_run_at DATETIME(3)
 )
 BEGIN
-    DECLARE v_task_id CHAR(36);
-    SET v_task_id = UUID();
-    INSERT INTO tasks (task_id, task_name, priority, status)
-    VALUES (v_task_id, p_task_name, p_priority, p_status);
+    INSERT INTO tasks (task_id, task_name, priority, status, payload, max_attempts, run_at)
+    VALUES (p_task_id, p_task_name, p_priority, p_status, p_payload, p_max_attempts, p_run_at);
+    SELECT p_task_id;
 END //
 DELIMITER ;
 
@@ -215,20 +300,258 @@ DELIMITER //
 CREATE PROCEDURE update_task(
     IN p_task_id CHAR(36),
     IN p_priority INT,
-    IN p_status NVARCHAR(20)
+    IN p_status NVARCHAR(20),
+    IN p_progress DOUBLE,
+    IN p_message VARCHAR(255),
+    IN p_error_message TEXT,
+    IN p_attempts INT,
+    IN p_run_at DATETIME(3)
 )
 BEGIN
     UPDATE tasks
     SET priority = p_priority,
-        status = p_status
+        status = p_status,
+        progress = p_progress,
+        message = p_message,
+        error_message = p_error_message,
+        attempts = p_attempts,
+        run_at = p_run_at
     WHERE task_id = p_task_id;
 END //
 DELIMITER ;
 
+-- Stored Procedure to fetch a task by ID
+DELIMITER //
+CREATE PROCEDURE get_task(IN p_task_id CHAR(36))
+BEGIN
+    SELECT task_id, task_name, priority, status, payload, progress, message, error_message,
+           attempts, max_attempts, run_at, created_time, updated_time
+    FROM tasks WHERE task_id = p_task_id;
+END //
+DELIMITER ;
+
+-- Stored Procedure to list tasks, optionally filtered by status (NULL or '' returns all)
+DELIMITER //
+CREATE PROCEDURE get_tasks_by_status(IN p_status NVARCHAR(20))
+BEGIN
+    SELECT task_id, task_name, priority, status, payload, progress, message, error_message,
+           attempts, max_attempts, run_at, created_time, updated_time
+    FROM tasks
+    WHERE p_status IS NULL OR p_status = '' OR status = p_status
+    ORDER BY created_time DESC;
+END //
+DELIMITER ;
+
+-- ================================================
+-- SECTION: SCHEDULER SPROCS
+-- ================================================
+-- Stored Procedure to insert or update a schedule
+DELIMITER //
+CREATE PROCEDURE save_schedule(
+    IN p_schedule_id CHAR(36),
+    IN p_schedule_name NVARCHAR(100),
+    IN p_cron_expr VARCHAR(100),
+    IN p_job_type NVARCHAR(50),
+    IN p_params JSON,
+    IN p_priority INT,
+    IN p_enabled BOOLEAN,
+    IN p_last_run DATETIME,
+    IN p_next_run DATETIME,
+    IN p_last_task_id CHAR(36)
+)
+BEGIN
+    INSERT INTO schedules (schedule_id, schedule_name, cron_expr, job_type, params, priority, enabled, last_run, next_run, last_task_id)
+    VALUES (p_schedule_id, p_schedule_name, p_cron_expr, p_job_type, p_params, p_priority, p_enabled, p_last_run, p_next_run, p_last_task_id)
+    ON DUPLICATE KEY UPDATE
+        schedule_name = p_schedule_name,
+        cron_expr = p_cron_expr,
+        job_type = p_job_type,
+        params = p_params,
+        priority = p_priority,
+        enabled = p_enabled,
+        last_run = p_last_run,
+        next_run = p_next_run,
+        last_task_id = p_last_task_id;
+END //
+DELIMITER ;
+
+-- Stored Procedure to list all schedules
+DELIMITER //
+CREATE PROCEDURE get_schedules()
+BEGIN
+    SELECT schedule_id, schedule_name, cron_expr, job_type, params, priority, enabled, last_run, next_run, last_task_id
+    FROM schedules;
+END //
+DELIMITER ;
+
+-- Stored Procedure to delete a schedule
+DELIMITER //
+CREATE PROCEDURE delete_schedule(IN p_schedule_id CHAR(36))
+BEGIN
+    DELETE FROM schedules WHERE schedule_id = p_schedule_id;
+    SELECT ROW_COUNT();
+END //
+DELIMITER ;
+
+-- ================================================
+-- SECTION: PIPELINE SPROCS
+-- ================================================
+-- Stored Procedure to insert or update a pipeline run checkpoint
+DELIMITER //
+CREATE PROCEDURE save_pipeline_run(
+    IN p_run_id CHAR(36),
+    IN p_pipeline_name NVARCHAR(100),
+    IN p_status NVARCHAR(20),
+    IN p_definition JSON,
+    IN p_stages JSON,
+    IN p_created_time DATETIME
+)
+BEGIN
+    INSERT INTO pipeline_runs (run_id, pipeline_name, status, definition, stages, created_time)
+    VALUES (p_run_id, p_pipeline_name, p_status, p_definition, p_stages, COALESCE(p_created_time, UTC_TIMESTAMP()))
+    ON DUPLICATE KEY UPDATE
+        status = p_status,
+        stages = p_stages;
+END //
+DELIMITER ;
+
+-- Stored Procedure to fetch a pipeline run by ID
+DELIMITER //
+CREATE PROCEDURE get_pipeline_run(IN p_run_id CHAR(36))
+BEGIN
+    SELECT run_id, pipeline_name, status, definition, stages, created_time, updated_time
+    FROM pipeline_runs WHERE run_id = p_run_id;
+END //
+DELIMITER ;
+
+-- Stored Procedure to list pipeline runs, optionally filtered by pipeline name (NULL or '' returns all)
+DELIMITER //
+CREATE PROCEDURE get_pipeline_runs(IN p_pipeline_name NVARCHAR(100))
+BEGIN
+    SELECT run_id, pipeline_name, status, definition, stages, created_time, updated_time
+    FROM pipeline_runs
+    WHERE p_pipeline_name IS NULL OR p_pipeline_name = '' OR pipeline_name = p_pipeline_name
+    ORDER BY created_time DESC;
+END //
+DELIMITER ;
+
 -- ================================================
 -- SECTION: CUDA SPROCS
 -- ================================================
 
+-- Stored Procedure to register a dataset version
+DELIMITER //
+CREATE PROCEDURE create_dataset(
+    IN p_dataset_id CHAR(36),
+    IN p_name NVARCHAR(100),
+    IN p_version INT,
+    IN p_domain NVARCHAR(100),
+    IN p_source NVARCHAR(255),
+    IN p_format VARCHAR(10),
+    IN p_file_path VARCHAR(512),
+    IN p_size_bytes BIGINT,
+    IN p_row_count INT,
+    IN p_checksum CHAR(64),
+    IN p_schema_json JSON,
+    IN p_created_time DATETIME
+)
+BEGIN
+    INSERT INTO datasets (dataset_id, name, version, domain, source, format, file_path, size_bytes, row_count, checksum, schema_json, created_time)
+    VALUES (p_dataset_id, p_name, p_version, p_domain, p_source, p_format, p_file_path, p_size_bytes, p_row_count, p_checksum, p_schema_json,
+            COALESCE(p_created_time, UTC_TIMESTAMP()));
+END //
+DELIMITER ;
+
+-- Stored Procedure to fetch a dataset version by ID
+DELIMITER //
+CREATE PROCEDURE get_dataset(IN p_dataset_id CHAR(36))
+BEGIN
+    SELECT dataset_id, name, version, domain, source, format, file_path, size_bytes, row_count, checksum, schema_json, created_time
+    FROM datasets WHERE dataset_id = p_dataset_id;
+END //
+DELIMITER ;
+
+-- Stored Procedure to list the versions of a dataset, newest first (NULL or '' lists every dataset by name)
+DELIMITER //
+CREATE PROCEDURE get_datasets(IN p_name NVARCHAR(100))
+BEGIN
+    SELECT dataset_id, name, version, domain, source, format, file_path, size_bytes, row_count, checksum, schema_json, created_time
+    FROM datasets
+    WHERE p_name IS NULL OR p_name = '' OR name = p_name
+    ORDER BY name, version DESC;
+END //
+DELIMITER ;
+
+-- Stored Procedure to remove a dataset version
+DELIMITER //
+CREATE PROCEDURE delete_dataset(IN p_dataset_id CHAR(36))
+BEGIN
+    DELETE FROM datasets WHERE dataset_id = p_dataset_id;
+    SELECT ROW_COUNT() AS deleted;
+END //
+DELIMITER ;
+
+-- Stored Procedure to list past predictions of every algorithm, newest first. NULL filters match everything.
+DELIMITER //
+CREATE PROCEDURE get_prediction_history(
+    IN p_domain VARCHAR(100),
+    IN p_algorithm VARCHAR(20),
+    IN p_query_identifier VARCHAR(255),
+    IN p_since DATETIME,
+    IN p_until DATETIME,
+    IN p_limit INT,
+    IN p_offset INT
+)
+BEGIN
+    SELECT prediction_id, algorithm, domain, query_identifier, input_data, prediction_info, prediction_time
+    FROM (
+        SELECT prediction_id, 'KNN' AS algorithm, domain, query_identifier, input_data, prediction_info, prediction_time
+        FROM knn_predictions
+        UNION ALL
+        SELECT prediction_id, 'LinearRegression', domain, query_identifier, input_data, prediction_info, prediction_time
+        FROM linear_regression_predictions
+        UNION ALL
+        SELECT prediction_id, 'NaiveBayes', domain, query_identifier, input_data, prediction_info, prediction_time
+        FROM naive_bayes_predictions
+    ) AS history
+    WHERE (p_domain IS NULL OR domain = p_domain)
+      AND (p_algorithm IS NULL OR algorithm = p_algorithm)
+      AND (p_query_identifier IS NULL OR query_identifier = p_query_identifier)
+      AND (p_since IS NULL OR prediction_time >= p_since)
+      AND (p_until IS NULL OR prediction_time < p_until)
+    ORDER BY prediction_time DESC, prediction_id
+    LIMIT p_limit OFFSET p_offset;
+END //
+DELIMITER ;
+
+-- Stored Procedure to fetch one prediction of any algorithm by ID
+DELIMITER //
+CREATE PROCEDURE get_prediction_by_id(IN p_prediction_id VARCHAR(36))
+BEGIN
+    SELECT prediction_id, 'KNN' AS algorithm, domain, query_identifier, input_data, prediction_info, prediction_time
+    FROM knn_predictions WHERE prediction_id = p_prediction_id
+    UNION ALL
+    SELECT prediction_id, 'LinearRegression', domain, query_identifier, input_data, prediction_info, prediction_time
+    FROM linear_regression_predictions WHERE prediction_id = p_prediction_id
+    UNION ALL
+    SELECT prediction_id, 'NaiveBayes', domain, query_identifier, input_data, prediction_info, prediction_time
+    FROM naive_bayes_predictions WHERE prediction_id = p_prediction_id;
+END //
+DELIMITER ;
+
+-- Stored Procedure to list the domains that have predictions
+DELIMITER //
+CREATE PROCEDURE get_prediction_domains()
+BEGIN
+    SELECT domain FROM knn_predictions WHERE domain IS NOT NULL
+    UNION
+    SELECT domain FROM linear_regression_predictions WHERE domain IS NOT NULL
+    UNION
+    SELECT domain FROM naive_bayes_predictions WHERE domain IS NOT NULL
+    ORDER BY domain;
+END //
+DELIMITER ;
+
 
 -- Stored Procedure to add a new machine learning model
 DELIMITER //
@@ -335,6 +658,28 @@ BEGIN
     FROM log;
 END //
 
+CREATE PROCEDURE insert_request_log(
+    IN pStatusCode VARCHAR(3),
+    IN pMessage VARCHAR(250),
+    IN pGoEngineArea VARCHAR(250),
+    IN pRequestID VARCHAR(64)
+)
+BEGIN
+    DECLARE pLogID BINARY(16);
+    SET pLogID = UNHEX(REPLACE(UUID(), '-', ''));
+
+    INSERT INTO log (log_ID, status_code, message, go_engine_area, request_id)
+    VALUES (pLogID, pStatusCode, pMessage, pGoEngineArea, pRequestID);
+END //
+
+CREATE PROCEDURE select_logs_by_request_id(IN pRequestID VARCHAR(64))
+BEGIN
+    SELECT log_ID, status_code, message, go_engine_area, date_time
+    FROM log
+    WHERE request_id = pRequestID
+    ORDER BY date_time;
+END //
+
 DELIMITER //
 
 DELIMITER //
@@ -369,6 +714,17 @@ select * from log
 -- SECTION: CARP SPROCS
 -- ================================================
 
+DELIMITER //
+-- Looks up the web service an API key (access token) belongs to, for rate limits and quotas
+CREATE PROCEDURE get_web_service_by_token(IN p_access_token LONGTEXT)
+BEGIN
+    SELECT web_service_ID, customer_ID, is_active, daily_quota
+    FROM web_service
+    WHERE access_token = p_access_token
+    LIMIT 1;
+END //
+DELIMITER ;
+
 DELIMITER //
 -- CREATE
 CREATE PROCEDURE create_user(
@@ -494,11 +850,14 @@ DELIMITER ;
 DELIMITER //
 CREATE PROCEDURE insert_url(IN p_url LONGTEXT, IN p_tags JSON, IN p_domain LONGTEXT)
 BEGIN
-    DECLARE v_id CHAR(36);
-    SET v_id = UUID();
-    INSERT INTO urls (id, url, tags, domain, created_time)
-    VALUES (v_id, p_url, p_tags, p_domain, CURRENT_TIMESTAMP);
-    SELECT v_id; -- This line returns the generated ID.
+    DECLARE v_hash CHAR(64);
+    SET v_hash = SHA2(p_url, 256);
+    -- A URL already stored keeps its ID and moves to the new tags and domain, so that
+    -- get_urls_from_domain finds it under the domain that stored it last
+    INSERT INTO urls (id, url_hash, url, tags, domain, created_time)
+    VALUES (UUID(), v_hash, p_url, p_tags, p_domain, CURRENT_TIMESTAMP)
+    ON DUPLICATE KEY UPDATE tags = p_tags, domain = p_domain;
+    SELECT id FROM urls WHERE url_hash = v_hash; -- This line returns the ID of the URL.
 END //
 DELIMITER ;
 
@@ -507,7 +866,9 @@ DELIMITER ;
 DELIMITER //
 CREATE PROCEDURE update_url(IN p_id LONG, IN p_url LONG, IN p_tags JSON, IN p_domain LONG)
 BEGIN
-    UPDATE urls SET url = p_url, tags = p_tags, domain = p_domain WHERE id = p_id;
+    UPDATE urls
+    SET url = p_url, url_hash = SHA2(p_url, 256), tags = p_tags, domain = p_domain
+    WHERE id = p_id;
 END //
 DELIMITER ;
 
@@ -527,6 +888,78 @@ BEGIN
 END //
 DELIMITER ;
 
+-- SPROC to store an item produced by the scraper
+DELIMITER //
+CREATE PROCEDURE insert_scraped_item(
+    IN p_domain VARCHAR(255),
+    IN p_title TEXT,
+    IN p_url TEXT,
+    IN p_description TEXT,
+    IN p_price VARCHAR(100),
+    IN p_source TEXT,
+    IN p_timestamp DATETIME,
+    IN p_run_id VARCHAR(100),
+    IN p_stage VARCHAR(20)
+)
+BEGIN
+    INSERT INTO scrapedData (domain, title, url, description, price, source, timestamp, run_id, stage)
+    VALUES (p_domain, LEFT(p_title, 255), LEFT(p_url, 500), p_description, LEFT(p_price, 100), LEFT(p_source, 255),
+            COALESCE(p_timestamp, UTC_TIMESTAMP()), p_run_id, p_stage);
+END //
+DELIMITER ;
+
+-- SPROC to delete the items a stage of a pipeline run stored, before the stage stores them again
+DELIMITER //
+CREATE PROCEDURE delete_scraped_items_of_run(IN p_run_id VARCHAR(100), IN p_stage VARCHAR(20))
+BEGIN
+    DELETE FROM scrapedData WHERE run_id = p_run_id AND stage = p_stage;
+END //
+DELIMITER ;
+
+-- SPROC to get the scraped items of a domain
+DELIMITER //
+CREATE PROCEDURE get_scraped_items_by_domain(IN p_domain VARCHAR(255))
+BEGIN
+    SELECT domain, title, url, description, price, source, timestamp
+    FROM scrapedData WHERE domain = p_domain ORDER BY id;
+END //
+DELIMITER ;
+
+-- SPROC to add a URL to the frontier of a crawl, queueing a URL the crawl already has again
+DELIMITER //
+CREATE PROCEDURE insert_frontier_url(
+    IN p_crawl_name VARCHAR(100),
+    IN p_url LONGTEXT,
+    IN p_seed LONGTEXT,
+    IN p_depth INT,
+    IN p_priority DOUBLE
+)
+BEGIN
+    -- A URL crawled before is queued again, as when its sitemap says it changed
+    INSERT INTO crawl_frontier (crawl_name, url_hash, url, seed, depth, priority)
+    VALUES (p_crawl_name, SHA2(p_url, 256), p_url, p_seed, p_depth, p_priority)
+    ON DUPLICATE KEY UPDATE crawled_time = NULL, priority = p_priority;
+END //
+DELIMITER ;
+
+-- SPROC to record when a URL of a crawl frontier was crawled
+DELIMITER //
+CREATE PROCEDURE mark_frontier_url_crawled(IN p_crawl_name VARCHAR(100), IN p_url LONGTEXT, IN p_crawled DATETIME)
+BEGIN
+    UPDATE crawl_frontier SET crawled_time = p_crawled
+    WHERE crawl_name = p_crawl_name AND url_hash = SHA2(p_url, 256);
+END //
+DELIMITER ;
+
+-- SPROC to get the frontier of a crawl in the order the URLs were found
+DELIMITER //
+CREATE PROCEDURE get_frontier_urls(IN p_crawl_name VARCHAR(100))
+BEGIN
+    SELECT url, seed, depth, priority, crawled_time
+    FROM crawl_frontier WHERE crawl_name = p_crawl_name ORDER BY id;
+END //
+DELIMITER ;
+
 -- SPROC to get UUID from URL and domain
 DELIMITER //
 CREATE PROCEDURE get_Uuid_from_URL_and_domain(IN p_url LONG, IN p_domain LONG)
@@ -1006,14 +1439,26 @@ Year    Price   CPI
 Predicted Target Price closest to Year 2023: $3.82, Year: 2015'
        );
 
+-- Domains of the sample predictions, as offered on the prediction page
+UPDATE linear_regression_predictions SET domain = 'Gas Prices' WHERE query_identifier LIKE 'Gas Prices%';
+UPDATE linear_regression_predictions SET domain = 'Airfare Prices' WHERE query_identifier LIKE 'Airfare Prices%';
+UPDATE naive_bayes_predictions SET domain = 'Job Market' WHERE query_identifier LIKE 'Top % Jobs';
+UPDATE knn_predictions SET domain = 'Gas Prices' WHERE query_identifier LIKE 'Gas prices%';
+
 # C:\\Users\\Public\\GoLandProjects\\JustAFork\\Nbc_output\\Business_top_jobs.json
 # C:\\Users\\Public\\GoLandProjects\\JustAFork\\Nbc_output\\Law_top_jobs.json
 # C:\\Users\\Public\\GoLandProjects\\JustAFork\\Nbc_output\\SoftwareEng_top_jobs.json
 
 -- Inserting sample URLs into the URLs table
-INSERT INTO urls (id, url, tags)
+INSERT INTO urls (id, url_hash, url, tags)
+VALUES
+    (UUID(), SHA2('http://books.toscrape.com/', 256), 'http://books.toscrape.com/', '{"tag1": "<a>"}');
+
+-- Default schedules: refresh the gasoline data daily and the inflation data on the first of every month
+INSERT INTO schedules (schedule_id, schedule_name, cron_expr, job_type, params, priority, enabled)
 VALUES
-    (UUID(), 'http://books.toscrape.com/', '{"tag1": "<a>"}');
+    (UUID(), 'Refresh gasoline data', '0 3 * * *', 'scrape-gas', NULL, 0, TRUE),
+    (UUID(), 'Refresh inflation data', '0 4 1 * *', 'scrape-inflation', NULL, 0, TRUE);
 
 -- Call to the procedure to populate log status codes
 CALL populate_log_status_codes();
@@ -0,0 +1,119 @@
+// Package nlq turns free-text questions such as "what will gas cost next year" into a prediction query
+// the engine understands, and explains the prediction back in prose. The work is done by a Provider:
+// RuleProvider is a deterministic keyword grammar that works offline, OpenAIProvider calls an
+// OpenAI-compatible chat completions endpoint.
+package nlq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotUnderstood is returned when a provider cannot map a question onto any known query.
+var ErrNotUnderstood = errors.New("question not understood")
+
+// QuerySpec is one prediction the engine can answer: a domain, the algorithm that produced it and the
+// identifier the prediction is stored under.
+type QuerySpec struct {
+	Domain          string            `json:"domain"`
+	Algorithm       string            `json:"algorithm"`
+	QueryIdentifier string            `json:"query"`
+	Params          map[string]string `json:"params,omitempty"`
+}
+
+// Intent is the interpretation of a question.
+type Intent struct {
+	QuerySpec
+	Question   string  `json:"question"`
+	Confidence float64 `json:"confidence"` // 0..1
+	Note       string  `json:"note,omitempty"`
+	Provider   string  `json:"provider"`
+}
+
+// Result is the prediction an intent produced, in the form the providers need to explain it.
+type Result struct {
+	PredictionInfo string   `json:"prediction_info,omitempty"`
+	Items          []string `json:"items,omitempty"` // e.g. the titles of the top matching jobs
+	ImagePath      string   `json:"image_path,omitempty"`
+}
+
+// Provider interprets questions and explains results.
+type Provider interface {
+	// Name identifies the provider in intents and logs.
+	Name() string
+	// Interpret maps a question onto one of the catalog's queries.
+	Interpret(ctx context.Context, question string) (Intent, error)
+	// Explain describes the result of an intent in a few sentences of prose.
+	Explain(ctx context.Context, intent Intent, result Result) (string, error)
+}
+
+// Catalog is the list of queries a provider may choose from.
+type Catalog []QuerySpec
+
+// DefaultCatalog lists the predictions stored by CUDA, matching the options offered on the home page.
+func DefaultCatalog() Catalog {
+	return Catalog{
+		{Domain: "Job Market", Algorithm: "NaiveBayes", QueryIdentifier: "Top 3 Tech Jobs", Params: map[string]string{"category": "Tech"}},
+		{Domain: "Job Market", Algorithm: "NaiveBayes", QueryIdentifier: "Top 3 Law Jobs", Params: map[string]string{"category": "Law"}},
+		{Domain: "Job Market", Algorithm: "NaiveBayes", QueryIdentifier: "Top 3 Business Jobs", Params: map[string]string{"category": "Business"}},
+		{Domain: "Gas Prices", Algorithm: "LinearRegression", QueryIdentifier: "Gas Prices Prediction 2023", Params: map[string]string{"year": "2023"}},
+		{Domain: "Gas Prices", Algorithm: "LinearRegression", QueryIdentifier: "Gas Prices Prediction 2024", Params: map[string]string{"year": "2024"}},
+		{Domain: "Gas Prices", Algorithm: "KNN", QueryIdentifier: "Gas prices target prediction for years similar to 2023 prediction", Params: map[string]string{"year": "2023", "similar": "true"}},
+		{Domain: "Airfare Prices", Algorithm: "KNN", QueryIdentifier: "Airfare Prices Prediction 2024", Params: map[string]string{"year": "2024"}},
+		{Domain: "Airfare Prices", Algorithm: "KNN", QueryIdentifier: "Airfare Prices Prediction 2025", Params: map[string]string{"year": "2025"}},
+		{Domain: "Airfare Prices", Algorithm: "KNN", QueryIdentifier: "Airfare Prices Prediction 2030", Params: map[string]string{"year": "2030"}},
+	}
+}
+
+// Find returns the catalog entry with the given query identifier, compared case-insensitively.
+func (c Catalog) Find(queryIdentifier string) (QuerySpec, bool) {
+	for _, spec := range c {
+		if strings.EqualFold(spec.QueryIdentifier, strings.TrimSpace(queryIdentifier)) {
+			return spec, true
+		}
+	}
+	return QuerySpec{}, false
+}
+
+// Domain returns the catalog entries of a domain.
+func (c Catalog) Domain(domain string) Catalog {
+	var specs Catalog
+	for _, spec := range c {
+		if spec.Domain == domain {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// Fallback returns a provider that uses primary and falls back to secondary whenever primary fails,
+// e.g. when a remote model is unreachable.
+func Fallback(primary, secondary Provider) Provider {
+	return fallback{primary: primary, secondary: secondary}
+}
+
+type fallback struct {
+	primary, secondary Provider
+}
+
+func (f fallback) Name() string {
+	return fmt.Sprintf("%s (fallback %s)", f.primary.Name(), f.secondary.Name())
+}
+
+func (f fallback) Interpret(ctx context.Context, question string) (Intent, error) {
+	intent, err := f.primary.Interpret(ctx, question)
+	if err == nil {
+		return intent, nil
+	}
+	return f.secondary.Interpret(ctx, question)
+}
+
+func (f fallback) Explain(ctx context.Context, intent Intent, result Result) (string, error) {
+	text, err := f.primary.Explain(ctx, intent, result)
+	if err == nil {
+		return text, nil
+	}
+	return f.secondary.Explain(ctx, intent, result)
+}
@@ -0,0 +1,178 @@
+package nlq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAIProvider talks to an OpenAI-compatible chat completions endpoint. BaseURL can point at the
+// hosted API, at a self-hosted model server or at a local stub, e.g. "http://localhost:8000/v1".
+type OpenAIProvider struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+	Catalog Catalog
+	Client  *http.Client
+}
+
+// NewOpenAIProvider creates a provider for the endpoint at baseURL with a 30 second request timeout.
+func NewOpenAIProvider(baseURL, apiKey, model string, catalog Catalog) *OpenAIProvider {
+	return &OpenAIProvider{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		APIKey:  apiKey,
+		Model:   model,
+		Catalog: catalog,
+		Client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// chatMessage is one message of a chat completions request or response.
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// chatRequest is the body of POST /chat/completions.
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+}
+
+// chatResponse holds the fields of the chat completions response that are used.
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Name identifies the provider.
+func (p *OpenAIProvider) Name() string {
+	return "openai:" + p.Model
+}
+
+// Interpret asks the model to pick one of the catalog's queries and answer with JSON.
+func (p *OpenAIProvider) Interpret(ctx context.Context, question string) (Intent, error) {
+	catalog, err := json.Marshal(p.Catalog)
+	if err != nil {
+		return Intent{}, err
+	}
+	system := "You map questions about predictions onto one of the queries the prediction engine can answer. " +
+		"The available queries are: " + string(catalog) + ". " +
+		`Reply with a single JSON object and nothing else: {"query": "<query identifier from the list>", ` +
+		`"confidence": <0..1>, "note": "<optional remark for the user, e.g. when the requested year is not available>"}. ` +
+		`If no query fits, reply {"query": ""}.`
+
+	reply, err := p.complete(ctx, []chatMessage{
+		{Role: "system", Content: system},
+		{Role: "user", Content: question},
+	})
+	if err != nil {
+		return Intent{}, err
+	}
+
+	var answer struct {
+		Query      string  `json:"query"`
+		Confidence float64 `json:"confidence"`
+		Note       string  `json:"note"`
+	}
+	if err := json.Unmarshal([]byte(extractJSON(reply)), &answer); err != nil {
+		return Intent{}, fmt.Errorf("%s: unreadable answer %q: %v", p.Name(), reply, err)
+	}
+	if answer.Query == "" {
+		return Intent{}, fmt.Errorf("%w by %s: %q", ErrNotUnderstood, p.Name(), question)
+	}
+	spec, ok := p.Catalog.Find(answer.Query)
+	if !ok {
+		return Intent{}, fmt.Errorf("%s: answered with unknown query %q", p.Name(), answer.Query)
+	}
+	if answer.Confidence <= 0 || answer.Confidence > 1 {
+		answer.Confidence = 0.8
+	}
+	return Intent{QuerySpec: spec, Question: question, Confidence: answer.Confidence, Note: answer.Note, Provider: p.Name()}, nil
+}
+
+// Explain asks the model to describe the result in plain language.
+func (p *OpenAIProvider) Explain(ctx context.Context, intent Intent, result Result) (string, error) {
+	data, err := json.Marshal(struct {
+		Intent Intent `json:"intent"`
+		Result Result `json:"result"`
+	}{intent, result})
+	if err != nil {
+		return "", err
+	}
+	reply, err := p.complete(ctx, []chatMessage{
+		{Role: "system", Content: "You explain the output of a prediction engine to a non-technical user in at most three sentences. " +
+			"Mention the algorithm that produced the result and only state facts contained in the data."},
+		{Role: "user", Content: "Question: " + intent.Question + "\nPrediction: " + string(data)},
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(reply), nil
+}
+
+// complete sends one chat completions request and returns the content of the first choice.
+func (p *OpenAIProvider) complete(ctx context.Context, messages []chatMessage) (string, error) {
+	body, err := json.Marshal(chatRequest{Model: p.Model, Messages: messages, Temperature: 0})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", p.Name(), err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", p.Name(), err)
+	}
+
+	var parsed chatResponse
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return "", fmt.Errorf("%s: status %d: invalid response: %v", p.Name(), resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg := resp.Status
+		if parsed.Error != nil && parsed.Error.Message != "" {
+			msg = parsed.Error.Message
+		}
+		return "", fmt.Errorf("%s: %s", p.Name(), msg)
+	}
+	if len(parsed.Choices) == 0 {
+		return "", fmt.Errorf("%s: response has no choices", p.Name())
+	}
+	return parsed.Choices[0].Message.Content, nil
+}
+
+// extractJSON returns the JSON object in a reply, dropping Markdown code fences or text around it.
+func extractJSON(reply string) string {
+	start := strings.Index(reply, "{")
+	end := strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return reply
+	}
+	return reply[start : end+1]
+}
@@ -0,0 +1,258 @@
+package nlq
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RuleProvider interprets questions with a fixed keyword grammar. It needs no network access and always
+// gives the same answer for the same question and clock, which makes it the default and the fallback.
+type RuleProvider struct {
+	Catalog Catalog
+	Now     func() time.Time // used to resolve "next year"; defaults to time.Now
+}
+
+// NewRuleProvider creates a RuleProvider over the given catalog.
+func NewRuleProvider(catalog Catalog) *RuleProvider {
+	return &RuleProvider{Catalog: catalog, Now: time.Now}
+}
+
+// domainKeywords maps the words that point at a domain onto the domain name.
+var domainKeywords = map[string][]string{
+	"Job Market":     {"job", "jobs", "career", "careers", "hiring", "employment", "position", "positions", "role", "roles", "skill", "skills", "salary", "salaries"},
+	"Gas Prices":     {"gas", "gasoline", "fuel", "petrol", "pump", "diesel"},
+	"Airfare Prices": {"airfare", "airfares", "flight", "flights", "plane", "airline", "airlines", "ticket", "tickets", "fly", "flying"},
+}
+
+// categoryKeywords maps the words that point at a job category onto the category name.
+var categoryKeywords = map[string][]string{
+	"Tech":     {"tech", "technology", "software", "developer", "developers", "programming", "programmer", "programmers", "engineer", "engineers", "engineering", "devops", "cloud", "coding"},
+	"Law":      {"law", "legal", "lawyer", "lawyers", "attorney", "attorneys", "paralegal", "litigation", "compliance"},
+	"Business": {"business", "finance", "financial", "marketing", "management", "manager", "managers", "sales", "accounting"},
+}
+
+// similarKeywords mark questions asking for years that resemble a given year.
+var similarKeywords = []string{"similar", "comparable", "resemble", "resembles", "resembling", "alike"}
+
+var (
+	wordPattern     = regexp.MustCompile(`[a-z0-9+#]+`)
+	yearPattern     = regexp.MustCompile(`\b(19|20)\d{2}\b`)
+	inYearsPattern  = regexp.MustCompile(`\bin (\d{1,2}) years?\b`)
+	nextYearPattern = regexp.MustCompile(`\bnext year\b`)
+	thisYearPattern = regexp.MustCompile(`\b(this|current) year\b`)
+)
+
+// Name identifies the provider.
+func (p *RuleProvider) Name() string {
+	return "rules"
+}
+
+// Interpret picks the domain with the most keyword hits and then the catalog entry that best matches
+// the category, year and "similar" hints found in the question.
+func (p *RuleProvider) Interpret(ctx context.Context, question string) (Intent, error) {
+	text := strings.ToLower(question)
+	words := make(map[string]bool)
+	for _, w := range wordPattern.FindAllString(text, -1) {
+		words[w] = true
+	}
+
+	domain := bestMatch(words, domainKeywords)
+	if domain == "" {
+		return Intent{}, fmt.Errorf("%w: no known domain in %q", ErrNotUnderstood, question)
+	}
+	candidates := p.Catalog.Domain(domain)
+	if len(candidates) == 0 {
+		return Intent{}, fmt.Errorf("%w: no queries for domain %s", ErrNotUnderstood, domain)
+	}
+
+	intent := Intent{Question: question, Provider: p.Name(), Confidence: 0.9}
+	if domain == "Job Market" {
+		p.pickCategory(&intent, candidates, words)
+	} else {
+		p.pickYear(&intent, candidates, text, words)
+	}
+	return intent, nil
+}
+
+// pickCategory chooses the job category query.
+func (p *RuleProvider) pickCategory(intent *Intent, candidates Catalog, words map[string]bool) {
+	category := bestMatch(words, categoryKeywords)
+	for _, spec := range candidates {
+		if category != "" && spec.Params["category"] == category {
+			intent.QuerySpec = spec
+			return
+		}
+	}
+	intent.QuerySpec = candidates[0]
+	intent.Confidence = 0.5
+	intent.Note = fmt.Sprintf("No job category was mentioned, so the %s category is shown.", candidates[0].Params["category"])
+}
+
+// pickYear chooses the query for the requested year, or the closest stored year.
+func (p *RuleProvider) pickYear(intent *Intent, candidates Catalog, text string, words map[string]bool) {
+	similar := false
+	for _, w := range similarKeywords {
+		similar = similar || words[w]
+	}
+	if similar {
+		for _, spec := range candidates {
+			if spec.Params["similar"] == "true" {
+				intent.QuerySpec = spec
+				return
+			}
+		}
+	}
+
+	var plain Catalog
+	for _, spec := range candidates {
+		if spec.Params["similar"] != "true" {
+			plain = append(plain, spec)
+		}
+	}
+	if len(plain) == 0 {
+		plain = candidates
+	}
+
+	year, explicit := p.requestedYear(text)
+	sort.SliceStable(plain, func(i, j int) bool {
+		di, dj := yearDistance(plain[i], year), yearDistance(plain[j], year)
+		if di != dj {
+			return di < dj
+		}
+		return plain[i].Params["year"] > plain[j].Params["year"]
+	})
+	intent.QuerySpec = plain[0]
+	switch {
+	case !explicit:
+		intent.Confidence = 0.5
+		intent.Note = fmt.Sprintf("No year was mentioned, so the prediction for %s is shown.", plain[0].Params["year"])
+	case yearDistance(plain[0], year) != 0:
+		intent.Confidence = 0.6
+		intent.Note = fmt.Sprintf("No prediction is stored for %d; the closest year, %s, is shown instead.", year, plain[0].Params["year"])
+	}
+}
+
+// requestedYear extracts the year a question asks about. Without one it returns next year and false.
+func (p *RuleProvider) requestedYear(text string) (int, bool) {
+	now := time.Now
+	if p.Now != nil {
+		now = p.Now
+	}
+	current := now().Year()
+	if m := yearPattern.FindString(text); m != "" {
+		year, _ := strconv.Atoi(m)
+		return year, true
+	}
+	if m := inYearsPattern.FindStringSubmatch(text); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return current + n, true
+	}
+	if nextYearPattern.MatchString(text) {
+		return current + 1, true
+	}
+	if thisYearPattern.MatchString(text) {
+		return current, true
+	}
+	return current + 1, false
+}
+
+// yearDistance returns how far the year of a query is from year.
+func yearDistance(spec QuerySpec, year int) int {
+	y, err := strconv.Atoi(spec.Params["year"])
+	if err != nil {
+		return 1 << 30
+	}
+	if y > year {
+		return y - year
+	}
+	return year - y
+}
+
+// bestMatch returns the key whose keywords occur most often in words, or "" if none occur.
+// Ties are broken alphabetically so the result does not depend on map order.
+func bestMatch(words map[string]bool, keywords map[string][]string) string {
+	keys := make([]string, 0, len(keywords))
+	for key := range keywords {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	best, bestScore := "", 0
+	for _, key := range keys {
+		score := 0
+		for _, w := range keywords[key] {
+			if words[w] {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = key, score
+		}
+	}
+	return best
+}
+
+// Explain describes the result with a sentence template per algorithm.
+func (p *RuleProvider) Explain(ctx context.Context, intent Intent, result Result) (string, error) {
+	var b strings.Builder
+	summary := summarize(result.PredictionInfo)
+
+	switch {
+	case intent.Algorithm == "NaiveBayes" && len(result.Items) > 0:
+		fmt.Fprintf(&b, "The Naive Bayes classifier ranked the scraped job listings by how many %s skills they mention. ", intent.Params["category"])
+		fmt.Fprintf(&b, "The best match is %q", result.Items[0])
+		if len(result.Items) > 1 {
+			fmt.Fprintf(&b, ", followed by %s", quoteList(result.Items[1:]))
+		}
+		b.WriteString(".")
+	case intent.Algorithm == "LinearRegression" && summary != "":
+		fmt.Fprintf(&b, "A linear regression fitted to the historical %s gives this estimate for %s: %s", strings.ToLower(intent.Domain), intent.Params["year"], summary)
+	case intent.Algorithm == "KNN" && summary != "":
+		fmt.Fprintf(&b, "The k-nearest neighbors model compared %s with the most similar years on record for %s: %s", intent.Params["year"], strings.ToLower(intent.Domain), summary)
+	case summary != "":
+		fmt.Fprintf(&b, "The %s prediction for %q is: %s", intent.Algorithm, intent.QueryIdentifier, summary)
+	default:
+		fmt.Fprintf(&b, "No stored prediction was found for %q yet.", intent.QueryIdentifier)
+	}
+	if intent.Note != "" {
+		b.WriteString(" " + intent.Note)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// summarize returns the last non-empty line of a prediction, which holds the summary for tabular
+// predictions, shortened to a readable length.
+func summarize(info string) string {
+	lines := strings.Split(strings.TrimSpace(info), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.Join(strings.Fields(lines[i]), " ")
+		if line == "" {
+			continue
+		}
+		if len(line) > 300 {
+			line = line[:297] + "..."
+		}
+		if !strings.HasSuffix(line, ".") {
+			line += "."
+		}
+		return line
+	}
+	return ""
+}
+
+// quoteList formats items as `"a", "b" and "c"`.
+func quoteList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = strconv.Quote(item)
+	}
+	if len(quoted) == 1 {
+		return quoted[0]
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " and " + quoted[len(quoted)-1]
+}
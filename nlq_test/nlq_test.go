@@ -0,0 +1,156 @@
+package nlq_test
+
+import (
+	"cmpscfa23team2/nlq"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newRules() *nlq.RuleProvider {
+	p := nlq.NewRuleProvider(nlq.DefaultCatalog())
+	p.Now = func() time.Time { return time.Date(2023, time.November, 8, 0, 0, 0, 0, time.UTC) }
+	return p
+}
+
+func TestRuleProviderInterpret(t *testing.T) {
+	tests := []struct {
+		question string
+		query    string
+		hasNote  bool
+	}{
+		{"What will gas cost next year?", "Gas Prices Prediction 2024", false},
+		{"gasoline price in 2023", "Gas Prices Prediction 2023", false},
+		{"Which years had fuel prices similar to 2023?", "Gas prices target prediction for years similar to 2023 prediction", false},
+		{"How expensive will flights be in 2030", "Airfare Prices Prediction 2030", false},
+		{"airfare in 2027", "Airfare Prices Prediction 2025", true},
+		{"What are the best software developer jobs?", "Top 3 Tech Jobs", false},
+		{"Any jobs for lawyers?", "Top 3 Law Jobs", false},
+		{"top marketing and finance careers", "Top 3 Business Jobs", false},
+		{"show me jobs", "Top 3 Tech Jobs", true},
+		{"How much is gas?", "Gas Prices Prediction 2024", true},
+	}
+	p := newRules()
+	for _, test := range tests {
+		intent, err := p.Interpret(context.Background(), test.question)
+		if err != nil {
+			t.Errorf("Interpret(%q) error = %v", test.question, err)
+			continue
+		}
+		if intent.QueryIdentifier != test.query {
+			t.Errorf("Interpret(%q) = %q, want %q", test.question, intent.QueryIdentifier, test.query)
+		}
+		if (intent.Note != "") != test.hasNote {
+			t.Errorf("Interpret(%q) note = %q, want note: %v", test.question, intent.Note, test.hasNote)
+		}
+	}
+}
+
+func TestRuleProviderNotUnderstood(t *testing.T) {
+	_, err := newRules().Interpret(context.Background(), "what is the meaning of life")
+	if !errors.Is(err, nlq.ErrNotUnderstood) {
+		t.Errorf("error = %v, want ErrNotUnderstood", err)
+	}
+}
+
+func TestRuleProviderExplain(t *testing.T) {
+	p := newRules()
+	ctx := context.Background()
+
+	intent, _ := p.Interpret(ctx, "best software jobs")
+	text, _ := p.Explain(ctx, intent, nlq.Result{Items: []string{"DevOps Engineer", "Java Developer", "React Developer"}})
+	want := `The Naive Bayes classifier ranked the scraped job listings by how many Tech skills they mention. The best match is "DevOps Engineer", followed by "Java Developer" and "React Developer".`
+	if text != want {
+		t.Errorf("Explain = %q, want %q", text, want)
+	}
+
+	intent, _ = p.Interpret(ctx, "gas price 2024")
+	text, _ = p.Explain(ctx, intent, nlq.Result{PredictionInfo: "Year Price\n2024 3.61\nPredicted price for 2024: $3.61"})
+	if !strings.Contains(text, "linear regression") || !strings.HasSuffix(text, "Predicted price for 2024: $3.61.") {
+		t.Errorf("Explain = %q", text)
+	}
+
+	text, _ = p.Explain(ctx, intent, nlq.Result{})
+	if !strings.Contains(text, "No stored prediction") {
+		t.Errorf("Explain without data = %q", text)
+	}
+}
+
+// stubServer answers chat completions with the given content and records the last request.
+func stubServer(t *testing.T, content string, last *map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, `{"error": {"message": "bad request"}}`, http.StatusUnauthorized)
+			return
+		}
+		if last != nil {
+			json.NewDecoder(r.Body).Decode(last)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{"message": map[string]string{"role": "assistant", "content": content}}},
+		})
+	}))
+}
+
+func TestOpenAIProviderInterpret(t *testing.T) {
+	var req map[string]interface{}
+	server := stubServer(t, "```json\n{\"query\": \"top 3 law jobs\", \"confidence\": 0.95}\n```", &req)
+	defer server.Close()
+
+	p := nlq.NewOpenAIProvider(server.URL+"/v1/", "secret", "local-model", nlq.DefaultCatalog())
+	intent, err := p.Interpret(context.Background(), "I want to be an attorney")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if intent.QueryIdentifier != "Top 3 Law Jobs" || intent.Algorithm != "NaiveBayes" || intent.Confidence != 0.95 {
+		t.Errorf("intent = %+v", intent)
+	}
+	if req["model"] != "local-model" {
+		t.Errorf("request model = %v", req["model"])
+	}
+}
+
+func TestOpenAIProviderUnknownQuery(t *testing.T) {
+	server := stubServer(t, `{"query": "Stock market crash 2025"}`, nil)
+	defer server.Close()
+
+	p := nlq.NewOpenAIProvider(server.URL+"/v1", "secret", "m", nlq.DefaultCatalog())
+	if _, err := p.Interpret(context.Background(), "will stocks crash"); err == nil {
+		t.Error("expected an error for a query outside the catalog")
+	}
+}
+
+func TestOpenAIProviderExplain(t *testing.T) {
+	server := stubServer(t, "  Gas should cost about $3.61 next year.  ", nil)
+	defer server.Close()
+
+	p := nlq.NewOpenAIProvider(server.URL+"/v1", "secret", "m", nlq.DefaultCatalog())
+	text, err := p.Explain(context.Background(), nlq.Intent{Question: "gas?"}, nlq.Result{PredictionInfo: "3.61"})
+	if err != nil || text != "Gas should cost about $3.61 next year." {
+		t.Errorf("Explain = %q, %v", text, err)
+	}
+}
+
+func TestFallbackUsesRulesWhenRemoteFails(t *testing.T) {
+	server := stubServer(t, "", nil)
+	defer server.Close()
+
+	// Wrong API key: every remote call fails.
+	remote := nlq.NewOpenAIProvider(server.URL+"/v1", "wrong", "m", nlq.DefaultCatalog())
+	p := nlq.Fallback(remote, newRules())
+	intent, err := p.Interpret(context.Background(), "gas prices in 2023")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if intent.Provider != "rules" || intent.QueryIdentifier != "Gas Prices Prediction 2023" {
+		t.Errorf("intent = %+v", intent)
+	}
+	if text, err := p.Explain(context.Background(), intent, nlq.Result{PredictionInfo: "3.50"}); err != nil || text == "" {
+		t.Errorf("Explain = %q, %v", text, err)
+	}
+}
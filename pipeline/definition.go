@@ -0,0 +1,169 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Definition declares one end-to-end pipeline for a domain: where CRAB starts crawling, which scraper
+// configuration it uses, where the scraped data is stored, which CUDA model is trained on it and where
+// the result goes. Definitions are loaded from JSON files, one per pipeline.
+type Definition struct {
+	Name    string      `json:"name"`
+	Domain  string      `json:"domain"`
+	Crawl   CrawlSpec   `json:"crawl"`
+	Scrape  ScrapeSpec  `json:"scrape"`
+	Storage StorageSpec `json:"storage"`
+	Model   ModelSpec   `json:"model"`
+	Output  OutputSpec  `json:"output"`
+	Stages  []string    `json:"stages,omitempty"` // optional subset of StageNames, in order
+}
+
+// CrawlSpec lists the seed URLs for the crawl stage and how far to follow their links: up to MaxDepth
+// links away, within Scope (host, subdomain, regex with Pattern, or any), keeping to URLs matching
+// Include and skipping those matching Exclude. Without a depth only the seeds are crawled. With Sitemaps
+// the pages listed by the sitemaps of the seed sites are crawled too, those modified since Since first.
+type CrawlSpec struct {
+	Seeds       []string `json:"seeds"`
+	Concurrency int      `json:"concurrency"`
+	MaxDepth    int      `json:"max_depth,omitempty"`
+	MaxPages    int      `json:"max_pages,omitempty"`
+	Scope       string   `json:"scope,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	Include     []string `json:"include,omitempty"`
+	Exclude     []string `json:"exclude,omitempty"`
+	Sitemaps    bool     `json:"sitemaps,omitempty"`
+	Since       string   `json:"since,omitempty"`
+}
+
+// ScrapeSpec selects the scraper configuration. Without URLs the scrape stage uses the URLs the
+// crawl stage stored for the domain.
+type ScrapeSpec struct {
+	Config string   `json:"config"`
+	URLs   []string `json:"urls"`
+}
+
+// StorageSpec names where scraped items are stored. Only "dal" is supported at the moment.
+type StorageSpec struct {
+	Target string `json:"target"`
+}
+
+// ModelSpec names the CUDA algorithm trained in the model stage and its parameters. With Dataset set
+// (an ID, name or name@version from the dataset registry) the model trains on that dataset instead of
+// the items stored for the domain.
+type ModelSpec struct {
+	Algorithm string          `json:"algorithm"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Dataset   string          `json:"dataset,omitempty"`
+}
+
+// OutputSpec controls where the model stage writes its result and whether it is recorded as a prediction.
+type OutputSpec struct {
+	Dir    string `json:"dir"`
+	Record bool   `json:"record"`
+}
+
+// Stage names in the order they run.
+const (
+	StageCrawl  = "crawl"
+	StageScrape = "scrape"
+	StageStore  = "store"
+	StageModel  = "model"
+	StageOutput = "output"
+)
+
+// StageNames is the default stage order of a pipeline.
+var StageNames = []string{StageCrawl, StageScrape, StageStore, StageModel, StageOutput}
+
+// StageList returns the stages the definition runs, in order.
+func (d Definition) StageList() []string {
+	if len(d.Stages) > 0 {
+		return d.Stages
+	}
+	return StageNames
+}
+
+// Validate checks that the definition names a pipeline and a domain and only uses known stages.
+func (d Definition) Validate() error {
+	var errs []error
+	if strings.TrimSpace(d.Name) == "" {
+		errs = append(errs, errors.New("name is required"))
+	}
+	if strings.TrimSpace(d.Domain) == "" {
+		errs = append(errs, errors.New("domain is required"))
+	}
+	seen := make(map[string]bool)
+	for _, stage := range d.Stages {
+		if !isStageName(stage) {
+			errs = append(errs, fmt.Errorf("unknown stage %q", stage))
+		}
+		if seen[stage] {
+			errs = append(errs, fmt.Errorf("stage %q listed twice", stage))
+		}
+		seen[stage] = true
+	}
+	if d.Storage.Target != "" && d.Storage.Target != "dal" {
+		errs = append(errs, fmt.Errorf("unsupported storage target %q", d.Storage.Target))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("pipeline %q: %w", d.Name, err)
+	}
+	return nil
+}
+
+// isStageName reports whether name is one of StageNames.
+func isStageName(name string) bool {
+	for _, stage := range StageNames {
+		if stage == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadDefinition reads and validates a single pipeline definition file.
+func LoadDefinition(path string) (Definition, error) {
+	var d Definition
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return d, err
+	}
+	if err := json.Unmarshal(data, &d); err != nil {
+		return d, fmt.Errorf("%s: %v", path, err)
+	}
+	if d.Name == "" {
+		d.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := d.Validate(); err != nil {
+		return d, fmt.Errorf("%s: %w", path, err)
+	}
+	return d, nil
+}
+
+// LoadDefinitions reads every *.json file in dir, sorted by pipeline name.
+func LoadDefinitions(dir string) ([]Definition, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	defs := make([]Definition, 0, len(paths))
+	names := make(map[string]string)
+	for _, path := range paths {
+		d, err := LoadDefinition(path)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := names[d.Name]; ok {
+			return nil, fmt.Errorf("pipeline %q is defined in both %s and %s", d.Name, other, path)
+		}
+		names[d.Name] = path
+		defs = append(defs, d)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs, nil
+}
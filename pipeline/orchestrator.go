@@ -0,0 +1,214 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StageFunc runs one stage of a pipeline. Stages hand data to each other through the database, so a
+// stage only returns a short summary of what it produced; earlier summaries are available on run.
+type StageFunc func(ctx context.Context, def Definition, run Run) (output string, err error)
+
+// ProgressFunc is called before each stage starts with the number of stages already done.
+type ProgressFunc func(stage string, done, total int)
+
+// ErrRunActive is returned when a run that is still executing is resumed.
+var ErrRunActive = errors.New("pipeline run is already active")
+
+// Orchestrator runs pipeline definitions stage by stage, checkpointing every stage in the Store so
+// that a failed run can be resumed from the stage that failed.
+type Orchestrator struct {
+	store  Store
+	now    func() time.Time
+	mu     sync.Mutex
+	stages map[string]StageFunc
+	active map[string]bool
+}
+
+// NewOrchestrator creates an orchestrator that checkpoints runs in store.
+func NewOrchestrator(store Store) *Orchestrator {
+	return &Orchestrator{
+		store:  store,
+		now:    time.Now,
+		stages: make(map[string]StageFunc),
+		active: make(map[string]bool),
+	}
+}
+
+// Handle registers the function that runs the named stage.
+func (o *Orchestrator) Handle(stage string, fn StageFunc) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.stages[stage] = fn
+}
+
+// Get returns a run by ID.
+func (o *Orchestrator) Get(ctx context.Context, id string) (Run, error) {
+	return o.store.Get(ctx, id)
+}
+
+// List returns the runs of a pipeline, newest first. An empty name returns every run.
+func (o *Orchestrator) List(ctx context.Context, pipeline string) ([]Run, error) {
+	return o.store.List(ctx, pipeline)
+}
+
+// Start creates a new run of def and executes all of its stages. The returned run holds the final
+// checkpoints; the error is the error of the stage that failed, if any.
+func (o *Orchestrator) Start(ctx context.Context, def Definition, progress ProgressFunc) (Run, error) {
+	return o.StartRun(ctx, uuid.New().String(), def, progress)
+}
+
+// StartRun is like Start but uses the given run ID. If a run with that ID already exists it is resumed
+// instead, which makes it safe to call again when the caller itself is retried.
+func (o *Orchestrator) StartRun(ctx context.Context, id string, def Definition, progress ProgressFunc) (Run, error) {
+	if _, err := o.store.Get(ctx, id); err == nil {
+		return o.Resume(ctx, id, progress)
+	} else if !errors.Is(err, ErrNotFound) {
+		return Run{}, err
+	}
+	if err := def.Validate(); err != nil {
+		return Run{}, err
+	}
+	for _, name := range def.StageList() {
+		if o.handler(name) == nil {
+			return Run{}, fmt.Errorf("pipeline %q: no handler registered for stage %q", def.Name, name)
+		}
+	}
+
+	now := o.now().UTC()
+	run := Run{
+		ID:         id,
+		Pipeline:   def.Name,
+		Definition: def,
+		Status:     StatusPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	for _, name := range def.StageList() {
+		run.Stages = append(run.Stages, StageState{Name: name, Status: StatusPending})
+	}
+	if err := o.store.Save(ctx, run); err != nil {
+		return run, err
+	}
+	return o.execute(ctx, run, progress)
+}
+
+// Resume continues a run from its first stage that has not succeeded. Stages that already succeeded
+// are not run again. Resuming a run that has finished returns it unchanged.
+func (o *Orchestrator) Resume(ctx context.Context, id string, progress ProgressFunc) (Run, error) {
+	run, err := o.store.Get(ctx, id)
+	if err != nil {
+		return Run{}, err
+	}
+	if run.Status == StatusSucceeded {
+		return run, nil
+	}
+	return o.execute(ctx, run, progress)
+}
+
+// handler returns the function registered for a stage, or nil.
+func (o *Orchestrator) handler(stage string) StageFunc {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.stages[stage]
+}
+
+// execute runs the remaining stages of run, saving a checkpoint before and after each one.
+func (o *Orchestrator) execute(ctx context.Context, run Run, progress ProgressFunc) (Run, error) {
+	o.mu.Lock()
+	if o.active[run.ID] {
+		o.mu.Unlock()
+		return run, ErrRunActive
+	}
+	o.active[run.ID] = true
+	o.mu.Unlock()
+	defer func() {
+		o.mu.Lock()
+		delete(o.active, run.ID)
+		o.mu.Unlock()
+	}()
+
+	run.Status = StatusRunning
+	if err := o.save(&run); err != nil {
+		return run, err
+	}
+
+	total := len(run.Stages)
+	for i := run.resumeIndex(); i < total; i++ {
+		stage := &run.Stages[i]
+		if progress != nil {
+			progress(stage.Name, i, total)
+		}
+
+		fn := o.handler(stage.Name)
+		stage.Status = StatusRunning
+		stage.Error = ""
+		stage.StartedAt = o.now().UTC()
+		stage.FinishedAt = time.Time{}
+		if err := o.save(&run); err != nil {
+			return run, err
+		}
+
+		var output string
+		var err error
+		if fn == nil {
+			err = fmt.Errorf("no handler registered for stage %q", stage.Name)
+		} else if err = ctx.Err(); err == nil {
+			output, err = safeRun(ctx, fn, run.Definition, run)
+		}
+
+		stage.FinishedAt = o.now().UTC()
+		if err != nil {
+			stage.Status = StatusFailed
+			stage.Error = err.Error()
+			run.Status = StatusFailed
+			log.Printf("Pipeline %s run %s failed at stage %s: %v", run.Pipeline, run.ID, stage.Name, err)
+			if saveErr := o.save(&run); saveErr != nil {
+				return run, errors.Join(err, saveErr)
+			}
+			return run, fmt.Errorf("stage %s: %w", stage.Name, err)
+		}
+		stage.Status = StatusSucceeded
+		stage.Output = output
+		if err := o.save(&run); err != nil {
+			return run, err
+		}
+		log.Printf("Pipeline %s run %s finished stage %s: %s", run.Pipeline, run.ID, stage.Name, output)
+	}
+
+	run.Status = StatusSucceeded
+	if err := o.save(&run); err != nil {
+		return run, err
+	}
+	if progress != nil {
+		progress("", total, total)
+	}
+	return run, nil
+}
+
+// save stamps and checkpoints the run. The checkpoint is written even when the run's context has been
+// cancelled, so the state of an interrupted run is still recorded and it can be resumed.
+func (o *Orchestrator) save(run *Run) error {
+	run.UpdatedAt = o.now().UTC()
+	if err := o.store.Save(context.Background(), *run); err != nil {
+		return fmt.Errorf("error saving checkpoint for run %s: %v", run.ID, err)
+	}
+	return nil
+}
+
+// safeRun calls a stage function, turning a panic into an error.
+func safeRun(ctx context.Context, fn StageFunc, def Definition, run Run) (output string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return fn(ctx, def, run)
+}
@@ -0,0 +1,61 @@
+package pipeline
+
+import (
+	"errors"
+	"time"
+)
+
+// Status is the state of a pipeline run or of one of its stages.
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// ErrNotFound is returned when a run does not exist.
+var ErrNotFound = errors.New("pipeline run not found")
+
+// StageState is the checkpoint of one stage: whether it finished and what it handed on to the next stage.
+type StageState struct {
+	Name       string    `json:"name"`
+	Status     Status    `json:"status"`
+	Output     string    `json:"output,omitempty"` // short summary of what the stage produced
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at,omitempty"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+// Run is one execution of a pipeline. The definition is copied into the run so a resumed run uses the
+// same settings it started with, even if the definition file changed in between.
+type Run struct {
+	ID         string       `json:"id"`
+	Pipeline   string       `json:"pipeline"`
+	Definition Definition   `json:"definition"`
+	Status     Status       `json:"status"`
+	Stages     []StageState `json:"stages"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+// Stage returns the checkpoint of the named stage, or nil if the run has no such stage.
+func (r *Run) Stage(name string) *StageState {
+	for i := range r.Stages {
+		if r.Stages[i].Name == name {
+			return &r.Stages[i]
+		}
+	}
+	return nil
+}
+
+// resumeIndex returns the index of the first stage that has not succeeded, or len(Stages) if all have.
+func (r *Run) resumeIndex() int {
+	for i, stage := range r.Stages {
+		if stage.Status != StatusSucceeded {
+			return i
+		}
+	}
+	return len(r.Stages)
+}
@@ -0,0 +1,67 @@
+package pipeline
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Store persists pipeline runs and their stage checkpoints. The orchestrator saves the run after
+// every stage transition, so a run can be resumed after a failure or a restart.
+type Store interface {
+	// Save creates or overwrites a run.
+	Save(ctx context.Context, run Run) error
+	// Get returns the run with the given ID or ErrNotFound.
+	Get(ctx context.Context, id string) (Run, error)
+	// List returns the runs of a pipeline, newest first. An empty name returns every run.
+	List(ctx context.Context, pipeline string) ([]Run, error)
+}
+
+// MemoryStore is a Store that keeps runs in a map. It is used when no database is available and in tests.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	runs map[string]Run
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{runs: make(map[string]Run)}
+}
+
+// Save creates or overwrites a run.
+func (m *MemoryStore) Save(ctx context.Context, run Run) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run.Stages = append([]StageState(nil), run.Stages...)
+	m.runs[run.ID] = run
+	return nil
+}
+
+// Get returns the run with the given ID.
+func (m *MemoryStore) Get(ctx context.Context, id string) (Run, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	run, ok := m.runs[id]
+	if !ok {
+		return Run{}, ErrNotFound
+	}
+	run.Stages = append([]StageState(nil), run.Stages...)
+	return run, nil
+}
+
+// List returns the runs of a pipeline, newest first.
+func (m *MemoryStore) List(ctx context.Context, pipeline string) ([]Run, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var list []Run
+	for _, run := range m.runs {
+		if pipeline == "" || run.Pipeline == pipeline {
+			run.Stages = append([]StageState(nil), run.Stages...)
+			list = append(list, run)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list, nil
+}
@@ -0,0 +1,166 @@
+package pipeline_test
+
+import (
+	"cmpscfa23team2/pipeline"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// recorder registers a stage function for every stage that records the order stages ran in.
+// Stages listed in fail return an error the first time they run.
+type recorder struct {
+	ran  []string
+	fail map[string]bool
+}
+
+func (r *recorder) register(o *pipeline.Orchestrator) {
+	for _, name := range pipeline.StageNames {
+		name := name
+		o.Handle(name, func(ctx context.Context, def pipeline.Definition, run pipeline.Run) (string, error) {
+			r.ran = append(r.ran, name)
+			if r.fail[name] {
+				delete(r.fail, name)
+				return "", errors.New(name + " failed")
+			}
+			return name + " done", nil
+		})
+	}
+}
+
+func TestRunsStagesInOrder(t *testing.T) {
+	o := pipeline.NewOrchestrator(pipeline.NewMemoryStore())
+	rec := &recorder{}
+	rec.register(o)
+
+	var progress []string
+	run, err := o.Start(context.Background(), pipeline.Definition{Name: "books", Domain: "books"},
+		func(stage string, done, total int) { progress = append(progress, stage) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rec.ran, pipeline.StageNames) {
+		t.Errorf("ran %v, want %v", rec.ran, pipeline.StageNames)
+	}
+	if run.Status != pipeline.StatusSucceeded {
+		t.Errorf("status = %s, want succeeded", run.Status)
+	}
+	if got := run.Stage(pipeline.StageModel).Output; got != "model done" {
+		t.Errorf("model output = %q", got)
+	}
+	if want := append(append([]string{}, pipeline.StageNames...), ""); !reflect.DeepEqual(progress, want) {
+		t.Errorf("progress = %v, want %v", progress, want)
+	}
+}
+
+func TestResumeFromFailedStage(t *testing.T) {
+	store := pipeline.NewMemoryStore()
+	o := pipeline.NewOrchestrator(store)
+	rec := &recorder{fail: map[string]bool{pipeline.StageStore: true}}
+	rec.register(o)
+
+	run, err := o.Start(context.Background(), pipeline.Definition{Name: "books", Domain: "books"}, nil)
+	if err == nil {
+		t.Fatal("expected the store stage to fail")
+	}
+	stored, _ := store.Get(context.Background(), run.ID)
+	if stored.Status != pipeline.StatusFailed {
+		t.Errorf("checkpointed status = %s, want failed", stored.Status)
+	}
+	if s := stored.Stage(pipeline.StageStore); s.Status != pipeline.StatusFailed || s.Error != "store failed" {
+		t.Errorf("store checkpoint = %+v", s)
+	}
+	if s := stored.Stage(pipeline.StageModel); s.Status != pipeline.StatusPending {
+		t.Errorf("model checkpoint = %+v, want pending", s)
+	}
+
+	rec.ran = nil
+	run, err = o.Resume(context.Background(), run.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{pipeline.StageStore, pipeline.StageModel, pipeline.StageOutput}
+	if !reflect.DeepEqual(rec.ran, want) {
+		t.Errorf("resumed %v, want %v", rec.ran, want)
+	}
+	if run.Status != pipeline.StatusSucceeded {
+		t.Errorf("status = %s, want succeeded", run.Status)
+	}
+
+	// A finished run is not run again.
+	rec.ran = nil
+	if _, err := o.Resume(context.Background(), run.ID, nil); err != nil || len(rec.ran) != 0 {
+		t.Errorf("resuming a finished run ran %v, err %v", rec.ran, err)
+	}
+}
+
+func TestStartRunResumesExistingRun(t *testing.T) {
+	o := pipeline.NewOrchestrator(pipeline.NewMemoryStore())
+	rec := &recorder{fail: map[string]bool{pipeline.StageModel: true}}
+	rec.register(o)
+	def := pipeline.Definition{Name: "jobs", Domain: "job-market", Stages: []string{"scrape", "store", "model"}}
+
+	if _, err := o.StartRun(context.Background(), "job-1", def, nil); err == nil {
+		t.Fatal("expected the model stage to fail")
+	}
+	rec.ran = nil
+	if _, err := o.StartRun(context.Background(), "job-1", def, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"model"}; !reflect.DeepEqual(rec.ran, want) {
+		t.Errorf("retry ran %v, want %v", rec.ran, want)
+	}
+}
+
+func TestStagePanicFailsRun(t *testing.T) {
+	o := pipeline.NewOrchestrator(pipeline.NewMemoryStore())
+	o.Handle(pipeline.StageCrawl, func(ctx context.Context, def pipeline.Definition, run pipeline.Run) (string, error) {
+		panic("boom")
+	})
+	run, err := o.Start(context.Background(), pipeline.Definition{Name: "p", Domain: "d", Stages: []string{"crawl"}}, nil)
+	if err == nil || run.Status != pipeline.StatusFailed {
+		t.Errorf("run = %+v, err = %v; want failed", run, err)
+	}
+}
+
+func TestStartRequiresHandlers(t *testing.T) {
+	o := pipeline.NewOrchestrator(pipeline.NewMemoryStore())
+	if _, err := o.Start(context.Background(), pipeline.Definition{Name: "p", Domain: "d"}, nil); err == nil {
+		t.Error("expected an error for stages without handlers")
+	}
+}
+
+func TestLoadDefinitions(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "gas.json"), []byte(`{"domain": "gas", "stages": ["scrape", "store"]}`), 0644)
+	os.WriteFile(filepath.Join(dir, "books.json"), []byte(`{"name": "books", "domain": "books"}`), 0644)
+
+	defs, err := pipeline.LoadDefinitions(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 2 || defs[0].Name != "books" || defs[1].Name != "gas" {
+		t.Fatalf("loaded %+v", defs)
+	}
+	if got := defs[1].StageList(); !reflect.DeepEqual(got, []string{"scrape", "store"}) {
+		t.Errorf("StageList = %v", got)
+	}
+
+	os.WriteFile(filepath.Join(dir, "bad.json"), []byte(`{"name": "bad", "domain": "x", "stages": ["train"]}`), 0644)
+	if _, err := pipeline.LoadDefinitions(dir); err == nil {
+		t.Error("expected an error for an unknown stage")
+	}
+}
+
+func TestRepositoryPipelinesAreValid(t *testing.T) {
+	defs, err := pipeline.LoadDefinitions("../pipelines")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) == 0 {
+		t.Error("no pipeline definitions found")
+	}
+}
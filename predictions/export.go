@@ -0,0 +1,72 @@
+package predictions
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Format is an export file format.
+type Format string
+
+const (
+	CSV  Format = "csv"
+	JSON Format = "json"
+)
+
+// ParseFormat parses an export format name, defaulting to CSV.
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case "", CSV:
+		return CSV, nil
+	case JSON:
+		return JSON, nil
+	}
+	return "", fmt.Errorf("unsupported export format %q: use csv or json", name)
+}
+
+// ContentType returns the MIME type of the format.
+func (f Format) ContentType() string {
+	if f == JSON {
+		return "application/json"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// csvHeader is the first row of a CSV export.
+var csvHeader = []string{"prediction_id", "algorithm", "domain", "query_identifier", "prediction_time", "input_data", "prediction_info"}
+
+// Export writes records in the given format, including their input data.
+func Export(w io.Writer, format Format, records []Record) error {
+	switch format {
+	case JSON:
+		if records == nil {
+			records = []Record{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	case CSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return err
+		}
+		for _, r := range records {
+			row := []string{r.ID, r.Algorithm, r.Domain, r.QueryIdentifier, r.Time.UTC().Format(time.RFC3339), r.InputData, r.PredictionInfo}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unsupported export format %q", format)
+}
+
+// Filename returns the name of an export file created at t.
+func (f Format) Filename(t time.Time) string {
+	return "predictions-" + t.UTC().Format("20060102-150405") + "." + string(f)
+}
@@ -0,0 +1,205 @@
+// Package predictions browses the history of model runs: every run of a cuda algorithm stores a
+// prediction, and this package lists them, compares two of them and exports them with their input
+// data. Predictions live in a Store; carp adapts the prediction tables of the dal to it.
+package predictions
+
+import (
+	"context"
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record is one stored prediction.
+type Record struct {
+	ID              string    `json:"prediction_id"`
+	Algorithm       string    `json:"algorithm"`
+	Domain          string    `json:"domain"`
+	QueryIdentifier string    `json:"query_identifier"`
+	InputData       string    `json:"input_data"`
+	PredictionInfo  string    `json:"prediction_info"`
+	Time            time.Time `json:"prediction_time"`
+}
+
+// Filter selects records. Empty fields match everything; Until is exclusive.
+type Filter struct {
+	Domain          string
+	Algorithm       string
+	QueryIdentifier string
+	Since           time.Time
+	Until           time.Time
+	Limit           int
+	Offset          int
+}
+
+// DefaultLimit and MaxLimit bound the page size of a history listing.
+const (
+	DefaultLimit = 50
+	MaxLimit     = 1000
+)
+
+// Normalize applies the default page size and clamps Limit and Offset to their allowed ranges.
+func (f Filter) Normalize() Filter {
+	if f.Limit <= 0 {
+		f.Limit = DefaultLimit
+	}
+	if f.Limit > MaxLimit {
+		f.Limit = MaxLimit
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	return f
+}
+
+// Matches reports whether r is selected by the filter, ignoring Limit and Offset.
+func (f Filter) Matches(r Record) bool {
+	return (f.Domain == "" || r.Domain == f.Domain) &&
+		(f.Algorithm == "" || r.Algorithm == f.Algorithm) &&
+		(f.QueryIdentifier == "" || r.QueryIdentifier == f.QueryIdentifier) &&
+		(f.Since.IsZero() || !r.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || r.Time.Before(f.Until))
+}
+
+// ErrNotFound is returned by a Store when no prediction has the requested ID.
+var ErrNotFound = errors.New("predictions: prediction not found")
+
+// Store reads stored predictions.
+type Store interface {
+	// History returns the records that match the filter, newest first.
+	History(ctx context.Context, f Filter) ([]Record, error)
+	// Get returns a record by ID, or ErrNotFound.
+	Get(ctx context.Context, id string) (Record, error)
+	// Domains lists the domains that have predictions.
+	Domains(ctx context.Context) ([]string, error)
+}
+
+// Comparison puts two predictions side by side. A is the older run and B the newer one.
+type Comparison struct {
+	A Record `json:"a"`
+	B Record `json:"b"`
+
+	SameQuery     bool   `json:"same_query"`
+	InputChanged  bool   `json:"input_changed"`
+	OutputChanged bool   `json:"output_changed"`
+	Elapsed       string `json:"elapsed"`
+
+	// InputAdded and InputRemoved are the input items only in B and only in A.
+	InputAdded   []string `json:"input_added,omitempty"`
+	InputRemoved []string `json:"input_removed,omitempty"`
+
+	// ValueA and ValueB are the predicted amounts, the last dollar figure of each prediction, when
+	// both have one; Change is ValueB - ValueA and ChangePercent the change relative to ValueA.
+	ValueA        *float64 `json:"value_a,omitempty"`
+	ValueB        *float64 `json:"value_b,omitempty"`
+	Change        *float64 `json:"change,omitempty"`
+	ChangePercent *float64 `json:"change_percent,omitempty"`
+}
+
+// Compare compares two runs, ordering them by time.
+func Compare(a, b Record) Comparison {
+	if b.Time.Before(a.Time) {
+		a, b = b, a
+	}
+	c := Comparison{
+		A:             a,
+		B:             b,
+		SameQuery:     a.Algorithm == b.Algorithm && a.QueryIdentifier == b.QueryIdentifier,
+		InputChanged:  a.InputData != b.InputData,
+		OutputChanged: a.PredictionInfo != b.PredictionInfo,
+		Elapsed:       b.Time.Sub(a.Time).String(),
+	}
+	if c.InputChanged {
+		c.InputAdded, c.InputRemoved = diffItems(InputItems(a.InputData), InputItems(b.InputData))
+	}
+
+	valueA, okA := PredictedValue(a.PredictionInfo)
+	valueB, okB := PredictedValue(b.PredictionInfo)
+	if okA && okB {
+		change := valueB - valueA
+		c.ValueA, c.ValueB, c.Change = &valueA, &valueB, &change
+		if valueA != 0 {
+			percent := math.Round(change/valueA*10000) / 100
+			c.ChangePercent = &percent
+		}
+	}
+	return c
+}
+
+// InputItems splits input data into its items: the "(x, y, z)" tuples of regression inputs, or the
+// lines or comma separated values of other inputs.
+func InputItems(input string) []string {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil
+	}
+	var items []string
+	switch {
+	case strings.HasPrefix(input, "("):
+		for _, item := range strings.Split(input, "),") {
+			item = strings.TrimSpace(item)
+			if !strings.HasSuffix(item, ")") {
+				item += ")"
+			}
+			items = append(items, item)
+		}
+	case strings.Contains(input, "\n"):
+		items = strings.Split(input, "\n")
+	default:
+		items = strings.Split(input, ",")
+	}
+	kept := items[:0]
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// diffItems returns the items only in b and only in a, in their original order.
+func diffItems(a, b []string) (added, removed []string) {
+	inA := make(map[string]int)
+	for _, item := range a {
+		inA[item]++
+	}
+	inB := make(map[string]int)
+	for _, item := range b {
+		inB[item]++
+	}
+	for _, item := range b {
+		if inA[item] > 0 {
+			inA[item]--
+		} else {
+			added = append(added, item)
+		}
+	}
+	for _, item := range a {
+		if inB[item] > 0 {
+			inB[item]--
+		} else {
+			removed = append(removed, item)
+		}
+	}
+	return added, removed
+}
+
+// dollarAmount matches amounts such as "$4.34" or "$1,204.50".
+var dollarAmount = regexp.MustCompile(`\$\s?([0-9][0-9,]*(?:\.[0-9]+)?)`)
+
+// PredictedValue returns the last dollar amount in a prediction, which is the predicted price in
+// the texts the regression and KNN models write.
+func PredictedValue(info string) (float64, bool) {
+	matches := dollarAmount.FindAllStringSubmatch(info, -1)
+	if len(matches) == 0 {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(strings.ReplaceAll(matches[len(matches)-1][1], ",", ""), 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
@@ -0,0 +1,81 @@
+package predictions
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryStore is a Store that keeps records in a slice. It is used when no database is available and in tests.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records []Record
+}
+
+// NewMemoryStore creates a MemoryStore holding the given records.
+func NewMemoryStore(records ...Record) *MemoryStore {
+	return &MemoryStore{records: append([]Record(nil), records...)}
+}
+
+// Add stores a record.
+func (m *MemoryStore) Add(r Record) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, r)
+}
+
+// History returns the matching records, newest first.
+func (m *MemoryStore) History(ctx context.Context, f Filter) ([]Record, error) {
+	f = f.Normalize()
+	m.mu.RLock()
+	var matched []Record
+	for _, r := range m.records {
+		if f.Matches(r) {
+			matched = append(matched, r)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		if !matched[i].Time.Equal(matched[j].Time) {
+			return matched[i].Time.After(matched[j].Time)
+		}
+		return matched[i].ID < matched[j].ID
+	})
+	if f.Offset >= len(matched) {
+		return nil, nil
+	}
+	matched = matched[f.Offset:]
+	if len(matched) > f.Limit {
+		matched = matched[:f.Limit]
+	}
+	return matched, nil
+}
+
+// Get returns a record by ID, or ErrNotFound.
+func (m *MemoryStore) Get(ctx context.Context, id string) (Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, r := range m.records {
+		if r.ID == id {
+			return r, nil
+		}
+	}
+	return Record{}, ErrNotFound
+}
+
+// Domains lists the distinct non-empty domains, sorted.
+func (m *MemoryStore) Domains(ctx context.Context) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	seen := make(map[string]bool)
+	var domains []string
+	for _, r := range m.records {
+		if r.Domain != "" && !seen[r.Domain] {
+			seen[r.Domain] = true
+			domains = append(domains, r.Domain)
+		}
+	}
+	sort.Strings(domains)
+	return domains, nil
+}
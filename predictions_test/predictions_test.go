@@ -0,0 +1,152 @@
+package predictions_test
+
+import (
+	"bytes"
+	"cmpscfa23team2/predictions"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+func sampleStore() *predictions.MemoryStore {
+	return predictions.NewMemoryStore(
+		predictions.Record{ID: "lr-1", Algorithm: "LinearRegression", Domain: "Gas Prices", QueryIdentifier: "Gas Prices Prediction 2024",
+			InputData: "(2021.000000, 264.017000, 3.133000),(2022.000000, 347.747000, 4.192000)", PredictionInfo: "anticipated to be: $4.59.", Time: base},
+		predictions.Record{ID: "lr-2", Algorithm: "LinearRegression", Domain: "Gas Prices", QueryIdentifier: "Gas Prices Prediction 2024",
+			InputData: "(2022.000000, 347.747000, 4.192000),(2023.000000, 304.702000, 3.520000)", PredictionInfo: "anticipated to be: $4.13.", Time: base.Add(24 * time.Hour)},
+		predictions.Record{ID: "nb-1", Algorithm: "NaiveBayes", Domain: "Job Market", QueryIdentifier: "Top 3 Tech Jobs",
+			InputData: "Software Engineer", PredictionInfo: "Nbc_output/tech_top_jobs.json", Time: base.Add(time.Hour)},
+	)
+}
+
+func ids(records []predictions.Record) []string {
+	var list []string
+	for _, r := range records {
+		list = append(list, r.ID)
+	}
+	return list
+}
+
+func TestHistoryFiltersAndPages(t *testing.T) {
+	store := sampleStore()
+	tests := []struct {
+		name   string
+		filter predictions.Filter
+		want   []string
+	}{
+		{"all, newest first", predictions.Filter{}, []string{"lr-2", "nb-1", "lr-1"}},
+		{"domain", predictions.Filter{Domain: "Gas Prices"}, []string{"lr-2", "lr-1"}},
+		{"algorithm", predictions.Filter{Algorithm: "NaiveBayes"}, []string{"nb-1"}},
+		{"since", predictions.Filter{Since: base.Add(time.Hour)}, []string{"lr-2", "nb-1"}},
+		{"until is exclusive", predictions.Filter{Until: base.Add(time.Hour)}, []string{"lr-1"}},
+		{"page", predictions.Filter{Limit: 1, Offset: 1}, []string{"nb-1"}},
+		{"past the end", predictions.Filter{Offset: 5}, nil},
+	}
+	for _, test := range tests {
+		got, err := store.History(context.Background(), test.filter)
+		if err != nil {
+			t.Fatalf("%s: History error = %v", test.name, err)
+		}
+		if !reflect.DeepEqual(ids(got), test.want) {
+			t.Errorf("%s: History = %v, want %v", test.name, ids(got), test.want)
+		}
+	}
+
+	if _, err := store.Get(context.Background(), "missing"); err != predictions.ErrNotFound {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+	domains, _ := store.Domains(context.Background())
+	if !reflect.DeepEqual(domains, []string{"Gas Prices", "Job Market"}) {
+		t.Errorf("Domains = %v", domains)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	store := sampleStore()
+	older, _ := store.Get(context.Background(), "lr-1")
+	newer, _ := store.Get(context.Background(), "lr-2")
+
+	// The arguments are put in time order.
+	c := predictions.Compare(newer, older)
+	if c.A.ID != "lr-1" || c.B.ID != "lr-2" {
+		t.Fatalf("Compare order = %s, %s", c.A.ID, c.B.ID)
+	}
+	if !c.SameQuery || !c.InputChanged || !c.OutputChanged {
+		t.Errorf("flags = same %v, input %v, output %v", c.SameQuery, c.InputChanged, c.OutputChanged)
+	}
+	if c.Elapsed != "24h0m0s" {
+		t.Errorf("Elapsed = %s", c.Elapsed)
+	}
+	if !reflect.DeepEqual(c.InputAdded, []string{"(2023.000000, 304.702000, 3.520000)"}) ||
+		!reflect.DeepEqual(c.InputRemoved, []string{"(2021.000000, 264.017000, 3.133000)"}) {
+		t.Errorf("input diff = +%v -%v", c.InputAdded, c.InputRemoved)
+	}
+	if c.ValueA == nil || *c.ValueA != 4.59 || *c.ValueB != 4.13 || c.ChangePercent == nil || *c.ChangePercent != -10.02 {
+		t.Errorf("values = %v %v %v", c.ValueA, c.ValueB, c.ChangePercent)
+	}
+
+	jobs, _ := store.Get(context.Background(), "nb-1")
+	c = predictions.Compare(older, jobs)
+	if c.SameQuery || c.Change != nil {
+		t.Errorf("unrelated runs: same %v, change %v", c.SameQuery, c.Change)
+	}
+}
+
+func TestPredictedValue(t *testing.T) {
+	tests := map[string]float64{
+		"Predicted Target Price closest to Year 2023: $3.82, Year: 2015": 3.82,
+		"average price ... anticipated to be: $1,204.50.":                1204.50,
+		"between $4.19 and $ 3.69":                                       3.69,
+	}
+	for info, want := range tests {
+		if got, ok := predictions.PredictedValue(info); !ok || got != want {
+			t.Errorf("PredictedValue(%q) = %v, %v; want %v", info, got, ok, want)
+		}
+	}
+	if _, ok := predictions.PredictedValue("no amount here"); ok {
+		t.Error("PredictedValue found an amount in text without one")
+	}
+}
+
+func TestExport(t *testing.T) {
+	records, _ := sampleStore().History(context.Background(), predictions.Filter{Domain: "Gas Prices"})
+
+	var buf bytes.Buffer
+	if err := predictions.Export(&buf, predictions.CSV, records); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	if len(rows) != 3 || rows[0][5] != "input_data" || rows[1][5] != records[0].InputData {
+		t.Errorf("CSV rows = %q", rows)
+	}
+
+	buf.Reset()
+	if err := predictions.Export(&buf, predictions.JSON, records); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []predictions.Record
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[1].InputData != records[1].InputData || !decoded[1].Time.Equal(records[1].Time) {
+		t.Errorf("JSON export = %+v", decoded)
+	}
+
+	buf.Reset()
+	predictions.Export(&buf, predictions.JSON, nil)
+	if got := bytes.TrimSpace(buf.Bytes()); string(got) != "[]" {
+		t.Errorf("empty JSON export = %s, want []", got)
+	}
+
+	if _, err := predictions.ParseFormat("xml"); err == nil {
+		t.Error("ParseFormat(xml) expected an error")
+	}
+}
@@ -0,0 +1,224 @@
+// Package ratelimit throttles clients with token buckets. Rules pick the bucket size and refill rate
+// per route and say whether clients are told apart by IP address, user ID or API key. Bucket state
+// and daily quota counters live in a Store, in memory by default.
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// KeyBy says how the clients of a rule are told apart.
+type KeyBy string
+
+const (
+	ByIP     KeyBy = "ip"
+	ByUser   KeyBy = "user"    // falls back to the IP address for anonymous requests
+	ByAPIKey KeyBy = "api_key" // falls back to the user ID, then the IP address
+)
+
+// Duration is a time.Duration written as a string such as "1m" or "24h" in config files.
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"1m\": %v", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON writes the duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Limit allows Requests per Per on average, with bursts of up to Burst requests. Burst defaults to
+// Requests.
+type Limit struct {
+	Requests int      `json:"requests"`
+	Per      Duration `json:"per"`
+	Burst    int      `json:"burst,omitempty"`
+}
+
+// Rate returns the refill rate in tokens per second.
+func (l Limit) Rate() float64 {
+	return float64(l.Requests) / time.Duration(l.Per).Seconds()
+}
+
+// Capacity returns the bucket size.
+func (l Limit) Capacity() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// Rule applies a limit to the requests whose path matches Path. A Path ending in "/" matches every
+// path below it, like http.ServeMux patterns, unless Exact is set. Without Methods the rule applies
+// to every method.
+type Rule struct {
+	Name    string   `json:"name"`
+	Path    string   `json:"path"`
+	Exact   bool     `json:"exact,omitempty"` // match Path only, e.g. "/" without every path below it
+	Methods []string `json:"methods,omitempty"`
+	By      KeyBy    `json:"by"`
+	Limit
+}
+
+// Matches reports whether the rule applies to the request.
+func (r Rule) Matches(req *http.Request) bool {
+	if len(r.Methods) > 0 {
+		found := false
+		for _, m := range r.Methods {
+			found = found || strings.EqualFold(m, req.Method)
+		}
+		if !found {
+			return false
+		}
+	}
+	if !r.Exact && strings.HasSuffix(r.Path, "/") {
+		return strings.HasPrefix(req.URL.Path, r.Path)
+	}
+	return req.URL.Path == r.Path
+}
+
+// Validate checks that the rule can be applied.
+func (r Rule) Validate() error {
+	var errs []error
+	if r.Name == "" {
+		errs = append(errs, errors.New("name is required"))
+	}
+	if !strings.HasPrefix(r.Path, "/") {
+		errs = append(errs, fmt.Errorf("path %q must start with /", r.Path))
+	}
+	switch r.By {
+	case ByIP, ByUser, ByAPIKey:
+	default:
+		errs = append(errs, fmt.Errorf("by must be %q, %q or %q, not %q", ByIP, ByUser, ByAPIKey, r.By))
+	}
+	if r.Requests <= 0 || r.Per <= 0 {
+		errs = append(errs, errors.New("requests and per must be positive"))
+	}
+	if r.Burst < 0 {
+		errs = append(errs, errors.New("burst must not be negative"))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("rule %q: %w", r.Name, err)
+	}
+	return nil
+}
+
+// Config is the rate limit section of a config file.
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Validate checks every rule and that rule names are unique.
+func (c Config) Validate() error {
+	var errs []error
+	seen := make(map[string]bool)
+	for _, rule := range c.Rules {
+		if err := rule.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+		if seen[rule.Name] {
+			errs = append(errs, fmt.Errorf("duplicate rule name %q", rule.Name))
+		}
+		seen[rule.Name] = true
+	}
+	return errors.Join(errs...)
+}
+
+// LoadConfig reads and validates a JSON config file.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Decision is the outcome of a Limiter check.
+type Decision struct {
+	Allowed    bool
+	Rule       *Rule         // nil when no rule matched
+	Remaining  int           // tokens left in the bucket
+	RetryAfter time.Duration // how long to wait before the next request can succeed
+}
+
+// RetryAfterSeconds returns RetryAfter rounded up to whole seconds, as sent in the Retry-After header.
+func (d Decision) RetryAfterSeconds() int {
+	return int(math.Ceil(d.RetryAfter.Seconds()))
+}
+
+// Limiter checks requests against the first matching rule.
+type Limiter struct {
+	rules []Rule
+	store Store
+	now   func() time.Time
+}
+
+// NewLimiter creates a limiter over the given rules, keeping its buckets in store.
+func NewLimiter(cfg Config, store Store) *Limiter {
+	return &Limiter{rules: cfg.Rules, store: store, now: time.Now}
+}
+
+// Rule returns the first rule that applies to the request, or nil.
+func (l *Limiter) Rule(req *http.Request) *Rule {
+	for i := range l.rules {
+		if l.rules[i].Matches(req) {
+			return &l.rules[i]
+		}
+	}
+	return nil
+}
+
+// Allow takes a token for the client identified by clientKey from the bucket of the matching rule.
+// Requests that match no rule are always allowed.
+func (l *Limiter) Allow(req *http.Request, clientKey string) (Decision, error) {
+	rule := l.Rule(req)
+	if rule == nil {
+		return Decision{Allowed: true}, nil
+	}
+	allowed, remaining, wait, err := l.store.Take(rule.Name+"|"+clientKey, rule.Limit, l.now())
+	if err != nil {
+		return Decision{}, err
+	}
+	return Decision{Allowed: allowed, Rule: rule, Remaining: remaining, RetryAfter: wait}, nil
+}
+
+// Quota checks a daily allowance, e.g. of an API key's customer. It counts the request and reports
+// whether the count is still within max; when it is not, RetryAfter is the time until midnight UTC.
+func (l *Limiter) Quota(key string, max int64) (Decision, error) {
+	now := l.now().UTC()
+	used, err := l.store.AddUsage(key, now.Format("2006-01-02"), 1)
+	if err != nil {
+		return Decision{}, err
+	}
+	remaining := max - used
+	if remaining >= 0 {
+		return Decision{Allowed: true, Remaining: int(remaining)}, nil
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return Decision{Allowed: false, RetryAfter: midnight.Sub(now)}, nil
+}
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Store keeps token buckets and usage counters. MemoryStore serves a single process; a shared store
+// such as Redis lets several carp instances enforce the same limits.
+type Store interface {
+	// Take removes one token from the bucket under key, filling it first at the limit's rate since
+	// the last call. It reports whether the token was taken, the tokens left and, when the bucket is
+	// empty, how long until a token is available, which may round down to zero.
+	Take(key string, limit Limit, now time.Time) (allowed bool, remaining int, wait time.Duration, err error)
+
+	// AddUsage adds n to the counter of key for the given period, such as a day, and returns the new
+	// total. Counters of earlier periods may be discarded.
+	AddUsage(key, period string, n int64) (int64, error)
+}
+
+// bucket is the state of one token bucket.
+type bucket struct {
+	tokens float64
+	last   time.Time
+	idle   time.Duration // time after which a full bucket can be forgotten
+}
+
+// usage is the counter of one key in the current period.
+type usage struct {
+	period string
+	count  int64
+}
+
+// sweepEvery is how many Take calls pass between removals of idle buckets.
+const sweepEvery = 1024
+
+// MemoryStore keeps buckets and counters in memory.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	usage   map[string]usage
+	calls   int
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket), usage: make(map[string]usage)}
+}
+
+// Take implements Store.
+func (s *MemoryStore) Take(key string, limit Limit, now time.Time) (bool, int, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+	if s.calls%sweepEvery == 0 {
+		s.sweep(now)
+	}
+
+	capacity := float64(limit.Capacity())
+	rate := limit.Rate()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		s.buckets[key] = b
+	}
+	b.idle = time.Duration(capacity / rate * float64(time.Second))
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*rate)
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, int(b.tokens), 0, nil
+	}
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	return false, 0, wait, nil
+}
+
+// sweep forgets buckets that have been idle long enough to be full again, which is the same as
+// never having been used. It must be called with s.mu held.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if now.Sub(b.last) > b.idle {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// AddUsage implements Store. Only the current period is kept for each key.
+func (s *MemoryStore) AddUsage(key, period string, n int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.usage[key]
+	if u.period != period {
+		u = usage{period: period}
+	}
+	u.count += n
+	s.usage[key] = u
+	return u.count, nil
+}
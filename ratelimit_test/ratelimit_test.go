@@ -0,0 +1,154 @@
+package ratelimit_test
+
+import (
+	"cmpscfa23team2/ratelimit"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTokenBucket(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Requests: 2, Per: ratelimit.Duration(time.Second)}
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, want := range []int{1, 0} {
+		allowed, remaining, wait, err := store.Take("k", limit, start)
+		if err != nil || !allowed || wait != 0 || remaining != want {
+			t.Fatalf("take %d = (%t, %d, %v, %v), want (true, %d, 0, nil)", i, allowed, remaining, wait, err, want)
+		}
+	}
+	allowed, _, wait, _ := store.Take("k", limit, start)
+	if allowed || wait != 500*time.Millisecond {
+		t.Errorf("take on empty bucket = (%t, %v), want (false, 500ms)", allowed, wait)
+	}
+	if allowed, _, wait, _ := store.Take("k", limit, start.Add(500*time.Millisecond)); !allowed || wait != 0 {
+		t.Errorf("take after refill = (%t, %v), want (true, 0)", allowed, wait)
+	}
+	if allowed, _, _, _ := store.Take("other", limit, start); !allowed {
+		t.Errorf("other key shares the bucket")
+	}
+}
+
+func TestMemoryStoreDeniesWaitBelowOneNanosecond(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Requests: 3, Per: ratelimit.Duration(time.Second), Burst: 1}
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	store.Take("k", limit, start)
+	// A third of a second minus a fraction of a nanosecond refills not quite one token
+	if allowed, _, wait, _ := store.Take("k", limit, start.Add(333333333*time.Nanosecond)); allowed {
+		t.Errorf("take of a not quite refilled token allowed, wait = %v", wait)
+	}
+}
+
+func TestBurstCapsBucket(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Requests: 60, Per: ratelimit.Duration(time.Minute), Burst: 3}
+	now := time.Now()
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if ok, _, _, _ := store.Take("k", limit, now); ok {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("allowed %d requests at once, want the burst of 3", allowed)
+	}
+}
+
+func TestLimiterMatchesFirstRule(t *testing.T) {
+	cfg := ratelimit.Config{Rules: []ratelimit.Rule{
+		{Name: "login", Path: "/", Methods: []string{"POST"}, By: ratelimit.ByIP, Limit: ratelimit.Limit{Requests: 1, Per: ratelimit.Duration(time.Hour)}},
+		{Name: "api", Path: "/api/", By: ratelimit.ByAPIKey, Limit: ratelimit.Limit{Requests: 1, Per: ratelimit.Duration(time.Hour)}},
+	}}
+	limiter := ratelimit.NewLimiter(cfg, ratelimit.NewMemoryStore())
+
+	if rule := limiter.Rule(httptest.NewRequest("GET", "/", nil)); rule != nil {
+		t.Errorf("GET / matched %q, want no rule", rule.Name)
+	}
+	if rule := limiter.Rule(httptest.NewRequest("GET", "/api/jobs/42", nil)); rule == nil || rule.Name != "api" {
+		t.Errorf("GET /api/jobs/42 matched %v, want api", rule)
+	}
+
+	req := httptest.NewRequest("POST", "/", nil)
+	if d, _ := limiter.Allow(req, "ip:1.2.3.4"); !d.Allowed {
+		t.Fatal("first login attempt denied")
+	}
+	d, _ := limiter.Allow(req, "ip:1.2.3.4")
+	if d.Allowed || d.RetryAfterSeconds() < 3599 {
+		t.Errorf("second attempt = %+v, want denied for about an hour", d)
+	}
+	if d, _ := limiter.Allow(req, "ip:5.6.7.8"); !d.Allowed {
+		t.Error("another client was denied")
+	}
+	if d, _ := limiter.Allow(httptest.NewRequest("GET", "/static/app.js", nil), "ip:1.2.3.4"); !d.Allowed || d.Rule != nil {
+		t.Errorf("unlimited route = %+v, want allowed without rule", d)
+	}
+}
+
+func TestExactRuleMatchesOnlyItsPath(t *testing.T) {
+	minute := ratelimit.Duration(time.Minute)
+	cfg := ratelimit.Config{Rules: []ratelimit.Rule{
+		{Name: "login-root", Path: "/", Exact: true, Methods: []string{"POST"}, By: ratelimit.ByIP, Limit: ratelimit.Limit{Requests: 5, Per: minute}},
+		{Name: "register", Path: "/register", Methods: []string{"POST"}, By: ratelimit.ByIP, Limit: ratelimit.Limit{Requests: 3, Per: ratelimit.Duration(time.Hour)}},
+		{Name: "api", Path: "/api/", By: ratelimit.ByAPIKey, Limit: ratelimit.Limit{Requests: 120, Per: minute}},
+	}}
+	limiter := ratelimit.NewLimiter(cfg, ratelimit.NewMemoryStore())
+	for path, want := range map[string]string{
+		"/":             "login-root",
+		"/register":     "register",
+		"/api/query":    "api",
+		"/api/jobs":     "api",
+		"/api/datasets": "api",
+	} {
+		if rule := limiter.Rule(httptest.NewRequest("POST", path, nil)); rule == nil || rule.Name != want {
+			t.Errorf("POST %s matched %v, want %s", path, rule, want)
+		}
+	}
+	if rule := limiter.Rule(httptest.NewRequest("POST", "/dashboard", nil)); rule != nil {
+		t.Errorf("POST /dashboard matched %q, want no rule", rule.Name)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.Config{}, ratelimit.NewMemoryStore())
+	for i := 0; i < 2; i++ {
+		if d, _ := limiter.Quota("customer:1", 2); !d.Allowed {
+			t.Fatalf("request %d denied within quota", i)
+		}
+	}
+	d, _ := limiter.Quota("customer:1", 2)
+	if d.Allowed || d.RetryAfter <= 0 || d.RetryAfter > 24*time.Hour {
+		t.Errorf("over quota = %+v, want denied until midnight", d)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.json")
+	os.WriteFile(good, []byte(`{"rules": [{"name": "query", "path": "/api/query", "by": "user", "requests": 10, "per": "1m", "burst": 2}]}`), 0644)
+	cfg, err := ratelimit.LoadConfig(good)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	rule := cfg.Rules[0]
+	if rule.By != ratelimit.ByUser || rule.Capacity() != 2 || time.Duration(rule.Per) != time.Minute {
+		t.Errorf("loaded rule = %+v", rule)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(bad, []byte(`{"rules": [{"name": "x", "path": "api", "by": "cookie", "requests": 0, "per": "1m"}, {"name": "x", "path": "/", "by": "ip", "requests": 1, "per": "1s"}]}`), 0644)
+	_, err = ratelimit.LoadConfig(bad)
+	if err == nil {
+		t.Fatal("LoadConfig() accepted an invalid config")
+	}
+	for _, want := range []string{"must start with /", "by must be", "must be positive", "duplicate rule name"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
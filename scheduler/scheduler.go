@@ -0,0 +1,280 @@
+package scheduler
+
+import (
+	"cmpscfa23team2/jobs"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schedule is a persisted recurring job definition. Every time CronExpr fires, a job of JobType
+// is enqueued with Params as its payload.
+type Schedule struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	CronExpr  string          `json:"cron"`
+	JobType   string          `json:"job_type"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Priority  int             `json:"priority"`
+	Enabled   bool            `json:"enabled"`
+	LastRun   time.Time       `json:"last_run"`
+	NextRun   time.Time       `json:"next_run"`
+	LastJobID string          `json:"last_job_id,omitempty"`
+}
+
+// ErrNotFound is returned by a Store when no schedule has the requested ID.
+var ErrNotFound = errors.New("scheduler: schedule not found")
+
+// ErrExists is returned by Add when a schedule with the requested ID is already stored.
+var ErrExists = errors.New("scheduler: schedule already exists")
+
+// Store persists schedule definitions together with their last and next run times. The context is the
+// one of the request that caused the call, or a background context when schedules fire.
+type Store interface {
+	List(ctx context.Context) ([]Schedule, error)
+	Save(ctx context.Context, s Schedule) error
+	Delete(ctx context.Context, id string) error
+}
+
+// JobRunner is the part of jobs.Manager the scheduler needs: checking job types and payloads,
+// enqueueing and checking on the previous run.
+type JobRunner interface {
+	Check(jobType string, payload json.RawMessage) error
+	Enqueue(ctx context.Context, jobType string, payload interface{}, priority int) (jobs.Job, error)
+	Get(ctx context.Context, id string) (jobs.Job, error)
+}
+
+// Options tunes a Scheduler.
+type Options struct {
+	// Interval is how often due schedules are checked. Defaults to 30 seconds.
+	Interval time.Duration
+	// SkipMissed disables catch-up: runs missed while the engine was down are dropped instead of fired once on start.
+	SkipMissed bool
+	// Now returns the current time; tests replace it. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Scheduler fires due schedules into the job system.
+type Scheduler struct {
+	store  Store
+	runner JobRunner
+	opts   Options
+
+	mu   sync.Mutex
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a Scheduler. Call Start to begin firing schedules.
+func New(store Store, runner JobRunner, opts Options) *Scheduler {
+	if opts.Interval <= 0 {
+		opts.Interval = 30 * time.Second
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Scheduler{store: store, runner: runner, opts: opts}
+}
+
+// Add validates and stores a new schedule, computing its first run time. The runner must accept the job
+// type and params, and a schedule given an ID must not replace a stored one.
+func (s *Scheduler) Add(ctx context.Context, sched Schedule) (Schedule, error) {
+	c, err := ParseCron(sched.CronExpr)
+	if err != nil {
+		return Schedule{}, err
+	}
+	if sched.JobType == "" {
+		return Schedule{}, errors.New("scheduler: schedule needs a job type")
+	}
+	if err := s.runner.Check(sched.JobType, sched.Params); err != nil {
+		return Schedule{}, err
+	}
+	if sched.ID == "" {
+		sched.ID = uuid.New().String()
+	}
+	sched.NextRun = c.Next(s.opts.Now())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list, err := s.store.List(ctx)
+	if err != nil {
+		return Schedule{}, err
+	}
+	for _, existing := range list {
+		if existing.ID == sched.ID {
+			return Schedule{}, fmt.Errorf("%w: %s", ErrExists, sched.ID)
+		}
+	}
+	if err := s.store.Save(ctx, sched); err != nil {
+		return Schedule{}, err
+	}
+	log.Printf("Schedule %s (%s) added, next run %s", sched.Name, sched.CronExpr, sched.NextRun.Format(time.RFC3339))
+	return sched, nil
+}
+
+// Remove deletes a schedule.
+func (s *Scheduler) Remove(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store.Delete(ctx, id)
+}
+
+// List returns all schedules ordered by their next run time.
+func (s *Scheduler) List(ctx context.Context) ([]Schedule, error) {
+	list, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].NextRun.Before(list[j].NextRun)
+	})
+	return list, nil
+}
+
+// Start catches up on runs missed while the engine was down and then checks for due schedules every Interval.
+func (s *Scheduler) Start() error {
+	s.mu.Lock()
+	if s.done != nil {
+		s.mu.Unlock()
+		return nil
+	}
+	done := make(chan struct{})
+	s.done = done
+	s.mu.Unlock()
+
+	if err := s.catchUp(); err != nil {
+		return err
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.RunDue(); err != nil {
+					log.Printf("Error running due schedules: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	log.Printf("Scheduler started, checking every %s", s.opts.Interval)
+	return nil
+}
+
+// Stop ends the check loop and waits for it to exit.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if s.done == nil {
+		s.mu.Unlock()
+		return
+	}
+	close(s.done)
+	s.done = nil
+	s.mu.Unlock()
+	s.wg.Wait()
+	log.Println("Scheduler stopped")
+}
+
+// catchUp handles schedules whose next run passed while the engine was not running. Missed runs
+// are coalesced into one immediate run unless SkipMissed is set.
+func (s *Scheduler) catchUp() error {
+	if !s.opts.SkipMissed {
+		return s.RunDue()
+	}
+	ctx := context.Background()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	now := s.opts.Now()
+	for _, sched := range list {
+		if !sched.Enabled || sched.NextRun.IsZero() || sched.NextRun.After(now) {
+			continue
+		}
+		c, err := ParseCron(sched.CronExpr)
+		if err != nil {
+			log.Printf("Skipping schedule %s: %v", sched.ID, err)
+			continue
+		}
+		log.Printf("Schedule %s missed its run at %s, skipping to the next one", sched.Name, sched.NextRun.Format(time.RFC3339))
+		sched.NextRun = c.Next(now)
+		if err := s.store.Save(ctx, sched); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunDue fires every enabled schedule whose next run time has passed. A schedule whose previous
+// job is still queued or running is not fired again; its next run is simply moved forward.
+func (s *Scheduler) RunDue() error {
+	ctx := context.Background()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	now := s.opts.Now()
+	var errs []error
+	for _, sched := range list {
+		if !sched.Enabled {
+			continue
+		}
+		c, err := ParseCron(sched.CronExpr)
+		if err != nil {
+			log.Printf("Skipping schedule %s: %v", sched.ID, err)
+			continue
+		}
+		if sched.NextRun.IsZero() {
+			sched.NextRun = c.Next(now)
+			errs = append(errs, s.store.Save(ctx, sched))
+			continue
+		}
+		if sched.NextRun.After(now) {
+			continue
+		}
+
+		if s.overlapping(ctx, sched) {
+			log.Printf("Schedule %s: previous job %s has not finished, skipping this run", sched.Name, sched.LastJobID)
+		} else {
+			job, err := s.runner.Enqueue(ctx, sched.JobType, sched.Params, sched.Priority)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("schedule %s: %v", sched.ID, err))
+			} else {
+				sched.LastJobID = job.ID
+				sched.LastRun = now
+				log.Printf("Schedule %s fired job %s (%s)", sched.Name, job.ID, sched.JobType)
+			}
+		}
+		sched.NextRun = c.Next(now)
+		errs = append(errs, s.store.Save(ctx, sched))
+	}
+	return errors.Join(errs...)
+}
+
+// overlapping reports whether the job started by the schedule's previous run is still pending.
+func (s *Scheduler) overlapping(ctx context.Context, sched Schedule) bool {
+	if sched.LastJobID == "" {
+		return false
+	}
+	job, err := s.runner.Get(ctx, sched.LastJobID)
+	if err != nil {
+		return false
+	}
+	return !job.Status.Done()
+}
@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore is a Store that keeps schedules in a map. It is used when no database is available and in tests.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	schedules map[string]Schedule
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{schedules: make(map[string]Schedule)}
+}
+
+// List returns every stored schedule.
+func (m *MemoryStore) List(ctx context.Context) ([]Schedule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]Schedule, 0, len(m.schedules))
+	for _, s := range m.schedules {
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// Save creates or replaces a schedule.
+func (m *MemoryStore) Save(ctx context.Context, s Schedule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.schedules[s.ID] = s
+	return nil
+}
+
+// Delete removes a schedule, returning ErrNotFound if it does not exist.
+func (m *MemoryStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.schedules[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.schedules, id)
+	return nil
+}
@@ -0,0 +1,177 @@
+package scheduler_test
+
+import (
+	"cmpscfa23team2/jobs"
+	"cmpscfa23team2/scheduler"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2024, time.January, 31, 10, 7, 30, 0, time.UTC) // a Wednesday
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, time.January, 31, 10, 15, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2024, time.February, 1, 3, 0, 0, 0, time.UTC)},
+		{"0 4 1 * *", time.Date(2024, time.February, 1, 4, 0, 0, 0, time.UTC)},
+		{"30 9 * * 1-5", time.Date(2024, time.February, 1, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, time.February, 4, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, time.January, 31, 11, 0, 0, 0, time.UTC)},
+		{"0 12 29 2 *", time.Date(2024, time.February, 29, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		c, err := scheduler.ParseCron(test.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) error = %v", test.expr, err)
+		}
+		if got := c.Next(base); !got.Equal(test.want) {
+			t.Errorf("Next(%q) = %s, want %s", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := scheduler.ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) expected an error", expr)
+		}
+	}
+}
+
+// fakeClock is a settable time source for the scheduler.
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+// newRunner returns a job manager with a "refresh" job type that blocks until release is closed.
+func newRunner(release chan struct{}) *jobs.Manager {
+	m := jobs.NewManager(jobs.NewMemoryStore(), jobs.Config{Concurrency: 1})
+	m.Register("refresh", func(ctx context.Context, job jobs.Job, progress jobs.ProgressFunc) error {
+		<-release
+		return nil
+	})
+	return m
+}
+
+func TestAddRejectsInvalidSchedules(t *testing.T) {
+	store := scheduler.NewMemoryStore()
+	runner := newRunner(make(chan struct{}))
+	runner.RegisterValidator("refresh", func(payload json.RawMessage) error {
+		var p struct{ Full bool }
+		if payload == nil {
+			return nil
+		}
+		return json.Unmarshal(payload, &p)
+	})
+	s := scheduler.New(store, runner, scheduler.Options{})
+
+	if _, err := s.Add(context.Background(), scheduler.Schedule{CronExpr: "0 3 * * *", JobType: "reindex"}); !errors.Is(err, jobs.ErrUnknownType) {
+		t.Errorf("Add of an unknown job type = %v, want ErrUnknownType", err)
+	}
+	bad := scheduler.Schedule{CronExpr: "0 3 * * *", JobType: "refresh", Params: json.RawMessage(`{"full": "yes"}`)}
+	if _, err := s.Add(context.Background(), bad); !errors.Is(err, jobs.ErrInvalidPayload) {
+		t.Errorf("Add with invalid params = %v, want ErrInvalidPayload", err)
+	}
+	if _, err := s.Add(context.Background(), scheduler.Schedule{ID: "nightly", Name: "first", CronExpr: "0 3 * * *", JobType: "refresh"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add(context.Background(), scheduler.Schedule{ID: "nightly", Name: "second", CronExpr: "0 4 * * *", JobType: "refresh"}); !errors.Is(err, scheduler.ErrExists) {
+		t.Errorf("Add of an existing ID = %v, want ErrExists", err)
+	}
+	if list, _ := store.List(context.Background()); len(list) != 1 || list[0].Name != "first" {
+		t.Errorf("stored schedules = %+v, want only the first", list)
+	}
+}
+
+func TestRunDueFiresAndPreventsOverlap(t *testing.T) {
+	release := make(chan struct{})
+	runner := newRunner(release)
+	clock := &fakeClock{now: time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)}
+	store := scheduler.NewMemoryStore()
+	s := scheduler.New(store, runner, scheduler.Options{Now: clock.Now})
+
+	added, err := s.Add(context.Background(), scheduler.Schedule{Name: "gas", CronExpr: "*/5 * * * *", JobType: "refresh", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := clock.now.Add(5 * time.Minute); !added.NextRun.Equal(want) {
+		t.Fatalf("NextRun = %s, want %s", added.NextRun, want)
+	}
+
+	clock.now = clock.now.Add(5 * time.Minute)
+	if err := s.RunDue(); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := s.List(context.Background())
+	first := list[0].LastJobID
+	if first == "" || !list[0].LastRun.Equal(clock.now) {
+		t.Fatalf("schedule did not fire: %+v", list[0])
+	}
+
+	// The first job was never started, so the next tick must not enqueue a second one.
+	clock.now = clock.now.Add(5 * time.Minute)
+	if err := s.RunDue(); err != nil {
+		t.Fatal(err)
+	}
+	list, _ = s.List(context.Background())
+	if list[0].LastJobID != first {
+		t.Errorf("overlapping run enqueued job %s", list[0].LastJobID)
+	}
+	if want := clock.now.Add(5 * time.Minute); !list[0].NextRun.Equal(want) {
+		t.Errorf("NextRun = %s, want %s", list[0].NextRun, want)
+	}
+	close(release)
+}
+
+func TestCatchUpFiresMissedRunOnce(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	runner := newRunner(release)
+	clock := &fakeClock{now: time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)}
+	store := scheduler.NewMemoryStore()
+	// Last planned run was three days ago: the engine was down for several daily runs.
+	store.Save(context.Background(), scheduler.Schedule{ID: "daily", Name: "daily", CronExpr: "0 3 * * *", JobType: "refresh", Enabled: true,
+		NextRun: time.Date(2024, time.March, 7, 3, 0, 0, 0, time.UTC)})
+
+	s := scheduler.New(store, runner, scheduler.Options{Now: clock.Now, Interval: time.Hour})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	list, _ := store.List(context.Background())
+	if list[0].LastJobID == "" {
+		t.Fatal("missed run was not caught up")
+	}
+	jobsRun, _ := runner.List(context.Background(), "")
+	if len(jobsRun) != 1 {
+		t.Errorf("caught up with %d jobs, want 1", len(jobsRun))
+	}
+	if want := time.Date(2024, time.March, 11, 3, 0, 0, 0, time.UTC); !list[0].NextRun.Equal(want) {
+		t.Errorf("NextRun = %s, want %s", list[0].NextRun, want)
+	}
+}
+
+func TestSkipMissed(t *testing.T) {
+	runner := newRunner(make(chan struct{}))
+	clock := &fakeClock{now: time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)}
+	store := scheduler.NewMemoryStore()
+	store.Save(context.Background(), scheduler.Schedule{ID: "daily", CronExpr: "0 3 * * *", JobType: "refresh", Enabled: true,
+		NextRun: time.Date(2024, time.March, 7, 3, 0, 0, 0, time.UTC)})
+
+	s := scheduler.New(store, runner, scheduler.Options{Now: clock.Now, Interval: time.Hour, SkipMissed: true})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	list, _ := store.List(context.Background())
+	if list[0].LastJobID != "" {
+		t.Error("missed run fired although SkipMissed is set")
+	}
+}